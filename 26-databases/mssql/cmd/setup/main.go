@@ -1,31 +1,26 @@
 package main
 
 import (
+	"context"
 	"log"
+	"time"
 
 	"learn-go-with-cyber-mountain-man/26-databases/mssql/internal/db"
 )
 
 func main() {
-	conn := db.Connect()
-	defer conn.Close()
+	store, err := db.Connect()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer store.Close()
 
-	createTableQuery := `
-	IF NOT EXISTS (
-		SELECT * FROM sysobjects WHERE name='users' AND xtype='U'
-	)
-	BEGIN
-		CREATE TABLE users (
-			id INT IDENTITY(1,1) PRIMARY KEY,
-			name NVARCHAR(100) NOT NULL,
-			email NVARCHAR(100) NOT NULL UNIQUE,
-			created_at DATETIME2 DEFAULT SYSDATETIME()
-		)
-	END
-	`
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
 
-	_, err := conn.Exec(createTableQuery)
-	if err != nil {
+	// The mssql dialect creates the table only if dbo.users does not exist:
+	// id INT IDENTITY, name/email NVARCHAR(100), created_at DATETIME2 DEFAULT SYSDATETIME()
+	if err := store.Migrate(ctx); err != nil {
 		log.Fatalf("Failed to create users table: %v", err)
 	}
 
@@ -3,13 +3,16 @@ module learn-go-with-cyber-mountain-man/26-databases/mssql
 go 1.24.0
 
 require (
-	github.com/denisenkom/go-mssqldb v0.12.3
 	github.com/go-chi/chi/v5 v5.2.1
 	github.com/joho/godotenv v1.5.1
+	learn-go-with-cyber-mountain-man/26-databases/shared v0.0.0
 )
 
 require (
+	github.com/denisenkom/go-mssqldb v0.12.3 // indirect
 	github.com/golang-sql/civil v0.0.0-20190719163853-cb61b32ac6fe // indirect
 	github.com/golang-sql/sqlexp v0.1.0 // indirect
 	golang.org/x/crypto v0.0.0-20220622213112-05595931fe9d // indirect
 )
+
+replace learn-go-with-cyber-mountain-man/26-databases/shared => ../shared
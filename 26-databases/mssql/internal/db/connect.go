@@ -1,43 +1,55 @@
 package db
 
 import (
-	"database/sql"
-	"fmt"
+	"context"
+	"errors"
 	"log"
+	"net"
+	"net/url"
 	"os"
 
 	"github.com/joho/godotenv"
-	_ "github.com/denisenkom/go-mssqldb"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/dbconn"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/users"
+	_ "learn-go-with-cyber-mountain-man/26-databases/shared/users/mssql" // Registers sqlserver:// DSNs (and the go-mssqldb driver)
 )
 
-func Connect() *sql.DB {
+// Connect opens the users store for SQL Server. DATABASE_URL wins if set;
+// otherwise the DSN is built from the DB* environment variables. SQL Server
+// containers take a while to accept logins, so the first ping is retried
+// (DB_STARTUP_TIMEOUT); pool limits come from dbconn.FromEnv.
+func Connect() (*users.Store, error) {
 	// Load .env if available
 	err := godotenv.Load()
 	if err != nil {
 		log.Println("Warning: .env file not found, falling back to environment variables")
 	}
 
-	user := os.Getenv("DBUSER")
-	password := os.Getenv("DBPASSWORD")
-	server := os.Getenv("DBHOST")
-	port := os.Getenv("DBPORT")
-	database := os.Getenv("DBNAME")
-
-	if user == "" || password == "" || server == "" || port == "" || database == "" {
-		log.Fatal("Missing one or more required MSSQL environment variables")
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		user := os.Getenv("DBUSER")
+		password := os.Getenv("DBPASSWORD")
+		server := os.Getenv("DBHOST")
+		port := os.Getenv("DBPORT")
+		database := os.Getenv("DBNAME")
+
+		if user == "" || password == "" || server == "" || port == "" || database == "" {
+			return nil, errors.New("missing one or more required MSSQL environment variables (DBUSER, DBPASSWORD, DBHOST, DBPORT, DBNAME)")
+		}
+
+		// Format MSSQL connection string as a URL; the scheme selects the dialect
+		u := url.URL{
+			Scheme:   "sqlserver",
+			User:     url.UserPassword(user, password),
+			Host:     net.JoinHostPort(server, port),
+			RawQuery: url.Values{"database": {database}}.Encode(),
+		}
+		dsn = u.String()
 	}
 
-	// Format MSSQL connection string
-	connStr := fmt.Sprintf("sqlserver://%s:%s@%s:%s?database=%s", user, password, server, port, database)
-
-	db, err := sql.Open("sqlserver", connStr)
+	cfg, err := dbconn.FromEnv()
 	if err != nil {
-		log.Fatalf("Failed to open MSSQL connection: %v", err)
+		return nil, err
 	}
-
-	if err = db.Ping(); err != nil {
-		log.Fatalf("Failed to connect to MSSQL: %v", err)
-	}
-
-	return db
+	return dbconn.Connect(context.Background(), dsn, cfg)
 }
@@ -1,42 +1,56 @@
 package handlers
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/go-chi/chi/v5"
-	"learn-go-with-cyber-mountain-man/26-databases/mssql/internal/models"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/dbctx"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/etag"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/users"
 )
 
 // ListUsers handles GET /users and returns all users
-func ListUsers(db *sql.DB) http.HandlerFunc {
+func ListUsers(store *users.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		users, err := models.GetAllUsers(db)
+		page, err := users.ParsePage(r.URL.Query()) // optional ?limit=&offset=
 		if err != nil {
-			http.Error(w, "Failed to retrieve users", http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		json.NewEncoder(w).Encode(users)
+
+		ctx, cancel := dbctx.WithTimeout(r)
+		defer cancel()
+
+		list, err := store.List(ctx, page)
+		if err != nil {
+			dbctx.Error(w, ctx, err, "Failed to retrieve users")
+			return
+		}
+		json.NewEncoder(w).Encode(list)
 	}
 }
 
 // CreateUser handles POST /users and inserts a new user
-func CreateUser(db *sql.DB) http.HandlerFunc {
+func CreateUser(store *users.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var u models.User
+		var u users.User
 		if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
 			http.Error(w, "Invalid input", http.StatusBadRequest)
 			return
 		}
 
-		id, err := models.InsertUser(db, u)
-		if err != nil {
-			http.Error(w, "Failed to insert user", http.StatusInternalServerError)
+		ctx, cancel := dbctx.WithTimeout(r)
+		defer cancel()
+
+		// Create fills in u.ID and u.CreatedAt from the database
+		if err := store.Create(ctx, &u); err != nil {
+			dbctx.Error(w, ctx, err, "Failed to insert user")
 			return
 		}
-		u.ID = id
 
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(u)
@@ -44,7 +58,7 @@ func CreateUser(db *sql.DB) http.HandlerFunc {
 }
 
 // GetUser handles GET /users/{id} and returns a user by ID
-func GetUser(db *sql.DB) http.HandlerFunc {
+func GetUser(store *users.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		idParam := chi.URLParam(r, "id")
 		id, err := strconv.Atoi(idParam)
@@ -53,22 +67,26 @@ func GetUser(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		u, err := models.GetUserByID(db, id)
+		ctx, cancel := dbctx.WithTimeout(r)
+		defer cancel()
+
+		u, err := store.Get(ctx, id)
 		if err != nil {
-			if err == sql.ErrNoRows {
+			if errors.Is(err, users.ErrNotFound) {
 				http.Error(w, "User not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "Failed to retrieve user", http.StatusInternalServerError)
+			dbctx.Error(w, ctx, err, "Failed to retrieve user")
 			return
 		}
 
+		etag.Set(w, u.Version) // clients send this back in If-Match
 		json.NewEncoder(w).Encode(u)
 	}
 }
 
 // UpdateUser handles PUT /users/{id} to update an existing user
-func UpdateUser(db *sql.DB) http.HandlerFunc {
+func UpdateUser(store *users.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		idParam := chi.URLParam(r, "id")
 		id, err := strconv.Atoi(idParam)
@@ -77,24 +95,44 @@ func UpdateUser(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		var u models.User
+		var u users.User
 		if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
 			http.Error(w, "Invalid input", http.StatusBadRequest)
 			return
 		}
 		u.ID = id
 
-		if err := models.UpdateUser(db, u); err != nil {
-			http.Error(w, "Failed to update user", http.StatusInternalServerError)
+		// The version the client read; 428 / 412 are written for us on failure
+		version, ok := etag.IfMatch(w, r)
+		if !ok {
 			return
 		}
+		u.Version = version
 
-		json.NewEncoder(w).Encode(u)
+		ctx, cancel := dbctx.WithTimeout(r)
+		defer cancel()
+
+		updated, err := store.Update(ctx, u)
+		if err != nil {
+			if errors.Is(err, users.ErrNotFound) {
+				http.Error(w, "User not found", http.StatusNotFound)
+				return
+			}
+			if errors.Is(err, users.ErrVersionMismatch) {
+				stale(w, ctx, store, id)
+				return
+			}
+			dbctx.Error(w, ctx, err, "Failed to update user")
+			return
+		}
+
+		etag.Set(w, updated.Version)
+		json.NewEncoder(w).Encode(updated)
 	}
 }
 
-// DeleteUser handles DELETE /users/{id}
-func DeleteUser(db *sql.DB) http.HandlerFunc {
+// DeleteUser handles DELETE /users/{id} (soft delete: sets deleted_at)
+func DeleteUser(store *users.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		idParam := chi.URLParam(r, "id")
 		id, err := strconv.Atoi(idParam)
@@ -103,11 +141,70 @@ func DeleteUser(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		if err := models.DeleteUser(db, id); err != nil {
-			http.Error(w, "Failed to delete user", http.StatusInternalServerError)
+		version, ok := etag.IfMatch(w, r)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := dbctx.WithTimeout(r)
+		defer cancel()
+
+		if err := store.Delete(ctx, id, version); err != nil {
+			if errors.Is(err, users.ErrNotFound) {
+				http.Error(w, "User not found", http.StatusNotFound)
+				return
+			}
+			if errors.Is(err, users.ErrVersionMismatch) {
+				stale(w, ctx, store, id)
+				return
+			}
+			dbctx.Error(w, ctx, err, "Failed to delete user")
 			return
 		}
 
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
+
+// RestoreUser handles POST /users/{id}/restore
+func RestoreUser(store *users.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idParam := chi.URLParam(r, "id")
+		id, err := strconv.Atoi(idParam)
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := dbctx.WithTimeout(r)
+		defer cancel()
+
+		if err := store.Restore(ctx, id); err != nil {
+			if errors.Is(err, users.ErrNotFound) {
+				http.Error(w, "No deleted user with this ID", http.StatusNotFound)
+				return
+			}
+			dbctx.Error(w, ctx, err, "Failed to restore user")
+			return
+		}
+
+		u, err := store.Get(ctx, id)
+		if err != nil {
+			dbctx.Error(w, ctx, err, "Failed to fetch restored user")
+			return
+		}
+		etag.Set(w, u.Version)
+		json.NewEncoder(w).Encode(u)
+	}
+}
+
+// stale answers a write with an outdated If-Match: 412 plus the user's
+// current state and ETag, so the client can merge and try again.
+func stale(w http.ResponseWriter, ctx context.Context, store *users.Store, id int) {
+	current, err := store.Get(ctx, id)
+	if err != nil {
+		http.Error(w, "User was changed or deleted by someone else", http.StatusPreconditionFailed)
+		return
+	}
+	etag.PreconditionFailed(w, current.Version, current)
+}
@@ -7,23 +7,54 @@ import (
 	"github.com/go-chi/chi/v5"
 	"learn-go-with-cyber-mountain-man/26-databases/mssql/internal/db"
 	"learn-go-with-cyber-mountain-man/26-databases/mssql/internal/handlers"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/apikey"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/cors"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/dbconn"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/users/audithttp"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/users/bulkhttp"
 )
 
 func main() {
-	// Establish DB connection using MSSQL driver
-	conn := db.Connect()
-	defer conn.Close()
+	// Establish DB connection using MSSQL driver (retried while SQL Server starts)
+	store, err := db.Connect()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer store.Close()
+
+	// Named API keys from API_KEYS; the key name is recorded as the audit actor
+	keys, err := apikey.FromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Initialize Chi router
 	r := chi.NewRouter()
 
+	// Readiness probe: 503 while the database is unreachable
+	r.Method(http.MethodGet, "/ready", &dbconn.Checker{DB: store.DB()})
+
 	// Register user-related routes
 	r.Route("/users", func(r chi.Router) {
-		r.Get("/", handlers.ListUsers(conn))     // GET all users
-		r.Post("/", handlers.CreateUser(conn))   // POST new user
-		r.Get("/{id}", handlers.GetUser(conn))   // GET single user
-		r.Put("/{id}", handlers.UpdateUser(conn)) // PUT update user
-		r.Delete("/{id}", handlers.DeleteUser(conn)) // DELETE user
+		r.Use(cors.Handler(cors.FromEnv("USERS"))) // CORS + preflight handling
+		r.Use(keys.Middleware, audithttp.Middleware) // API key + audit actor / request ID
+
+		r.Get("/", handlers.ListUsers(store))     // GET all users
+		r.Post("/", handlers.CreateUser(store))   // POST new user
+		r.Get("/{id}", handlers.GetUser(store))   // GET single user
+		r.Put("/{id}", handlers.UpdateUser(store)) // PUT update user
+		r.Delete("/{id}", handlers.DeleteUser(store)) // DELETE user (soft delete)
+		r.Post("/{id}/restore", handlers.RestoreUser(store)) // POST undo delete
+
+		r.Post("/import", bulkhttp.Import(store)) // POST CSV / NDJSON bulk import
+		r.Get("/export", bulkhttp.Export(store))  // GET streaming export
+	})
+
+	// Admin routes: trash listing and per-user audit history
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(keys.Middleware, apikey.RequireAdmin, audithttp.Middleware)
+		r.Get("/users/deleted", audithttp.Deleted(store))
+		r.Get("/users/{id}/audit", audithttp.History(store))
 	})
 
 	// Start the HTTP server
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"log"
+	"time"
 
 	// Custom database package from internal directory
 	"learn-go-with-cyber-mountain-man/26-databases/postgres/internal/db"
@@ -9,22 +11,21 @@ import (
 
 func main() {
 	// Establish a connection to the PostgreSQL database using shared Connect function
-	conn := db.Connect()
+	store, err := db.Connect()
+	if err != nil {
+		log.Fatal(err)
+	}
 	// Ensure the database connection is closed when this function completes
-	defer conn.Close()
+	defer store.Close()
 
-	// SQL statement to create the 'users' table if it doesn't already exist
-	createTableQuery := `
-	CREATE TABLE IF NOT EXISTS users (
-		id SERIAL PRIMARY KEY,             -- Auto-incrementing unique ID
-		name TEXT NOT NULL,                -- User's name (required)
-		email TEXT NOT NULL UNIQUE,        -- Email must be unique (required)
-		created_at TIMESTAMPTZ DEFAULT NOW() -- Timestamp of user creation (defaults to current time)
-	);`
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
 
-	// Execute the SQL statement to create the table
-	_, err := conn.Exec(createTableQuery)
-	if err != nil {
+	// The postgres dialect owns the schema:
+	//   id SERIAL PRIMARY KEY, name TEXT NOT NULL, email TEXT NOT NULL UNIQUE,
+	//   created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	// It uses CREATE TABLE IF NOT EXISTS, so running setup twice is safe.
+	if err := store.Migrate(ctx); err != nil {
 		// Log and terminate if table creation fails
 		log.Fatalf("Error creating users table: %v", err)
 	}
@@ -50,7 +51,7 @@ func main() {
 | Concept                         | Explanation |
 |----------------------------------|-------------|
 | `cmd/setup` pattern              | Convention for small, self-contained CLI apps in Go projects |
-| `Exec()` with raw SQL            | Executes statements that don't return rows (e.g., CREATE TABLE) |
+| `store.Migrate(ctx)`             | Runs the dialect's CREATE TABLE (shared with the conformance suite) |
 | `CREATE TABLE IF NOT EXISTS`     | Safe schema creation without overwriting existing data |
 | Logging to the console           | Useful for CI/CD, cloud init logs, and troubleshooting |
 
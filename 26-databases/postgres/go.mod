@@ -5,5 +5,9 @@ go 1.24.0
 require (
 	github.com/go-chi/chi/v5 v5.2.1
 	github.com/joho/godotenv v1.5.1
-	github.com/lib/pq v1.10.9
+	learn-go-with-cyber-mountain-man/26-databases/shared v0.0.0
 )
+
+require github.com/lib/pq v1.10.9 // indirect
+
+replace learn-go-with-cyber-mountain-man/26-databases/shared => ../shared
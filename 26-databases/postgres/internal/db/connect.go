@@ -1,65 +1,84 @@
 package db
 
 import (
-	"database/sql"
-	"fmt"
+	"context"
+	"errors"
 	"log"
+	"net"
+	"net/url"
 	"os"
 
 	"github.com/joho/godotenv" // Loads environment variables from .env file
-	_ "github.com/lib/pq"      // PostgreSQL driver — blank import to register with database/sql
+	"learn-go-with-cyber-mountain-man/26-databases/shared/dbconn"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/users"
+	_ "learn-go-with-cyber-mountain-man/26-databases/shared/users/postgres" // Registers postgres:// DSNs (and the lib/pq driver)
 )
 
-// Connect initializes and returns a PostgreSQL database connection using environment variables.
-func Connect() *sql.DB {
+// Connect opens the users store for PostgreSQL. DATABASE_URL wins if set;
+// otherwise the DSN is built from the PG* environment variables. It keeps
+// retrying while the server starts up (DB_STARTUP_TIMEOUT) and sizes the
+// pool from the DB_* variables read by dbconn.FromEnv.
+func Connect() (*users.Store, error) {
 	// Attempt to load variables from .env file (optional, useful for local dev)
 	err := godotenv.Load()
 	if err != nil {
 		log.Println("Warning: .env file not found, relying on environment variables")
 	}
 
-	// Fetch PostgreSQL connection parameters from environment
-	user := os.Getenv("PGUSER")
-	password := os.Getenv("PGPASSWORD")
-	dbname := os.Getenv("PGDATABASE")
-	host := os.Getenv("PGHOST")
-	port := os.Getenv("PGPORT")
-	sslmode := os.Getenv("PGSSLMODE")
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		// Fetch PostgreSQL connection parameters from environment
+		user := os.Getenv("PGUSER")
+		password := os.Getenv("PGPASSWORD")
+		dbname := os.Getenv("PGDATABASE")
+		host := os.Getenv("PGHOST")
+		port := os.Getenv("PGPORT")
+		sslmode := os.Getenv("PGSSLMODE")
 
-	// Validate presence of all required environment variables
-	if user == "" || password == "" || dbname == "" || host == "" || port == "" {
-		log.Fatal("Missing required PostgreSQL environment variables")
-	}
+		// Validate presence of all required environment variables
+		if user == "" || password == "" || dbname == "" || host == "" || port == "" {
+			return nil, errors.New("missing required PostgreSQL environment variables (PGUSER, PGPASSWORD, PGDATABASE, PGHOST, PGPORT)")
+		}
 
-	// Format the DSN (Data Source Name) string required by lib/pq
-	dsn := fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		host, port, user, password, dbname, sslmode,
-	)
+		// Format the DSN as a URL; its scheme selects the postgres dialect
+		u := url.URL{
+			Scheme: "postgres",
+			User:   url.UserPassword(user, password), // escapes special characters in the password
+			Host:   net.JoinHostPort(host, port),
+			Path:   "/" + dbname,
+		}
+		if sslmode != "" {
+			u.RawQuery = url.Values{"sslmode": {sslmode}}.Encode()
+		}
+		dsn = u.String()
+	}
 
-	// Open the database connection (driver name must match the imported driver)
-	db, err := sql.Open("postgres", dsn)
+	cfg, err := dbconn.FromEnv()
 	if err != nil {
-		log.Fatalf("Failed to open DB: %v", err)
+		return nil, err
 	}
 
-	// Ping the database to ensure the connection is valid
-	if err = db.Ping(); err != nil {
-		log.Fatalf("Failed to ping DB: %v", err)
+	// Open the pool and ping it until PostgreSQL answers; the dialect is
+	// chosen from the DSN scheme
+	store, err := dbconn.Connect(context.Background(), dsn, cfg)
+	if err != nil {
+		return nil, err
 	}
 
-	// Return a pointer to the initialized sql.DB connection pool
-	return db
+	// Return the store; it owns the sql.DB connection pool
+	return store, nil
 }
 
 /*
 🧠 DATABASE CONNECTION — ENVIRONMENT-BASED CONFIG (db/connect.go)
 
 ✅ What Happens Here:
-- This file defines the `Connect()` function, which initializes a PostgreSQL connection using environment variables.
+- This file defines the `Connect()` function, which opens the shared `users.Store` for PostgreSQL using environment variables.
+- The PG* variables are assembled into a `postgres://` URL (or `DATABASE_URL` is used as-is); the URL scheme selects the dialect.
 - It loads variables from a `.env` file using `godotenv`, making it easier to manage secrets and settings locally.
 - If `.env` isn't found, it safely falls back to system environment variables — great for production containers.
-- It checks for required variables and fails fast if anything is missing.
+- It checks for required variables and returns an error if anything is missing.
+- `dbconn.Connect` retries the first ping with backoff and jitter (PostgreSQL in Docker takes a few seconds to accept connections) and applies the pool limits.
 
 ✅ Why This Matters:
 - Environment-based configuration is a **12-Factor App** principle and essential for writing scalable, deployable systems.
@@ -72,8 +91,8 @@ func Connect() *sql.DB {
 | `godotenv.Load()`         | Loads key-value pairs from a `.env` file into `os.Getenv()` |
 | `sql.Open`                | Initializes a database pool (lazy connection) |
 | `db.Ping()`               | Verifies the connection is actually live |
-| `log.Fatal()`             | Exits immediately if required config is missing |
-| Blank import `_ "lib/pq"` | Registers the PostgreSQL driver with `database/sql` |
+| `dbconn.Connect`          | Retries the ping until `DB_STARTUP_TIMEOUT`, returning an error instead of exiting |
+| Blank import `_ ".../users/postgres"` | Registers the postgres dialect and, through it, the `lib/pq` driver |
 
 🔔 Bonus Tip:
 - This setup makes it easy to deploy in Docker, Kubernetes, or CI/CD by swapping `.env` files with environment variable injection.
@@ -81,6 +100,5 @@ func Connect() *sql.DB {
 
 📚 Up Next:
 - Add query timeouts using `context.Context`
-- Extract DB health checks into a `/health` endpoint
-- Add retry logic for connection failures during startup
+- Point the load balancer at `GET /ready`
 */
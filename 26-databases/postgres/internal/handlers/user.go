@@ -2,42 +2,56 @@ package handlers
 
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/go-chi/chi/v5"
-	"learn-go-with-cyber-mountain-man/26-databases/postgres/internal/models"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/dbctx"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/etag"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/users"
 )
 
 // ListUsers returns all users
-func ListUsers(db *sql.DB) http.HandlerFunc {
+func ListUsers(store *users.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		users, err := models.GetAllUsers(db)
+		page, err := users.ParsePage(r.URL.Query()) // optional ?limit=&offset=
 		if err != nil {
-			http.Error(w, "Error fetching users", http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		json.NewEncoder(w).Encode(users)
+
+		ctx, cancel := dbctx.WithTimeout(r)
+		defer cancel()
+
+		list, err := store.List(ctx, page)
+		if err != nil {
+			dbctx.Error(w, ctx, err, "Error fetching users")
+			return
+		}
+		json.NewEncoder(w).Encode(list)
 	}
 }
 
 // CreateUser adds a new user
-func CreateUser(db *sql.DB) http.HandlerFunc {
+func CreateUser(store *users.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var u models.User
+		var u users.User
 		if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
 			http.Error(w, "Invalid input", http.StatusBadRequest)
 			return
 		}
 
-		id, err := models.InsertUser(db, u)
-		if err != nil {
-			http.Error(w, "Failed to insert user", http.StatusInternalServerError)
+		ctx, cancel := dbctx.WithTimeout(r)
+		defer cancel()
+
+		// Create fills in u.ID and u.CreatedAt from the database
+		if err := store.Create(ctx, &u); err != nil {
+			dbctx.Error(w, ctx, err, "Failed to insert user")
 			return
 		}
-		u.ID = id
 
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(u)
@@ -45,7 +59,7 @@ func CreateUser(db *sql.DB) http.HandlerFunc {
 }
 
 // GetUser returns a user by ID
-func GetUser(db *sql.DB) http.HandlerFunc {
+func GetUser(store *users.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		idParam := chi.URLParam(r, "id")
 		id, err := strconv.Atoi(idParam)
@@ -54,22 +68,26 @@ func GetUser(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		u, err := models.GetUserByID(db, id)
+		ctx, cancel := dbctx.WithTimeout(r)
+		defer cancel()
+
+		u, err := store.Get(ctx, id)
 		if err != nil {
-			if err == sql.ErrNoRows {
+			if errors.Is(err, users.ErrNotFound) {
 				http.Error(w, "User not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "Error retrieving user", http.StatusInternalServerError)
+			dbctx.Error(w, ctx, err, "Error retrieving user")
 			return
 		}
 
+		etag.Set(w, u.Version) // clients send this back in If-Match
 		json.NewEncoder(w).Encode(u)
 	}
 }
 
 // UpdateUser modifies an existing user
-func UpdateUser(db *sql.DB) http.HandlerFunc {
+func UpdateUser(store *users.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		idParam := chi.URLParam(r, "id")
 		id, err := strconv.Atoi(idParam)
@@ -78,25 +96,45 @@ func UpdateUser(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		var u models.User
+		var u users.User
 		if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
 			http.Error(w, "Invalid input", http.StatusBadRequest)
 			return
 		}
 		u.ID = id
 
-		if err := models.UpdateUser(db, u); err != nil {
-			http.Error(w, "Failed to update user", http.StatusInternalServerError)
+		// The version the client read; 428 / 412 are written for us on failure
+		version, ok := etag.IfMatch(w, r)
+		if !ok {
 			return
 		}
+		u.Version = version
 
+		ctx, cancel := dbctx.WithTimeout(r)
+		defer cancel()
+
+		updated, err := store.Update(ctx, u)
+		if err != nil {
+			if errors.Is(err, users.ErrNotFound) {
+				http.Error(w, "User not found", http.StatusNotFound)
+				return
+			}
+			if errors.Is(err, users.ErrVersionMismatch) {
+				stale(w, ctx, store, id)
+				return
+			}
+			dbctx.Error(w, ctx, err, "Failed to update user")
+			return
+		}
+
+		etag.Set(w, updated.Version)
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(u)
+		json.NewEncoder(w).Encode(updated)
 	}
 }
 
-// DeleteUser removes a user by ID
-func DeleteUser(db *sql.DB) http.HandlerFunc {
+// DeleteUser moves a user to the trash (soft delete)
+func DeleteUser(store *users.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		idParam := chi.URLParam(r, "id")
 		id, err := strconv.Atoi(idParam)
@@ -105,8 +143,24 @@ func DeleteUser(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		if err := models.DeleteUser(db, id); err != nil {
-			http.Error(w, "Failed to delete user", http.StatusInternalServerError)
+		version, ok := etag.IfMatch(w, r)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := dbctx.WithTimeout(r)
+		defer cancel()
+
+		if err := store.Delete(ctx, id, version); err != nil {
+			if errors.Is(err, users.ErrNotFound) {
+				http.Error(w, "User not found", http.StatusNotFound)
+				return
+			}
+			if errors.Is(err, users.ErrVersionMismatch) {
+				stale(w, ctx, store, id)
+				return
+			}
+			dbctx.Error(w, ctx, err, "Failed to delete user")
 			return
 		}
 
@@ -114,12 +168,55 @@ func DeleteUser(db *sql.DB) http.HandlerFunc {
 	}
 }
 
+// RestoreUser takes a user out of the trash
+func RestoreUser(store *users.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idParam := chi.URLParam(r, "id")
+		id, err := strconv.Atoi(idParam)
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := dbctx.WithTimeout(r)
+		defer cancel()
+
+		if err := store.Restore(ctx, id); err != nil {
+			if errors.Is(err, users.ErrNotFound) {
+				http.Error(w, "No deleted user with this ID", http.StatusNotFound)
+				return
+			}
+			dbctx.Error(w, ctx, err, "Failed to restore user")
+			return
+		}
+
+		u, err := store.Get(ctx, id)
+		if err != nil {
+			dbctx.Error(w, ctx, err, "Failed to fetch restored user")
+			return
+		}
+		etag.Set(w, u.Version)
+		json.NewEncoder(w).Encode(u)
+	}
+}
+
+// stale answers a write with an outdated If-Match: 412 plus the user's
+// current state and ETag, so the client can merge and try again.
+func stale(w http.ResponseWriter, ctx context.Context, store *users.Store, id int) {
+	current, err := store.Get(ctx, id)
+	if err != nil {
+		http.Error(w, "User was changed or deleted by someone else", http.StatusPreconditionFailed)
+		return
+	}
+	etag.PreconditionFailed(w, current.Version, current)
+}
+
 /*
 🧠 GO + POSTGRESQL CRUD — ROUTE HANDLERS WALKTHROUGH (handlers/user.go)
 
 ✅ What Happens Here:
 - We define HTTP handlers for all CRUD operations on the `/users` endpoint.
-- These handlers delegate data operations to the `models` package and focus purely on request/response logic.
+- These handlers delegate data operations to the shared `users.Store` (see `shared/users`) and focus purely on request/response logic.
 - Input from the client is decoded from JSON, and responses are returned as JSON using `encoding/json`.
 - Route parameters (like `/users/{id}`) are extracted using `chi.URLParam`.
 
@@ -10,29 +10,69 @@ import (
 	// Internal packages for DB connection and route handlers
 	"learn-go-with-cyber-mountain-man/26-databases/postgres/internal/db"
 	"learn-go-with-cyber-mountain-man/26-databases/postgres/internal/handlers"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/apikey"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/cors"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/dbconn"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/users/audithttp"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/users/bulkhttp"
 )
 
 func main() {
 	// Establish database connection using the custom db package
-	conn := db.Connect()
+	// (retries until DB_STARTUP_TIMEOUT while PostgreSQL starts up)
+	store, err := db.Connect()
+	if err != nil {
+		log.Fatal(err)
+	}
 	// Ensure the DB connection is gracefully closed when main() exits
-	defer conn.Close()
+	defer store.Close()
+
+	// API_KEYS="name:secret[:admin],..." says who is calling; the name is the audit actor
+	keys, err := apikey.FromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Create a new HTTP router using chi
 	r := chi.NewRouter()
 
+	// GET /ready → 200 when the database answers, 503 while it is unreachable
+	r.Method(http.MethodGet, "/ready", &dbconn.Checker{DB: store.DB()})
+
 	// Define a group of routes under the /users path
 	r.Route("/users", func(r chi.Router) {
+		// Allow browser apps on other origins (configured via CORS_USERS_* / CORS_* env vars)
+		r.Use(cors.Handler(cors.FromEnv("USERS")))
+		// Require an API key (when configured) and stamp actor + request ID for the audit log
+		r.Use(keys.Middleware, audithttp.Middleware)
+
 		// GET /users        → List all users
-		r.Get("/", handlers.ListUsers(conn))
+		r.Get("/", handlers.ListUsers(store))
 		// POST /users       → Create a new user
-		r.Post("/", handlers.CreateUser(conn))
+		r.Post("/", handlers.CreateUser(store))
 		// GET /users/{id}   → Fetch a specific user by ID
-		r.Get("/{id}", handlers.GetUser(conn))
+		r.Get("/{id}", handlers.GetUser(store))
 		// PUT /users/{id}   → Update user by ID
-		r.Put("/{id}", handlers.UpdateUser(conn))
-		// DELETE /users/{id}→ Delete user by ID
-		r.Delete("/{id}", handlers.DeleteUser(conn))
+		r.Put("/{id}", handlers.UpdateUser(store))
+		// DELETE /users/{id}→ Move user to the trash (soft delete)
+		r.Delete("/{id}", handlers.DeleteUser(store))
+		// POST /users/{id}/restore → Take user out of the trash
+		r.Post("/{id}/restore", handlers.RestoreUser(store))
+
+		// POST /users/import → Bulk insert a CSV / NDJSON file in one transaction
+		r.Post("/import", bulkhttp.Import(store))
+		// GET /users/export  → Stream every user as CSV, NDJSON or JSON
+		r.Get("/export", bulkhttp.Export(store))
+	})
+
+	// Admin-only routes (API key with the :admin role)
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(keys.Middleware, apikey.RequireAdmin, audithttp.Middleware)
+
+		// GET /admin/users/deleted    → Users in the trash
+		r.Get("/users/deleted", audithttp.Deleted(store))
+		// GET /admin/users/{id}/audit → Who changed this user, when, and how
+		r.Get("/users/{id}/audit", audithttp.History(store))
 	})
 
 	// Log server startup message with port info
@@ -61,9 +101,11 @@ func main() {
 |----------------------------|-------------|
 | `chi.NewRouter()`          | Creates a new instance of a Chi router |
 | `r.Route("/users", ...)`   | Group routes under a shared prefix |
-| `handlers.*(conn)`         | Injects the shared DB connection into handlers |
+| `dbconn.Checker`           | Readiness probe at `/ready`; pings (and reconnects) on each request |
+| `cors.Handler(...)`        | Applies CORS rules and answers preflight requests for the group |
+| `handlers.*(store)`        | Injects the shared users store into handlers |
 | `http.ListenAndServe`      | Starts the server and blocks until it stops |
-| `defer conn.Close()`       | Ensures DB resources are released cleanly on exit |
+| `defer store.Close()`      | Ensures DB resources are released cleanly on exit |
 
 🔔 Bonus Tip:
 - Chi supports middleware out of the box — you can easily add logging, auth, or rate limiting per route or globally.
@@ -0,0 +1,168 @@
+// Package apikey authenticates API callers with static keys from the
+// environment and records who they are in the request context.
+package apikey
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Principal is the caller a key belongs to.
+type Principal struct {
+	Name  string
+	Admin bool
+}
+
+// Anonymous is the principal of requests when no keys are configured.
+var Anonymous = Principal{Name: "anonymous"}
+
+type key struct {
+	hash      [sha256.Size]byte
+	principal Principal
+}
+
+// Keys is the set of accepted API keys. The zero value accepts every
+// request as Anonymous.
+type Keys struct {
+	keys []key
+}
+
+// Parse reads a comma-separated list of name:secret or name:secret:admin
+// entries, e.g. "alice:s3cret:admin,importer:t0ken".
+func Parse(spec string) (Keys, error) {
+	var k Keys
+	names := map[string]bool{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+			return Keys{}, fmt.Errorf("apikey: entry %q is not name:secret[:admin]", redact(entry))
+		}
+		p := Principal{Name: parts[0]}
+		if len(parts) == 3 {
+			if parts[2] != "admin" {
+				return Keys{}, fmt.Errorf("apikey: unknown role %q for %s (only \"admin\")", parts[2], p.Name)
+			}
+			p.Admin = true
+		}
+		if names[p.Name] {
+			return Keys{}, fmt.Errorf("apikey: %s is listed twice", p.Name)
+		}
+		names[p.Name] = true
+		k.keys = append(k.keys, key{hash: sha256.Sum256([]byte(parts[1])), principal: p})
+	}
+	return k, nil
+}
+
+// FromEnv parses API_KEYS. When it is empty, every request is Anonymous and
+// admin routes are closed.
+func FromEnv() (Keys, error) {
+	k, err := Parse(os.Getenv("API_KEYS"))
+	if err == nil && !k.Enabled() {
+		log.Println("Warning: API_KEYS not set; requests are anonymous and admin routes are disabled")
+	}
+	return k, err
+}
+
+// Enabled reports whether any keys are configured.
+func (k Keys) Enabled() bool { return len(k.keys) > 0 }
+
+// lookup compares against every key in constant time, so the response time
+// does not reveal how much of a guess was right.
+func (k Keys) lookup(secret string) (Principal, bool) {
+	h := sha256.Sum256([]byte(secret))
+	var found Principal
+	ok := false
+	for _, c := range k.keys {
+		if subtle.ConstantTimeCompare(h[:], c.hash[:]) == 1 {
+			found, ok = c.principal, true
+		}
+	}
+	return found, ok
+}
+
+// Middleware requires a valid key in "Authorization: Bearer <key>" or
+// "X-API-Key: <key>" and stores its Principal in the context. Without any
+// configured keys it lets everything through as Anonymous.
+func (k Keys) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := Anonymous
+		if k.Enabled() {
+			var ok bool
+			p, ok = k.lookup(secretFrom(r))
+			if !ok {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+				http.Error(w, "Missing or invalid API key", http.StatusUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, p)))
+	})
+}
+
+func secretFrom(r *http.Request) string {
+	if v := r.Header.Get("X-API-Key"); v != "" {
+		return v
+	}
+	if v, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(v)
+	}
+	return ""
+}
+
+// RequireAdmin lets only admin principals through. It must run after
+// Middleware.
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p, ok := FromContext(r.Context()); !ok || !p.Admin {
+			http.Error(w, "Admin API key required", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type principalKey struct{}
+
+// FromContext returns the principal stored by Middleware.
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// redact hides the secret part of an entry in error messages.
+func redact(entry string) string {
+	name, _, _ := strings.Cut(entry, ":")
+	return name + ":***"
+}
+
+/*
+🧠 API KEYS — WHO IS CALLING? (shared/apikey/apikey.go)
+
+✅ What Happens Here:
+- `API_KEYS="alice:s3cret:admin,importer:t0ken"` defines named keys; `:admin` grants the admin routes.
+- `Middleware` checks `Authorization: Bearer <key>` (or `X-API-Key`) and puts the caller's `Principal` in the context.
+- `RequireAdmin` guards routes such as the audit history.
+- With no keys configured every caller is `anonymous` and admin routes answer 403.
+
+✅ Why This Matters:
+- An audit trail is only useful if it says *who* made the change; the principal name becomes the audit actor.
+- Keys are hashed once at startup and compared with `subtle.ConstantTimeCompare`, so timing does not leak them.
+
+✅ Key Concepts:
+| Concept                      | Explanation |
+|------------------------------|-------------|
+| `context.WithValue`          | Carries the authenticated caller to handlers and the data layer |
+| `401 Unauthorized`           | No key or a wrong key |
+| `403 Forbidden`              | A valid key without the admin role |
+| `crypto/subtle`              | Constant-time comparison of secrets |
+*/
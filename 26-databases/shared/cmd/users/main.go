@@ -0,0 +1,238 @@
+// Command users imports, exports and seeds the users table of any of the
+// lesson databases. Import and export use the same code as
+// POST /users/import and GET /users/export.
+//
+//	go run ./cmd/users import -dsn sqlite:../sqlite/data/app.db -mode best-effort people.csv
+//	go run ./cmd/users export -dsn sqlite:../sqlite/data/app.db -format ndjson > users.ndjson
+//	go run ./cmd/users seed -dsn sqlite:../sqlite/data/app.db -truncate -n 50 -seed 1
+//	go run ./cmd/users seed -dsn sqlite:../sqlite/data/app.db -truncate -fixtures users/seed/fixtures/users.yaml -set admin,demo
+//
+// -dsn defaults to $DATABASE_URL. The import format is taken from the file
+// extension (.csv, .ndjson, .jsonl) unless -format is given; "-" reads
+// stdin.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"learn-go-with-cyber-mountain-man/26-databases/shared/users"
+	_ "learn-go-with-cyber-mountain-man/26-databases/shared/users/mssql"
+	_ "learn-go-with-cyber-mountain-man/26-databases/shared/users/postgres"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/users/seed"
+	_ "learn-go-with-cyber-mountain-man/26-databases/shared/users/sqlite"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage:
+  users import [-dsn DSN] [-mode all-or-nothing|best-effort] [-format csv|ndjson] FILE
+  users export [-dsn DSN] [-format csv|ndjson|json] [-o FILE]
+  users seed   [-dsn DSN] [-truncate] [-n N] [-seed S] [-fixtures FILE -set NAME,...]`)
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "import":
+		err = runImport(os.Args[2:])
+	case "export":
+		err = runExport(os.Args[2:])
+	case "seed":
+		err = runSeed(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "users:", err)
+		os.Exit(1)
+	}
+}
+
+func open(ctx context.Context, dsn string) (*users.Store, error) {
+	if dsn == "" {
+		return nil, fmt.Errorf("no database: pass -dsn or set DATABASE_URL (schemes: %s)", strings.Join(users.Schemes(), ", "))
+	}
+	return users.Open(ctx, dsn)
+}
+
+// cliActor names the audit actor for imports run from a shell.
+func cliActor() string {
+	if u := os.Getenv("USER"); u != "" {
+		return "cli:" + u
+	}
+	return "cli"
+}
+
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	dsn := fs.String("dsn", os.Getenv("DATABASE_URL"), "database URL")
+	modeFlag := fs.String("mode", string(users.AllOrNothing), "all-or-nothing or best-effort")
+	format := fs.String("format", "", "csv or ndjson (default: from the file extension)")
+	timeout := fs.Duration("timeout", 10*time.Minute, "time limit for the whole import")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage()
+	}
+	path := fs.Arg(0)
+
+	mode, err := users.ParseImportMode(*modeFlag)
+	if err != nil {
+		return err
+	}
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+	var read func(io.Reader) ([]users.ImportRow, error)
+	switch *format {
+	case "csv":
+		read = users.ReadCSV
+	case "ndjson", "jsonl":
+		read = users.ReadNDJSON
+	default:
+		return fmt.Errorf("cannot tell the format of %q; pass -format csv|ndjson", path)
+	}
+
+	in := io.Reader(os.Stdin)
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	rows, err := read(bufio.NewReader(in))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	ctx = users.WithActor(ctx, cliActor())
+	store, err := open(ctx, *dsn)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		return err
+	}
+
+	report, err := store.Import(ctx, rows, mode)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+	if !report.Committed {
+		return fmt.Errorf("nothing imported: %d of %d rows rejected", report.Failed, report.Total)
+	}
+	return nil
+}
+
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	dsn := fs.String("dsn", os.Getenv("DATABASE_URL"), "database URL")
+	format := fs.String("format", "csv", "csv, ndjson or json")
+	out := fs.String("o", "-", "output file (- for stdout)")
+	timeout := fs.Duration("timeout", 10*time.Minute, "time limit for the whole export")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		usage()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	store, err := open(ctx, *dsn)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+	if err := store.Export(ctx, bw, *format); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func runSeed(args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ExitOnError)
+	dsn := fs.String("dsn", os.Getenv("DATABASE_URL"), "database URL")
+	truncate := fs.Bool("truncate", false, "delete all users and the audit log first (IDs restart at 1)")
+	n := fs.Int("n", 0, "number of users to generate")
+	seedFlag := fs.Uint64("seed", 1, "random seed for generated users")
+	fixtures := fs.String("fixtures", "", "YAML or JSON file of fixture sets")
+	set := fs.String("set", "", "comma-separated fixture sets to load (default: all, by name)")
+	timeout := fs.Duration("timeout", 10*time.Minute, "time limit for the whole seed")
+	fs.Parse(args)
+	if fs.NArg() != 0 || *n < 0 {
+		usage()
+	}
+
+	var rows []users.User
+	if *fixtures != "" {
+		sets, err := seed.ReadFile(*fixtures)
+		if err != nil {
+			return err
+		}
+		names := sets.Names()
+		if *set != "" {
+			names = strings.Split(*set, ",")
+		}
+		if rows, err = sets.Users(names...); err != nil {
+			return err
+		}
+	} else if *set != "" {
+		return fmt.Errorf("-set needs -fixtures")
+	}
+	rows = append(rows, seed.Generate(*n, *seedFlag)...)
+	if len(rows) == 0 && !*truncate {
+		return fmt.Errorf("nothing to do: pass -n, -fixtures or -truncate")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	store, err := open(ctx, *dsn)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		return err
+	}
+
+	if *truncate {
+		err = seed.Reset(ctx, store, rows)
+	} else {
+		err = store.Load(ctx, rows)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "seeded %d users into %s\n", len(rows), store.Dialect().Name())
+	return nil
+}
@@ -0,0 +1,314 @@
+package cors
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Options controls which cross-origin callers may use a group of routes.
+type Options struct {
+	// AllowedOrigins lists exact origins ("https://app.example.com"),
+	// wildcard subdomains ("https://*.example.com") or "*" for any origin.
+	AllowedOrigins []string
+
+	// AllowOriginFunc is an optional predicate consulted when no entry in
+	// AllowedOrigins matches.
+	AllowOriginFunc func(origin string) bool
+
+	AllowedMethods   []string // Methods allowed in preflight responses
+	AllowedHeaders   []string // Request headers the browser may send ("*" echoes the request)
+	ExposedHeaders   []string // Response headers readable from JavaScript
+	AllowCredentials bool     // Allow cookies and Authorization headers
+	MaxAge           int      // Seconds a preflight may be cached (0 omits the header)
+}
+
+// Default methods and headers used when the environment does not override them.
+var (
+	defaultMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	defaultHeaders = []string{"Accept", "Authorization", "Content-Type", "If-Match", "X-Requested-With"}
+	defaultExposed = []string{"ETag"} // needed to send If-Match on the next write
+)
+
+// FromEnv builds Options for a route group from environment variables.
+// For group "USERS" it reads CORS_USERS_ALLOWED_ORIGINS and falls back to
+// CORS_ALLOWED_ORIGINS, and likewise for the other settings:
+//
+//	CORS_ALLOWED_ORIGINS     comma-separated origins (empty disables CORS)
+//	CORS_ALLOWED_METHODS     comma-separated methods
+//	CORS_ALLOWED_HEADERS     comma-separated request headers
+//	CORS_EXPOSED_HEADERS     comma-separated response headers
+//	CORS_ALLOW_CREDENTIALS   "true" to allow credentials
+//	CORS_MAX_AGE             preflight cache lifetime in seconds
+func FromEnv(group string) Options {
+	get := func(name string) string {
+		if group != "" {
+			if v, ok := os.LookupEnv("CORS_" + strings.ToUpper(group) + "_" + name); ok {
+				return v
+			}
+		}
+		return os.Getenv("CORS_" + name)
+	}
+
+	opts := Options{
+		AllowedOrigins: splitList(get("ALLOWED_ORIGINS")),
+		AllowedMethods: splitList(get("ALLOWED_METHODS")),
+		AllowedHeaders: splitList(get("ALLOWED_HEADERS")),
+		ExposedHeaders: splitList(get("EXPOSED_HEADERS")),
+	}
+	opts.AllowCredentials, _ = strconv.ParseBool(get("ALLOW_CREDENTIALS"))
+	opts.MaxAge, _ = strconv.Atoi(get("MAX_AGE"))
+	return opts
+}
+
+// ErrWildcardCredentials rejects AllowedOrigins "*" together with
+// AllowCredentials: every website could then make logged-in requests and
+// read the answers.
+var ErrWildcardCredentials = errors.New(`cors: allowed origin "*" cannot be combined with allow credentials; list the origins instead`)
+
+// Validate reports settings that are unsafe to serve.
+func (o Options) Validate() error {
+	if !o.AllowCredentials {
+		return nil
+	}
+	for _, origin := range o.AllowedOrigins {
+		if strings.TrimSpace(origin) == "*" {
+			return ErrWildcardCredentials
+		}
+	}
+	return nil
+}
+
+// Handler returns middleware that applies the CORS policy and answers
+// preflight requests itself, so they never reach the router's 405 handling.
+// It panics if opts fail Validate, so a bad configuration stops the server
+// at startup instead of opening the API to every site.
+func Handler(opts Options) func(http.Handler) http.Handler {
+	if err := opts.Validate(); err != nil {
+		panic(err)
+	}
+	p := newPolicy(opts)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				p.preflight(w, r)
+				return
+			}
+			p.actual(w, r)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// policy is the normalised form of Options used while serving requests.
+type policy struct {
+	allowAll    bool
+	exact       map[string]bool
+	wildcards   []wildcard
+	originFunc  func(string) bool
+	methods     map[string]bool
+	methodList  string
+	headers     map[string]bool
+	anyHeader   bool
+	exposed     string
+	credentials bool
+	maxAge      string
+	disabled    bool
+}
+
+// wildcard matches origins such as "https://*.example.com".
+type wildcard struct {
+	prefix, suffix string
+}
+
+func (w wildcard) match(origin string) bool {
+	return len(origin) > len(w.prefix)+len(w.suffix) &&
+		strings.HasPrefix(origin, w.prefix) &&
+		strings.HasSuffix(origin, w.suffix)
+}
+
+func newPolicy(opts Options) *policy {
+	p := &policy{
+		exact:       make(map[string]bool),
+		originFunc:  opts.AllowOriginFunc,
+		methods:     make(map[string]bool),
+		headers:     make(map[string]bool),
+		exposed:     strings.Join(opts.ExposedHeaders, ", "),
+		credentials: opts.AllowCredentials,
+	}
+
+	for _, o := range opts.AllowedOrigins {
+		o = strings.ToLower(strings.TrimSpace(o))
+		switch {
+		case o == "*":
+			p.allowAll = true
+		case strings.Contains(o, "*"):
+			i := strings.Index(o, "*")
+			p.wildcards = append(p.wildcards, wildcard{prefix: o[:i], suffix: o[i+1:]})
+		case o != "":
+			p.exact[o] = true
+		}
+	}
+	p.disabled = !p.allowAll && len(p.exact) == 0 && len(p.wildcards) == 0 && p.originFunc == nil
+
+	methods := opts.AllowedMethods
+	if len(methods) == 0 {
+		methods = defaultMethods
+	}
+	upper := make([]string, len(methods))
+	for i, m := range methods {
+		upper[i] = strings.ToUpper(m)
+		p.methods[upper[i]] = true
+	}
+	p.methodList = strings.Join(upper, ", ")
+
+	if len(opts.ExposedHeaders) == 0 {
+		p.exposed = strings.Join(defaultExposed, ", ")
+	}
+
+	headers := opts.AllowedHeaders
+	if len(headers) == 0 {
+		headers = defaultHeaders
+	}
+	for _, h := range headers {
+		if h == "*" {
+			p.anyHeader = true
+			continue
+		}
+		p.headers[http.CanonicalHeaderKey(h)] = true
+	}
+
+	if opts.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(opts.MaxAge)
+	}
+	return p
+}
+
+// originAllowed reports whether the given Origin header value may call us.
+func (p *policy) originAllowed(origin string) bool {
+	if p.disabled || origin == "" {
+		return false
+	}
+	if p.allowAll {
+		return true
+	}
+	lower := strings.ToLower(origin)
+	if p.exact[lower] {
+		return true
+	}
+	for _, w := range p.wildcards {
+		if w.match(lower) {
+			return true
+		}
+	}
+	return p.originFunc != nil && p.originFunc(origin)
+}
+
+// allowOrigin writes Access-Control-Allow-Origin: a literal "*" when any
+// origin is allowed (Validate rules out credentials then), otherwise the
+// caller's origin, echoed back.
+func (p *policy) allowOrigin(h http.Header, origin string) {
+	if p.allowAll {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.credentials && !p.allowAll {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// varyOnOrigin reports whether responses differ per Origin, which is the
+// case whenever the allowed origin is echoed instead of a fixed "*".
+func (p *policy) varyOnOrigin() bool {
+	return !p.disabled && !p.allowAll
+}
+
+// preflight answers an OPTIONS preflight request without calling the router.
+// A rejected preflight still gets 204; the missing CORS headers are what
+// tells the browser to block the real request.
+func (p *policy) preflight(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	h.Add("Vary", "Origin")
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+
+	origin := r.Header.Get("Origin")
+	method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	if !p.originAllowed(origin) || !p.methods[method] {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	requested := splitList(r.Header.Get("Access-Control-Request-Headers"))
+	if !p.anyHeader {
+		for _, name := range requested {
+			if !p.headers[http.CanonicalHeaderKey(name)] {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+	}
+
+	p.allowOrigin(h, origin)
+	h.Set("Access-Control-Allow-Methods", p.methodList)
+	if len(requested) > 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(requested, ", "))
+	}
+	if p.maxAge != "" {
+		h.Set("Access-Control-Max-Age", p.maxAge)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// actual decorates a normal (non-preflight) response with CORS headers.
+func (p *policy) actual(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	if p.varyOnOrigin() {
+		h.Add("Vary", "Origin")
+	}
+
+	origin := r.Header.Get("Origin")
+	if !p.originAllowed(origin) {
+		return
+	}
+	p.allowOrigin(h, origin)
+	if p.exposed != "" {
+		h.Set("Access-Control-Expose-Headers", p.exposed)
+	}
+}
+
+// splitList turns "a, b ,c" into []string{"a", "b", "c"}.
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+/*
+🧠 CROSS-ORIGIN REQUESTS — SHARED CORS MIDDLEWARE (shared/cors/cors.go)
+
+✅ What Happens Here:
+- Browser apps served from another origin must be explicitly allowed to call the users API.
+- Preflight `OPTIONS` requests are answered here with 204, so chi never replies 405.
+- `Vary: Origin` is added whenever the response echoes the caller's origin, keeping caches honest.
+
+✅ Why This Matters:
+- All three lesson-26 servers (SQLite, PostgreSQL, MSSQL) import this one package,
+  so the CORS rules cannot drift apart between database variants.
+
+✅ Key Concepts:
+| Concept                          | Explanation |
+|----------------------------------|-------------|
+| `Access-Control-Allow-Origin`    | Which origin may read the response |
+| Preflight (`OPTIONS`)            | Browser asks before sending PUT/DELETE or JSON bodies |
+| `https://*.example.com`          | Wildcard subdomain match |
+| `CORS_USERS_ALLOWED_ORIGINS`     | Per-group override of `CORS_ALLOWED_ORIGINS` |
+*/
@@ -0,0 +1,220 @@
+package cors
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+// serve runs one request through Handler(opts) and reports whether the
+// wrapped handler was reached.
+func serve(opts Options, method, origin string, headers map[string]string) (*httptest.ResponseRecorder, bool) {
+	reached := false
+	h := Handler(opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	r := httptest.NewRequest(method, "/users", nil)
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w, reached
+}
+
+func TestOrigins(t *testing.T) {
+	opts := Options{AllowedOrigins: []string{"https://app.example.org", "https://*.example.com"}}
+	tests := []struct {
+		origin string
+		allow  bool
+	}{
+		{"https://app.example.org", true},
+		{"HTTPS://APP.EXAMPLE.ORG", true},
+		{"https://app.example.org:8443", false},
+		{"http://app.example.org", false},
+		{"https://evil.example.org", false},
+		{"https://api.example.com", true},
+		{"https://a.b.example.com", true},
+		{"https://example.com", false}, // the apex isn't a subdomain
+		{"https://evilexample.com", false},
+		{"https://example.com.evil.net", false},
+		{"https://api.example.com.evil.net", false},
+		{"http://api.example.com", false},
+		{"null", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			w, reached := serve(opts, http.MethodGet, tt.origin, nil)
+			if !reached {
+				t.Fatal("a simple request must always reach the handler")
+			}
+			got := w.Header().Get("Access-Control-Allow-Origin")
+			if tt.allow && got != tt.origin {
+				t.Errorf("Allow-Origin = %q, want the origin echoed", got)
+			}
+			if !tt.allow && got != "" {
+				t.Errorf("Allow-Origin = %q, want none", got)
+			}
+			// Allowed or not, the answer depends on Origin.
+			if !slices.Contains(w.Header().Values("Vary"), "Origin") {
+				t.Errorf("Vary = %q, want Origin", w.Header().Values("Vary"))
+			}
+		})
+	}
+}
+
+func TestAllowOriginFunc(t *testing.T) {
+	opts := Options{
+		AllowedOrigins:  []string{"https://app.example.org"},
+		AllowOriginFunc: func(origin string) bool { return origin == "http://localhost:3000" },
+	}
+	for origin, want := range map[string]string{
+		"https://app.example.org": "https://app.example.org",
+		"http://localhost:3000":   "http://localhost:3000",
+		"http://localhost:4000":   "",
+	} {
+		w, _ := serve(opts, http.MethodGet, origin, nil)
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != want {
+			t.Errorf("%s: Allow-Origin = %q, want %q", origin, got, want)
+		}
+	}
+}
+
+func TestAnyOrigin(t *testing.T) {
+	w, _ := serve(Options{AllowedOrigins: []string{"*"}}, http.MethodGet, "https://anyone.test", nil)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Allow-Origin = %q, want *", got)
+	}
+	if v := w.Header().Values("Vary"); len(v) != 0 {
+		t.Errorf("Vary = %q: a fixed * doesn't depend on Origin", v)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Allow-Credentials = %q with *", got)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	w, reached := serve(Options{}, http.MethodGet, "https://app.example.org", nil)
+	if !reached || len(w.Header()) != 0 {
+		t.Errorf("no origins configured: reached %v, headers %v; want untouched", reached, w.Header())
+	}
+}
+
+func TestCredentialsAndExposedHeaders(t *testing.T) {
+	opts := Options{AllowedOrigins: []string{"https://app.example.org"}, AllowCredentials: true}
+	w, _ := serve(opts, http.MethodGet, "https://app.example.org", nil)
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("Allow-Credentials = %q, want true", got)
+	}
+	if got := w.Header().Get("Access-Control-Expose-Headers"); got != "ETag" {
+		t.Errorf("Expose-Headers = %q, want the default ETag", got)
+	}
+
+	w, _ = serve(opts, http.MethodGet, "https://evil.test", nil)
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("refused origin got Allow-Credentials %q", got)
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	opts := Options{AllowedOrigins: []string{"https://app.example.org"}, MaxAge: 600}
+	tests := []struct {
+		name    string
+		origin  string
+		method  string
+		headers string
+		allow   bool
+	}{
+		{"allowed", "https://app.example.org", "PUT", "Content-Type, If-Match", true},
+		{"method in lower case", "https://app.example.org", "delete", "", true},
+		{"origin refused", "https://evil.test", "PUT", "", false},
+		{"method refused", "https://app.example.org", "TRACE", "", false},
+		{"header refused", "https://app.example.org", "PUT", "Content-Type, X-Secret", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, reached := serve(opts, http.MethodOptions, tt.origin, map[string]string{
+				"Access-Control-Request-Method":  tt.method,
+				"Access-Control-Request-Headers": tt.headers,
+			})
+			if reached {
+				t.Error("preflight reached the handler")
+			}
+			if w.Code != http.StatusNoContent {
+				t.Errorf("status %d, want 204", w.Code)
+			}
+			vary := w.Header().Values("Vary")
+			for _, v := range []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"} {
+				if !slices.Contains(vary, v) {
+					t.Errorf("Vary = %q, missing %s", vary, v)
+				}
+			}
+
+			h := w.Header()
+			if !tt.allow {
+				if got := h.Get("Access-Control-Allow-Origin"); got != "" {
+					t.Errorf("Allow-Origin = %q on a refused preflight", got)
+				}
+				return
+			}
+			if h.Get("Access-Control-Allow-Origin") != tt.origin ||
+				h.Get("Access-Control-Allow-Methods") != "GET, HEAD, POST, PUT, PATCH, DELETE" ||
+				h.Get("Access-Control-Allow-Headers") != tt.headers ||
+				h.Get("Access-Control-Max-Age") != "600" {
+				t.Errorf("headers = %v", h)
+			}
+		})
+	}
+
+	// OPTIONS without Access-Control-Request-Method is an ordinary request.
+	if _, reached := serve(opts, http.MethodOptions, "https://app.example.org", nil); !reached {
+		t.Error("plain OPTIONS was treated as a preflight")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want error
+	}{
+		{"star with credentials", Options{AllowedOrigins: []string{"https://a.test", " * "}, AllowCredentials: true}, ErrWildcardCredentials},
+		{"star without credentials", Options{AllowedOrigins: []string{"*"}}, nil},
+		{"listed origins with credentials", Options{AllowedOrigins: []string{"https://*.example.com"}, AllowCredentials: true}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.Validate(); !errors.Is(err, tt.want) {
+				t.Errorf("Validate = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Handler accepted * with credentials")
+		}
+	}()
+	Handler(tests[0].opts)
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://a.test, https://b.test")
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "true")
+	t.Setenv("CORS_USERS_ALLOWED_ORIGINS", "https://users.test")
+	t.Setenv("CORS_MAX_AGE", "300")
+
+	if o := FromEnv(""); !slices.Equal(o.AllowedOrigins, []string{"https://a.test", "https://b.test"}) || !o.AllowCredentials || o.MaxAge != 300 {
+		t.Errorf("FromEnv(\"\") = %+v", o)
+	}
+	if o := FromEnv("users"); !slices.Equal(o.AllowedOrigins, []string{"https://users.test"}) || !o.AllowCredentials {
+		t.Errorf("FromEnv(users) = %+v, want the group's origins and the shared rest", o)
+	}
+}
@@ -0,0 +1,222 @@
+// Package dbconn opens the users store without giving up on the first
+// failed ping: it retries with exponential backoff and jitter until a
+// startup deadline, sizes the connection pool from the environment, and
+// serves a readiness probe that reconnects on demand.
+package dbconn
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"learn-go-with-cyber-mountain-man/26-databases/shared/users"
+)
+
+// Config controls connection retries and the pool. FromEnv fills it from
+// DB_* variables; zero pool values keep database/sql's defaults.
+type Config struct {
+	StartupTimeout time.Duration // give up connecting after this long
+	InitialBackoff time.Duration // first wait between attempts
+	MaxBackoff     time.Duration // cap for the doubling wait
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// Defaults used by FromEnv for unset variables.
+var Defaults = Config{
+	StartupTimeout:  30 * time.Second,
+	InitialBackoff:  250 * time.Millisecond,
+	MaxBackoff:      5 * time.Second,
+	MaxOpenConns:    10,
+	MaxIdleConns:    5,
+	ConnMaxLifetime: 30 * time.Minute,
+	ConnMaxIdleTime: 5 * time.Minute,
+}
+
+// FromEnv reads the configuration, starting from Defaults:
+//
+//	DB_STARTUP_TIMEOUT     how long to keep retrying at startup ("30s")
+//	DB_RETRY_BACKOFF       first wait between attempts ("250ms")
+//	DB_RETRY_MAX_BACKOFF   longest wait between attempts ("5s")
+//	DB_MAX_OPEN_CONNS      pool size (0 = unlimited)
+//	DB_MAX_IDLE_CONNS      idle connections kept open
+//	DB_CONN_MAX_LIFETIME   recycle connections after this long ("30m")
+//	DB_CONN_MAX_IDLE_TIME  close connections idle for this long ("5m")
+func FromEnv() (Config, error) {
+	c := Defaults
+	for _, d := range []struct {
+		name string
+		dst  *time.Duration
+	}{
+		{"DB_STARTUP_TIMEOUT", &c.StartupTimeout},
+		{"DB_RETRY_BACKOFF", &c.InitialBackoff},
+		{"DB_RETRY_MAX_BACKOFF", &c.MaxBackoff},
+		{"DB_CONN_MAX_LIFETIME", &c.ConnMaxLifetime},
+		{"DB_CONN_MAX_IDLE_TIME", &c.ConnMaxIdleTime},
+	} {
+		if v := os.Getenv(d.name); v != "" {
+			dur, err := time.ParseDuration(v)
+			if err != nil || dur < 0 {
+				return Config{}, fmt.Errorf("dbconn: %s=%q is not a duration like 30s", d.name, v)
+			}
+			*d.dst = dur
+		}
+	}
+	for _, n := range []struct {
+		name string
+		dst  *int
+	}{
+		{"DB_MAX_OPEN_CONNS", &c.MaxOpenConns},
+		{"DB_MAX_IDLE_CONNS", &c.MaxIdleConns},
+	} {
+		if v := os.Getenv(n.name); v != "" {
+			i, err := strconv.Atoi(v)
+			if err != nil || i < 0 {
+				return Config{}, fmt.Errorf("dbconn: %s=%q is not a non-negative integer", n.name, v)
+			}
+			*n.dst = i
+		}
+	}
+	return c, nil
+}
+
+// Apply sets the pool limits on db.
+func (c Config) Apply(db *sql.DB) {
+	db.SetMaxOpenConns(c.MaxOpenConns)
+	db.SetMaxIdleConns(c.MaxIdleConns)
+	db.SetConnMaxLifetime(c.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(c.ConnMaxIdleTime)
+}
+
+// Connect opens the store for dsn, applies the pool settings and pings
+// until the database answers or cfg.StartupTimeout (or ctx) runs out. The
+// returned error wraps the last ping error.
+func Connect(ctx context.Context, dsn string, cfg Config) (*users.Store, error) {
+	store, err := users.OpenPool(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(dsn, ":memory:") {
+		// An in-memory database lives only as long as one of its
+		// connections, so none may be closed for age or idleness.
+		cfg.ConnMaxLifetime, cfg.ConnMaxIdleTime = 0, 0
+	}
+	cfg.Apply(store.DB())
+
+	if cfg.StartupTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.StartupTimeout)
+		defer cancel()
+	}
+	attempt := 0
+	err = Retry(ctx, cfg, func(ctx context.Context) error {
+		attempt++
+		err := store.DB().PingContext(ctx)
+		if err != nil {
+			log.Printf("Database not ready (attempt %d): %v", attempt, err)
+		}
+		return err
+	})
+	if err != nil {
+		store.Close()
+		return nil, fmt.Errorf("dbconn: %s not reachable after %d attempts: %w", store.Dialect().Name(), attempt, err)
+	}
+	return store, nil
+}
+
+// Retry calls op until it succeeds or ctx is done. The wait between calls
+// doubles from cfg.InitialBackoff up to cfg.MaxBackoff, and each wait is a
+// random fraction of that ("full jitter") so restarted replicas don't retry
+// in lockstep. On timeout the last error from op is returned.
+func Retry(ctx context.Context, cfg Config, op func(ctx context.Context) error) error {
+	backoff := cfg.InitialBackoff
+	if backoff <= 0 {
+		backoff = Defaults.InitialBackoff
+	}
+	for {
+		err := op(ctx)
+		if err == nil {
+			return nil
+		}
+		wait := rand.N(backoff) + time.Millisecond
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		if backoff *= 2; cfg.MaxBackoff > 0 && backoff > cfg.MaxBackoff {
+			backoff = cfg.MaxBackoff
+		}
+	}
+}
+
+// Checker answers readiness probes. Each check pings the pool, which
+// replaces dead connections, and retries briefly so a database that has
+// just come back is reported ready on the first probe after it. Probes run
+// one at a time, so a burst of them cannot open a burst of connections.
+type Checker struct {
+	DB      *sql.DB
+	Timeout time.Duration // per probe; 0 means 2s
+	Config  Config        // backoff for the retries within a probe
+
+	mu sync.Mutex
+}
+
+// Check reports whether the database is reachable now.
+func (c *Checker) Check(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return Retry(ctx, c.Config, c.DB.PingContext)
+}
+
+// ServeHTTP is the readiness endpoint: 200 when the database answers, 503
+// (so the load balancer stops sending traffic) when it does not.
+func (c *Checker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := c.Check(r.Context()); err != nil {
+		log.Println("Readiness check failed:", err)
+		http.Error(w, "database unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ready"))
+}
+
+/*
+🧠 RESILIENT CONNECTIONS — RETRIES, POOL SIZE, READINESS (shared/dbconn/dbconn.go)
+
+✅ What Happens Here:
+- `Connect` opens the pool, applies `SetMaxOpenConns` / `SetMaxIdleConns` / `SetConnMaxLifetime` / `SetConnMaxIdleTime`, then pings until the database answers.
+- Between pings it waits 250ms, 500ms, 1s, ... up to 5s, each time a random fraction of that (jitter), for at most `DB_STARTUP_TIMEOUT`.
+- It returns an error instead of calling `log.Fatal`, so `main` decides what to do (and tests can call it).
+- `Checker` serves `GET /ready`: a ping with a short retry, 503 while the database is down.
+
+✅ Why This Matters:
+- In docker-compose the app container usually starts before the database accepts connections; one failed ping shouldn't kill it.
+- Without jitter, ten replicas restarted together hammer the database at the same instants.
+- An unbounded pool can exhaust the server's connection limit; long-lived connections never pick up DNS or failover changes.
+
+✅ Key Concepts:
+| Concept                    | Explanation |
+|----------------------------|-------------|
+| Exponential backoff        | Double the wait after each failure, up to a cap |
+| Full jitter                | Sleep a random time in `[0, backoff)` |
+| `SetConnMaxLifetime`       | Recycle connections so none lives forever |
+| Readiness vs liveness      | "Can I take traffic?" (DB reachable) vs "am I running?" |
+*/
@@ -0,0 +1,125 @@
+package dbctx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"learn-go-with-cyber-mountain-man/26-databases/shared/users"
+)
+
+// DefaultTimeout bounds a request's database work when DB_QUERY_TIMEOUT is unset.
+const DefaultTimeout = 5 * time.Second
+
+// StatusClientClosedRequest is the non-standard 499 code (popularised by
+// nginx) for "the client went away before we answered".
+const StatusClientClosedRequest = 499
+
+var timeout = sync.OnceValue(func() time.Duration {
+	if v := os.Getenv("DB_QUERY_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err == nil && d > 0 {
+			return d
+		}
+		log.Printf("Warning: invalid DB_QUERY_TIMEOUT %q, using %s", v, DefaultTimeout)
+	}
+	return DefaultTimeout
+})
+
+// Timeout returns the configured per-request query deadline (DB_QUERY_TIMEOUT,
+// e.g. "2s" or "500ms").
+func Timeout() time.Duration {
+	return timeout()
+}
+
+// WithTimeout derives a context from the request context that is cancelled
+// when the client disconnects or the query deadline passes, whichever
+// comes first.
+func WithTimeout(r *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(r.Context(), Timeout())
+}
+
+// DefaultBulkTimeout bounds imports and exports when DB_BULK_TIMEOUT is unset.
+const DefaultBulkTimeout = 2 * time.Minute
+
+var bulkTimeout = sync.OnceValue(func() time.Duration {
+	if v := os.Getenv("DB_BULK_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err == nil && d > 0 {
+			return d
+		}
+		log.Printf("Warning: invalid DB_BULK_TIMEOUT %q, using %s", v, DefaultBulkTimeout)
+	}
+	return DefaultBulkTimeout
+})
+
+// WithBulkTimeout is WithTimeout for imports and exports, which touch many
+// rows and get the longer DB_BULK_TIMEOUT deadline.
+func WithBulkTimeout(r *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(r.Context(), bulkTimeout())
+}
+
+// Error writes an error response for a failed database call. Cancellation
+// and deadline errors get 499 and 504, a missing user 404 and a constraint
+// violation 409 (duplicate) or 422 (other) naming the field; anything else
+// gets 500 with msg.
+func Error(w http.ResponseWriter, ctx context.Context, err error, msg string) {
+	var ce *users.ConstraintError
+	switch {
+	case errors.As(err, &ce):
+		status := http.StatusUnprocessableEntity
+		if errors.Is(ce, users.ErrDuplicate) {
+			status = http.StatusConflict
+		}
+		Problem(w, status, ce.Field, ce.Message())
+	case errors.Is(err, users.ErrNotFound):
+		http.Error(w, "User not found", http.StatusNotFound)
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded):
+		http.Error(w, "Database query timed out", http.StatusGatewayTimeout)
+	case errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled):
+		// Nobody is listening any more; the status only shows up in logs.
+		w.WriteHeader(StatusClientClosedRequest)
+	default:
+		http.Error(w, msg, http.StatusInternalServerError)
+	}
+}
+
+// Problem writes a JSON error body, {"error": msg, "field": field}, so a
+// client can point at the input that was rejected.
+func Problem(w http.ResponseWriter, status int, field, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+		Field string `json:"field,omitempty"`
+	}{msg, field})
+}
+
+/*
+🧠 REQUEST-SCOPED DATABASE CALLS — CONTEXT + TIMEOUTS (shared/dbctx/dbctx.go)
+
+✅ What Happens Here:
+- `WithTimeout(r)` derives a context from `r.Context()` with a deadline from `DB_QUERY_TIMEOUT` (default 5s).
+- `WithBulkTimeout(r)` does the same for imports/exports with `DB_BULK_TIMEOUT` (default 2m).
+- Model functions take that context and call `QueryContext` / `ExecContext`, so the driver cancels the query when it fires.
+- `Error(...)` turns the outcome into a status code: 504 for a deadline, 499 when the client hung up, 404 for `users.ErrNotFound`, 409/422 for constraint violations, 500 otherwise.
+
+✅ Why This Matters:
+- Without a context, a client disconnect or a locked table leaves queries running and connections tied up.
+- A deadline turns "hangs forever" into a fast, explicit failure the caller can retry.
+
+✅ Key Concepts:
+| Concept                       | Explanation |
+|-------------------------------|-------------|
+| `r.Context()`                 | Cancelled automatically when the client disconnects |
+| `context.WithTimeout`         | Adds a deadline on top of the request context |
+| `context.DeadlineExceeded`    | The deadline passed → 504 Gateway Timeout |
+| `context.Canceled`            | The client went away → 499 Client Closed Request |
+| `users.ErrDuplicate`          | Unique constraint → 409 Conflict, `{"error": ..., "field": "email"}` |
+| `users.ErrConstraint`         | Not-null / foreign key / check → 422 Unprocessable Entity, same body |
+*/
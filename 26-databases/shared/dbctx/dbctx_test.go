@@ -0,0 +1,133 @@
+package dbctx_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"learn-go-with-cyber-mountain-man/26-databases/shared/dbctx"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/users"
+	_ "learn-go-with-cyber-mountain-man/26-databases/shared/users/mssql"
+	_ "learn-go-with-cyber-mountain-man/26-databases/shared/users/postgres"
+	_ "learn-go-with-cyber-mountain-man/26-databases/shared/users/sqlite"
+)
+
+// testTimeout is DB_QUERY_TIMEOUT for these tests: short, but long enough
+// that the slow query is certainly running when it fires.
+const testTimeout = 300 * time.Millisecond
+
+func TestMain(m *testing.M) {
+	os.Setenv("DB_QUERY_TIMEOUT", testTimeout.String()) // read once, on first use
+	os.Exit(m.Run())
+}
+
+// slowQueries outlast testTimeout on each engine.
+var slowQueries = map[string]string{
+	"sqlite": `WITH RECURSIVE n(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM n WHERE x < 1000000000)
+		SELECT COUNT(*) FROM n`,
+	"postgres": `SELECT 1 FROM pg_sleep(30)`,
+	"mssql":    `WAITFOR DELAY '00:00:30'; SELECT 1`,
+}
+
+// databases returns in-memory SQLite plus PostgreSQL and SQL Server when
+// USERS_TEST_POSTGRES_DSN / USERS_TEST_MSSQL_DSN are set.
+func databases(t *testing.T) map[string]*users.Store {
+	dsns := map[string]string{"sqlite": "sqlite::memory:"}
+	for name, env := range map[string]string{"postgres": "USERS_TEST_POSTGRES_DSN", "mssql": "USERS_TEST_MSSQL_DSN"} {
+		if dsn := os.Getenv(env); dsn != "" {
+			dsns[name] = dsn
+		} else {
+			t.Logf("%s: skipped, %s not set", name, env)
+		}
+	}
+	stores := make(map[string]*users.Store)
+	for name, dsn := range dsns {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		store, err := users.Open(ctx, dsn)
+		cancel()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		t.Cleanup(func() { store.Close() })
+		stores[name] = store
+	}
+	return stores
+}
+
+// runSlowQuery runs the engine's slow query under dbctx.WithTimeout(r), as
+// a handler would, and returns that context, how long it took and the error.
+func runSlowQuery(store *users.Store, r *http.Request) (context.Context, time.Duration, error) {
+	ctx, cancel := dbctx.WithTimeout(r)
+	defer cancel()
+	start := time.Now()
+	var n int
+	err := store.DB().QueryRowContext(ctx, slowQueries[store.Dialect().Name()]).Scan(&n)
+	return ctx, time.Since(start), err
+}
+
+func TestDeadlineCancelsRunningQuery(t *testing.T) {
+	for name, store := range databases(t) {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			ctx, elapsed, err := runSlowQuery(store, req)
+
+			if err == nil {
+				t.Fatal("slow query finished; want it cut short by the deadline")
+			}
+			// Some drivers report the server's "statement cancelled" error
+			// rather than wrapping ctx.Err(); the context says why either way.
+			if !errors.Is(err, context.DeadlineExceeded) && !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				t.Fatalf("err = %v (ctx: %v), want context.DeadlineExceeded", err, ctx.Err())
+			}
+			if name == "sqlite" && !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("err = %v, want it to wrap context.DeadlineExceeded", err)
+			}
+			if elapsed < testTimeout {
+				t.Errorf("query failed after %s, before the %s deadline: it never ran", elapsed, testTimeout)
+			}
+			if elapsed > testTimeout+5*time.Second {
+				t.Errorf("query stopped %s after the deadline; it should be cancelled promptly", elapsed-testTimeout)
+			}
+
+			rec := httptest.NewRecorder()
+			dbctx.Error(rec, ctx, err, "Database query failed")
+			if rec.Code != http.StatusGatewayTimeout {
+				t.Errorf("dbctx.Error status = %d, want 504", rec.Code)
+			}
+		})
+	}
+}
+
+func TestClientDisconnectCancelsRunningQuery(t *testing.T) {
+	for name, store := range databases(t) {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			parent, disconnect := context.WithCancel(req.Context())
+			time.AfterFunc(testTimeout/3, disconnect) // the client hangs up mid-query
+			ctx, _, err := runSlowQuery(store, req.WithContext(parent))
+
+			if err == nil {
+				t.Fatal("slow query finished; want it cut short by the disconnect")
+			}
+			if !errors.Is(err, context.Canceled) && !errors.Is(ctx.Err(), context.Canceled) {
+				t.Fatalf("err = %v (ctx: %v), want context.Canceled", err, ctx.Err())
+			}
+
+			rec := httptest.NewRecorder()
+			dbctx.Error(rec, ctx, err, "Database query failed")
+			if rec.Code != dbctx.StatusClientClosedRequest {
+				t.Errorf("dbctx.Error status = %d, want 499", rec.Code)
+			}
+		})
+	}
+}
+
+func TestTimeoutFromEnv(t *testing.T) {
+	if got := dbctx.Timeout(); got != testTimeout {
+		t.Errorf("Timeout() = %s, want %s from DB_QUERY_TIMEOUT", got, testTimeout)
+	}
+}
@@ -0,0 +1,16 @@
+module learn-go-with-cyber-mountain-man/26-databases/shared
+
+go 1.24.0
+
+require (
+	github.com/denisenkom/go-mssqldb v0.12.3
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.28
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/golang-sql/civil v0.0.0-20190719163853-cb61b32ac6fe // indirect
+	github.com/golang-sql/sqlexp v0.1.0 // indirect
+	golang.org/x/crypto v0.0.0-20220622213112-05595931fe9d // indirect
+)
@@ -0,0 +1,241 @@
+// Package sqlscan runs a query and scans its rows into structs, matching
+// result columns to fields by their `db:"..."` tags, so callers don't write
+// (and get wrong) their own rows.Next / rows.Scan / rows.Err loops.
+package sqlscan
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned by QueryOne when the query yields no rows. It
+// wraps sql.ErrNoRows, so errors.Is(err, sql.ErrNoRows) is true as well.
+var ErrNotFound = fmt.Errorf("sqlscan: no rows: %w", sql.ErrNoRows)
+
+// Querier runs a query; *sql.DB, *sql.Tx and *sql.Conn all provide it.
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// Execer runs a statement that returns no rows.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// QueryAll returns every row of the query as a T. The result is never nil,
+// so it encodes as [] in JSON.
+func QueryAll[T any](ctx context.Context, q Querier, query string, args ...any) ([]T, error) {
+	all := []T{}
+	err := Each(ctx, q, query, func(v T) error {
+		all = append(all, v)
+		return nil
+	}, args...)
+	if err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+// QueryOne returns the first row of the query as a T, or ErrNotFound when
+// there is none. Further rows are ignored, as with QueryRowContext.
+func QueryOne[T any](ctx context.Context, q Querier, query string, args ...any) (T, error) {
+	var v T
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return v, err
+	}
+	defer rows.Close()
+
+	dest, err := plan[T](rows)
+	if err != nil {
+		return v, err
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return v, err
+		}
+		return v, ErrNotFound
+	}
+	if err := rows.Scan(dest(&v)...); err != nil {
+		return v, err
+	}
+	return v, rows.Close()
+}
+
+// Each calls fn with every row of the query while the rows are being read,
+// so a large result is never held in memory. An error from fn stops the
+// iteration and is returned.
+func Each[T any](ctx context.Context, q Querier, query string, fn func(T) error, args ...any) error {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	dest, err := plan[T](rows)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var v T
+		if err := rows.Scan(dest(&v)...); err != nil {
+			return err
+		}
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Exec runs a statement and returns the number of rows it affected.
+func Exec(ctx context.Context, e Execer, query string, args ...any) (int64, error) {
+	res, err := e.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// plan matches the columns of rows to the fields of T once per query and
+// returns a function giving the Scan destinations inside one T.
+//
+// A T that is not a struct (int, string, time.Time, a sql.Scanner such as
+// sql.NullString) receives the only column directly.
+func plan[T any](rows *sql.Rows) (func(*T) []any, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	t := reflect.TypeFor[T]()
+	if !isStruct(t) {
+		if len(cols) != 1 {
+			return nil, fmt.Errorf("sqlscan: scanning %d columns into %s, which is not a struct", len(cols), t)
+		}
+		return func(v *T) []any { return []any{v} }, nil
+	}
+
+	fields := fieldsOf(t)
+	paths := make([][]int, len(cols))
+	for i, c := range cols {
+		f, ok := fields[strings.ToLower(c)]
+		if !ok {
+			return nil, fmt.Errorf("sqlscan: column %q has no field in %s (add a `db:\"%s\"` tag)", c, t, strings.ToLower(c))
+		}
+		paths[i] = f
+	}
+	return func(v *T) []any {
+		root := reflect.ValueOf(v).Elem()
+		dest := make([]any, len(paths))
+		for i, path := range paths {
+			dest[i] = field(root, path).Addr().Interface()
+		}
+		return dest
+	}, nil
+}
+
+// field follows path from v like FieldByIndex, allocating nil embedded
+// struct pointers on the way.
+func field(v reflect.Value, path []int) reflect.Value {
+	for i, x := range path {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+var (
+	scannerType = reflect.TypeFor[sql.Scanner]()
+	timeType    = reflect.TypeFor[time.Time]()
+)
+
+// isStruct reports whether t is scanned field by field rather than as one
+// value.
+func isStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != timeType && !reflect.PointerTo(t).Implements(scannerType)
+}
+
+// cache holds the column → field index map of every struct type seen, so
+// reflection over the type happens once per type, not once per query.
+var cache sync.Map // reflect.Type → map[string][]int
+
+func fieldsOf(t reflect.Type) map[string][]int {
+	if m, ok := cache.Load(t); ok {
+		return m.(map[string][]int)
+	}
+	m := map[string][]int{}
+	collect(t, nil, m)
+	actual, _ := cache.LoadOrStore(t, m)
+	return actual.(map[string][]int)
+}
+
+// collect adds the fields of t to m. The column name is the db tag or, for
+// untagged fields, the lower-cased field name; db:"-" skips a field.
+// Untagged embedded structs are flattened, and as with Go's own field
+// promotion a shallower field wins over a deeper one of the same name.
+func collect(t reflect.Type, index []int, m map[string][]int) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag, _, _ := strings.Cut(f.Tag.Get("db"), ",")
+		if tag == "-" {
+			continue
+		}
+		path := append(index[:len(index):len(index)], i)
+
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && tag == "" && isStruct(ft) {
+			// An unexported embedded pointer cannot be allocated.
+			if f.IsExported() || f.Type.Kind() != reflect.Pointer {
+				collect(ft, path, m)
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		name := tag
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		if prev, ok := m[name]; !ok || len(path) < len(prev) {
+			m[name] = path
+		}
+	}
+}
+
+/*
+🧠 GENERIC ROW SCANNING (shared/sqlscan/sqlscan.go)
+
+✅ What Happens Here:
+- `QueryAll[User]`, `QueryOne[User]` and `Each[User]` run a query and fill one `User` per row; `Exec` returns the rows affected.
+- Columns are matched to fields by `db:"created_at"` tags (or the lower-cased field name), case-insensitively.
+- The column → field map of each struct type is built once with reflection and cached.
+- Embedded structs are flattened; `sql.NullString`, `*time.Time` and other nullable fields take NULL.
+- An empty `QueryOne` is `ErrNotFound`, which still matches `sql.ErrNoRows`.
+
+✅ Why This Matters:
+- A hand-written loop has to remember `defer rows.Close()`, check `rows.Scan`'s error, and call `rows.Err()` at the end; forgetting the last one silently returns half a result when the connection drops.
+- A column added to the SELECT but not to the struct is a clear error (`column "x" has no field`), not a shifted Scan argument list.
+
+✅ Key Concepts:
+| Concept                     | Explanation |
+|-----------------------------|-------------|
+| Type parameters (`[T any]`) | One helper for every row type, checked at compile time |
+| `reflect.TypeFor[T]()`      | The struct type to inspect, without a value |
+| `sync.Map` cache            | Reflection cost is paid once per type |
+| `**time.Time` destination   | `database/sql` sets the pointer to nil for NULL, or allocates it |
+*/
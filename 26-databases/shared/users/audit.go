@@ -0,0 +1,139 @@
+package users
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"learn-go-with-cyber-mountain-man/26-databases/shared/sqlscan"
+)
+
+// Audit actions recorded in audit_log.action.
+const (
+	ActionCreate  = "create"
+	ActionImport  = "import"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+	ActionPurge   = "purge"
+)
+
+// EntityUser is the audit_log.entity value for rows of the users table.
+const EntityUser = "user"
+
+// SystemActor is recorded when a change is made without an actor in the
+// context (setup tools, tests).
+const SystemActor = "system"
+
+// AuditEntry is a row of audit_log. Before is null for creates and After
+// is null for purges.
+type AuditEntry struct {
+	ID        int64           `json:"id" db:"id"`
+	Actor     string          `json:"actor" db:"actor"`
+	Action    string          `json:"action" db:"action"`
+	Entity    string          `json:"entity" db:"entity"`
+	EntityID  int             `json:"entity_id" db:"entity_id"`
+	Before    json.RawMessage `json:"before" db:"-"`
+	After     json.RawMessage `json:"after" db:"-"`
+	RequestID string          `json:"request_id,omitempty" db:"-"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
+
+// auditRow is an audit_log row as scanned: the nullable columns land in
+// sql.NullString and are copied into the embedded entry afterwards.
+type auditRow struct {
+	AuditEntry
+	BeforeJSON sql.NullString `db:"before_json"`
+	AfterJSON  sql.NullString `db:"after_json"`
+	RequestID  sql.NullString `db:"request_id"`
+}
+
+func (r auditRow) entry() AuditEntry {
+	e := r.AuditEntry
+	if r.BeforeJSON.Valid {
+		e.Before = json.RawMessage(r.BeforeJSON.String)
+	}
+	if r.AfterJSON.Valid {
+		e.After = json.RawMessage(r.AfterJSON.String)
+	}
+	e.RequestID = r.RequestID.String
+	return e
+}
+
+type (
+	actorKey     struct{}
+	requestIDKey struct{}
+)
+
+// WithActor records who is making the changes done with ctx, e.g. the name
+// of the API key or the logged-in user.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor set by WithActor, or SystemActor.
+func ActorFrom(ctx context.Context) string {
+	if a, ok := ctx.Value(actorKey{}).(string); ok && a != "" {
+		return a
+	}
+	return SystemActor
+}
+
+// WithRequestID attaches the ID of the HTTP request that caused the changes.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFrom returns the ID set by WithRequestID, or "".
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// audit writes one audit_log row through ex, which is the transaction that
+// made the change, so the entry commits or rolls back with it.
+func (s *Store) audit(ctx context.Context, ex execer, action string, id int, before, after *User) error {
+	b, err := snapshot(before)
+	if err != nil {
+		return err
+	}
+	a, err := snapshot(after)
+	if err != nil {
+		return err
+	}
+	reqID := sql.NullString{String: RequestIDFrom(ctx), Valid: RequestIDFrom(ctx) != ""}
+
+	q := Rebind(s.dialect, `INSERT INTO audit_log (actor, action, entity, entity_id, before_json, after_json, request_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, `+s.dialect.Now()+`)`)
+	_, err = ex.ExecContext(ctx, q, ActorFrom(ctx), action, EntityUser, id, b, a, reqID)
+	return err
+}
+
+func snapshot(u *User) (sql.NullString, error) {
+	if u == nil {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(u)
+	return sql.NullString{String: string(data), Valid: true}, err
+}
+
+// AuditLog returns the audit history of one user, oldest first. Entries
+// outlive the user, so a purged ID still has its history.
+func (s *Store) AuditLog(ctx context.Context, userID int, p Page) ([]AuditEntry, error) {
+	q := Rebind(s.dialect, `SELECT id, actor, action, entity, entity_id, before_json, after_json, request_id, created_at
+		FROM audit_log WHERE entity = ? AND entity_id = ? ORDER BY id`)
+	if p.Limit > 0 || p.Offset > 0 {
+		q += " " + s.dialect.Paginate(p.Limit, p.Offset)
+	}
+
+	entries := []AuditEntry{}
+	err := sqlscan.Each(ctx, s.db, q, func(r auditRow) error {
+		entries = append(entries, r.entry())
+		return nil
+	}, EntityUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,142 @@
+// Package audithttp connects HTTP requests to the users audit trail and
+// serves the admin views of it:
+//
+//	GET /admin/users/{id}/audit   audit history of one user
+//	GET /admin/users/deleted      users in the trash
+package audithttp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"learn-go-with-cyber-mountain-man/26-databases/shared/apikey"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/dbctx"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/users"
+)
+
+// RequestIDHeader carries the request ID in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+// Middleware stamps the request context with the audit actor (the API key
+// principal set by apikey.Middleware) and a request ID. An incoming
+// X-Request-ID from a proxy is reused; otherwise one is generated. The ID
+// is echoed in the response so clients can quote it.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor := apikey.Anonymous.Name
+		if p, ok := apikey.FromContext(r.Context()); ok {
+			actor = p.Name
+		}
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		ctx := users.WithRequestID(users.WithActor(r.Context(), actor), id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// validRequestID accepts short printable-ASCII IDs so a client cannot
+// stuff arbitrary data into the audit log.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 100 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// History handles GET /admin/users/{id}/audit. It accepts ?limit= and
+// ?offset= and also works for purged users, whose history is kept.
+func History(store *users.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+		page, err := users.ParsePage(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := dbctx.WithTimeout(r)
+		defer cancel()
+
+		entries, err := store.AuditLog(ctx, id, page)
+		if err != nil {
+			dbctx.Error(w, ctx, err, "Error fetching audit history")
+			return
+		}
+		if len(entries) == 0 && page.Offset == 0 {
+			// No history at all means the user never existed.
+			if _, err := store.Get(ctx, id); errors.Is(err, users.ErrNotFound) {
+				http.Error(w, "No audit history for this user", http.StatusNotFound)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(entries)
+	}
+}
+
+// Deleted handles GET /admin/users/deleted, the list of trashed users that
+// can still be restored.
+func Deleted(store *users.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page, err := users.ParsePage(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := dbctx.WithTimeout(r)
+		defer cancel()
+
+		list, err := store.ListDeleted(ctx, page)
+		if err != nil {
+			dbctx.Error(w, ctx, err, "Error fetching deleted users")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	}
+}
+
+/*
+🧠 AUDIT TRAIL OVER HTTP (shared/users/audithttp)
+
+✅ What Happens Here:
+- `Middleware` turns "who is calling" (the API key name) and an `X-Request-ID` into context values the users store writes into `audit_log`.
+- `History` lists every create / update / delete / restore / import / purge of one user with before and after JSON.
+- `Deleted` lists the trash, so an admin can find the ID to pass to `POST /users/{id}/restore`.
+
+✅ Why This Matters:
+- The audit entry is written in the same transaction as the change: if one fails, neither is saved.
+- A request ID in both the response header and the audit row ties a support ticket to the exact change.
+
+✅ Key Concepts:
+| Concept                | Explanation |
+|------------------------|-------------|
+| `users.WithActor`      | Passes the actor down to the data layer without extra parameters |
+| `X-Request-ID`         | Reused from a proxy when present, generated otherwise |
+| `r.PathValue("id")`    | Route parameter set by chi (and by the standard `http.ServeMux`) |
+| Soft delete            | `deleted_at` hides a row without losing it |
+*/
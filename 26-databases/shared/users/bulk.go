@@ -0,0 +1,449 @@
+package users
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"learn-go-with-cyber-mountain-man/26-databases/shared/sqlscan"
+)
+
+// ---------------------------------------------------------------------------
+// Import
+// ---------------------------------------------------------------------------
+
+// ImportMode decides what happens when some rows cannot be inserted.
+type ImportMode string
+
+const (
+	// AllOrNothing commits only if every row is valid and inserted.
+	AllOrNothing ImportMode = "all-or-nothing"
+	// BestEffort inserts the good rows and reports the bad ones.
+	BestEffort ImportMode = "best-effort"
+)
+
+// ParseImportMode accepts the mode names above; "" means AllOrNothing.
+func ParseImportMode(s string) (ImportMode, error) {
+	switch ImportMode(strings.ToLower(s)) {
+	case "", AllOrNothing, "atomic":
+		return AllOrNothing, nil
+	case BestEffort, "partial":
+		return BestEffort, nil
+	}
+	return "", fmt.Errorf("unknown import mode %q (want %s or %s)", s, AllOrNothing, BestEffort)
+}
+
+// Limits applied to imported rows. The column sizes match the SQL Server
+// schema (NVARCHAR(100)), the strictest of the three engines.
+const (
+	MaxNameLength  = 100
+	MaxEmailLength = 100
+	MaxImportRows  = 100_000
+)
+
+// ImportRow is one record read from an import file. Line is the 1-based
+// line in the source (the CSV header is line 1). Err is set when the
+// record itself could not be parsed.
+type ImportRow struct {
+	Line int
+	User User
+	Err  error
+}
+
+// RowError describes why one row was rejected.
+type RowError struct {
+	Line  int    `json:"line"`
+	Email string `json:"email,omitempty"`
+	Field string `json:"field,omitempty"`
+	Error string `json:"error"`
+}
+
+// ImportReport is the result of an import.
+type ImportReport struct {
+	Mode      ImportMode `json:"mode"`
+	Total     int        `json:"total"`
+	Inserted  int        `json:"inserted"`
+	Failed    int        `json:"failed"`
+	Committed bool       `json:"committed"`
+	Errors    []RowError `json:"errors"`
+}
+
+func (r *ImportReport) reject(row ImportRow, field, msg string) {
+	r.Failed++
+	r.Errors = append(r.Errors, RowError{Line: row.Line, Email: row.User.Email, Field: field, Error: msg})
+}
+
+// ReadCSV parses a CSV file with a header row. The name and email columns
+// are required and matched case-insensitively; other columns (such as id
+// and created_at from an export) are ignored, so exports re-import as is.
+func ReadCSV(r io.Reader) ([]ImportRow, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read CSV header: %w", err)
+	}
+	nameCol, emailCol := -1, -1
+	for i, h := range header {
+		switch strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))) {
+		case "name":
+			nameCol = i
+		case "email":
+			emailCol = i
+		}
+	}
+	if nameCol < 0 || emailCol < 0 {
+		return nil, errors.New(`CSV header must contain "name" and "email" columns`)
+	}
+
+	var rows []ImportRow
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			var pe *csv.ParseError
+			if !errors.As(err, &pe) {
+				return nil, err
+			}
+			rows = append(rows, ImportRow{Line: pe.StartLine, Err: err})
+		} else if line, _ := cr.FieldPos(0); len(rec) <= max(nameCol, emailCol) {
+			rows = append(rows, ImportRow{Line: line, Err: fmt.Errorf("expected at least %d fields, got %d", max(nameCol, emailCol)+1, len(rec))})
+		} else {
+			rows = append(rows, ImportRow{Line: line, User: User{Name: rec[nameCol], Email: rec[emailCol]}})
+		}
+		if len(rows) > MaxImportRows {
+			return nil, fmt.Errorf("import is limited to %d rows", MaxImportRows)
+		}
+	}
+}
+
+// ReadNDJSON parses one JSON object per line ({"name": ..., "email": ...}).
+// Blank lines are skipped; a malformed line is reported and does not
+// affect the lines after it.
+func ReadNDJSON(r io.Reader) ([]ImportRow, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20) // allow lines up to 1 MiB
+	var rows []ImportRow
+	for line := 1; sc.Scan(); line++ {
+		text := bytes.TrimSpace(sc.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var u User
+		if err := json.Unmarshal(text, &u); err != nil {
+			rows = append(rows, ImportRow{Line: line, Err: err})
+		} else {
+			rows = append(rows, ImportRow{Line: line, User: User{Name: u.Name, Email: u.Email}})
+		}
+		if len(rows) > MaxImportRows {
+			return nil, fmt.Errorf("import is limited to %d rows", MaxImportRows)
+		}
+	}
+	return rows, sc.Err()
+}
+
+// validate checks every row and returns the report so far plus the rows
+// that may be inserted. Emails are compared case-insensitively, both within
+// the file and (later, by the unique constraint) against the table.
+func validate(rows []ImportRow, mode ImportMode) (ImportReport, []ImportRow) {
+	report := ImportReport{Mode: mode, Total: len(rows), Errors: []RowError{}}
+	seen := make(map[string]int, len(rows))
+	valid := make([]ImportRow, 0, len(rows))
+
+	for _, row := range rows {
+		row.User.Name = strings.TrimSpace(row.User.Name)
+		row.User.Email = strings.TrimSpace(row.User.Email)
+		u := row.User
+
+		switch {
+		case row.Err != nil:
+			report.reject(row, "", row.Err.Error())
+		case u.Name == "":
+			report.reject(row, "name", "name is required")
+		case utf8.RuneCountInString(u.Name) > MaxNameLength:
+			report.reject(row, "name", fmt.Sprintf("name is longer than %d characters", MaxNameLength))
+		case u.Email == "":
+			report.reject(row, "email", "email is required")
+		case utf8.RuneCountInString(u.Email) > MaxEmailLength:
+			report.reject(row, "email", fmt.Sprintf("email is longer than %d characters", MaxEmailLength))
+		case !validEmail(u.Email):
+			report.reject(row, "email", "email is not a valid address")
+		default:
+			key := strings.ToLower(u.Email)
+			if first, dup := seen[key]; dup {
+				report.reject(row, "email", fmt.Sprintf("duplicate of line %d", first))
+				continue
+			}
+			seen[key] = row.Line
+			valid = append(valid, row)
+		}
+	}
+	return report, valid
+}
+
+// validEmail accepts a bare address such as "ada@example.com" (no display
+// name, no angle brackets).
+func validEmail(s string) bool {
+	a, err := mail.ParseAddress(s)
+	return err == nil && a.Address == s && strings.Contains(s[strings.LastIndexByte(s, '@'):], ".")
+}
+
+// Import validates rows and inserts them in one transaction through a
+// prepared INSERT (the same statement Create uses). Each inserted row gets
+// an "import" audit entry in the same transaction.
+//
+// In AllOrNothing mode any invalid or conflicting row rolls everything
+// back. In BestEffort mode each row runs inside a savepoint, so a row that
+// violates a constraint is undone and reported while the rest commit.
+//
+// Row problems are returned in the report; the error is reserved for
+// failures of the import as a whole (lost connection, cancelled context).
+func (s *Store) Import(ctx context.Context, rows []ImportRow, mode ImportMode) (ImportReport, error) {
+	report, err := s.importRows(ctx, rows, mode)
+	// Validation errors are found before insert errors; report in file order.
+	slices.SortStableFunc(report.Errors, func(a, b RowError) int { return cmp.Compare(a.Line, b.Line) })
+	return report, err
+}
+
+func (s *Store) importRows(ctx context.Context, rows []ImportRow, mode ImportMode) (ImportReport, error) {
+	report, valid := validate(rows, mode)
+	if mode == AllOrNothing && report.Failed > 0 {
+		return report, nil
+	}
+	if len(valid) == 0 {
+		report.Committed = true // nothing to do, nothing to undo
+		return report, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return report, err
+	}
+	defer tx.Rollback() // no-op after Commit
+
+	q := s.insertQuery()
+	stmt, err := tx.PrepareContext(ctx, q)
+	if err != nil {
+		return report, err
+	}
+	defer stmt.Close()
+	ins := preparedInsert{stmt: stmt, tx: tx, query: q}
+
+	d := s.dialect
+	const sp = "import_row"
+	inserted := 0
+	for _, row := range valid {
+		if mode == BestEffort {
+			if _, err := tx.ExecContext(ctx, d.Savepoint(sp)); err != nil {
+				return report, err
+			}
+		}
+
+		u := row.User
+		err := s.insert(ctx, ins, q, &u)
+		if err == nil {
+			if err := s.audit(ctx, tx, ActionImport, u.ID, nil, &u); err != nil {
+				return report, err
+			}
+			inserted++
+			if mode == BestEffort && d.Release(sp) != "" {
+				if _, err := tx.ExecContext(ctx, d.Release(sp)); err != nil {
+					return report, err
+				}
+			}
+			continue
+		}
+
+		ce := s.constraintError(err)
+		if ce == nil {
+			return report, err // not the row's fault; abort the whole import
+		}
+		report.reject(row, ce.Field, ce.Message())
+		if mode == AllOrNothing {
+			return report, nil // deferred Rollback undoes earlier rows
+		}
+		if _, err := tx.ExecContext(ctx, d.RollbackTo(sp)); err != nil {
+			return report, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return report, err
+	}
+	report.Inserted, report.Committed = inserted, true
+	return report, nil
+}
+
+// preparedInsert sends the prepared INSERT through its *sql.Stmt and any
+// other query (the follow-up SELECT for LastInsertID) through the tx.
+type preparedInsert struct {
+	stmt  *sql.Stmt
+	tx    *sql.Tx
+	query string
+}
+
+func (p preparedInsert) ExecContext(ctx context.Context, q string, args ...any) (sql.Result, error) {
+	if q == p.query {
+		return p.stmt.ExecContext(ctx, args...)
+	}
+	return p.tx.ExecContext(ctx, q, args...)
+}
+
+func (p preparedInsert) QueryContext(ctx context.Context, q string, args ...any) (*sql.Rows, error) {
+	if q == p.query {
+		return p.stmt.QueryContext(ctx, args...)
+	}
+	return p.tx.QueryContext(ctx, q, args...)
+}
+
+func (p preparedInsert) QueryRowContext(ctx context.Context, q string, args ...any) *sql.Row {
+	if q == p.query {
+		return p.stmt.QueryRowContext(ctx, args...)
+	}
+	return p.tx.QueryRowContext(ctx, q, args...)
+}
+
+// ---------------------------------------------------------------------------
+// Export
+// ---------------------------------------------------------------------------
+
+// Each calls fn for every user (trashed ones excluded) in ID order while the rows are still being
+// read, so the table is never held in memory. Returning an error from fn
+// stops the iteration and returns that error.
+func (s *Store) Each(ctx context.Context, fn func(User) error) error {
+	return sqlscan.Each(ctx, s.db, selectColumns+` WHERE`+active+` ORDER BY id`, fn)
+}
+
+// ExportFormats lists the formats NewExportWriter accepts.
+var ExportFormats = []string{"csv", "ndjson", "json"}
+
+// ExportWriter writes users one at a time in a streaming format.
+type ExportWriter interface {
+	Write(User) error
+	// Close writes any trailer (e.g. the closing ] of a JSON array) and
+	// flushes buffered output. It does not close the underlying writer.
+	Close() error
+	// Flush pushes buffered rows to the underlying writer.
+	Flush() error
+	// ContentType is the MIME type of the output.
+	ContentType() string
+}
+
+// NewExportWriter returns a writer for format ("csv", "ndjson" or "json").
+func NewExportWriter(w io.Writer, format string) (ExportWriter, error) {
+	switch strings.ToLower(format) {
+	case "", "csv":
+		cw := csv.NewWriter(w)
+		return &csvExport{w: cw}, nil
+	case "ndjson", "jsonl":
+		return &ndjsonExport{enc: json.NewEncoder(w)}, nil
+	case "json":
+		return &jsonExport{w: w}, nil
+	}
+	return nil, fmt.Errorf("unknown export format %q (want %s)", format, strings.Join(ExportFormats, ", "))
+}
+
+const timeFormat = "2006-01-02T15:04:05Z07:00"
+
+type csvExport struct {
+	w      *csv.Writer
+	header bool
+}
+
+func (e *csvExport) ContentType() string { return "text/csv; charset=utf-8" }
+
+func (e *csvExport) Write(u User) error {
+	if !e.header {
+		e.header = true
+		if err := e.w.Write([]string{"id", "name", "email", "created_at"}); err != nil {
+			return err
+		}
+	}
+	return e.w.Write([]string{fmt.Sprint(u.ID), u.Name, u.Email, u.CreatedAt.UTC().Format(timeFormat)})
+}
+
+func (e *csvExport) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvExport) Close() error {
+	if !e.header { // empty table: still emit the header
+		e.header = true
+		e.w.Write([]string{"id", "name", "email", "created_at"})
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type ndjsonExport struct{ enc *json.Encoder }
+
+func (e *ndjsonExport) ContentType() string { return "application/x-ndjson" }
+func (e *ndjsonExport) Write(u User) error  { return e.enc.Encode(u) }
+func (e *ndjsonExport) Flush() error        { return nil }
+func (e *ndjsonExport) Close() error        { return nil }
+
+type jsonExport struct {
+	w io.Writer
+	n int
+}
+
+func (e *jsonExport) ContentType() string { return "application/json" }
+
+func (e *jsonExport) Write(u User) error {
+	b, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	sep := ",\n"
+	if e.n == 0 {
+		sep = "[\n"
+	}
+	e.n++
+	if _, err := io.WriteString(e.w, sep); err != nil {
+		return err
+	}
+	_, err = e.w.Write(b)
+	return err
+}
+
+func (e *jsonExport) Flush() error { return nil }
+
+func (e *jsonExport) Close() error {
+	end := "\n]\n"
+	if e.n == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(e.w, end)
+	return err
+}
+
+// Export streams every user to w in the given format.
+func (s *Store) Export(ctx context.Context, w io.Writer, format string) error {
+	ew, err := NewExportWriter(w, format)
+	if err != nil {
+		return err
+	}
+	if err := s.Each(ctx, ew.Write); err != nil {
+		return err
+	}
+	return ew.Close()
+}
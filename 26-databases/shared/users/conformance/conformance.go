@@ -0,0 +1,602 @@
+// Package conformance checks that a users.Store behaves the same on every
+// database engine. It only touches rows it creates and purges them
+// afterwards, so it can run against a shared development database, but a
+// scratch database is still the better target: audit_log entries for those
+// rows are kept.
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"strings"
+	"time"
+
+	"learn-go-with-cyber-mountain-man/26-databases/shared/users"
+)
+
+// Result is the outcome of one check.
+type Result struct {
+	Name     string
+	Err      error
+	Duration time.Duration
+}
+
+// Passed reports whether the check succeeded.
+func (r Result) Passed() bool { return r.Err == nil }
+
+type check struct {
+	name string
+	run  func(ctx context.Context, t *run) error
+}
+
+// run carries state between checks: the users they created and a random
+// tag that keeps emails unique across runs.
+type run struct {
+	store   *users.Store
+	tag     string
+	created []int
+}
+
+// Run migrates the schema and executes every check in order. Rows created
+// along the way are deleted before it returns.
+func Run(ctx context.Context, s *users.Store) []Result {
+	t := &run{store: s, tag: fmt.Sprintf("%08x", rand.Uint32())}
+	defer t.cleanup()
+
+	results := make([]Result, 0, len(checks)+1)
+	start := time.Now()
+	if err := s.Migrate(ctx); err != nil {
+		// Nothing else can pass without a table.
+		return append(results, Result{Name: "migrate", Err: err, Duration: time.Since(start)})
+	}
+	results = append(results, Result{Name: "migrate", Duration: time.Since(start)})
+	// Running it twice must be harmless.
+	start = time.Now()
+	results = append(results, Result{Name: "migrate is idempotent", Err: s.Migrate(ctx), Duration: time.Since(start)})
+
+	for _, c := range checks {
+		start := time.Now()
+		err := c.run(ctx, t)
+		results = append(results, Result{Name: c.name, Err: err, Duration: time.Since(start)})
+	}
+	return results
+}
+
+func (t *run) email(local string) string {
+	return fmt.Sprintf("%s+%s@conformance.test", local, t.tag)
+}
+
+func (t *run) create(ctx context.Context, name, local string) (users.User, error) {
+	u := users.User{Name: name, Email: t.email(local)}
+	if err := t.store.Create(ctx, &u); err != nil {
+		return u, err
+	}
+	t.created = append(t.created, u.ID)
+	return u, nil
+}
+
+// cleanup purges every row carrying this run's tag, including rows
+// inserted by Import, whose IDs are not reported, and rows in the trash.
+// Their audit entries stay behind, as they would for any purged user.
+func (t *run) cleanup() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	suffix := "+" + t.tag + "@conformance.test"
+	live, _ := t.store.List(ctx, users.Page{})
+	trash, _ := t.store.ListDeleted(ctx, users.Page{})
+	for _, u := range append(live, trash...) {
+		if strings.HasSuffix(u.Email, suffix) {
+			t.store.Purge(ctx, u.ID)
+		}
+	}
+}
+
+// importRows builds ImportRows for the given local parts of emails.
+func (t *run) importRows(locals ...string) []users.ImportRow {
+	rows := make([]users.ImportRow, len(locals))
+	for i, l := range locals {
+		rows[i] = users.ImportRow{Line: i + 2, User: users.User{Name: "Import " + l, Email: t.email(l)}}
+	}
+	return rows
+}
+
+func (t *run) exists(ctx context.Context, local string) (bool, error) {
+	all, err := t.store.List(ctx, users.Page{})
+	if err != nil {
+		return false, err
+	}
+	for _, u := range all {
+		if u.Email == t.email(local) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+var checks = []check{
+	{"create returns id and created_at", func(ctx context.Context, t *run) error {
+		before := time.Now().Add(-time.Minute) // allow for clock skew with the server
+		u, err := t.create(ctx, "Ada", "ada")
+		if err != nil {
+			return err
+		}
+		if u.ID <= 0 {
+			return fmt.Errorf("got id %d, want > 0", u.ID)
+		}
+		if u.CreatedAt.Before(before) || u.CreatedAt.After(time.Now().Add(time.Minute)) {
+			return fmt.Errorf("created_at %s is not close to now", u.CreatedAt)
+		}
+		return nil
+	}},
+
+	{"get returns the stored row", func(ctx context.Context, t *run) error {
+		want, err := t.create(ctx, "Grace", "grace")
+		if err != nil {
+			return err
+		}
+		got, err := t.store.Get(ctx, want.ID)
+		if err != nil {
+			return err
+		}
+		if got.ID != want.ID || got.Name != want.Name || got.Email != want.Email {
+			return fmt.Errorf("got %+v, want %+v", got, want)
+		}
+		if !got.CreatedAt.Equal(want.CreatedAt) && got.CreatedAt.Sub(want.CreatedAt).Abs() > time.Second {
+			return fmt.Errorf("created_at changed between create (%s) and get (%s)", want.CreatedAt, got.CreatedAt)
+		}
+		return nil
+	}},
+
+	{"get of a missing id is ErrNotFound", func(ctx context.Context, t *run) error {
+		_, err := t.store.Get(ctx, -1)
+		if !errors.Is(err, users.ErrNotFound) {
+			return fmt.Errorf("got %v, want ErrNotFound", err)
+		}
+		return nil
+	}},
+
+	{"unicode round-trips", func(ctx context.Context, t *run) error {
+		want, err := t.create(ctx, "Zoë Ångström 李", "zoe")
+		if err != nil {
+			return err
+		}
+		got, err := t.store.Get(ctx, want.ID)
+		if err != nil {
+			return err
+		}
+		if got.Name != want.Name {
+			return fmt.Errorf("got name %q, want %q", got.Name, want.Name)
+		}
+		return nil
+	}},
+
+	{"duplicate email is ErrDuplicate", func(ctx context.Context, t *run) error {
+		if _, err := t.create(ctx, "First", "dup"); err != nil {
+			return err
+		}
+		_, err := t.create(ctx, "Second", "dup")
+		if !errors.Is(err, users.ErrDuplicate) {
+			return fmt.Errorf("got %v, want ErrDuplicate", err)
+		}
+		var ce *users.ConstraintError
+		if !errors.As(err, &ce) || ce.Field != "email" {
+			return fmt.Errorf("got %#v, want a ConstraintError on email", err)
+		}
+		return nil
+	}},
+
+	{"empty name or bad email is ErrConstraint on the field", func(ctx context.Context, t *run) error {
+		for _, c := range []struct{ name, email, field string }{
+			{"", t.email("noname"), "name"},
+			{"No At", "no-at-sign-" + t.tag, "email"},
+		} {
+			u := users.User{Name: c.name, Email: c.email}
+			err := t.store.Create(ctx, &u)
+			if err == nil {
+				t.store.Purge(ctx, u.ID) // the tag-based cleanup would miss "no-at-sign"
+			}
+			var ce *users.ConstraintError
+			if !errors.Is(err, users.ErrConstraint) || !errors.As(err, &ce) || ce.Field != c.field {
+				return fmt.Errorf("name %q, email %q: got %v, want ErrConstraint on %s", c.name, c.email, err, c.field)
+			}
+		}
+		return nil
+	}},
+
+	{"list is ordered by id", func(ctx context.Context, t *run) error {
+		all, err := t.store.List(ctx, users.Page{})
+		if err != nil {
+			return err
+		}
+		for i := 1; i < len(all); i++ {
+			if all[i-1].ID >= all[i].ID {
+				return fmt.Errorf("ids %d and %d out of order", all[i-1].ID, all[i].ID)
+			}
+		}
+		seen := make(map[int]bool, len(all))
+		for _, u := range all {
+			seen[u.ID] = true
+		}
+		for _, id := range t.created {
+			if !seen[id] {
+				return fmt.Errorf("created id %d missing from list", id)
+			}
+		}
+		return nil
+	}},
+
+	{"pagination matches slices of the full list", func(ctx context.Context, t *run) error {
+		for i := range 3 {
+			if _, err := t.create(ctx, fmt.Sprintf("Page %d", i), fmt.Sprintf("page%d", i)); err != nil {
+				return err
+			}
+		}
+		all, err := t.store.List(ctx, users.Page{})
+		if err != nil {
+			return err
+		}
+		for _, p := range []users.Page{{Limit: 2}, {Limit: 2, Offset: 1}, {Limit: 1, Offset: len(all) - 1}, {Offset: 2}, {Limit: 5, Offset: len(all)}} {
+			got, err := t.store.List(ctx, p)
+			if err != nil {
+				return fmt.Errorf("%+v: %w", p, err)
+			}
+			want := window(all, p)
+			if !sameIDs(got, want) {
+				return fmt.Errorf("%+v: got ids %v, want %v", p, ids(got), ids(want))
+			}
+		}
+		return nil
+	}},
+
+	{"empty page is [] not nil", func(ctx context.Context, t *run) error {
+		got, err := t.store.List(ctx, users.Page{Limit: 1, Offset: 1 << 30})
+		if err != nil {
+			return err
+		}
+		if got == nil {
+			return errors.New("got nil slice")
+		}
+		return nil
+	}},
+
+	{"update changes name and email", func(ctx context.Context, t *run) error {
+		u, err := t.create(ctx, "Before", "before")
+		if err != nil {
+			return err
+		}
+		u.Name, u.Email = "After", t.email("after")
+		if _, err := t.store.Update(ctx, u); err != nil {
+			return err
+		}
+		got, err := t.store.Get(ctx, u.ID)
+		if err != nil {
+			return err
+		}
+		if got.Name != u.Name || got.Email != u.Email {
+			return fmt.Errorf("got %+v, want %+v", got, u)
+		}
+		return nil
+	}},
+
+	{"update to a taken email is ErrDuplicate", func(ctx context.Context, t *run) error {
+		a, err := t.create(ctx, "A", "taken-a")
+		if err != nil {
+			return err
+		}
+		b, err := t.create(ctx, "B", "taken-b")
+		if err != nil {
+			return err
+		}
+		b.Email = a.Email
+		if _, err := t.store.Update(ctx, b); !errors.Is(err, users.ErrDuplicate) {
+			return fmt.Errorf("got %v, want ErrDuplicate", err)
+		}
+		return nil
+	}},
+
+	{"writes bump the version and refuse a stale one", func(ctx context.Context, t *run) error {
+		u, err := t.create(ctx, "V1", "version")
+		if err != nil {
+			return err
+		}
+		if u.Version != 1 {
+			return fmt.Errorf("create: got version %d, want 1", u.Version)
+		}
+		u.Name = "V2"
+		v2, err := t.store.Update(ctx, u)
+		if err != nil {
+			return err
+		}
+		if v2.Version != 2 {
+			return fmt.Errorf("update: got version %d, want 2", v2.Version)
+		}
+		if got, err := t.store.Get(ctx, u.ID); err != nil || got.Version != 2 || got.Name != "V2" {
+			return fmt.Errorf("get after update: got %+v (err=%v)", got, err)
+		}
+		// u still carries version 1: a second editor working from the old read.
+		u.Name = "Lost update"
+		if _, err := t.store.Update(ctx, u); !errors.Is(err, users.ErrVersionMismatch) {
+			return fmt.Errorf("stale update: got %v, want ErrVersionMismatch", err)
+		}
+		if err := t.store.Delete(ctx, u.ID, 1); !errors.Is(err, users.ErrVersionMismatch) {
+			return fmt.Errorf("stale delete: got %v, want ErrVersionMismatch", err)
+		}
+		if err := t.store.Delete(ctx, u.ID, 2); err != nil {
+			return err
+		}
+		if err := t.store.Restore(ctx, u.ID); err != nil {
+			return err
+		}
+		if got, err := t.store.Get(ctx, u.ID); err != nil || got.Version != 4 || got.Name != "V2" {
+			return fmt.Errorf("after delete+restore: got %+v (err=%v), want version 4 named V2", got, err)
+		}
+		return nil
+	}},
+
+	{"delete moves the row to the trash", func(ctx context.Context, t *run) error {
+		u, err := t.create(ctx, "Gone", "gone")
+		if err != nil {
+			return err
+		}
+		if err := t.store.Delete(ctx, u.ID, 0); err != nil {
+			return err
+		}
+		if _, err := t.store.Get(ctx, u.ID); !errors.Is(err, users.ErrNotFound) {
+			return fmt.Errorf("get after delete: got %v, want ErrNotFound", err)
+		}
+		if err := t.store.Delete(ctx, u.ID, 0); !errors.Is(err, users.ErrNotFound) {
+			return fmt.Errorf("second delete: got %v, want ErrNotFound", err)
+		}
+		trash, err := t.store.ListDeleted(ctx, users.Page{})
+		if err != nil {
+			return err
+		}
+		for _, d := range trash {
+			if d.ID == u.ID {
+				if d.DeletedAt == nil {
+					return errors.New("trashed user has no deleted_at")
+				}
+				return nil
+			}
+		}
+		return fmt.Errorf("id %d missing from the trash", u.ID)
+	}},
+
+	{"restore brings a trashed row back", func(ctx context.Context, t *run) error {
+		u, err := t.create(ctx, "Back", "back")
+		if err != nil {
+			return err
+		}
+		if err := t.store.Restore(ctx, u.ID); !errors.Is(err, users.ErrNotFound) {
+			return fmt.Errorf("restore of a live user: got %v, want ErrNotFound", err)
+		}
+		if err := t.store.Delete(ctx, u.ID, 0); err != nil {
+			return err
+		}
+		if err := t.store.Restore(ctx, u.ID); err != nil {
+			return err
+		}
+		got, err := t.store.Get(ctx, u.ID)
+		if err != nil {
+			return err
+		}
+		if got.DeletedAt != nil {
+			return fmt.Errorf("deleted_at still set to %s", got.DeletedAt)
+		}
+		return nil
+	}},
+
+	{"update of a trashed or missing id is ErrNotFound", func(ctx context.Context, t *run) error {
+		u, err := t.create(ctx, "Trashed", "trashed")
+		if err != nil {
+			return err
+		}
+		if err := t.store.Delete(ctx, u.ID, 0); err != nil {
+			return err
+		}
+		for _, id := range []int{u.ID, -1} {
+			if _, err := t.store.Update(ctx, users.User{ID: id, Name: "X", Email: t.email("x")}); !errors.Is(err, users.ErrNotFound) {
+				return fmt.Errorf("id %d: got %v, want ErrNotFound", id, err)
+			}
+		}
+		return nil
+	}},
+
+	{"audit log records each change with actor and request id", func(ctx context.Context, t *run) error {
+		actx := users.WithRequestID(users.WithActor(ctx, "conformance"), "req-"+t.tag)
+		u := users.User{Name: "Audited", Email: t.email("audited")}
+		if err := t.store.Create(actx, &u); err != nil {
+			return err
+		}
+		// A failed update must not leave an entry behind.
+		if _, err := t.create(ctx, "Other", "audited-other"); err != nil {
+			return err
+		}
+		if _, err := t.store.Update(actx, users.User{ID: u.ID, Name: "Audited", Email: t.email("audited-other")}); !errors.Is(err, users.ErrDuplicate) {
+			return fmt.Errorf("conflicting update: got %v, want ErrDuplicate", err)
+		}
+		u.Name = "Audited Again"
+		for _, step := range []func() error{
+			func() error { _, err := t.store.Update(actx, u); return err },
+			func() error { return t.store.Delete(actx, u.ID, 0) },
+			func() error { return t.store.Restore(actx, u.ID) },
+		} {
+			if err := step(); err != nil {
+				return err
+			}
+		}
+
+		log, err := t.store.AuditLog(ctx, u.ID, users.Page{})
+		if err != nil {
+			return err
+		}
+		var actions []string
+		for _, e := range log {
+			actions = append(actions, e.Action)
+			if e.Actor != "conformance" || e.RequestID != "req-"+t.tag || e.Entity != users.EntityUser {
+				return fmt.Errorf("entry %+v lacks actor, request id or entity", e)
+			}
+		}
+		want := []string{users.ActionCreate, users.ActionUpdate, users.ActionDelete, users.ActionRestore}
+		if !reflect.DeepEqual(actions, want) {
+			return fmt.Errorf("got actions %v, want %v", actions, want)
+		}
+
+		var before, after users.User
+		if err := json.Unmarshal(log[1].Before, &before); err != nil {
+			return fmt.Errorf("update before: %w", err)
+		}
+		if err := json.Unmarshal(log[1].After, &after); err != nil {
+			return fmt.Errorf("update after: %w", err)
+		}
+		if before.Name != "Audited" || after.Name != "Audited Again" {
+			return fmt.Errorf("update recorded %q -> %q", before.Name, after.Name)
+		}
+		if log[0].Before != nil || log[2].After == nil {
+			return errors.New("create should have no before, delete should have an after")
+		}
+		if err := json.Unmarshal(log[2].After, &after); err != nil || after.DeletedAt == nil {
+			return fmt.Errorf("delete after has no deleted_at (err=%v)", err)
+		}
+		return nil
+	}},
+
+	{"all-or-nothing import rolls back on a conflict", func(ctx context.Context, t *run) error {
+		if _, err := t.create(ctx, "Existing", "aon-taken"); err != nil {
+			return err
+		}
+		report, err := t.store.Import(ctx, t.importRows("aon-1", "aon-taken", "aon-2"), users.AllOrNothing)
+		if err != nil {
+			return err
+		}
+		if report.Committed || report.Failed != 1 || len(report.Errors) != 1 || report.Errors[0].Line != 3 {
+			return fmt.Errorf("unexpected report %+v", report)
+		}
+		if ok, err := t.exists(ctx, "aon-1"); err != nil || ok {
+			return fmt.Errorf("row before the conflict survived the rollback (err=%v)", err)
+		}
+		return nil
+	}},
+
+	{"best-effort import keeps the good rows", func(ctx context.Context, t *run) error {
+		if _, err := t.create(ctx, "Existing", "be-taken"); err != nil {
+			return err
+		}
+		rows := t.importRows("be-1", "be-taken", "be-2")
+		rows = append(rows, users.ImportRow{Line: 5, User: users.User{Name: "", Email: t.email("be-3")}})
+		report, err := t.store.Import(ctx, rows, users.BestEffort)
+		if err != nil {
+			return err
+		}
+		if !report.Committed || report.Inserted != 2 || report.Failed != 2 {
+			return fmt.Errorf("unexpected report %+v", report)
+		}
+		for _, l := range []string{"be-1", "be-2"} {
+			if ok, err := t.exists(ctx, l); err != nil || !ok {
+				return fmt.Errorf("%s missing after best-effort import (err=%v)", l, err)
+			}
+		}
+		return nil
+	}},
+
+	{"export round-trips through CSV import", func(ctx context.Context, t *run) error {
+		var buf bytes.Buffer
+		if err := t.store.Export(ctx, &buf, "csv"); err != nil {
+			return err
+		}
+		rows, err := users.ReadCSV(&buf)
+		if err != nil {
+			return err
+		}
+		all, err := t.store.List(ctx, users.Page{})
+		if err != nil {
+			return err
+		}
+		if len(rows) != len(all) {
+			return fmt.Errorf("export has %d rows, table has %d", len(rows), len(all))
+		}
+		for i := range rows {
+			if rows[i].User.Email != all[i].Email || rows[i].User.Name != all[i].Name {
+				return fmt.Errorf("row %d: got %+v, want %+v", i, rows[i].User, all[i])
+			}
+		}
+		return nil
+	}},
+
+	{"cancelled context is refused before the query", func(ctx context.Context, t *run) error {
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err := t.store.List(cctx, users.Page{})
+		if !errors.Is(err, context.Canceled) {
+			return fmt.Errorf("got %v, want context.Canceled", err)
+		}
+		return nil
+	}},
+
+	{"deadline stops a query that is already running", func(ctx context.Context, t *run) error {
+		slow, ok := slowQueries[t.store.Dialect().Name()]
+		if !ok {
+			return fmt.Errorf("no slow query for dialect %q", t.store.Dialect().Name())
+		}
+		const deadline = 300 * time.Millisecond
+		cctx, cancel := context.WithTimeout(ctx, deadline)
+		defer cancel()
+
+		start := time.Now()
+		var n int
+		err := t.store.DB().QueryRowContext(cctx, slow).Scan(&n)
+		elapsed := time.Since(start)
+		switch {
+		case err == nil:
+			return fmt.Errorf("slow query finished after %s; want it cut short", elapsed)
+		// Some drivers return the server's "statement cancelled" error
+		// instead of wrapping ctx.Err(); the context says why either way.
+		case !errors.Is(err, context.DeadlineExceeded) && !errors.Is(cctx.Err(), context.DeadlineExceeded):
+			return fmt.Errorf("got %v, want context.DeadlineExceeded", err)
+		case elapsed < deadline:
+			return fmt.Errorf("failed after %s, before the deadline: the query never ran (%v)", elapsed, err)
+		case elapsed > deadline+5*time.Second:
+			return fmt.Errorf("stopped %s after the deadline; want prompt cancellation", elapsed-deadline)
+		}
+		// The cancelled connection must not poison the pool.
+		_, err = t.store.List(ctx, users.Page{Limit: 1})
+		return err
+	}},
+}
+
+// slowQueries run far longer than any deadline in these checks, keyed by
+// Dialect.Name.
+var slowQueries = map[string]string{
+	"sqlite": `WITH RECURSIVE n(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM n WHERE x < 1000000000)
+		SELECT COUNT(*) FROM n`,
+	"postgres": `SELECT 1 FROM pg_sleep(30)`,
+	"mssql":    `WAITFOR DELAY '00:00:30'; SELECT 1`,
+}
+
+func window(all []users.User, p users.Page) []users.User {
+	if p.Offset >= len(all) {
+		return nil
+	}
+	out := all[p.Offset:]
+	if p.Limit > 0 && p.Limit < len(out) {
+		out = out[:p.Limit]
+	}
+	return out
+}
+
+func ids(us []users.User) []int {
+	out := make([]int, len(us))
+	for i, u := range us {
+		out[i] = u.ID
+	}
+	return out
+}
+
+func sameIDs(a, b []users.User) bool {
+	return reflect.DeepEqual(ids(a), ids(b))
+}
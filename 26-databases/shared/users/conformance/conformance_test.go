@@ -0,0 +1,57 @@
+package conformance_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"learn-go-with-cyber-mountain-man/26-databases/shared/users"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/users/conformance"
+	_ "learn-go-with-cyber-mountain-man/26-databases/shared/users/mssql"
+	_ "learn-go-with-cyber-mountain-man/26-databases/shared/users/postgres"
+	_ "learn-go-with-cyber-mountain-man/26-databases/shared/users/sqlite"
+)
+
+func TestSQLite(t *testing.T) {
+	runSuite(t, "sqlite::memory:")
+}
+
+func TestPostgres(t *testing.T) {
+	runSuite(t, dsnFromEnv(t, "USERS_TEST_POSTGRES_DSN"))
+}
+
+func TestMSSQL(t *testing.T) {
+	runSuite(t, dsnFromEnv(t, "USERS_TEST_MSSQL_DSN"))
+}
+
+func dsnFromEnv(t *testing.T, env string) string {
+	dsn := os.Getenv(env)
+	if dsn == "" {
+		t.Skipf("%s not set", env)
+	}
+	return dsn
+}
+
+// runSuite reports every conformance check as a subtest, so
+// `go test -run 'SQLite/duplicate'` picks out one check.
+func runSuite(t *testing.T, dsn string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	store, err := users.Open(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	// The test databases are scratch ones, so the checks that truncate
+	// the tables run too.
+	for _, r := range append(conformance.Run(ctx, store), conformance.RunReset(ctx, store)...) {
+		t.Run(r.Name, func(t *testing.T) {
+			if !r.Passed() {
+				t.Error(r.Err)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package conformance
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"learn-go-with-cyber-mountain-man/26-databases/shared/users"
+)
+
+// RunReset checks Truncate and Load, the pair seed.Reset is built on.
+// Unlike Run it wipes users and audit_log, so point it at a scratch
+// database only; the tables are left empty.
+func RunReset(ctx context.Context, s *users.Store) []Result {
+	t := &run{store: s, tag: "reset"}
+	results := make([]Result, 0, len(resetChecks))
+	if err := s.Migrate(ctx); err != nil {
+		return append(results, Result{Name: "migrate", Err: err})
+	}
+	for _, c := range resetChecks {
+		start := time.Now()
+		err := c.run(ctx, t)
+		results = append(results, Result{Name: c.name, Err: err, Duration: time.Since(start)})
+	}
+	if err := s.Truncate(ctx); err != nil {
+		results = append(results, Result{Name: "truncate at the end", Err: err})
+	}
+	return results
+}
+
+// The rows the Load checks insert, in order. Timestamps are in whole
+// seconds and UTC so every engine stores them exactly.
+var (
+	loadedCreated = time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	loadedDeleted = time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC)
+)
+
+func loaded() []users.User {
+	deleted := loadedDeleted
+	return []users.User{
+		{Name: "Mario", Email: "mario@reset.test", Version: 3, CreatedAt: loadedCreated},
+		{Name: "Luigi", Email: "luigi@reset.test", CreatedAt: loadedCreated.Add(time.Minute)},
+		{Name: "Wario", Email: "wario@reset.test", Version: 2, CreatedAt: loadedCreated.Add(time.Hour), DeletedAt: &deleted},
+	}
+}
+
+// all returns live and trashed users together, ordered by ID.
+func (t *run) all(ctx context.Context) ([]users.User, error) {
+	live, err := t.store.List(ctx, users.Page{})
+	if err != nil {
+		return nil, err
+	}
+	trash, err := t.store.ListDeleted(ctx, users.Page{})
+	if err != nil {
+		return nil, err
+	}
+	all := append(live, trash...)
+	slices.SortFunc(all, func(a, b users.User) int { return cmp.Compare(a.ID, b.ID) })
+	return all, nil
+}
+
+var resetChecks = []check{
+	{"truncate empties users and audit_log", func(ctx context.Context, t *run) error {
+		u, err := t.create(ctx, "Doomed", "doomed")
+		if err != nil {
+			return err
+		}
+		if err := t.store.Truncate(ctx); err != nil {
+			return err
+		}
+		if all, err := t.all(ctx); err != nil || len(all) != 0 {
+			return fmt.Errorf("after truncate: %d users, %v", len(all), err)
+		}
+		if log, err := t.store.AuditLog(ctx, u.ID, users.Page{}); err != nil || len(log) != 0 {
+			return fmt.Errorf("after truncate: %d audit entries for user %d, %v", len(log), u.ID, err)
+		}
+		return nil
+	}},
+
+	{"load after truncate numbers ids from 1, every time", func(ctx context.Context, t *run) error {
+		// Push the sequence past 1 first, so a table that merely got
+		// emptied would show up as IDs 4, 5, 6.
+		for _, local := range []string{"a", "b", "c"} {
+			if _, err := t.create(ctx, "Filler", local); err != nil {
+				return err
+			}
+		}
+		for round := 1; round <= 2; round++ {
+			if err := t.store.Truncate(ctx); err != nil {
+				return err
+			}
+			if err := t.store.Load(ctx, loaded()); err != nil {
+				return err
+			}
+			all, err := t.all(ctx)
+			if err != nil {
+				return err
+			}
+			if got := ids(all); fmt.Sprint(got) != "[1 2 3]" {
+				return fmt.Errorf("round %d: ids %v, want [1 2 3]", round, got)
+			}
+			for i, u := range loaded() {
+				if all[i].Email != u.Email {
+					return fmt.Errorf("round %d: id %d is %s, want %s: Load must keep the order", round, i+1, all[i].Email, u.Email)
+				}
+			}
+		}
+		return nil
+	}},
+
+	{"load keeps created_at, version and deleted_at", func(ctx context.Context, t *run) error {
+		if err := t.store.Truncate(ctx); err != nil {
+			return err
+		}
+		if err := t.store.Load(ctx, loaded()); err != nil {
+			return err
+		}
+		all, err := t.all(ctx)
+		if err != nil {
+			return err
+		}
+		if len(all) != len(loaded()) {
+			return fmt.Errorf("got %d users, want %d", len(all), len(loaded()))
+		}
+		for i, want := range loaded() {
+			got := all[i]
+			if !got.CreatedAt.Equal(want.CreatedAt) {
+				return fmt.Errorf("%s: created_at %s, want %s", want.Email, got.CreatedAt, want.CreatedAt)
+			}
+			if v := max(want.Version, 1); got.Version != v {
+				return fmt.Errorf("%s: version %d, want %d", want.Email, got.Version, v)
+			}
+			switch {
+			case want.DeletedAt == nil && got.DeletedAt != nil:
+				return fmt.Errorf("%s: deleted_at %s, want none", want.Email, got.DeletedAt)
+			case want.DeletedAt != nil && (got.DeletedAt == nil || !got.DeletedAt.Equal(*want.DeletedAt)):
+				return fmt.Errorf("%s: deleted_at %v, want %s", want.Email, got.DeletedAt, want.DeletedAt)
+			}
+		}
+		if log, err := t.store.AuditLog(ctx, 1, users.Page{}); err != nil || len(log) != 0 {
+			return fmt.Errorf("load wrote %d audit entries (%v), want none", len(log), err)
+		}
+		return nil
+	}},
+}
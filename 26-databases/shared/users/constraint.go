@@ -0,0 +1,113 @@
+package users
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrDuplicate is matched (with errors.Is) by errors from a unique
+// constraint, e.g. a second user with the same email.
+var ErrDuplicate = errors.New("users: duplicate value")
+
+// ErrConstraint is matched by errors from not-null, foreign-key and check
+// constraints: the values are not acceptable, whatever else is stored.
+var ErrConstraint = errors.New("users: constraint violation")
+
+// ErrNotFound is returned for a missing (or trashed) user. It wraps
+// sql.ErrNoRows, so callers that test for that keep working.
+var ErrNotFound = fmt.Errorf("users: user not found: %w", sql.ErrNoRows)
+
+// Violation is what a Dialect can tell about a failed constraint. The zero
+// value (ClassOther) means the error was not a constraint violation.
+type Violation struct {
+	Class      ErrorClass
+	Constraint string // constraint or index name, when the driver reports it
+	Column     string // offending column, when the driver reports it
+}
+
+// ConstraintError is a database error caused by a constraint on the users
+// table. It matches ErrDuplicate or ErrConstraint and unwraps to the driver
+// error.
+type ConstraintError struct {
+	Class      ErrorClass
+	Field      string // JSON name of the offending field ("email"), or ""
+	Constraint string
+	Err        error
+}
+
+func (e *ConstraintError) Error() string {
+	return "users: " + e.Message() + ": " + e.Err.Error()
+}
+
+func (e *ConstraintError) Unwrap() error { return e.Err }
+
+func (e *ConstraintError) Is(target error) bool {
+	if e.Class == ClassUniqueViolation {
+		return target == ErrDuplicate
+	}
+	return target == ErrConstraint
+}
+
+// Message describes the violation in terms of the field, without any
+// driver detail, so it can be shown to API clients.
+func (e *ConstraintError) Message() string {
+	field := e.Field
+	if field == "" {
+		field = "value"
+	}
+	switch e.Class {
+	case ClassUniqueViolation:
+		return "a user with this " + field + " already exists"
+	case ClassNotNullViolation:
+		return field + " is required"
+	case ClassForeignKeyViolation:
+		return field + " refers to a record that does not exist"
+	}
+	return field + " is not valid"
+}
+
+// columns are the users columns a client can set; a violation is blamed on
+// the one its constraint name mentions.
+var columns = []string{"email", "name"}
+
+// constraintError turns err into a *ConstraintError when the dialect
+// recognises it as a constraint violation, and returns nil otherwise.
+func (s *Store) constraintError(err error) *ConstraintError {
+	v := s.dialect.Classify(err)
+	if v.Class == ClassOther {
+		return nil
+	}
+	field := strings.ToLower(v.Column)
+	if field == "" {
+		name := strings.ToLower(v.Constraint)
+		for _, c := range columns {
+			if strings.Contains(name, c) {
+				field = c
+				break
+			}
+		}
+	}
+	if field == "" && v.Class == ClassUniqueViolation {
+		// email is the only unique column besides the generated id; SQL
+		// Server names an inline UNIQUE constraint UQ__users__<hash>.
+		field = "email"
+	}
+	return &ConstraintError{Class: v.Class, Field: field, Constraint: v.Constraint, Err: err}
+}
+
+// classify wraps constraint violations in a *ConstraintError and turns
+// sql.ErrNoRows into ErrNotFound; other errors are returned untouched.
+func (s *Store) classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if ce := s.constraintError(err); ce != nil {
+		return ce
+	}
+	return err
+}
@@ -0,0 +1,198 @@
+// Package seed fills a users store with predictable data: N generated
+// users from a fixed random seed, or named fixture sets read from YAML or
+// JSON. It goes through users.Store, so the same data loads into every
+// dialect, and tests can call it directly:
+//
+//	if err := seed.Reset(ctx, store, seed.Generate(50, 1)); err != nil { ... }
+package seed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/users"
+)
+
+// Epoch is the first created_at handed out by Generate and the default for
+// fixtures that leave it out. It is fixed so seeded databases are identical
+// from one run to the next.
+var Epoch = time.Date(2024, time.January, 1, 9, 0, 0, 0, time.UTC)
+
+var (
+	firstNames = []string{
+		"Ada", "Alan", "Barbara", "Dennis", "Edsger", "Frances", "Grace", "Guido",
+		"Hedy", "Ken", "Linus", "Margaret", "Niklaus", "Radia", "Rob", "Sophie",
+		"Tim", "Katherine", "John", "Mary", "Donald", "Leslie", "Shafi", "Yukihiro",
+	}
+	lastNames = []string{
+		"Lovelace", "Turing", "Liskov", "Ritchie", "Dijkstra", "Allen", "Hopper", "van Rossum",
+		"Lamarr", "Thompson", "Torvalds", "Hamilton", "Wirth", "Perlman", "Pike", "Wilson",
+		"Berners-Lee", "Johnson", "McCarthy", "Jackson", "Knuth", "Lamport", "Goldwasser", "Matsumoto",
+	}
+	domains = []string{"example.com", "example.org", "example.net", "mail.test"}
+)
+
+// Generate returns n users built from seed: the same seed always gives the
+// same names, emails and timestamps. Emails are unique, and CreatedAt goes
+// up with the index, starting at Epoch.
+func Generate(n int, seed uint64) []users.User {
+	rng := rand.New(rand.NewPCG(seed, seed))
+	taken := make(map[string]bool, n)
+	created := Epoch
+	out := make([]users.User, 0, n)
+	for range n {
+		first := firstNames[rng.IntN(len(firstNames))]
+		last := lastNames[rng.IntN(len(lastNames))]
+		local := emailPart(first) + "." + emailPart(last)
+		domain := domains[rng.IntN(len(domains))]
+
+		email := local + "@" + domain
+		for i := 2; taken[email]; i++ {
+			email = local + strconv.Itoa(i) + "@" + domain
+		}
+		taken[email] = true
+
+		// Between a minute and two days apart, in whole seconds so every
+		// engine stores the value exactly.
+		created = created.Add(time.Duration(60+rng.IntN(2*24*3600)) * time.Second)
+		out = append(out, users.User{Name: first + " " + last, Email: email, Version: 1, CreatedAt: created})
+	}
+	return out
+}
+
+// emailPart lower-cases a name and drops everything but letters and digits.
+func emailPart(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return -1
+	}, s)
+}
+
+// Fixture is one user in a fixture file. CreatedAt defaults to Epoch plus
+// an hour per position in the set; DeletedAt puts the user in the trash.
+type Fixture struct {
+	Name      string     `json:"name" yaml:"name"`
+	Email     string     `json:"email" yaml:"email"`
+	CreatedAt time.Time  `json:"created_at" yaml:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" yaml:"deleted_at"`
+}
+
+// Sets maps a fixture set name to its users, in insertion order. A file
+// holds one or more sets:
+//
+//	demo:
+//	  - name: Mario
+//	    email: mario@nintendo.com
+//	  - name: Luigi
+//	    email: luigi@nintendo.com
+//	    deleted_at: 2024-02-01T12:00:00Z
+type Sets map[string][]Fixture
+
+// ReadFile reads fixture sets from a .yaml, .yml or .json file.
+func ReadFile(path string) (Sets, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sets, err := Read(f, strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), "."))
+	if err != nil {
+		return nil, fmt.Errorf("seed: %s: %w", path, err)
+	}
+	return sets, nil
+}
+
+// Read decodes fixture sets in format "yaml" (or "yml") or "json".
+func Read(r io.Reader, format string) (Sets, error) {
+	var sets Sets
+	var err error
+	switch format {
+	case "yaml", "yml":
+		err = yaml.NewDecoder(r).Decode(&sets)
+	case "json":
+		dec := json.NewDecoder(r)
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&sets)
+	default:
+		return nil, fmt.Errorf("unknown fixture format %q (want yaml or json)", format)
+	}
+	if err == io.EOF {
+		err = nil // an empty file has no sets
+	}
+	return sets, err
+}
+
+// Names returns the set names in sorted order.
+func (s Sets) Names() []string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Users returns the named sets as users, in the order given, with default
+// timestamps filled in.
+func (s Sets) Users(names ...string) ([]users.User, error) {
+	var out []users.User
+	for _, name := range names {
+		set, ok := s[name]
+		if !ok {
+			return nil, fmt.Errorf("seed: no fixture set %q (have %s)", name, strings.Join(s.Names(), ", "))
+		}
+		for i, f := range set {
+			created := f.CreatedAt
+			if created.IsZero() {
+				created = Epoch.Add(time.Duration(i) * time.Hour)
+			}
+			out = append(out, users.User{Name: f.Name, Email: f.Email, Version: 1, CreatedAt: created.UTC(), DeletedAt: f.DeletedAt})
+		}
+	}
+	return out, nil
+}
+
+// Reset truncates users and audit_log, then loads us, so IDs start at 1
+// again. Call it between test runs for a known starting point.
+func Reset(ctx context.Context, store *users.Store, us []users.User) error {
+	if err := store.Truncate(ctx); err != nil {
+		return err
+	}
+	return store.Load(ctx, us)
+}
+
+/*
+🧠 DETERMINISTIC SEED DATA & FIXTURES (shared/users/seed/seed.go)
+
+✅ What Happens Here:
+- `Generate(n, seed)` builds n users from a seeded PCG random source: realistic names, unique emails, increasing `created_at`.
+- `ReadFile` loads named fixture sets from YAML or JSON; `Sets.Users("demo", "trash")` turns them into rows.
+- `Reset` truncates `users` and `audit_log` (restarting IDs at 1) and loads the rows through `Store.Load`.
+
+✅ Why This Matters:
+- A fixed seed and a fixed `Epoch` mean every run — and every engine — gets byte-for-byte the same data, so tests and screenshots don't drift.
+- Fixtures go through the dialect layer: one YAML file seeds SQLite, PostgreSQL and SQL Server alike.
+
+✅ Key Concepts:
+| Concept                      | Explanation |
+|------------------------------|-------------|
+| `rand.New(rand.NewPCG(s, s))`| A reproducible random stream; never use the global source for fixtures |
+| `Dialect.Truncate`           | `DELETE` + `sqlite_sequence`, `TRUNCATE ... RESTART IDENTITY`, or `TRUNCATE TABLE` |
+| `Store.Load`                 | Inserts rows as given (timestamps, trash) without audit entries |
+| Fixture sets                 | Named groups of rows you combine per test |
+*/
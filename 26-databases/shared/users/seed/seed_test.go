@@ -0,0 +1,135 @@
+package seed_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"learn-go-with-cyber-mountain-man/26-databases/shared/users"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/users/seed"
+	_ "learn-go-with-cyber-mountain-man/26-databases/shared/users/sqlite"
+)
+
+func TestGenerateIsDeterministic(t *testing.T) {
+	a, b := seed.Generate(500, 1), seed.Generate(500, 1)
+	if !reflect.DeepEqual(a, b) {
+		t.Fatal("the same seed gave different users")
+	}
+	if reflect.DeepEqual(a, seed.Generate(500, 2)) {
+		t.Error("different seeds gave the same users")
+	}
+	if !reflect.DeepEqual(seed.Generate(10, 1), a[:10]) {
+		t.Error("Generate(10) is not a prefix of Generate(500) with the same seed")
+	}
+
+	emails := make(map[string]bool, len(a))
+	last := seed.Epoch
+	for i, u := range a {
+		if emails[strings.ToLower(u.Email)] {
+			t.Errorf("user %d: email %s repeats", i, u.Email)
+		}
+		emails[strings.ToLower(u.Email)] = true
+		if u.Name == "" || !strings.Contains(u.Email, "@") || u.Version != 1 {
+			t.Errorf("user %d = %+v", i, u)
+		}
+		if !u.CreatedAt.After(last) || u.CreatedAt.Truncate(time.Second) != u.CreatedAt {
+			t.Errorf("user %d: created_at %s after %s, want later and in whole seconds", i, u.CreatedAt, last)
+		}
+		last = u.CreatedAt
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	sets, err := seed.ReadFile("fixtures/users.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sets.Names(); !reflect.DeepEqual(got, []string{"admin", "demo", "trash"}) {
+		t.Errorf("Names() = %v", got)
+	}
+
+	us, err := sets.Users("admin", "trash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleted := time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC)
+	want := []users.User{
+		{Name: "Admin", Email: "admin@example.com", Version: 1, CreatedAt: seed.Epoch}, // default created_at
+		{Name: "Wario", Email: "wario@nintendo.com", Version: 1, CreatedAt: time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC), DeletedAt: &deleted},
+	}
+	if len(us) != 3 || !reflect.DeepEqual(us[:2], want) {
+		t.Errorf("Users(admin, trash) = %+v, want %+v and Waluigi", us, want)
+	}
+	if _, err := sets.Users("demo", "nope"); err == nil || !strings.Contains(err.Error(), "admin, demo, trash") {
+		t.Errorf("unknown set: %v, want an error naming the sets there are", err)
+	}
+
+	// The same sets as JSON read back identically.
+	data, err := json.Marshal(sets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "users.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fromJSON, err := seed.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromJSON, sets) {
+		t.Errorf("JSON = %+v, want %+v", fromJSON, sets)
+	}
+}
+
+func TestReadRejects(t *testing.T) {
+	tests := map[string]struct{ format, body string }{
+		"unknown JSON field": {"json", `{"demo": [{"name": "Mario", "emial": "mario@nintendo.com"}]}`},
+		"not a list":         {"yaml", "demo: Mario\n"},
+		"unknown format":     {"toml", "[demo]\n"},
+	}
+	for name, tt := range tests {
+		if _, err := seed.Read(strings.NewReader(tt.body), tt.format); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+	if sets, err := seed.Read(strings.NewReader(""), "yaml"); err != nil || len(sets) != 0 {
+		t.Errorf("empty file = %v, %v; want no sets", sets, err)
+	}
+}
+
+func TestReset(t *testing.T) {
+	ctx := context.Background()
+	store, err := users.Open(ctx, "sqlite::memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	generated := seed.Generate(5, 1)
+	for range 2 { // the second Reset starts over at ID 1 too
+		if err := seed.Reset(ctx, store, generated); err != nil {
+			t.Fatal(err)
+		}
+		got, err := store.List(ctx, users.Page{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(generated) {
+			t.Fatalf("got %d users, want %d", len(got), len(generated))
+		}
+		for i, u := range got {
+			if u.ID != i+1 || u.Email != generated[i].Email || !u.CreatedAt.Equal(generated[i].CreatedAt) {
+				t.Errorf("row %d = %+v, want id %d and %+v", i, u, i+1, generated[i])
+			}
+		}
+	}
+}
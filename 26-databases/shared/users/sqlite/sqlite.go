@@ -0,0 +1,180 @@
+// Package sqlite registers the SQLite dialect for the "sqlite" and
+// "sqlite3" DSN schemes:
+//
+//	sqlite:data/app.db
+//	sqlite:///var/lib/app/app.db
+//	sqlite::memory:
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/users"
+)
+
+func init() {
+	users.Register(Dialect{}, "sqlite", "sqlite3")
+}
+
+// Dialect implements users.Dialect for github.com/mattn/go-sqlite3.
+type Dialect struct {
+	users.ANSISavepoints
+}
+
+func (Dialect) Name() string       { return "sqlite" }
+func (Dialect) DriverName() string { return "sqlite3" }
+
+// DataSource accepts both the opaque form (sqlite:data/app.db) and the
+// hierarchical one (sqlite:///abs/path.db). ":memory:" becomes a
+// shared-cache in-memory database so every pooled connection sees the
+// same tables.
+func (Dialect) DataSource(u *url.URL) (string, error) {
+	path := u.Opaque
+	if path == "" {
+		path = u.Host + u.Path
+	}
+	if path == "" {
+		return "", errors.New("missing database path")
+	}
+	if path == ":memory:" {
+		path = "file::memory:?cache=shared"
+		if u.RawQuery != "" {
+			path += "&" + u.RawQuery
+		}
+		return path, nil
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return path, nil
+}
+
+func (Dialect) Placeholder(int) string         { return "?" }
+func (Dialect) InsertStyle() users.InsertStyle { return users.LastInsertID }
+func (Dialect) Now() string                    { return "CURRENT_TIMESTAMP" }
+
+func (Dialect) Paginate(limit, offset int) string {
+	if limit <= 0 {
+		limit = -1 // SQLite needs a LIMIT before OFFSET; -1 means no limit
+	}
+	return "LIMIT " + strconv.Itoa(limit) + " OFFSET " + strconv.Itoa(offset)
+}
+
+// Classify reads the extended result code. SQLite names the columns of a
+// unique or not-null violation in the message ("UNIQUE constraint failed:
+// users.email") and the constraint of a check ("CHECK constraint failed:
+// users_email_check").
+func (Dialect) Classify(err error) users.Violation {
+	var se sqlite3.Error
+	if !errors.As(err, &se) {
+		return users.Violation{}
+	}
+	var v users.Violation
+	switch se.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		v.Class = users.ClassUniqueViolation
+	case sqlite3.ErrConstraintNotNull:
+		v.Class = users.ClassNotNullViolation
+	case sqlite3.ErrConstraintForeignKey:
+		v.Class = users.ClassForeignKeyViolation
+	case sqlite3.ErrConstraintCheck:
+		v.Class = users.ClassCheckViolation
+	default:
+		return v
+	}
+
+	_, detail, _ := strings.Cut(se.Error(), "constraint failed: ")
+	if v.Class == users.ClassCheckViolation {
+		v.Constraint = detail
+		return v
+	}
+	// "users.email" or, for a composite key, "users.a, users.b"
+	first, _, _ := strings.Cut(detail, ",")
+	if _, col, ok := strings.Cut(first, "."); ok {
+		v.Column = col
+	}
+	return v
+}
+
+// Migrate creates the users and audit_log tables and adds created_at,
+// version and deleted_at to databases made before those columns existed.
+// SQLite cannot ALTER in a column with a CURRENT_TIMESTAMP default, so old
+// rows are backfilled and new rows get the value from the INSERT. Nor can
+// it add a CHECK constraint to an existing table: the name and email
+// checks only exist in databases created by this version.
+func (Dialect) Migrate(ctx context.Context, db *sql.DB) error {
+	const schema = `
+	CREATE TABLE IF NOT EXISTS users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		email TEXT UNIQUE NOT NULL,
+		version INTEGER NOT NULL DEFAULT 1,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		deleted_at TIMESTAMP,
+		CONSTRAINT users_name_check CHECK (name <> ''),
+		CONSTRAINT users_email_check CHECK (email LIKE '%_@_%')
+	);
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		actor TEXT NOT NULL,
+		action TEXT NOT NULL,
+		entity TEXT NOT NULL,
+		entity_id INTEGER NOT NULL,
+		before_json TEXT,
+		after_json TEXT,
+		request_id TEXT,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS audit_log_entity ON audit_log (entity, entity_id, id);`
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		return err
+	}
+
+	// Tables created by earlier versions of the lesson lack these columns.
+	if err := addColumn(ctx, db, "created_at", `TIMESTAMP`,
+		`UPDATE users SET created_at = CURRENT_TIMESTAMP WHERE created_at IS NULL`); err != nil {
+		return err
+	}
+	if err := addColumn(ctx, db, "version", `INTEGER NOT NULL DEFAULT 1`, ""); err != nil {
+		return err
+	}
+	return addColumn(ctx, db, "deleted_at", `TIMESTAMP`, "")
+}
+
+// Truncate empties both tables. SQLite has no TRUNCATE; the AUTOINCREMENT
+// counters live in sqlite_sequence and are reset by deleting their rows.
+func (Dialect) Truncate(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, `
+	DELETE FROM audit_log;
+	DELETE FROM users;
+	DELETE FROM sqlite_sequence WHERE name IN ('users', 'audit_log');`)
+	return err
+}
+
+// addColumn adds a column to users unless it is already there, then runs
+// the optional backfill statement.
+func addColumn(ctx context.Context, db *sql.DB, name, typ, backfill string) error {
+	var n int
+	err := db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM pragma_table_info('users') WHERE name = ?`, name).Scan(&n)
+	if err != nil || n > 0 {
+		return err
+	}
+	stmts := []string{`ALTER TABLE users ADD COLUMN ` + name + ` ` + typ}
+	if backfill != "" {
+		stmts = append(stmts, backfill)
+	}
+	for _, stmt := range stmts {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("add %s: %w", name, err)
+		}
+	}
+	return nil
+}
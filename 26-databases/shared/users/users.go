@@ -0,0 +1,368 @@
+package users
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"learn-go-with-cyber-mountain-man/26-databases/shared/sqlscan"
+)
+
+// User is a row of the users table. All three engines store the same
+// columns, including created_at. DeletedAt is set while the user is in the
+// trash (soft-deleted). Version starts at 1 and goes up with every write;
+// it is what HTTP handlers expose as the ETag.
+type User struct {
+	ID        int        `json:"id" db:"id"`
+	Name      string     `json:"name" db:"name"`
+	Email     string     `json:"email" db:"email"`
+	Version   int        `json:"version" db:"version"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+}
+
+// Page selects a slice of the ordered user list. A zero Limit means "all".
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+// MaxPageSize caps the limit accepted by ParsePage.
+const MaxPageSize = 500
+
+// ParsePage reads ?limit= and ?offset= from a query string.
+func ParsePage(q url.Values) (Page, error) {
+	var p Page
+	for _, f := range []struct {
+		name string
+		dst  *int
+	}{{"limit", &p.Limit}, {"offset", &p.Offset}} {
+		v := q.Get(f.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return Page{}, fmt.Errorf("%s must be a non-negative integer", f.name)
+		}
+		*f.dst = n
+	}
+	if p.Limit > MaxPageSize {
+		return Page{}, fmt.Errorf("limit must be at most %d", MaxPageSize)
+	}
+	return p, nil
+}
+
+// ErrVersionMismatch is returned by Update and Delete when the caller's
+// version is not the row's current one: someone else changed it first.
+var ErrVersionMismatch = errors.New("users: version does not match the current one")
+
+// Store runs the users queries for one database through its Dialect.
+type Store struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+// New wraps an already-open pool. Most callers use Open instead.
+func New(db *sql.DB, d Dialect) *Store {
+	return &Store{db: db, dialect: d}
+}
+
+// DB exposes the underlying pool (for health checks, stats, Close).
+func (s *Store) DB() *sql.DB { return s.db }
+
+// Dialect returns the dialect chosen for this store.
+func (s *Store) Dialect() Dialect { return s.dialect }
+
+// Close closes the pool.
+func (s *Store) Close() error { return s.db.Close() }
+
+// Migrate creates the users table if needed.
+func (s *Store) Migrate(ctx context.Context) error {
+	if err := s.dialect.Migrate(ctx, s.db); err != nil {
+		return fmt.Errorf("users: migrate %s: %w", s.dialect.Name(), err)
+	}
+	return nil
+}
+
+// Truncate deletes all users and the whole audit log and restarts the ID
+// sequences. It exists for seeding and tests; nothing is audited.
+func (s *Store) Truncate(ctx context.Context) error {
+	if err := s.dialect.Truncate(ctx, s.db); err != nil {
+		return fmt.Errorf("users: truncate %s: %w", s.dialect.Name(), err)
+	}
+	return nil
+}
+
+// Load inserts us as given, in one transaction, keeping their CreatedAt,
+// Version and DeletedAt (a zero Version is stored as 1). IDs are left to
+// the database, so after Truncate they are 1..len(us) in order. Unlike
+// Create it writes no audit entries: it is meant for seed data and test
+// fixtures.
+func (s *Store) Load(ctx context.Context, us []User) error {
+	q := Rebind(s.dialect, `INSERT INTO users (name, email, version, created_at, deleted_at) VALUES (?, ?, ?, ?, ?)`)
+	return s.classify(s.inTx(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, q)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for _, u := range us {
+			var deleted sql.NullTime
+			if u.DeletedAt != nil {
+				deleted = sql.NullTime{Time: *u.DeletedAt, Valid: true}
+			}
+			if _, err := stmt.ExecContext(ctx, u.Name, u.Email, max(u.Version, 1), u.CreatedAt, deleted); err != nil {
+				return fmt.Errorf("load %s: %w", u.Email, err)
+			}
+		}
+		return nil
+	}))
+}
+
+const selectColumns = `SELECT id, name, email, version, created_at, deleted_at FROM users`
+
+// active and trashed filter on soft-delete state. Every read except the
+// ones used to restore or audit a user excludes trashed rows.
+const (
+	active  = ` deleted_at IS NULL`
+	trashed = ` deleted_at IS NOT NULL`
+)
+
+// Create inserts u and fills in its generated ID and CreatedAt. The insert
+// and its audit entry commit together.
+func (s *Store) Create(ctx context.Context, u *User) error {
+	return s.classify(s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := s.insert(ctx, tx, s.insertQuery(), u); err != nil {
+			return err
+		}
+		return s.audit(ctx, tx, ActionCreate, u.ID, nil, u)
+	}))
+}
+
+// insertQuery builds the INSERT for the dialect's InsertStyle.
+func (s *Store) insertQuery() string {
+	d := s.dialect
+	now := d.Now()
+	switch d.InsertStyle() {
+	case Returning:
+		return Rebind(d, `INSERT INTO users (name, email, created_at) VALUES (?, ?, `+now+`) RETURNING id, created_at`)
+	case OutputInserted:
+		return Rebind(d, `INSERT INTO users (name, email, created_at) OUTPUT INSERTED.id, INSERTED.created_at VALUES (?, ?, `+now+`)`)
+	default: // LastInsertID
+		return Rebind(d, `INSERT INTO users (name, email, created_at) VALUES (?, ?, `+now+`)`)
+	}
+}
+
+// execer is what insert and get need; *sql.DB, *sql.Tx and a prepared
+// *sql.Stmt (through preparedInsert) all provide it.
+type execer interface {
+	sqlscan.Querier
+	sqlscan.Execer
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// insert runs q (from insertQuery) on ex and reads back the generated columns.
+func (s *Store) insert(ctx context.Context, ex execer, q string, u *User) error {
+	u.Version = 1 // the column default
+	if s.dialect.InsertStyle() != LastInsertID {
+		return ex.QueryRowContext(ctx, q, u.Name, u.Email).Scan(&u.ID, &u.CreatedAt)
+	}
+
+	res, err := ex.ExecContext(ctx, q, u.Name, u.Email)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	u.ID = int(id)
+	return ex.QueryRowContext(ctx, Rebind(s.dialect, `SELECT created_at FROM users WHERE id = ?`), u.ID).Scan(&u.CreatedAt)
+}
+
+// inTx runs fn in a transaction and commits if it returns nil.
+func (s *Store) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // no-op after Commit
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// get reads one user through ex; filter is active, trashed or "" for either.
+func (s *Store) get(ctx context.Context, ex execer, id int, filter string) (User, error) {
+	q := selectColumns + ` WHERE id = ?`
+	if filter != "" {
+		q += ` AND` + filter
+	}
+	return sqlscan.QueryOne[User](ctx, ex, Rebind(s.dialect, q), id)
+}
+
+// Get returns one user. A missing or trashed ID yields ErrNotFound.
+func (s *Store) Get(ctx context.Context, id int) (User, error) {
+	u, err := s.get(ctx, s.db, id, active)
+	return u, s.classify(err)
+}
+
+// List returns users ordered by ID, leaving out trashed ones. The result is
+// never nil, so it encodes as [] rather than null.
+func (s *Store) List(ctx context.Context, p Page) ([]User, error) {
+	return s.list(ctx, active, p)
+}
+
+// ListDeleted returns the trashed users ordered by ID.
+func (s *Store) ListDeleted(ctx context.Context, p Page) ([]User, error) {
+	return s.list(ctx, trashed, p)
+}
+
+func (s *Store) list(ctx context.Context, filter string, p Page) ([]User, error) {
+	q := selectColumns + ` WHERE` + filter + ` ORDER BY id`
+	if p.Limit > 0 || p.Offset > 0 {
+		q += " " + s.dialect.Paginate(p.Limit, p.Offset)
+	}
+	return sqlscan.QueryAll[User](ctx, s.db, q)
+}
+
+// Update changes a user's name and email and bumps the version. u.Version
+// must be the version the caller read, or 0 to skip the check; a stale one
+// yields ErrVersionMismatch. Even with 0, a write that lands between the
+// read and the UPDATE gives ErrVersionMismatch rather than being
+// overwritten; retry if that is what the caller wants. A missing or
+// trashed ID yields ErrNotFound, and a taken email a *ConstraintError
+// matching ErrDuplicate.
+func (s *Store) Update(ctx context.Context, u User) (User, error) {
+	var after User
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		before, err := s.readForWrite(ctx, tx, u.ID, u.Version, active)
+		if err != nil {
+			return err
+		}
+		q := Rebind(s.dialect, `UPDATE users SET name = ?, email = ?, version = version + 1 WHERE id = ? AND version = ? AND`+active)
+		if err := execOne(ctx, tx, q, u.Name, u.Email, u.ID, before.Version); err != nil {
+			return staleIfGone(err)
+		}
+		after = before
+		after.Name, after.Email, after.Version = u.Name, u.Email, before.Version+1
+		return s.audit(ctx, tx, ActionUpdate, u.ID, &before, &after)
+	})
+	return after, s.classify(err)
+}
+
+// readForWrite reads the row a write is about to change and checks the
+// caller's expected version (0 accepts any). It takes no lock: the write
+// that follows is guarded by the version read here instead, so a
+// concurrent write in between turns it into ErrVersionMismatch.
+func (s *Store) readForWrite(ctx context.Context, ex execer, id, version int, filter string) (User, error) {
+	before, err := s.get(ctx, ex, id, filter)
+	if err != nil {
+		return before, err
+	}
+	if version != 0 && version != before.Version {
+		return before, ErrVersionMismatch
+	}
+	return before, nil
+}
+
+// staleIfGone turns "no row matched" into ErrVersionMismatch for writes that
+// found the row a moment earlier: only a concurrent write can explain it.
+func staleIfGone(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrVersionMismatch
+	}
+	return err
+}
+
+// Delete moves a user to the trash by setting deleted_at. The row (and its
+// email address) stays in the table until Purge. version works as in
+// Update. A missing or already trashed ID yields ErrNotFound.
+func (s *Store) Delete(ctx context.Context, id, version int) error {
+	return s.setDeleted(ctx, id, version, ActionDelete, s.dialect.Now(), active)
+}
+
+// Restore takes a user out of the trash. An ID that is not in the trash
+// yields ErrNotFound.
+func (s *Store) Restore(ctx context.Context, id int) error {
+	return s.setDeleted(ctx, id, 0, ActionRestore, "NULL", trashed)
+}
+
+func (s *Store) setDeleted(ctx context.Context, id, version int, action, value, filter string) error {
+	return s.classify(s.inTx(ctx, func(tx *sql.Tx) error {
+		before, err := s.readForWrite(ctx, tx, id, version, filter)
+		if err != nil {
+			return err
+		}
+		q := Rebind(s.dialect, `UPDATE users SET deleted_at = `+value+`, version = version + 1 WHERE id = ? AND version = ? AND`+filter)
+		if err := execOne(ctx, tx, q, id, before.Version); err != nil {
+			return staleIfGone(err)
+		}
+		after, err := s.get(ctx, tx, id, "")
+		if err != nil {
+			return err
+		}
+		return s.audit(ctx, tx, action, id, &before, &after)
+	}))
+}
+
+// Purge removes a user row for good. The audit history is kept. A missing
+// ID yields ErrNotFound.
+func (s *Store) Purge(ctx context.Context, id int) error {
+	return s.classify(s.inTx(ctx, func(tx *sql.Tx) error {
+		before, err := s.get(ctx, tx, id, "")
+		if err != nil {
+			return err
+		}
+		if err := execOne(ctx, tx, Rebind(s.dialect, `DELETE FROM users WHERE id = ?`), id); err != nil {
+			return err
+		}
+		return s.audit(ctx, tx, ActionPurge, id, &before, nil)
+	}))
+}
+
+// execOne runs a statement that must change exactly one row; zero rows
+// means another request got there first and yields sql.ErrNoRows.
+func execOne(ctx context.Context, ex execer, q string, args ...any) error {
+	res, err := ex.ExecContext(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+/*
+🧠 ONE USERS DATA LAYER, THREE DATABASES (shared/users)
+
+✅ What Happens Here:
+- `Store` holds every users query once, written with `?` placeholders and plain SQL.
+- A `Dialect` fills in what differs per engine: placeholders, how INSERT returns the new id, pagination and error codes.
+- `Open(ctx, dsn)` picks the dialect from the DSN scheme (`sqlite:`, `postgres://`, `sqlserver://`).
+- Dialects live in sub-packages that register themselves in `init`, so each app only links the driver it imports.
+- Deletes are soft: `deleted_at` is set and the default queries skip the row until `Restore`.
+- Every write runs in a transaction together with its `audit_log` entry (see audit.go).
+- Every write also bumps `version`; `Update` and `Delete` refuse a stale version with `ErrVersionMismatch` (optimistic concurrency).
+- Driver errors never leak as "something failed": constraint violations become a `*ConstraintError` naming the field (`ErrDuplicate` / `ErrConstraint`), missing rows `ErrNotFound`.
+
+✅ Why This Matters:
+- Three hand-copied model files drift apart (only one of them had `created_at`). One package keeps behaviour identical.
+- The conformance suite (`shared/users/conformance`) runs the same checks against every engine.
+
+✅ Key Concepts:
+| Concept                      | SQLite            | PostgreSQL              | SQL Server |
+|------------------------------|-------------------|-------------------------|------------|
+| `Placeholder(n)`             | `?`               | `$1`                    | `@p1` |
+| `InsertStyle()`              | `LastInsertId()`  | `RETURNING id, ...`     | `OUTPUT INSERTED.id, ...` |
+| `Paginate(limit, offset)`    | `LIMIT n OFFSET m`| `LIMIT n OFFSET m`      | `OFFSET m ROWS FETCH NEXT n ROWS ONLY` |
+| `Classify(err)` (unique)     | extended code 2067| SQLSTATE `23505`        | error 2627 / 2601 |
+| `Classify(err)` (check)      | extended code 275 | SQLSTATE `23514`        | error 547 + "CHECK" |
+*/
@@ -4,5 +4,9 @@ go 1.24.0
 
 require (
 	github.com/go-chi/chi/v5 v5.2.1
-	github.com/mattn/go-sqlite3 v1.14.28
+	learn-go-with-cyber-mountain-man/26-databases/shared v0.0.0
 )
+
+require github.com/mattn/go-sqlite3 v1.14.28 // indirect
+
+replace learn-go-with-cyber-mountain-man/26-databases/shared => ../shared
@@ -1,47 +1,44 @@
 package db
 
 import (
-	"database/sql"
+	"context"
 	"log"
-	"path/filepath"
+	"time"
 
-	_ "github.com/mattn/go-sqlite3" // Import the SQLite driver anonymously
+	"learn-go-with-cyber-mountain-man/26-databases/shared/dbconn"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/users"
+	_ "learn-go-with-cyber-mountain-man/26-databases/shared/users/sqlite" // Registers the sqlite: DSN scheme (and the go-sqlite3 driver)
 )
 
-var DB *sql.DB // Expose a global database connection (used by other packages)
+var Store *users.Store // Expose the users store globally (used by main to build routes)
 
-// InitDB initializes the SQLite connection and ensures the schema is set up.
-// It stores the connection in the package-level `DB` variable.
-func InitDB(relativePath string) error {
-	// Resolve full path (optional safety)
-	fullPath := filepath.Clean(relativePath)
-
-	// Open a SQLite database file (creates it if not present)
-	db, err := sql.Open("sqlite3", fullPath)
+// InitDB opens the database named by dsn (e.g. "sqlite:data/app.db") and
+// ensures the schema is set up. It stores the result in the package-level
+// `Store` variable. Pool limits and the startup retry window come from the
+// DB_* environment variables (see dbconn.FromEnv).
+func InitDB(dsn string) error {
+	cfg, err := dbconn.FromEnv()
 	if err != nil {
 		return err
 	}
 
-	// Test the connection
-	if err := db.Ping(); err != nil {
+	// Pick the dialect from the DSN scheme, size the pool and ping it
+	// (retrying with backoff, since DATABASE_URL may name a server)
+	store, err := dbconn.Connect(context.Background(), dsn, cfg)
+	if err != nil {
 		return err
 	}
 
-	log.Println("📦 Connected to SQLite DB:", fullPath)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	// Save the connection globally for reuse
-	DB = db
+	log.Printf("📦 Connected to %s DB: %s", store.Dialect().Name(), dsn)
 
-	// Create the users table if it doesn't exist
-	schema := `
-	CREATE TABLE IF NOT EXISTS users (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL,
-		email TEXT UNIQUE NOT NULL
-	);
-	`
+	// Save the store globally for reuse
+	Store = store
 
-	if _, err := DB.Exec(schema); err != nil {
+	// Create the users table if it doesn't exist (and add created_at to older files)
+	if err := Store.Migrate(ctx); err != nil {
 		return err
 	}
 
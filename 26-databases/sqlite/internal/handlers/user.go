@@ -1,24 +1,28 @@
 package handlers
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
-	"sqlite/internal/models"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/dbctx"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/etag"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/users"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/users/bulkhttp"
 
 	"github.com/go-chi/chi/v5"
 )
 
 // UserHandler handles routes related to the User resource.
 type UserHandler struct {
-	DB *sql.DB
+	Store *users.Store
 }
 
 // NewUserHandler returns a new UserHandler instance.
-func NewUserHandler(db *sql.DB) *UserHandler {
-	return &UserHandler{DB: db}
+func NewUserHandler(store *users.Store) *UserHandler {
+	return &UserHandler{Store: store}
 }
 
 // RegisterRoutes registers all user-related endpoints under /users.
@@ -28,16 +32,30 @@ func (h *UserHandler) RegisterRoutes(r chi.Router) {
 	r.Post("/", h.CreateUser)
 	r.Put("/{id}", h.UpdateUser)
 	r.Delete("/{id}", h.DeleteUser)
+	r.Post("/{id}/restore", h.RestoreUser)
+
+	// Bulk endpoints share their implementation with the other engines.
+	r.Post("/import", bulkhttp.Import(h.Store))
+	r.Get("/export", bulkhttp.Export(h.Store))
 }
 
-// GetAllUsers handles GET /users — fetches all users.
+// GetAllUsers handles GET /users — fetches all users (or one page with ?limit=&offset=).
 func (h *UserHandler) GetAllUsers(w http.ResponseWriter, r *http.Request) {
-	users, err := models.GetAllUsers(h.DB)
+	page, err := users.ParsePage(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := dbctx.WithTimeout(r)
+	defer cancel()
+
+	list, err := h.Store.List(ctx, page)
 	if err != nil {
-		http.Error(w, "Failed to fetch users", http.StatusInternalServerError)
+		dbctx.Error(w, ctx, err, "Failed to fetch users")
 		return
 	}
-	json.NewEncoder(w).Encode(users)
+	json.NewEncoder(w).Encode(list)
 }
 
 // GetUserByID handles GET /users/{id} — fetch a user by ID.
@@ -49,25 +67,36 @@ func (h *UserHandler) GetUserByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := models.GetUserByID(h.DB, id)
-	if err != nil {
+	ctx, cancel := dbctx.WithTimeout(r)
+	defer cancel()
+
+	user, err := h.Store.Get(ctx, id)
+	if errors.Is(err, users.ErrNotFound) {
 		http.Error(w, "User not found", http.StatusNotFound)
 		return
 	}
+	if err != nil {
+		dbctx.Error(w, ctx, err, "Failed to fetch user")
+		return
+	}
 
+	etag.Set(w, user.Version) // send back in If-Match to update or delete
 	json.NewEncoder(w).Encode(user)
 }
 
 // CreateUser handles POST /users — creates a new user.
 func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
-	var user models.User
+	var user users.User
 	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
-	if err := models.CreateUser(h.DB, &user); err != nil {
-		http.Error(w, "Failed to create user", http.StatusInternalServerError)
+	ctx, cancel := dbctx.WithTimeout(r)
+	defer cancel()
+
+	if err := h.Store.Create(ctx, &user); err != nil {
+		dbctx.Error(w, ctx, err, "Failed to create user")
 		return
 	}
 
@@ -84,22 +113,42 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var user models.User
+	var user users.User
 	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 	user.ID = id
 
-	if err := models.UpdateUser(h.DB, &user); err != nil {
-		http.Error(w, "Failed to update user", http.StatusInternalServerError)
+	// The version the client read; 428 / 412 are written for us on failure
+	version, ok := etag.IfMatch(w, r)
+	if !ok {
 		return
 	}
+	user.Version = version
 
-	json.NewEncoder(w).Encode(user)
+	ctx, cancel := dbctx.WithTimeout(r)
+	defer cancel()
+
+	updated, err := h.Store.Update(ctx, user)
+	if err != nil {
+		if errors.Is(err, users.ErrNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, users.ErrVersionMismatch) {
+			stale(w, ctx, h.Store, id)
+			return
+		}
+		dbctx.Error(w, ctx, err, "Failed to update user")
+		return
+	}
+
+	etag.Set(w, updated.Version)
+	json.NewEncoder(w).Encode(updated)
 }
 
-// DeleteUser handles DELETE /users/{id}.
+// DeleteUser handles DELETE /users/{id} — moves the user to the trash.
 func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := strconv.Atoi(idStr)
@@ -108,10 +157,67 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := models.DeleteUser(h.DB, id); err != nil {
-		http.Error(w, "Failed to delete user", http.StatusInternalServerError)
+	version, ok := etag.IfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := dbctx.WithTimeout(r)
+	defer cancel()
+
+	if err := h.Store.Delete(ctx, id, version); err != nil {
+		if errors.Is(err, users.ErrNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, users.ErrVersionMismatch) {
+			stale(w, ctx, h.Store, id)
+			return
+		}
+		dbctx.Error(w, ctx, err, "Failed to delete user")
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// RestoreUser handles POST /users/{id}/restore — takes a user out of the trash.
+func (h *UserHandler) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := dbctx.WithTimeout(r)
+	defer cancel()
+
+	if err := h.Store.Restore(ctx, id); err != nil {
+		if errors.Is(err, users.ErrNotFound) {
+			http.Error(w, "No deleted user with this ID", http.StatusNotFound)
+			return
+		}
+		dbctx.Error(w, ctx, err, "Failed to restore user")
+		return
+	}
+
+	user, err := h.Store.Get(ctx, id)
+	if err != nil {
+		dbctx.Error(w, ctx, err, "Failed to fetch restored user")
+		return
+	}
+	etag.Set(w, user.Version)
+	json.NewEncoder(w).Encode(user)
+}
+
+// stale answers a write with an outdated If-Match: 412 plus the user's
+// current state and ETag, so the client can merge and try again.
+func stale(w http.ResponseWriter, ctx context.Context, store *users.Store, id int) {
+	current, err := store.Get(ctx, id)
+	if err != nil {
+		http.Error(w, "User was changed or deleted by someone else", http.StatusPreconditionFailed)
+		return
+	}
+	etag.PreconditionFailed(w, current.Version, current)
+}
@@ -1,17 +1,22 @@
 package routes
 
 import (
-	"database/sql"
 	"net/http"
 
 	"sqlite/internal/handlers"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/apikey"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/cors"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/dbconn"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/users"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/users/audithttp"
 )
 
-// Register sets up the application's routes and middlewares.
-func Register(db *sql.DB) http.Handler {
+// Register sets up the application's routes and middlewares. keys decides
+// who may call the API and becomes the actor in the audit log.
+func Register(store *users.Store, keys apikey.Keys) http.Handler {
 	r := chi.NewRouter()
 
 	// -----------------------------
@@ -23,8 +28,29 @@ func Register(db *sql.DB) http.Handler {
 	// -----------------------------
 	// 👤 USER ROUTES
 	// -----------------------------
-	userHandler := handlers.NewUserHandler(db)
-	r.Route("/users", userHandler.RegisterRoutes)
+	userHandler := handlers.NewUserHandler(store)
+	r.Route("/users", func(r chi.Router) {
+		// CORS rules come from CORS_USERS_* / CORS_* env vars
+		r.Use(cors.Handler(cors.FromEnv("USERS")))
+		// Who is calling (API_KEYS) + request ID → audit_log
+		r.Use(keys.Middleware, audithttp.Middleware)
+		userHandler.RegisterRoutes(r)
+	})
+
+	// -----------------------------
+	// 🛡️ ADMIN ROUTES
+	// -----------------------------
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(keys.Middleware, apikey.RequireAdmin, audithttp.Middleware)
+		r.Get("/users/deleted", audithttp.Deleted(store))
+		r.Get("/users/{id}/audit", audithttp.History(store))
+	})
+
+	// -----------------------------
+	// 🩺 READINESS PROBE
+	// -----------------------------
+	// 200 when the database answers a ping, 503 otherwise
+	r.Method(http.MethodGet, "/ready", &dbconn.Checker{DB: store.DB()})
 
 	// -----------------------------
 	// 🏠 DEFAULT ROUTE
@@ -3,23 +3,37 @@ package main
 import (
 	"log"
 	"net/http"
+	"os"
 
 	"sqlite/internal/db"
 	"sqlite/internal/routes"
+
+	"learn-go-with-cyber-mountain-man/26-databases/shared/apikey"
 )
 
 func main() {
 	// -----------------------------
 	// 1️⃣ INITIALIZE DATABASE
 	// -----------------------------
-	if err := db.InitDB("data/app.db"); err != nil {
+	// DATABASE_URL picks the database by scheme; default is the bundled file
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		dsn = "sqlite:data/app.db"
+	}
+	if err := db.InitDB(dsn); err != nil {
 		log.Fatal("❌ Failed to initialize database:", err)
 	}
+	defer db.Store.Close()
 
 	// -----------------------------
 	// 2️⃣ SET UP ROUTES USING CHI
 	// -----------------------------
-	router := routes.Register(db.DB) // Inject db into router setup
+	// API_KEYS="name:secret[:admin],..." identifies callers for the audit log
+	keys, err := apikey.FromEnv()
+	if err != nil {
+		log.Fatal("❌ Invalid API_KEYS:", err)
+	}
+	router := routes.Register(db.Store, keys) // Inject the users store into router setup
 
 	// -----------------------------
 	// 3️⃣ START SERVER
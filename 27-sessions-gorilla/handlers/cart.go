@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-gorilla/internal/sessiondata"
+)
+
+// CartItem is one line of the shopping cart.
+type CartItem struct {
+	SKU string `json:"sku"`
+	Qty int    `json:"qty"`
+}
+
+// Cart is kept in the session under "cart", as a typed value.
+type Cart struct {
+	Items []CartItem `json:"items"`
+}
+
+// Add changes the quantity of sku by qty, dropping the line at zero.
+func (c *Cart) Add(sku string, qty int) {
+	for i := range c.Items {
+		if c.Items[i].SKU == sku {
+			c.Items[i].Qty += qty
+			if c.Items[i].Qty <= 0 {
+				c.Items = append(c.Items[:i], c.Items[i+1:]...)
+			}
+			return
+		}
+	}
+	if qty > 0 {
+		c.Items = append(c.Items, CartItem{SKU: sku, Qty: qty})
+	}
+}
+
+// ShowCart is GET /cart: the cart as JSON.
+func ShowCart(w http.ResponseWriter, r *http.Request) {
+	cart, _ := sessiondata.Get[Cart](sessiondata.From(r), "cart") // empty if none yet
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cart)
+}
+
+// AddToCart is POST /cart with sku and qty (negative to remove). The
+// session is saved by Autosave, and only because the cart changed.
+func AddToCart(w http.ResponseWriter, r *http.Request) {
+	sku := r.FormValue("sku")
+	qty, err := strconv.Atoi(r.FormValue("qty"))
+	if sku == "" || err != nil {
+		http.Error(w, "sku and a numeric qty are required", http.StatusBadRequest)
+		return
+	}
+	session := sessiondata.From(r)
+	cart, _ := sessiondata.Get[Cart](session, "cart")
+	cart.Add(sku, qty)
+	if err := sessiondata.Set(session, "cart", cart); err != nil {
+		http.Error(w, "Could not update cart", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cart)
+}
+
+/*
+🧠 A TYPED SHOPPING CART (handlers/cart.go)
+
+✅ What Happens Here:
+- The cart is a normal Go struct; `sessiondata.Get[Cart]` and `sessiondata.Set` move it in and out of the session.
+- Neither handler calls `Save`: `middleware.Autosave` writes the cookie when (and only when) the cart changed.
+
+✅ Why This Matters:
+- No `gob.Register(Cart{})`, no `Values["cart"].(Cart)` that panics on old cookies.
+- Everything in a cookie session travels on every request — keep carts small, or store an ID and keep the rest server-side.
+*/
@@ -5,7 +5,8 @@ import (
 	"log"
 	"net/http"
 
-	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-gorilla/internal/config"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-gorilla/internal/remember"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-gorilla/internal/sessiondata"
 )
 
 // Home displays the public landing page.
@@ -13,61 +14,67 @@ func Home(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("🏠 Welcome to the homepage. Go to /login to begin."))
 }
 
-// Login creates a new session and sets a secure cookie.
-func Login(w http.ResponseWriter, r *http.Request) {
-	session, _ := config.Store.Get(r, "session")
-	session.Values["username"] = "demo_user"
-
-	if err := session.Save(r, w); err != nil {
-		log.Printf("❌ Failed to save session: %v", err)
-		http.Error(w, "Could not save session", http.StatusInternalServerError)
-		return
+// Login creates a new session and sets a secure cookie. With ?remember=1
+// it also issues a remember-me token so the login survives the session.
+func Login(tokens *remember.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session := sessiondata.From(r)
+		sessiondata.Set(session, "username", "demo_user")
+
+		// Saved here rather than by Autosave, so a failure can still get a 500
+		if err := session.Save(r, w); err != nil {
+			log.Printf("❌ Failed to save session: %v", err)
+			http.Error(w, "Could not save session", http.StatusInternalServerError)
+			return
+		}
+
+		if r.URL.Query().Get("remember") == "1" {
+			if old, err := r.Cookie(remember.CookieName); err == nil {
+				tokens.Revoke(old.Value)
+			}
+			value, expires := tokens.Issue("demo_user")
+			tokens.SetCookie(w, value, expires)
+		}
+
+		// ✅ Log raw Set-Cookie header for debugging
+		for _, cookie := range w.Header()["Set-Cookie"] {
+			log.Printf("🔍 Set-Cookie: %s", cookie)
+		}
+
+		w.Write([]byte("✅ Logged in with Gorilla sessions. Visit /dashboard"))
 	}
+}
 
-	// ✅ Log raw Set-Cookie header for debugging
-	for _, cookie := range w.Header()["Set-Cookie"] {
-		log.Printf("🔍 Set-Cookie: %s", cookie)
-	}
 
-	w.Write([]byte("✅ Logged in with Gorilla sessions. Visit /dashboard"))
-}
+// Logout clears the session, expires the cookie and forgets the device's
+// remember-me token.
+func Logout(tokens *remember.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session := sessiondata.From(r)
 
+		if cookie, err := r.Cookie(remember.CookieName); err == nil {
+			tokens.Revoke(cookie.Value)
+		}
+		tokens.ClearCookie(w)
 
-// Logout clears the session and expires the cookie.
-func Logout(w http.ResponseWriter, r *http.Request) {
-	// Retrieve session for current user
-	session, err := config.Store.Get(r, "session")
-	if err != nil {
-		log.Printf("❌ Failed to get session for logout: %v", err)
-		http.Error(w, "Could not retrieve session", http.StatusInternalServerError)
-		return
-	}
+		// Empty the session and mark the cookie for deletion (MaxAge -1)
+		session.Clear()
 
-	// Mark session for deletion by setting MaxAge to -1
-	session.Options.MaxAge = -1
+		// Save the change to delete the cookie
+		if err := session.Save(r, w); err != nil {
+			log.Printf("❌ Failed to clear session: %v", err)
+			http.Error(w, "Could not clear session", http.StatusInternalServerError)
+			return
+		}
 
-	// Save the change to delete the cookie
-	if err := session.Save(r, w); err != nil {
-		log.Printf("❌ Failed to clear session: %v", err)
-		http.Error(w, "Could not clear session", http.StatusInternalServerError)
-		return
+		w.Write([]byte("🚪 Logged out."))
 	}
-
-	w.Write([]byte("🚪 Logged out."))
 }
 
 // Dashboard is a protected route that requires a valid session.
 func Dashboard(w http.ResponseWriter, r *http.Request) {
-	// Attempt to retrieve session
-	session, err := config.Store.Get(r, "session")
-	if err != nil {
-		log.Printf("❌ Failed to retrieve session: %v", err)
-		http.Error(w, "Unauthorized access", http.StatusUnauthorized)
-		return
-	}
-
-	// Safely extract the username from session data
-	username, ok := session.Values["username"].(string)
+	// Typed read: ok is false if the key is missing or isn't a string
+	username, ok := sessiondata.Get[string](sessiondata.From(r), "username")
 	if !ok || username == "" {
 		http.Error(w, "🔒 Unauthorized. Please login first.", http.StatusUnauthorized)
 		return
@@ -80,7 +87,7 @@ func Dashboard(w http.ResponseWriter, r *http.Request) {
 🧠 HANDLERS — GORILLA SESSION EDITION (Final Version with Full Error Handling)
 
 ✅ What Happens Here:
-- Each handler checks for session retrieval errors before accessing session data.
+- Handlers get the request's session from `sessiondata.From(r)`; `middleware.Autosave` (wired in `main` with the store) loads it and saves it if it changed.
 - `Login` sets a demo username and persists the session cookie.
 - `Logout` marks the session for deletion and confirms removal with `session.Save()`.
 - `Dashboard` ensures a valid, non-empty session exists before showing protected content.
@@ -94,7 +101,7 @@ func Dashboard(w http.ResponseWriter, r *http.Request) {
 |-------------------------------|----------------------------------------------------------|
 | `session.Save()`              | Persists session cookie updates (creation or deletion)   |
 | Defensive programming         | Prevents undefined behavior when sessions fail           |
-| `session.Values["key"].(T)`   | Safely casts session values with a type assertion        |
+| `sessiondata.Get[T]`          | Typed session values, no unchecked type assertions       |
 | Logging failures              | Supports debugging and runtime visibility                |
 
 🔐 Best Practices:
@@ -1,69 +1,186 @@
 package config
 
 import (
-	"log"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/gorilla/sessions"  // Gorilla's secure session library
-	"github.com/joho/godotenv"     // .env loader for local development
+	"github.com/gorilla/sessions" // Gorilla's secure session library
 )
 
-// Store holds the global session store instance
-var Store *sessions.CookieStore
+// KeyPair signs (Auth) and encrypts (Encrypt) session cookies.
+type KeyPair struct {
+	Auth    []byte // HMAC-SHA256 key: 32 or 64 bytes
+	Encrypt []byte // AES key: 16, 24 or 32 bytes
+}
 
-func init() {
-	// ✅ Load .env in development (optional in production environments)
-	if err := godotenv.Load(); err != nil {
-		log.Println("⚠️  .env not found — using system environment variables instead")
-	}
+// Config describes the session store. Keys is ordered newest first: the
+// first pair signs and encrypts new cookies, and every pair is tried when
+// reading one, so cookies issued under an older pair keep working while
+// keys are rotated.
+type Config struct {
+	Keys   []KeyPair
+	MaxAge int    // cookie lifetime in seconds; 0 means one hour
+	Secure bool   // send the cookie over HTTPS only
+	Domain string // "" means the host that set it
 
-	// 🔐 Retrieve session keys from environment
-	authKey := os.Getenv("SESSION_AUTH_KEY")
-	encryptKey := os.Getenv("SESSION_ENCRYPT_KEY")
+	RememberFor time.Duration // "keep me signed in" lifetime
+}
 
-	// ❌ Abort if keys are missing (required for session security)
-	if authKey == "" || encryptKey == "" {
-		log.Fatal("❌ SESSION_AUTH_KEY and SESSION_ENCRYPT_KEY must be set")
+// NewStore validates cfg and builds the cookie store.
+func NewStore(cfg Config) (*sessions.CookieStore, error) {
+	if len(cfg.Keys) == 0 {
+		return nil, errors.New("config: at least one session key pair is required")
+	}
+	var pairs [][]byte
+	for i, k := range cfg.Keys {
+		if n := len(k.Auth); n != 32 && n != 64 {
+			return nil, fmt.Errorf("config: key pair %d: auth key is %d bytes, want 32 or 64", i+1, n)
+		}
+		if n := len(k.Encrypt); n != 16 && n != 24 && n != 32 {
+			return nil, fmt.Errorf("config: key pair %d: encryption key is %d bytes, want 16, 24 or 32", i+1, n)
+		}
+		pairs = append(pairs, k.Auth, k.Encrypt)
 	}
 
-	// ✅ Initialize the Gorilla session store with secure keys
-	Store = sessions.NewCookieStore([]byte(authKey), []byte(encryptKey))
-	Store.Options = &sessions.Options{
+	store := sessions.NewCookieStore(pairs...)
+	store.Options = &sessions.Options{
 		Path:     "/",                     // Session cookie is valid site-wide
-		MaxAge:   3600,                    // 1-hour expiration
+		Domain:   cfg.Domain,              // Share across subdomains if set
 		HttpOnly: true,                    // JS can't access
+		Secure:   cfg.Secure,              // HTTPS only (set SESSION_SECURE=true in production)
 		SameSite: http.SameSiteStrictMode, // CSRF protection
-		// Secure: true,                   // Uncomment when deploying over HTTPS
 	}
+	maxAge := cfg.MaxAge
+	if maxAge == 0 {
+		maxAge = 3600 // 1-hour expiration
+	}
+	store.MaxAge(maxAge) // also rejects signed cookies older than this
+	return store, nil
+}
+
+// FromEnv reads the store configuration:
+//
+//	SESSION_AUTH_KEY, SESSION_ENCRYPT_KEY  the current key pair (hex or base64)
+//	SESSION_PREVIOUS_KEYS                  older pairs still accepted: "auth:encrypt,auth:encrypt"
+//	SESSION_MAX_AGE                        lifetime in seconds (default 3600)
+//	SESSION_SECURE                         "true" for HTTPS-only cookies
+//	SESSION_DOMAIN                         cookie domain
+//	REMEMBER_ME_LIFETIME                   remember-me lifetime, e.g. 168h (default 30 days)
+func FromEnv() (Config, error) {
+	authKey := os.Getenv("SESSION_AUTH_KEY")
+	encryptKey := os.Getenv("SESSION_ENCRYPT_KEY")
+	if authKey == "" || encryptKey == "" {
+		return Config{}, errors.New("config: SESSION_AUTH_KEY and SESSION_ENCRYPT_KEY must be set (run: go run . keygen)")
+	}
+	current, err := parsePair(authKey, encryptKey)
+	if err != nil {
+		return Config{}, fmt.Errorf("config: current session keys: %w", err)
+	}
+	cfg := Config{Keys: []KeyPair{current}, Domain: os.Getenv("SESSION_DOMAIN"), RememberFor: 30 * 24 * time.Hour}
+
+	if prev := os.Getenv("SESSION_PREVIOUS_KEYS"); prev != "" {
+		for i, entry := range strings.Split(prev, ",") {
+			a, e, ok := strings.Cut(strings.TrimSpace(entry), ":")
+			if !ok {
+				return Config{}, fmt.Errorf("config: SESSION_PREVIOUS_KEYS entry %d is not auth:encrypt", i+1)
+			}
+			pair, err := parsePair(a, e)
+			if err != nil {
+				return Config{}, fmt.Errorf("config: SESSION_PREVIOUS_KEYS entry %d: %w", i+1, err)
+			}
+			cfg.Keys = append(cfg.Keys, pair)
+		}
+	}
+
+	if v := os.Getenv("SESSION_MAX_AGE"); v != "" {
+		if cfg.MaxAge, err = strconv.Atoi(v); err != nil || cfg.MaxAge <= 0 {
+			return Config{}, fmt.Errorf("config: SESSION_MAX_AGE=%q is not a positive number of seconds", v)
+		}
+	}
+	if v := os.Getenv("SESSION_SECURE"); v != "" {
+		if cfg.Secure, err = strconv.ParseBool(v); err != nil {
+			return Config{}, fmt.Errorf("config: SESSION_SECURE=%q is not true or false", v)
+		}
+	}
+	if v := os.Getenv("REMEMBER_ME_LIFETIME"); v != "" {
+		if cfg.RememberFor, err = time.ParseDuration(v); err != nil || cfg.RememberFor <= 0 {
+			return Config{}, fmt.Errorf("config: REMEMBER_ME_LIFETIME=%q is not a positive duration", v)
+		}
+	}
+	return cfg, nil
+}
+
+func parsePair(authKey, encryptKey string) (KeyPair, error) {
+	a, err := DecodeKey(authKey)
+	if err != nil {
+		return KeyPair{}, fmt.Errorf("auth key: %w", err)
+	}
+	e, err := DecodeKey(encryptKey)
+	if err != nil {
+		return KeyPair{}, fmt.Errorf("encryption key: %w", err)
+	}
+	return KeyPair{Auth: a, Encrypt: e}, nil
+}
+
+// DecodeKey decodes a key written as hex (what `openssl rand -hex` prints)
+// or base64 (standard or URL alphabet, padded or not). A string that is
+// valid hex is read as hex.
+func DecodeKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if b, err := hex.DecodeString(s); err == nil {
+		return b, nil
+	}
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if b, err := enc.DecodeString(s); err == nil {
+			return b, nil
+		}
+	}
+	return nil, errors.New("not valid hex or base64")
+}
+
+// GenerateKeys returns a fresh 64-byte auth key and 32-byte encryption key,
+// hex encoded.
+func GenerateKeys() (authKey, encryptKey string) {
+	a := make([]byte, 64)
+	e := make([]byte, 32)
+	rand.Read(a)
+	rand.Read(e)
+	return hex.EncodeToString(a), hex.EncodeToString(e)
 }
 
 /*
 🔐 SESSION SECURITY CONFIGURATION (Gorilla + .env)
 
 ✅ What Happens Here:
-- This file defines a global, shared Gorilla `CookieStore` using **secure auth/encryption keys**.
-- Session keys are loaded from the environment — via `.env` for dev or OS variables in production.
-- The store is configured with strict cookie options for session security.
+- `FromEnv` reads the keys and cookie settings; `NewStore(cfg)` checks them and builds the Gorilla `CookieStore`.
+- Keys may be hex or base64 and are **decoded** — a 64-character hex string is a 32-byte key, not 64 bytes of text.
+- Key lengths are validated up front: auth 32/64 bytes, encryption 16/24/32 bytes (AES-128/192/256).
+- Several key pairs can be active: the first signs new cookies, the rest only verify old ones.
 
 ✅ Why This Matters:
-- Session cookies contain sensitive data (e.g., login state), so integrity and confidentiality are crucial.
-- This setup ensures **tamper-proof** and **encrypted** session data.
-- Externalized secrets support scalable, secure deployments across dev, staging, and prod.
+- No `init()` and no `log.Fatal`: the package can be imported by tests, and `main` decides what a bad config means.
+- Rotating keys without `SESSION_PREVIOUS_KEYS` would log out every user at once; with it, old cookies stay valid until they expire.
+- A wrong key length used to surface only at the first login as a cryptic AES error.
 
 ✅ Key Concepts:
 | Concept                      | Purpose                                          |
 |------------------------------|--------------------------------------------------|
-| `sessions.NewCookieStore()`  | Creates a tamper-resistant, encrypted session store |
-| `.env` + `os.Getenv()`       | Loads session keys securely without hardcoding   |
-| `HttpOnly`, `SameSite`       | Mitigate XSS and CSRF attacks                   |
+| `sessions.NewCookieStore(a1, e1, a2, e2)` | Encode with the first pair, decode with any |
+| `store.MaxAge(n)`            | Cookie lifetime *and* the signed timestamp check |
+| `Secure`, `Domain`           | HTTPS-only cookies and the cookie's scope        |
+| `RememberFor`                | How long "keep me signed in" lasts               |
+| `go run . keygen`            | Prints a fresh key pair for `.env`               |
 
 🚨 Security Tip:
 - Do **not** commit `.env` files or keys to version control.
-- Use `openssl rand -hex 32` to generate strong keys.
+- To rotate: move the current pair into `SESSION_PREVIOUS_KEYS`, put the `keygen` output in place, and drop the old pair after `SESSION_MAX_AGE`.
 - For production, use a secrets manager (e.g., AWS Secrets Manager, Vault, GCP Secret Manager).
-
-📚 Up Next:
-- Use `config.Store.Get()` in your handlers and middleware to manage sessions securely.
-- Implement flash messages or user roles with `session.Values[...]`.
 */
@@ -0,0 +1,189 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func key(n int, b byte) []byte { return bytes.Repeat([]byte{b}, n) }
+
+func TestNewStoreKeyLengths(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    []KeyPair
+		wantErr string // "" means the store is built
+	}{
+		{"no keys", nil, "at least one session key pair"},
+		{"auth 32 enc 16", []KeyPair{{key(32, 1), key(16, 2)}}, ""},
+		{"auth 64 enc 24", []KeyPair{{key(64, 1), key(24, 2)}}, ""},
+		{"auth 64 enc 32", []KeyPair{{key(64, 1), key(32, 2)}}, ""},
+		{"auth 16", []KeyPair{{key(16, 1), key(32, 2)}}, "key pair 1: auth key is 16 bytes"},
+		{"auth 128 (hex text, not decoded)", []KeyPair{{key(128, 'a'), key(32, 2)}}, "auth key is 128 bytes"},
+		{"enc 31", []KeyPair{{key(32, 1), key(31, 2)}}, "key pair 1: encryption key is 31 bytes"},
+		{"enc 64", []KeyPair{{key(32, 1), key(64, 2)}}, "encryption key is 64 bytes"},
+		{"bad second pair", []KeyPair{{key(32, 1), key(32, 2)}, {key(32, 3), key(8, 4)}}, "key pair 2: encryption key is 8 bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := NewStore(Config{Keys: tt.keys})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("NewStore: %v", err)
+				}
+				if store.Options.MaxAge != 3600 || !store.Options.HttpOnly {
+					t.Errorf("options = %+v, want MaxAge 3600 and HttpOnly", store.Options)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("NewStore error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDecodeKey(t *testing.T) {
+	raw := make([]byte, 32)
+	for i := range raw {
+		raw[i] = byte(i * 7) // includes bytes that need '+' and '/' in base64
+	}
+	tests := []struct {
+		name    string
+		in      string
+		want    []byte
+		wantErr bool
+	}{
+		{"hex", hex.EncodeToString(raw), raw, false},
+		{"hex upper case", strings.ToUpper(hex.EncodeToString(raw)), raw, false},
+		{"hex with whitespace", "  " + hex.EncodeToString(raw) + "\n", raw, false},
+		{"base64 std padded", base64.StdEncoding.EncodeToString(raw), raw, false},
+		{"base64 std raw", base64.RawStdEncoding.EncodeToString(raw), raw, false},
+		{"base64 url padded", base64.URLEncoding.EncodeToString(raw), raw, false},
+		{"base64 url raw", base64.RawURLEncoding.EncodeToString(raw), raw, false},
+		{"neither", "not a key!", nil, true},
+		{"hex with a stray character", hex.EncodeToString(raw)[:62] + "zz!", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeKey(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("DecodeKey(%q) = %x, want an error", tt.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodeKey(%q): %v", tt.in, err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("DecodeKey(%q) = %x, want %x", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldPair := KeyPair{key(64, 1), key(32, 2)}
+	newPair := KeyPair{key(64, 3), key(32, 4)}
+
+	tests := []struct {
+		name     string
+		signWith []KeyPair
+		readWith []KeyPair
+		wantOK   bool
+	}{
+		{"old cookie, old pair kept after the new one", []KeyPair{oldPair}, []KeyPair{newPair, oldPair}, true},
+		{"new cookie, rotated store", []KeyPair{newPair, oldPair}, []KeyPair{newPair, oldPair}, true},
+		{"new cookie signed with the first pair", []KeyPair{newPair, oldPair}, []KeyPair{newPair}, true},
+		{"old cookie after the old pair is dropped", []KeyPair{oldPair}, []KeyPair{newPair}, false},
+		{"new cookie on a server that was not rotated", []KeyPair{newPair, oldPair}, []KeyPair{oldPair}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cookie := issue(t, tt.signWith)
+
+			store, err := NewStore(Config{Keys: tt.readWith})
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.AddCookie(cookie)
+			sess, err := store.Get(r, cookie.Name)
+			if !tt.wantOK {
+				if err == nil {
+					t.Fatalf("cookie decoded with %d pair(s) it was not signed with", len(tt.readWith))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if got := sess.Values["user"]; got != "alice" {
+				t.Errorf(`Values["user"] = %v, want "alice"`, got)
+			}
+		})
+	}
+}
+
+// issue saves a session through a store built from pairs and returns the
+// cookie it set.
+func issue(t *testing.T, pairs []KeyPair) *http.Cookie {
+	t.Helper()
+	store, err := NewStore(Config{Keys: pairs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	sess, _ := store.New(r, "session")
+	sess.Values["user"] = "alice"
+	if err := sess.Save(r, w); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("got %d cookies, want 1", len(cookies))
+	}
+	return cookies[0]
+}
+
+func TestFromEnvPreviousKeys(t *testing.T) {
+	a1, e1 := GenerateKeys()
+	a2, e2 := GenerateKeys()
+	t.Setenv("SESSION_AUTH_KEY", a1)
+	t.Setenv("SESSION_ENCRYPT_KEY", e1)
+	t.Setenv("SESSION_PREVIOUS_KEYS", a2+":"+base64.StdEncoding.EncodeToString(mustHex(t, e2)))
+
+	cfg, err := FromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Keys) != 2 {
+		t.Fatalf("got %d key pairs, want 2", len(cfg.Keys))
+	}
+	if !bytes.Equal(cfg.Keys[0].Auth, mustHex(t, a1)) || !bytes.Equal(cfg.Keys[1].Encrypt, mustHex(t, e2)) {
+		t.Error("key pairs are not in env order (current first)")
+	}
+	if _, err := NewStore(cfg); err != nil {
+		t.Errorf("NewStore(FromEnv()): %v", err)
+	}
+
+	t.Setenv("SESSION_PREVIOUS_KEYS", a2)
+	if _, err := FromEnv(); err == nil || !strings.Contains(err.Error(), "not auth:encrypt") {
+		t.Errorf("FromEnv with a malformed previous pair: %v", err)
+	}
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
@@ -0,0 +1,184 @@
+// Package remember implements "keep me signed in" with split
+// selector/validator tokens. The cookie holds "selector:validator"; the
+// store keeps the selector in the clear and a SHA-256 hash of the
+// validator, never the validator itself.
+package remember
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CookieName is the name of the remember-me cookie.
+const CookieName = "remember_me"
+
+var (
+	ErrInvalid = errors.New("remember: unknown or malformed token")
+	ErrExpired = errors.New("remember: token expired")
+	// ErrTheft means the selector was right but the validator was not:
+	// the token was used somewhere else first. The series is gone.
+	ErrTheft = errors.New("remember: token reused, series revoked")
+)
+
+// series is one remembered device: the selector is fixed, the validator
+// is replaced on every use.
+type series struct {
+	username  string
+	validator [sha256.Size]byte
+	previous  [sha256.Size]byte // the validator the last rotation replaced
+	rotated   time.Time         // zero until the first rotation
+	expires   time.Time
+}
+
+// Store holds remember-me series in memory.
+type Store struct {
+	Lifetime time.Duration // absolute, from the original login
+	Secure   bool          // send the cookie over HTTPS only
+	// Grace keeps the validator replaced by the last rotation valid for a
+	// while, so two tabs presenting the same cookie at once are not taken
+	// for a stolen token.
+	Grace time.Duration
+
+	mu     sync.Mutex
+	tokens map[string]*series // keyed by selector
+}
+
+// New returns an empty store whose logins last lifetime, with a 30s Grace.
+func New(lifetime time.Duration, secure bool) *Store {
+	return &Store{Lifetime: lifetime, Secure: secure, Grace: 30 * time.Second, tokens: make(map[string]*series)}
+}
+
+// Issue starts a new series for username and returns the cookie value and
+// its expiry.
+func (s *Store) Issue(username string) (string, time.Time) {
+	selector, validator := randomHex(12), randomHex(32)
+	ser := &series{username: username, validator: hash(validator), expires: time.Now().Add(s.Lifetime)}
+	s.mu.Lock()
+	s.tokens[selector] = ser
+	s.mu.Unlock()
+	return selector + ":" + validator, ser.expires
+}
+
+// Redeem checks a cookie value and, if it is good, rotates the validator,
+// returning the user, the new cookie value and its expiry. On ErrTheft,
+// username says whose series was revoked.
+//
+// The validator rotated away less than Grace ago is still accepted, with
+// next == "": the request that rotated it is sending the new cookie, so
+// the caller must not overwrite it.
+func (s *Store) Redeem(value string) (username, next string, expires time.Time, err error) {
+	selector, validator, ok := strings.Cut(value, ":")
+	if !ok {
+		return "", "", time.Time{}, ErrInvalid
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ser, ok := s.tokens[selector]
+	if !ok {
+		return "", "", time.Time{}, ErrInvalid
+	}
+	h := hash(validator)
+	now := time.Now()
+	current := subtle.ConstantTimeCompare(h[:], ser.validator[:]) == 1
+	raced := !ser.rotated.IsZero() && now.Sub(ser.rotated) < s.Grace &&
+		subtle.ConstantTimeCompare(h[:], ser.previous[:]) == 1
+	if !current && !raced {
+		delete(s.tokens, selector)
+		return ser.username, "", time.Time{}, ErrTheft
+	}
+	if now.After(ser.expires) {
+		delete(s.tokens, selector)
+		return "", "", time.Time{}, ErrExpired
+	}
+	if raced {
+		return ser.username, "", ser.expires, nil
+	}
+	validator = randomHex(32)
+	ser.previous, ser.validator, ser.rotated = ser.validator, hash(validator), now
+	return ser.username, selector + ":" + validator, ser.expires, nil
+}
+
+// Revoke forgets the series behind a cookie value.
+func (s *Store) Revoke(value string) {
+	selector, _, _ := strings.Cut(value, ":")
+	s.mu.Lock()
+	delete(s.tokens, selector)
+	s.mu.Unlock()
+}
+
+// RevokeUser forgets every series of username and returns how many.
+func (s *Store) RevokeUser(username string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for selector, ser := range s.tokens {
+		if ser.username == username {
+			delete(s.tokens, selector)
+			n++
+		}
+	}
+	return n
+}
+
+// SetCookie sends the remember-me cookie, persistent until expires.
+func (s *Store) SetCookie(w http.ResponseWriter, value string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   s.Secure,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// ClearCookie tells the browser to drop the remember-me cookie.
+func (s *Store) ClearCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    "",
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   s.Secure,
+		MaxAge:   -1,
+	})
+}
+
+func hash(validator string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(validator))
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+/*
+🧠 PERSISTENT LOGIN NEXT TO GORILLA SESSIONS (internal/remember/remember.go)
+
+✅ What Happens Here:
+- The Gorilla session cookie still dies after `SESSION_MAX_AGE`; the `remember_me` cookie outlives it.
+- Redeeming the token checks the validator's hash, then swaps in a new validator and re-sends the cookie.
+- A right selector with a wrong validator is a replay of an old token, so the series is deleted on the spot — unless it is the validator rotated away less than `Grace` ago, which is just two requests racing.
+
+✅ Why This Matters:
+- A cookie store can't "log out" a session it already handed out, but it *can* refuse to renew one: theft detection cuts the attacker off at the next renewal.
+- Only hashes are kept, so a memory dump or leaked table doesn't hand out working cookies.
+
+✅ Key Concepts:
+| Concept                  | Explanation |
+|--------------------------|-------------|
+| `selector:validator`     | Lookup key + secret, split so lookups never touch the secret |
+| Rotation                 | Each use replaces the validator; an old one is evidence of theft |
+| `Store.Grace`            | Window in which the previous validator still works (parallel tabs) |
+| `Store.Lifetime`         | From `REMEMBER_ME_LIFETIME`; not extended by rotation |
+*/
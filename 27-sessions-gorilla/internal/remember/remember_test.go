@@ -0,0 +1,100 @@
+package remember
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRedeemRotates(t *testing.T) {
+	s := New(time.Hour, false)
+	first, _ := s.Issue("alice")
+
+	user, second, _, err := s.Redeem(first)
+	if err != nil || user != "alice" {
+		t.Fatalf("Redeem = %q, %v; want alice, nil", user, err)
+	}
+	if second == "" || second == first {
+		t.Fatalf("Redeem did not rotate: next = %q", second)
+	}
+	if _, third, _, err := s.Redeem(second); err != nil || third == "" {
+		t.Fatalf("rotated value: next = %q, err = %v", third, err)
+	}
+}
+
+func TestRedeemGrace(t *testing.T) {
+	tests := []struct {
+		name    string
+		grace   time.Duration
+		replay  func(s *Store, first, second string) string // returns the value to redeem
+		wantErr error
+	}{
+		{
+			name:   "concurrent request with the previous validator",
+			grace:  time.Minute,
+			replay: func(_ *Store, first, _ string) string { return first },
+		},
+		{
+			name:    "previous validator after the grace period",
+			grace:   0,
+			replay:  func(_ *Store, first, _ string) string { return first },
+			wantErr: ErrTheft,
+		},
+		{
+			name:  "validator two rotations old",
+			grace: time.Minute,
+			replay: func(s *Store, first, second string) string {
+				if _, _, _, err := s.Redeem(second); err != nil {
+					panic(err)
+				}
+				return first
+			},
+			wantErr: ErrTheft,
+		},
+		{
+			name:    "made-up validator",
+			grace:   time.Minute,
+			replay:  func(_ *Store, first, _ string) string { return first[:25] + "00" },
+			wantErr: ErrTheft,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(time.Hour, false)
+			s.Grace = tt.grace
+			first, _ := s.Issue("alice")
+			_, second, _, err := s.Redeem(first)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			user, next, _, err := s.Redeem(tt.replay(s, first, second))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Redeem error = %v, want %v", err, tt.wantErr)
+			}
+			if user != "alice" {
+				t.Errorf("username = %q, want alice", user)
+			}
+			if tt.wantErr != nil {
+				if _, _, _, err := s.Redeem(second); !errors.Is(err, ErrInvalid) {
+					t.Errorf("series survived theft: Redeem(current) = %v", err)
+				}
+				return
+			}
+			if next != "" {
+				t.Errorf("grace redemption rotated again: next = %q, want \"\"", next)
+			}
+			if _, _, _, err := s.Redeem(second); err != nil {
+				t.Errorf("current validator after a grace redemption: %v", err)
+			}
+		})
+	}
+}
+
+func TestRedeemExpired(t *testing.T) {
+	s := New(-time.Second, false)
+	value, _ := s.Issue("alice")
+	if _, _, _, err := s.Redeem(value); !errors.Is(err, ErrExpired) {
+		t.Fatalf("Redeem = %v, want ErrExpired", err)
+	}
+}
@@ -0,0 +1,111 @@
+package sessiondata
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/sessions"
+)
+
+type cart struct {
+	Items []string `json:"items"`
+}
+
+// load returns a fresh session for a request with no cookie.
+func load(t *testing.T) *Data {
+	t.Helper()
+	store := sessions.NewCookieStore([]byte("0123456789abcdef0123456789abcdef"))
+	d, err := Load(store, httptest.NewRequest(http.MethodGet, "/", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestSet(t *testing.T) {
+	d := load(t)
+	if err := Set(d, "cart", cart{Items: []string{"apple"}}); err != nil {
+		t.Fatal(err)
+	}
+	if !d.Dirty() {
+		t.Error("Set did not mark the session dirty")
+	}
+	if got, ok := Get[cart](d, "cart"); !ok || len(got.Items) != 1 || got.Items[0] != "apple" {
+		t.Errorf("Get = %+v, %v", got, ok)
+	}
+
+	d.dirty = false // as if saved
+	if err := Set(d, "cart", cart{Items: []string{"apple"}}); err != nil {
+		t.Fatal(err)
+	}
+	if d.Dirty() {
+		t.Error("setting an equal value marked the session dirty")
+	}
+	if err := Set(d, "cart", cart{Items: []string{"pear"}}); err != nil || !d.Dirty() {
+		t.Errorf("setting a new value: err = %v, dirty = %v", err, d.Dirty())
+	}
+	if err := Set(d, "f", func() {}); err == nil {
+		t.Error("Set stored a value JSON can't encode")
+	}
+}
+
+func TestDeleteAndClear(t *testing.T) {
+	d := load(t)
+	Set(d, "username", "alice")
+	Set(d, "cart", cart{})
+
+	d.dirty = false
+	d.Delete("missing")
+	if d.Dirty() {
+		t.Error("deleting a missing key marked the session dirty")
+	}
+	d.Delete("cart")
+	if _, ok := Get[cart](d, "cart"); ok || !d.Dirty() {
+		t.Errorf("Delete: still there = %v, dirty = %v", ok, d.Dirty())
+	}
+
+	d.dirty = false
+	d.Clear()
+	if _, ok := Get[string](d, "username"); ok || !d.Dirty() {
+		t.Errorf("Clear: still there = %v, dirty = %v", ok, d.Dirty())
+	}
+	if d.session.Options.MaxAge >= 0 {
+		t.Errorf("Clear left MaxAge = %d, want the cookie expired", d.session.Options.MaxAge)
+	}
+}
+
+func TestGetTypeMismatch(t *testing.T) {
+	d := load(t)
+	Set(d, "username", "alice")
+	d.session.Values["legacy"] = "not JSON bytes"
+
+	if v, ok := Get[int](d, "username"); ok || v != 0 {
+		t.Errorf("Get[int] of a string = %v, %v; want 0, false", v, ok)
+	}
+	if _, ok := Get[string](d, "legacy"); ok {
+		t.Error("Get read a value Set didn't store")
+	}
+	if _, ok := Get[string](d, "missing"); ok {
+		t.Error("Get found a missing key")
+	}
+}
+
+func TestNilData(t *testing.T) {
+	var d *Data // From outside Autosave
+	if _, ok := Get[string](d, "username"); ok {
+		t.Error("Get found a value in no session")
+	}
+	if err := Set(d, "username", "alice"); !errors.Is(err, ErrNoSession) {
+		t.Errorf("Set = %v, want ErrNoSession", err)
+	}
+	d.Delete("username")
+	d.Clear()
+	if d.Dirty() {
+		t.Error("no session is dirty")
+	}
+	if err := d.Save(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder()); err != nil {
+		t.Errorf("Save = %v", err)
+	}
+}
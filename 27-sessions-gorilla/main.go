@@ -1,36 +1,71 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"net/http"
+	"os"
 
-	// Import route handlers and middleware from local packages
+	"github.com/joho/godotenv" // Loads key=value pairs from .env into the environment
+
+	// Import route handlers, middleware and session config from local packages
 	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-gorilla/handlers"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-gorilla/internal/config"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-gorilla/internal/remember"
 	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-gorilla/middleware"
 )
 
 func main() {
+	// `go run . keygen` prints a fresh key pair for .env and exits
+	if len(os.Args) > 1 && os.Args[1] == "keygen" {
+		authKey, encryptKey := config.GenerateKeys()
+		fmt.Printf("SESSION_AUTH_KEY=%s\nSESSION_ENCRYPT_KEY=%s\n", authKey, encryptKey)
+		fmt.Fprintln(os.Stderr, "# rotating? move the old pair into SESSION_PREVIOUS_KEYS=oldauth:oldencrypt")
+		return
+	}
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("⚠️ No .env file found, using environment variables")
+	}
+
+	cfg, err := config.FromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	store, err := config.NewStore(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tokens := remember.New(cfg.RememberFor, cfg.Secure) // "keep me signed in"
+
 	// Create a new HTTP multiplexer (router)
 	mux := http.NewServeMux()
 
 	// Public routes
-	mux.HandleFunc("/", handlers.Home)     // Accessible to everyone
-	mux.HandleFunc("/login", handlers.Login)   // Creates session and sets cookie
-	mux.HandleFunc("/logout", handlers.Logout) // Destroys session and clears cookie
+	mux.HandleFunc("/", handlers.Home)             // Accessible to everyone
+	mux.Handle("/login", handlers.Login(tokens))   // Creates session and sets cookie (?remember=1 to stay signed in)
+	mux.Handle("/logout", handlers.Logout(tokens)) // Destroys session and clears cookie
 
-	// Protected route — requires session token to access
+	// Protected routes — require session token to access
 	mux.Handle("/dashboard", middleware.RequireSession(http.HandlerFunc(handlers.Dashboard)))
+	mux.Handle("GET /cart", middleware.RequireSession(http.HandlerFunc(handlers.ShowCart)))
+	mux.Handle("POST /cart", middleware.RequireSession(http.HandlerFunc(handlers.AddToCart)))
 
 	// Start the HTTP server on port 8080
 	log.Println("🍪 Gorilla session server running at http://localhost:8080")
-	http.ListenAndServe(":8080", mux)
+	// RememberMe wraps everything so it runs before RequireSession; inside
+	// it, Autosave loads the session for handlers and saves their changes
+	http.ListenAndServe(":8080", middleware.RememberMe(store, tokens)(middleware.Autosave(store)(mux)))
 }
 
 /*
 🧠 GO WEB SERVER — GORILLA SESSIONS EDITION
 
 ✅ What Happens Here:
-- This entry point registers all HTTP routes using `http.NewServeMux`.
+- This entry point loads `.env`, builds the session store with `config.NewStore`, and hands it to every handler and middleware.
+- A `remember.Store` backs "keep me signed in"; `middleware.RememberMe` wraps the whole router.
+- `go run . keygen` prints a new key pair instead of starting the server.
+- It registers all HTTP routes using `http.NewServeMux`.
 - It introduces protected routing via Gorilla sessions — session tokens are securely stored and validated.
 - The `/dashboard` route uses middleware to enforce authentication based on session presence.
 - The server listens for incoming HTTP requests on `localhost:8080`.
@@ -46,6 +81,7 @@ func main() {
 | `gorilla/sessions`           | Secure cookie-based session management                  |
 | `http.HandlerFunc`           | Converts a function into a handler                      |
 | `middleware.RequireSession`  | Validates the session and injects context               |
+| `middleware.Autosave`        | Loads typed session data, saves it only when changed    |
 | Modular routing              | Cleanly separates public vs protected routes            |
 
 🔐 Security Considerations:
@@ -1,18 +1,23 @@
 package middleware
 
 import (
+	"errors"
+	"log"
 	"net/http"
 
-	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-gorilla/internal/config"
+	"sync"
+
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-gorilla/internal/remember"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-gorilla/internal/sessiondata"
+	"github.com/gorilla/sessions"
 )
 
-// RequireSession wraps a protected route and checks if a valid session exists.
+// RequireSession wraps a protected route and checks that the session
+// Autosave loaded belongs to a logged-in user.
 func RequireSession(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		session, _ := config.Store.Get(r, "session")
-
 		// Check for session value "username"
-		if username, ok := session.Values["username"].(string); !ok || username == "" {
+		if username, ok := sessiondata.Get[string](sessiondata.From(r), "username"); !ok || username == "" {
 			http.Error(w, "🔒 Unauthorized. Please login first.", http.StatusUnauthorized)
 			return
 		}
@@ -22,6 +27,111 @@ func RequireSession(next http.Handler) http.Handler {
 	})
 }
 
+// Autosave loads the request's session into the context (see
+// sessiondata.From) and saves it if a handler changed it. The cookie has
+// to go out with the headers, so the save happens just before the
+// handler's first write, or after it returns if it wrote nothing.
+func Autosave(store sessions.Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			d, err := sessiondata.Load(store, r)
+			if err != nil {
+				log.Printf("⚠️ Ignoring unreadable session cookie: %v", err)
+			}
+			r = r.WithContext(sessiondata.NewContext(r.Context(), d))
+			sw := &saveWriter{ResponseWriter: w}
+			sw.save = func() {
+				if err := d.Save(r, w); err != nil {
+					log.Printf("❌ Failed to save session: %v", err)
+				}
+			}
+			next.ServeHTTP(sw, r)
+			sw.once.Do(sw.save)
+		})
+	}
+}
+
+// saveWriter runs save once, before the first byte of the response.
+type saveWriter struct {
+	http.ResponseWriter
+	save func()
+	once sync.Once
+}
+
+func (sw *saveWriter) WriteHeader(code int) {
+	sw.once.Do(sw.save)
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *saveWriter) Write(b []byte) (int, error) {
+	sw.once.Do(sw.save)
+	return sw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the real writer.
+func (sw *saveWriter) Unwrap() http.ResponseWriter { return sw.ResponseWriter }
+
+// RememberMe silently signs the user back in: if the request has no valid
+// session but does carry a remember-me cookie, the token is redeemed and a
+// fresh session is saved before the rest of the chain (RequireSession
+// included) runs. Wrap the whole router with it.
+func RememberMe(store sessions.Store, tokens *remember.Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Peek, unlike Load, doesn't cache the result on the request
+			if d, err := sessiondata.Peek(store, r); err == nil {
+				if username, ok := sessiondata.Get[string](d, "username"); ok && username != "" {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			cookie, err := r.Cookie(remember.CookieName)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			username, value, expires, err := tokens.Redeem(cookie.Value)
+			if err != nil {
+				if errors.Is(err, remember.ErrTheft) {
+					log.Printf("⚠️ remember-me token reuse for %s: series revoked", username)
+				}
+				tokens.ClearCookie(w)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Drop the stale session cookie so Get starts an empty session
+			// (and later calls in this request see the one we fill in)
+			r = withoutCookie(r, sessiondata.SessionName)
+			d, _ := sessiondata.Load(store, r)
+			sessiondata.Set(d, "username", username)
+			if err := d.Save(r, w); err != nil {
+				log.Printf("❌ Failed to save session: %v", err)
+				http.Error(w, "Could not save session", http.StatusInternalServerError)
+				return
+			}
+			if value != "" { // "" means a racing request already sent the rotated cookie
+				tokens.SetCookie(w, value, expires)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// withoutCookie returns a copy of r without the cookie called name.
+func withoutCookie(r *http.Request, name string) *http.Request {
+	r2 := r.Clone(r.Context())
+	r2.Header.Del("Cookie")
+	for _, c := range r.Cookies() {
+		if c.Name != name {
+			r2.AddCookie(c)
+		}
+	}
+	return r2
+}
+
 /*
 🧠 SESSION MIDDLEWARE — GORILLA WRAPPER
 
@@ -29,6 +139,8 @@ func RequireSession(next http.Handler) http.Handler {
 - This middleware checks whether the incoming HTTP request contains a valid session.
 - Specifically, it looks for the key `username` in the session — if missing, access is denied.
 - If the session is valid, it allows the request to proceed to the next handler.
+- `Autosave` loads the session once per request, hands it to handlers as typed `sessiondata.Data`, and saves it only if it changed — right before the response headers go out.
+- `RememberMe` runs before all of that: no valid session + a good `remember_me` cookie = a new session, saved on the spot.
 
 ✅ Why This Matters:
 - Centralizes access control logic: write it once, apply it to many routes.
@@ -38,8 +150,10 @@ func RequireSession(next http.Handler) http.Handler {
 ✅ Key Concepts:
 | Concept                    | Purpose                                  |
 |----------------------------|------------------------------------------|
-| `Store.Get(r, "session")`  | Loads the session associated with request |
+| `store.Get(r, "session")`  | Loads the session associated with request |
 | `session.Values[...]`      | Reads or writes session-level data        |
+| `Peek` vs `Load`           | `Load` (`store.Get`) caches per request, errors too |
+| `saveWriter`               | Saves on first `Write`/`WriteHeader`: cookies are headers |
 | `http.HandlerFunc`         | Adapter to treat functions as middleware  |
 
 🔐 Best Practices:
@@ -47,7 +161,6 @@ func RequireSession(next http.Handler) http.Handler {
 - Consider adding logging or redirect logic for failed auth
 
 📚 Up Next:
-- Add secure configuration for the session store
 - Build persistent storage for sessions using Redis or database
 */
 
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-gorilla/internal/sessiondata"
+	"github.com/gorilla/sessions"
+)
+
+func TestAutosave(t *testing.T) {
+	store := sessions.NewCookieStore([]byte("0123456789abcdef0123456789abcdef"))
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    bool // a session cookie in the response
+	}{
+		{
+			name: "change, then write",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				sessiondata.Set(sessiondata.From(r), "username", "alice")
+				w.Write([]byte("hello"))
+			},
+			want: true,
+		},
+		{
+			name: "change, then status",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				sessiondata.Set(sessiondata.From(r), "username", "alice")
+				w.WriteHeader(http.StatusCreated)
+			},
+			want: true,
+		},
+		{
+			name: "change, no body",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				sessiondata.Set(sessiondata.From(r), "username", "alice")
+			},
+			want: true,
+		},
+		{
+			name: "read only",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				sessiondata.Get[string](sessiondata.From(r), "username")
+				w.Write([]byte("hello"))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The recorder keeps the headers as they were at the first
+			// write, so a cookie set after that doesn't show up.
+			w := httptest.NewRecorder()
+			Autosave(store)(tt.handler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			var got *http.Cookie
+			for _, c := range w.Result().Cookies() {
+				if c.Name == sessiondata.SessionName {
+					got = c
+				}
+			}
+			if (got != nil) != tt.want {
+				t.Fatalf("session cookie = %v, want one: %v", got, tt.want)
+			}
+			if got == nil {
+				return
+			}
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.AddCookie(got)
+			d, err := sessiondata.Load(store, r)
+			if username, ok := sessiondata.Get[string](d, "username"); err != nil || username != "alice" {
+				t.Errorf("saved session: username = %q, %v (err %v)", username, ok, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/audit"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/middleware"
+)
+
+// UnlockAccount is POST /admin/users/{username}/unlock: clear the
+// account's failed logins and lockout.
+func UnlockAccount(w http.ResponseWriter, r *http.Request) {
+	username := r.PathValue("username")
+	admin, _ := middleware.GetUserFromContext(r)
+	if err := Throttle.Unlock(r.Context(), username, admin); err != nil {
+		log.Printf("❌ unlock %s: %v", username, err)
+		http.Error(w, "Could not unlock", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"username": username, "unlocked": true})
+}
+
+// UnlockIP is POST /admin/ips/{ip}/unlock, for an office locked out by
+// someone else's typos.
+func UnlockIP(w http.ResponseWriter, r *http.Request) {
+	ip := r.PathValue("ip")
+	admin, _ := middleware.GetUserFromContext(r)
+	if err := Throttle.UnlockIP(r.Context(), ip, admin); err != nil {
+		log.Printf("❌ unlock %s: %v", ip, err)
+		http.Error(w, "Could not unlock", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ip": ip, "unlocked": true})
+}
+
+// AuditLog is GET /admin/audit?limit=N: recent security events, newest
+// first (default 100).
+func AuditLog(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	writeJSON(w, http.StatusOK, audit.Recent(limit))
+}
+
+/*
+🧠 ADMIN: UNLOCKS & THE AUDIT LOG (handlers/admin.go)
+
+✅ What Happens Here:
+- `POST /admin/users/{username}/unlock` and `POST /admin/ips/{ip}/unlock` clear lockouts early.
+- `GET /admin/audit` returns recent security events as JSON: lockouts, unlocks, forced logouts, 2FA resets, password resets.
+
+✅ Why This Matters:
+- Lockouts protect accounts but also block their owners; support needs a way out that leaves a trace.
+- Every admin action is attributed, so "who unlocked this account?" has an answer.
+*/
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/cookiecodec"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/sessionstore"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/middleware"
+)
+
+// CartItem is one line of the shopping cart.
+type CartItem struct {
+	SKU string `json:"sku"`
+	Qty int    `json:"qty"`
+}
+
+// Cart is kept in the session under "cart", as a typed value.
+type Cart struct {
+	Items []CartItem `json:"items"`
+}
+
+// Add changes the quantity of sku by qty, dropping the line at zero.
+func (c *Cart) Add(sku string, qty int) {
+	for i := range c.Items {
+		if c.Items[i].SKU == sku {
+			c.Items[i].Qty += qty
+			if c.Items[i].Qty <= 0 {
+				c.Items = append(c.Items[:i], c.Items[i+1:]...)
+			}
+			return
+		}
+	}
+	if qty > 0 {
+		c.Items = append(c.Items, CartItem{SKU: sku, Qty: qty})
+	}
+}
+
+// ShowCart is GET /cart: the cart as JSON.
+func ShowCart(w http.ResponseWriter, r *http.Request) {
+	cart, _ := sessionstore.Get[Cart](middleware.GetDataFromContext(r), "cart") // empty if none yet
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cart)
+}
+
+// AddToCart is POST /cart with sku and qty (negative to remove).
+// Autosave stores the change after the handler returns.
+func AddToCart(w http.ResponseWriter, r *http.Request) {
+	sku := r.FormValue("sku")
+	qty, err := strconv.Atoi(r.FormValue("qty"))
+	if sku == "" || err != nil {
+		http.Error(w, "sku and a numeric qty are required", http.StatusBadRequest)
+		return
+	}
+	data := middleware.GetDataFromContext(r)
+	cart, _ := sessionstore.Get[Cart](data, "cart")
+	cart.Add(sku, qty)
+	if err := sessionstore.Set(data, "cart", cart); err != nil {
+		if errors.Is(err, cookiecodec.ErrTooLarge) {
+			http.Error(w, "Cart is full", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Could not update cart", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cart)
+}
+
+/*
+🧠 A CART IN THE SESSION (handlers/cart.go)
+
+✅ What Happens Here:
+- The cart is an ordinary struct stored in the server-side session: `sessionstore.Get[Cart]` to read, `sessionstore.Set` to write.
+- `middleware.Autosave` saves after the handler, and only when the cart actually changed; `GET /cart` never writes.
+
+✅ Why This Matters:
+- Sessions used to hold just a username. Typed values let them carry real per-login state (carts, wizard steps, flash messages) without `interface{}` juggling.
+- In server mode the cart can be large and the cookie stays a bare session ID; in cookie mode it travels in the cookie, and `Set` refuses to grow it past 4 KB.
+*/
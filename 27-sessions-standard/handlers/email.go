@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/accounts"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/audit"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/mail"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/onetime"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/remember"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/sessionstore"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/middleware"
+)
+
+// Email settings; main sets them from the environment before serving.
+var (
+	Mailer               mail.Mailer = &mail.Memory{}
+	BaseURL                          = "http://localhost:8080" // for links in emails
+	RequireVerifiedEmail bool                                  // refuse logins until the address is confirmed
+)
+
+// How long emailed links stay valid (vars so tests can shorten them).
+var (
+	resetTTL  = 30 * time.Minute
+	verifyTTL = 24 * time.Hour
+)
+
+// sendLater composes and sends in the background, so the response takes
+// the same time whether or not a message went out.
+func sendLater(to, name string, data any) {
+	go func() {
+		m, err := mail.Compose(to, name, data)
+		if err == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			err = Mailer.Send(ctx, m)
+		}
+		if err != nil {
+			log.Printf("❌ sending %s email: %v", name, err)
+		}
+	}()
+}
+
+type linkEmail struct {
+	Username, Email, Link string
+	ExpiresIn             string
+}
+
+// ForgotPasswordPage is GET /password/forgot.
+func ForgotPasswordPage(w http.ResponseWriter, r *http.Request) {
+	render(w, r, "forgot.html", "Forgot your password?", struct{ Sent bool }{})
+}
+
+// ForgotPassword is POST /password/forgot. The answer is the same whether
+// or not the address belongs to an account, so it can't be used to find
+// out who has one.
+func ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	if u, ok := accounts.LookupEmail(r.FormValue("email")); ok {
+		token := onetime.Issue(onetime.PasswordReset, u.Username, resetTTL)
+		sendLater(u.Email, "password_reset", linkEmail{
+			Username:  u.Username,
+			Email:     u.Email,
+			Link:      BaseURL + "/password/reset?token=" + token,
+			ExpiresIn: "30 minutes",
+		})
+	}
+	render(w, r, "forgot.html", "Forgot your password?", struct{ Sent bool }{true})
+}
+
+type resetView struct {
+	Token, Error string
+	MinLength    int
+}
+
+const badLink = "This link is invalid or has expired."
+
+// ResetPasswordPage is GET /password/reset?token=...: the new-password
+// form, if the token is still good.
+func ResetPasswordPage(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if _, err := onetime.Check(onetime.PasswordReset, token); err != nil {
+		render(w, r, "reset.html", "Choose a new password", resetView{Error: badLink})
+		return
+	}
+	render(w, r, "reset.html", "Choose a new password", resetView{Token: token, MinLength: accounts.MinPasswordLength})
+}
+
+// ResetPassword is POST /password/reset. It spends the token, sets the
+// password and signs the user out everywhere.
+func ResetPassword(w http.ResponseWriter, r *http.Request) {
+	token, password := r.FormValue("token"), r.FormValue("password")
+	if len(password) < accounts.MinPasswordLength {
+		// Checked before Consume so a typo doesn't burn the link.
+		render(w, r, "reset.html", "Choose a new password", resetView{
+			Token: token, MinLength: accounts.MinPasswordLength, Error: accounts.ErrWeakPassword.Error(),
+		})
+		return
+	}
+	username, err := onetime.Consume(onetime.PasswordReset, token)
+	if err != nil {
+		render(w, r, "reset.html", "Choose a new password", resetView{Error: badLink})
+		return
+	}
+	if err := accounts.SetPassword(username, password); err != nil {
+		http.Error(w, "Could not set password", http.StatusInternalServerError)
+		return
+	}
+	// Whoever had the old password loses every session and device, and
+	// the user has just proved they read mail at this address.
+	sessionstore.DeleteUserSessions(username, "")
+	remember.RevokeUser(username, "")
+	accounts.MarkVerified(username)
+	audit.Record(audit.Event{Actor: username, Action: "password.reset", Target: username, IP: sessionstore.ClientOf(r).IP})
+	render(w, r, "notice.html", "Password changed", "Your password has been changed and all devices were signed out. You can log in now.")
+}
+
+// sendVerification emails u a link that confirms their address.
+func sendVerification(u accounts.User) {
+	token := onetime.Issue(onetime.VerifyEmail, u.Username, verifyTTL)
+	sendLater(u.Email, "verify_email", linkEmail{
+		Username:  u.Username,
+		Email:     u.Email,
+		Link:      BaseURL + "/email/verify?token=" + token,
+		ExpiresIn: "24 hours",
+	})
+}
+
+// SendVerification is POST /email/verify: (re)send the current user a
+// verification link.
+func SendVerification(w http.ResponseWriter, r *http.Request) {
+	username, _ := middleware.GetUserFromContext(r)
+	u, _ := accounts.Lookup(username)
+	if u.Verified {
+		render(w, r, "notice.html", "Email address", u.Email+" is already verified.")
+		return
+	}
+	sendVerification(u)
+	render(w, r, "notice.html", "Email address", "We've sent a confirmation link to "+u.Email+".")
+}
+
+// VerifyEmail is GET /email/verify?token=...: the link from the email.
+func VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	username, err := onetime.Consume(onetime.VerifyEmail, r.URL.Query().Get("token"))
+	if err != nil {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusBadRequest)
+		render(w, r, "notice.html", "Email address", badLink)
+		return
+	}
+	accounts.MarkVerified(username)
+	render(w, r, "notice.html", "Email address", "Thanks! Your email address is verified.")
+}
+
+/*
+🧠 PASSWORD RESET & EMAIL VERIFICATION (handlers/email.go)
+
+✅ What Happens Here:
+- `POST /password/forgot` emails a reset link if the address has an account — and shows the same page either way.
+- `GET /password/reset?token=` shows the form; `POST /password/reset` spends the token, sets the new password, and ends all sessions and remember-me devices.
+- `POST /email/verify` sends a confirmation link; `GET /email/verify?token=` marks the account verified.
+- With `REQUIRE_VERIFIED_EMAIL=true`, `Login` refuses unverified accounts and sends them a fresh link.
+
+✅ Why This Matters:
+- **Account enumeration**: "no such email" tells an attacker which addresses to target. Same words, same status, and sending in the background (same timing) avoid that.
+- A reset is a strong signal the old password is compromised, so every existing login is cut off.
+
+✅ Key Concepts:
+| Concept               | Explanation |
+|-----------------------|-------------|
+| `onetime` tokens      | Hashed, single-use, 30 min (reset) / 24 h (verify) |
+| `sendLater`           | Mail goes out in a goroutine; the response never waits on SMTP |
+| `mail.Compose`        | Renders `NAME.txt` (+ subject) and `NAME.html` templates |
+*/
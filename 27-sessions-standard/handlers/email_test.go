@@ -0,0 +1,375 @@
+package handlers
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	netmail "net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/accounts"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/mail"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/middleware"
+)
+
+// fakeSMTP is just enough of an SMTP server for mail.SMTP: no STARTTLS,
+// no AUTH, every message accepted and handed to the test.
+type fakeSMTP struct {
+	addr string
+	msgs chan smtpMessage
+}
+
+type smtpMessage struct {
+	To   string
+	Text string // the text/plain part, transfer encoding undone
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	f := &fakeSMTP{addr: ln.Addr().String(), msgs: make(chan smtpMessage, 16)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+	reply("220 fake ESMTP")
+	var to string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 fake")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			to = strings.Trim(strings.TrimSpace(line)[len("RCPT TO:"):], "<>")
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, ".")) // undo dot-stuffing
+			}
+			text, err := textPart(data.String())
+			if err != nil {
+				text = "unreadable message: " + err.Error() // fails link()
+			}
+			f.msgs <- smtpMessage{To: to, Text: text}
+			reply("250 queued")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+// textPart returns the decoded text/plain part of a multipart message.
+func textPart(raw string) (string, error) {
+	m, err := netmail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		return "", err
+	}
+	_, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil {
+		return "", err
+	}
+	mr := multipart.NewReader(m.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err != nil {
+			return "", err
+		}
+		if strings.HasPrefix(p.Header.Get("Content-Type"), "text/plain") {
+			b, err := io.ReadAll(p) // NextPart undoes quoted-printable
+			return string(b), err
+		}
+	}
+}
+
+// next waits for the next delivered message.
+func (f *fakeSMTP) next(t *testing.T) smtpMessage {
+	t.Helper()
+	select {
+	case m := <-f.msgs:
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatal("no email arrived")
+		return smtpMessage{}
+	}
+}
+
+// none checks that nothing more is delivered for a little while.
+func (f *fakeSMTP) none(t *testing.T) {
+	t.Helper()
+	select {
+	case m := <-f.msgs:
+		t.Fatalf("unexpected email to %s", m.To)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+var linkPattern = regexp.MustCompile(`http://app\.test(/\S+)`)
+
+// link returns the path and query of the link in m.
+func (m smtpMessage) link(t *testing.T) string {
+	t.Helper()
+	match := linkPattern.FindStringSubmatch(m.Text)
+	if match == nil {
+		t.Fatalf("no link in email to %s:\n%s", m.To, m.Text)
+	}
+	return match[1]
+}
+
+// emailApp routes the email flows the way main does and sends mail to a
+// fake SMTP server.
+func emailApp(t *testing.T) (http.Handler, *fakeSMTP) {
+	t.Helper()
+	smtpd := newFakeSMTP(t)
+	mailer, base, verified := Mailer, BaseURL, RequireVerifiedEmail
+	t.Cleanup(func() { Mailer, BaseURL, RequireVerifiedEmail = mailer, base, verified })
+	Mailer = &mail.SMTP{Addr: smtpd.addr, From: "no-reply@app.test"}
+	BaseURL = "http://app.test"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", Login)
+	mux.HandleFunc("POST /password/forgot", ForgotPassword)
+	mux.HandleFunc("GET /password/reset", ResetPasswordPage)
+	mux.HandleFunc("POST /password/reset", ResetPassword)
+	mux.HandleFunc("GET /email/verify", VerifyEmail)
+	mux.Handle("POST /email/verify", middleware.RequireSession(http.HandlerFunc(SendVerification)))
+	mux.Handle("/dashboard", middleware.RequireSession(http.HandlerFunc(Dashboard)))
+	return mux, smtpd
+}
+
+// newUser adds an unverified account with a password; names must be
+// unique across the package's tests.
+func newUser(t *testing.T, name, password string) accounts.User {
+	t.Helper()
+	u, _, err := accounts.LinkExternal(accounts.External{
+		Issuer: "email_test", Subject: name, Email: name + "@example.com", PreferredUsername: name,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Username != name || u.Verified {
+		t.Fatalf("newUser(%q) = %+v: name taken or already verified", name, u)
+	}
+	if err := accounts.SetPassword(name, password); err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func do(h http.Handler, method, target string, form url.Values, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	r := httptest.NewRequest(method, target, body)
+	if form != nil {
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func login(h http.Handler, username, password string) *httptest.ResponseRecorder {
+	return do(h, http.MethodPost, "/login", url.Values{"user": {username}, "password": {password}})
+}
+
+func TestForgotPasswordSameAnswerForUnknownEmail(t *testing.T) {
+	app, smtpd := emailApp(t)
+	u := newUser(t, "forgetful", "first-password")
+
+	unknown := do(app, http.MethodPost, "/password/forgot", url.Values{"email": {"nobody@example.com"}})
+	known := do(app, http.MethodPost, "/password/forgot", url.Values{"email": {strings.ToUpper(u.Email)}})
+
+	if unknown.Code != known.Code || unknown.Body.String() != known.Body.String() {
+		t.Errorf("responses differ:\nunknown %d %q\nknown   %d %q", unknown.Code, unknown.Body, known.Code, known.Body)
+	}
+	if m := smtpd.next(t); m.To != u.Email {
+		t.Errorf("email went to %q, want %q", m.To, u.Email)
+	}
+	smtpd.none(t) // nothing for the unknown address
+}
+
+func TestPasswordReset(t *testing.T) {
+	testPasswordReset(t, "resetter")
+}
+
+// In cookie mode the old session can't be deleted, so it must be refused.
+func TestPasswordResetCookieSessions(t *testing.T) {
+	useCookieSessions(t)
+	testPasswordReset(t, "cookie-resetter")
+}
+
+func testPasswordReset(t *testing.T, username string) {
+	app, smtpd := emailApp(t)
+	u := newUser(t, username, "old-password")
+	old := login(app, u.Username, "old-password").Result().Cookies()
+
+	do(app, http.MethodPost, "/password/forgot", url.Values{"email": {u.Email}})
+	link := smtpd.next(t).link(t)
+	token := strings.TrimPrefix(link, "/password/reset?token=")
+
+	if w := do(app, http.MethodGet, link, nil); !strings.Contains(w.Body.String(), `name="token" value="`+token+`"`) {
+		t.Fatalf("reset page has no form for the emailed token:\n%s", w.Body)
+	}
+	// A too-short password is refused without burning the link
+	w := do(app, http.MethodPost, "/password/reset", url.Values{"token": {token}, "password": {"short"}})
+	if !strings.Contains(w.Body.String(), accounts.ErrWeakPassword.Error()) {
+		t.Fatalf("short password: %s", w.Body)
+	}
+	w = do(app, http.MethodPost, "/password/reset", url.Values{"token": {token}, "password": {"new-password"}})
+	if !strings.Contains(w.Body.String(), "Your password has been changed") {
+		t.Fatalf("reset: %d %s", w.Code, w.Body)
+	}
+
+	// Used once: the same link is dead
+	w = do(app, http.MethodPost, "/password/reset", url.Values{"token": {token}, "password": {"third-password"}})
+	if !strings.Contains(w.Body.String(), badLink) {
+		t.Errorf("second use of the link: %s", w.Body)
+	}
+	if w := do(app, http.MethodGet, link, nil); !strings.Contains(w.Body.String(), badLink) {
+		t.Errorf("reset page after use: %s", w.Body)
+	}
+
+	if w := do(app, http.MethodGet, "/dashboard", nil, old...); w.Code != http.StatusUnauthorized {
+		t.Errorf("session from before the reset: %d, want 401", w.Code)
+	}
+	if w := login(app, u.Username, "old-password"); w.Code != http.StatusUnauthorized {
+		t.Errorf("old password: %d, want 401", w.Code)
+	}
+	if w := login(app, u.Username, "new-password"); w.Code != http.StatusOK {
+		t.Errorf("new password: %d %s", w.Code, w.Body)
+	}
+	if u, _ := accounts.Lookup(u.Username); !u.Verified {
+		t.Error("a completed reset should verify the address")
+	}
+}
+
+func TestPasswordResetLinkExpires(t *testing.T) {
+	app, smtpd := emailApp(t)
+	u := newUser(t, "slowpoke", "old-password")
+	ttl := resetTTL
+	resetTTL = 50 * time.Millisecond
+	t.Cleanup(func() { resetTTL = ttl })
+
+	do(app, http.MethodPost, "/password/forgot", url.Values{"email": {u.Email}})
+	link := smtpd.next(t).link(t)
+	time.Sleep(100 * time.Millisecond)
+
+	if w := do(app, http.MethodGet, link, nil); !strings.Contains(w.Body.String(), badLink) {
+		t.Errorf("expired link shows: %s", w.Body)
+	}
+	token := strings.TrimPrefix(link, "/password/reset?token=")
+	w := do(app, http.MethodPost, "/password/reset", url.Values{"token": {token}, "password": {"new-password"}})
+	if !strings.Contains(w.Body.String(), badLink) {
+		t.Errorf("expired link accepted: %s", w.Body)
+	}
+	if w := login(app, u.Username, "old-password"); w.Code != http.StatusOK {
+		t.Errorf("password changed through an expired link: login %d", w.Code)
+	}
+}
+
+func TestEmailVerification(t *testing.T) {
+	app, smtpd := emailApp(t)
+	u := newUser(t, "unverified", "the-password")
+	RequireVerifiedEmail = true
+
+	// Unverified: no session, and a fresh link in the mail
+	w := login(app, u.Username, "the-password")
+	if w.Code != http.StatusForbidden || len(w.Result().Cookies()) != 0 {
+		t.Fatalf("unverified login: %d, cookies %v; want 403 and none", w.Code, w.Result().Cookies())
+	}
+	m := smtpd.next(t)
+	if m.To != u.Email {
+		t.Fatalf("verification went to %q, want %q", m.To, u.Email)
+	}
+	link := m.link(t)
+
+	if w := do(app, http.MethodGet, "/email/verify?token=not-a-token", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("bogus token: %d, want 400", w.Code)
+	}
+	if w := do(app, http.MethodGet, link, nil); !strings.Contains(w.Body.String(), "is verified") {
+		t.Fatalf("verify link: %d %s", w.Code, w.Body)
+	}
+	if w := do(app, http.MethodGet, link, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("second use of the link: %d, want 400", w.Code)
+	}
+
+	w = login(app, u.Username, "the-password")
+	if w.Code != http.StatusOK {
+		t.Fatalf("verified login: %d %s", w.Code, w.Body)
+	}
+	// Asking again once verified sends nothing
+	w = do(app, http.MethodPost, "/email/verify", nil, w.Result().Cookies()...)
+	if !strings.Contains(w.Body.String(), "already verified") {
+		t.Errorf("resend when verified: %s", w.Body)
+	}
+	smtpd.none(t)
+}
+
+func TestResendVerification(t *testing.T) {
+	app, smtpd := emailApp(t)
+	u := newUser(t, "resender", "the-password")
+
+	// Without RequireVerifiedEmail the login works and the user asks for a link
+	cookies := login(app, u.Username, "the-password").Result().Cookies()
+	w := do(app, http.MethodPost, "/email/verify", nil, cookies...)
+	if !strings.Contains(w.Body.String(), "sent a confirmation link") {
+		t.Fatalf("resend: %d %s", w.Code, w.Body)
+	}
+	first := smtpd.next(t).link(t)
+	do(app, http.MethodPost, "/email/verify", nil, cookies...)
+	second := smtpd.next(t).link(t)
+
+	// Only the newest link works
+	if w := do(app, http.MethodGet, first, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("superseded link: %d, want 400", w.Code)
+	}
+	if w := do(app, http.MethodGet, second, nil); w.Code != http.StatusOK {
+		t.Errorf("newest link: %d %s", w.Code, w.Body)
+	}
+}
@@ -1,45 +1,138 @@
 package handlers
 
 import (
+	"context"
+	"log"
 	"net/http"
+	"time"
 
-	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/middleware"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/accounts"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/mfa"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/remember"
 	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/sessionstore"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/throttle"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/middleware"
 )
 
 func Home(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("Welcome to the homepage. Visit /login to authenticate."))
 }
 
+// Throttle tracks failed logins; main swaps in a SQL-backed one when
+// THROTTLE_DB is set.
+var Throttle = throttle.New(throttle.NewMemoryStore())
+
+// loginView is what login.html shows.
+type loginView struct {
+	Username, Error string
+	SSO             bool // show "Sign in with SSO"
+}
+
+// Login checks the form fields user and password (GET with no password
+// shows the form); remember=1 also keeps the user signed in across browser
+// restarts. The session itself is started by signIn.
 func Login(w http.ResponseWriter, r *http.Request) {
-	sessionID := sessionstore.CreateSession("demo_user")
-
-	cookie := &http.Cookie{
-		Name:     "session_token",
-		Value:    sessionID,
-		Path:     "/",
-		HttpOnly: true,
-		SameSite: http.SameSiteStrictMode,
+	username, password := r.FormValue("user"), r.FormValue("password")
+	if r.Method == http.MethodGet && password == "" {
+		render(w, r, "login.html", "Log in", loginView{Username: username, SSO: SSO != nil})
+		return
+	}
+	ip := sessionstore.ClientOf(r).IP
+	decision, err := Throttle.Check(r.Context(), username, ip)
+	if err != nil {
+		log.Printf("❌ login throttle: %v", err)
+		http.Error(w, "Could not log in right now", http.StatusInternalServerError)
+		return
+	}
+	if !sleep(r.Context(), decision.Delay) {
+		return // client gave up
+	}
+
+	// The password is checked even when locked, so both take as long
+	u, ok := accounts.Authenticate(username, password)
+	if decision.Locked || !ok {
+		if err := Throttle.Failure(r.Context(), username, ip); err != nil {
+			log.Printf("❌ login throttle: %v", err)
+		}
+		// One message for unknown users, wrong passwords and lockouts alike
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusUnauthorized)
+		render(w, r, "login.html", "Log in", loginView{Username: username, Error: "Invalid username or password.", SSO: SSO != nil})
+		return
+	}
+	if err := Throttle.Success(r.Context(), username); err != nil {
+		log.Printf("❌ login throttle: %v", err)
+	}
+	if RequireVerifiedEmail && !u.Verified {
+		sendVerification(u)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusForbidden)
+		render(w, r, "notice.html", "Verify your email address",
+			"Please confirm your email address first. We've sent a new link to "+u.Email+".")
+		return
 	}
-	http.SetCookie(w, cookie)
 
-	w.Write([]byte("✅ Logged in. Visit /dashboard"))
+	signIn(w, r, username, r.FormValue("remember") == "1")
+}
+
+// signIn starts a session for a user who has just proved who they are
+// (password or SSO) and writes the response. Any session the browser
+// already presented is discarded, so an ID fixed by an attacker before
+// login is never promoted.
+func signIn(w http.ResponseWriter, r *http.Request, username string, keep bool) {
+	if old, err := r.Cookie(sessionstore.CookieName); err == nil {
+		sessionstore.DeleteSession(old.Value)
+	}
+
+	// With 2FA on, this is only step one: the session stays pending until
+	// SecondFactorLogin accepts a code.
+	if mfa.Enabled(username) {
+		sessionstore.SetCookie(w, sessionstore.CreatePendingSession(username, sessionstore.ClientOf(r)))
+		w.Write([]byte("🔑 Now enter the code from your authenticator app: POST code=123456 to /login/2fa (add remember=1 to stay signed in)"))
+		return
+	}
+
+	sessionID := sessionstore.CreateSession(username, sessionstore.ClientOf(r))
+	sessionstore.SetCookie(w, sessionID)
+
+	if keep {
+		if old, err := r.Cookie(remember.CookieName); err == nil {
+			remember.Revoke(old.Value)
+		}
+		session, _ := sessionstore.GetSession(sessionID)
+		value, expires := remember.Issue(username, session.Ref)
+		remember.SetCookie(w, value, expires)
+	}
+
+	w.Write([]byte("✅ Logged in as " + username + ". Visit /dashboard or /sessions"))
+}
+
+// sleep waits d, or until the request is cancelled (then it returns false).
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
 }
 
 func Logout(w http.ResponseWriter, r *http.Request) {
-	cookie, err := r.Cookie("session_token")
+	cookie, err := r.Cookie(sessionstore.CookieName)
 	if err == nil {
 		sessionstore.DeleteSession(cookie.Value)
 	}
-
-	expired := &http.Cookie{
-		Name:     "session_token",
-		Value:    "",
-		Path:     "/",
-		HttpOnly: true,
-		MaxAge:   -1,
+	if cookie, err := r.Cookie(remember.CookieName); err == nil {
+		remember.Revoke(cookie.Value)
 	}
-	http.SetCookie(w, expired)
+
+	sessionstore.ClearCookie(w)
+	remember.ClearCookie(w)
 
 	w.Write([]byte("🚪 Logged out."))
 }
@@ -59,8 +152,12 @@ func Dashboard(w http.ResponseWriter, r *http.Request) {
 
 ✅ What Happens Here:
 - `Home` serves a public message with a prompt to authenticate.
-- `Login` generates a random session ID for a hardcoded user, stores it server-side, and sets a secure cookie.
-- `Logout` invalidates the session both server-side and client-side by deleting the token and expiring the cookie.
+- `Login` checks the username and password (PBKDF2 hashes in `accounts`), then generates a fresh random session ID, stores it server-side with the device's IP and browser, and sets a secure cookie.
+- Failed logins are throttled per account and per IP (growing delay, then a temporary lockout) — with the same error message throughout.
+- `/login/sso` signs in through the company identity provider instead (see `sso.go`); both paths end in `signIn`.
+- For a user with 2FA, `Login` only creates a *pending* session; `/login/2fa` finishes the job.
+- `Login?remember=1` additionally issues a remember-me token, so the user stays signed in after the session ends.
+- `Logout` invalidates the session both server-side and client-side by deleting the token and expiring the cookie — and forgets the device's remember-me token.
 - `Dashboard` is a protected route that reads the username from request context (populated by middleware).
 
 ✅ Why This Matters:
@@ -72,7 +169,7 @@ func Dashboard(w http.ResponseWriter, r *http.Request) {
 | Handler     | Responsibility                                      |
 |-------------|------------------------------------------------------|
 | `Home`      | Public-facing content, no session logic             |
-| `Login`     | Creates a session token and returns it as a cookie  |
+| `Login`     | Creates a new session token (never reuses one) and returns it as a cookie |
 | `Logout`    | Deletes the session token and clears the cookie     |
 | `Dashboard` | Reads user identity from context (set by middleware) |
 
@@ -82,7 +179,6 @@ func Dashboard(w http.ResponseWriter, r *http.Request) {
 - The context key uses a custom type to prevent collisions (Go best practice).
 
 📚 Up Next:
-- Replace hardcoded users with real login forms and credential validation.
 - Implement session timeout logic or sliding expiration.
 - Introduce persistent session storage (e.g., Redis, PostgreSQL).
 */
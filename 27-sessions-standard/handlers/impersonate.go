@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/accounts"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/audit"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/remember"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/sessionstore"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/middleware"
+)
+
+// StartImpersonation is POST /admin/users/{username}/impersonate: the
+// admin's session starts acting as that user. Accounts that may
+// impersonate can't be impersonated, so nobody gains privileges this way.
+func StartImpersonation(w http.ResponseWriter, r *http.Request) {
+	admin, _ := middleware.GetRealUserFromContext(r)
+	current, _ := middleware.GetSessionFromContext(r)
+	target, ok := accounts.Lookup(r.PathValue("username"))
+	switch {
+	case !ok:
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	case target.Username == admin || target.Can(accounts.PermImpersonate):
+		http.Error(w, "This account can't be impersonated", http.StatusForbidden)
+		return
+	}
+
+	sessionID, ok := sessionstore.StartImpersonation(current.ID, target.Username)
+	if !ok {
+		http.Error(w, "Session expired", http.StatusUnauthorized)
+		return
+	}
+	sessionstore.SetCookie(w, sessionID)
+	rebindRemember(current, sessionID)
+	audit.Record(audit.Event{Actor: admin, Action: "impersonation.start", Target: target.Username, IP: sessionstore.ClientOf(r).IP})
+	w.Write([]byte("🎭 You are now acting as " + target.Username + ". POST /impersonate/stop to return to your account."))
+}
+
+// StopImpersonation is POST /impersonate/stop, the banner's "Return to my
+// account" button.
+func StopImpersonation(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Impersonating(r) {
+		http.Error(w, "You are not impersonating anyone", http.StatusBadRequest)
+		return
+	}
+	admin, _ := middleware.GetRealUserFromContext(r)
+	target, _ := middleware.GetUserFromContext(r)
+	current, _ := middleware.GetSessionFromContext(r)
+
+	sessionID, ok := sessionstore.StopImpersonation(current.ID)
+	if !ok {
+		http.Error(w, "Session expired", http.StatusUnauthorized)
+		return
+	}
+	sessionstore.SetCookie(w, sessionID)
+	rebindRemember(current, sessionID)
+	audit.Record(audit.Event{Actor: admin, Action: "impersonation.stop", Target: target, IP: sessionstore.ClientOf(r).IP})
+	http.Redirect(w, r, "/sessions", http.StatusSeeOther)
+}
+
+// rebindRemember keeps the owner's remember-me device attached to the
+// session after a change that gave it a new Ref (cookie mode).
+func rebindRemember(before sessionstore.Session, sessionID string) {
+	if after, ok := sessionstore.GetSession(sessionID); ok {
+		remember.Rebind(before.Username, before.Ref, after.Ref)
+	}
+}
+
+/*
+🧠 "LOG IN AS USER" FOR SUPPORT (handlers/impersonate.go)
+
+✅ What Happens Here:
+- `POST /admin/users/{username}/impersonate` (permission `impersonate`, 2FA on) sets `ActingAs` on the admin's own session and gives it a new ID.
+- From then on every handler sees the user (`GetUserFromContext`), while `GetRealUserFromContext` and the audit log still know the admin.
+- Every page shows a banner with a "Return to my account" button: `POST /impersonate/stop`.
+
+✅ Why This Matters:
+- Support can see exactly what the user sees without asking for their password.
+- The session still belongs to the admin: it appears on *their* sessions page, and logging out ends it there, not for the user.
+- Admins can't impersonate other admins, and credential changes (2FA) are refused while impersonating.
+
+✅ Key Concepts:
+| Concept              | Explanation |
+|----------------------|-------------|
+| Real principal       | Who logged in (`Session.Username`) |
+| Effective principal  | Who the app treats them as (`Session.Effective()`) |
+| `impersonation.*`    | `start`, `stop`, and one `request` entry per request in between |
+*/
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/accounts"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/audit"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/middleware"
+)
+
+// impersonateApp routes impersonation the way main does (minus the 2FA
+// requirement), plus /whoami, which reports both identities.
+func impersonateApp() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", Login)
+	mux.Handle("POST /admin/users/{username}/impersonate", middleware.RequireSession(
+		middleware.RequirePermission(accounts.PermImpersonate)(http.HandlerFunc(StartImpersonation))))
+	mux.Handle("POST /impersonate/stop", middleware.RequireSession(http.HandlerFunc(StopImpersonation)))
+	mux.Handle("GET /whoami", middleware.RequireSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _ := middleware.GetUserFromContext(r)
+		real, _ := middleware.GetRealUserFromContext(r)
+		io.WriteString(w, user+" as seen by "+real)
+	})))
+	mux.Handle("POST /2fa", middleware.RequireSession(middleware.NotImpersonating(http.HandlerFunc(ConfirmTwoFactor))))
+	mux.Handle("POST /settings/tokens", middleware.RequireSession(middleware.NotImpersonating(http.HandlerFunc(CreateToken))))
+	return mux
+}
+
+// newAdmin adds an account that may impersonate.
+func newAdmin(t *testing.T, name string) {
+	t.Helper()
+	newUser(t, name, "the-password")
+	if err := accounts.SetRoles(name, accounts.RoleUser, accounts.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func whoami(t *testing.T, app http.Handler, session *http.Cookie) string {
+	t.Helper()
+	w := do(app, http.MethodGet, "/whoami", nil, session)
+	if w.Code != http.StatusOK {
+		return w.Result().Status
+	}
+	return w.Body.String()
+}
+
+func TestImpersonationRefused(t *testing.T) {
+	app := impersonateApp()
+	newAdmin(t, "support-refused")
+	newAdmin(t, "other-admin")
+	newUser(t, "plain-user", "the-password")
+	admin := sessionCookie(login(app, "support-refused", "the-password"))
+	user := sessionCookie(login(app, "plain-user", "the-password"))
+
+	tests := []struct {
+		name    string
+		session *http.Cookie
+		target  string
+		want    int
+	}{
+		{"yourself", admin, "support-refused", http.StatusForbidden},
+		{"another account that may impersonate", admin, "other-admin", http.StatusForbidden},
+		{"unknown account", admin, "nobody-here", http.StatusNotFound},
+		{"without the permission", user, "support-refused", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := do(app, http.MethodPost, "/admin/users/"+tt.target+"/impersonate", nil, tt.session); w.Code != tt.want {
+				t.Errorf("status %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+	if got := whoami(t, app, admin); got != "support-refused as seen by support-refused" {
+		t.Errorf("after refusals the admin is %q", got)
+	}
+
+	// Admin rights taken away mid-impersonation end it on the next request.
+	newUser(t, "demoted-target", "the-password")
+	w := do(app, http.MethodPost, "/admin/users/demoted-target/impersonate", nil, admin)
+	acting := sessionCookie(w)
+	if w.Code != http.StatusOK || acting == nil {
+		t.Fatalf("impersonate: %d", w.Code)
+	}
+	if err := accounts.SetRoles("support-refused", accounts.RoleUser); err != nil {
+		t.Fatal(err)
+	}
+	if got := whoami(t, app, acting); got != "support-refused as seen by support-refused" {
+		t.Errorf("after losing the permission: %q, want back to their own account", got)
+	}
+}
+
+func TestImpersonation(t *testing.T) {
+	for _, mode := range []string{"server", "cookie"} {
+		t.Run(mode, func(t *testing.T) {
+			if mode == "cookie" {
+				useCookieSessions(t)
+			}
+			app := impersonateApp()
+			support, target := "support-"+mode, "customer-"+mode
+			newAdmin(t, support)
+			newUser(t, target, "the-password")
+			before := sessionCookie(login(app, support, "the-password"))
+
+			w := do(app, http.MethodPost, "/admin/users/"+target+"/impersonate", nil, before)
+			acting := sessionCookie(w)
+			if w.Code != http.StatusOK || acting == nil || acting.Value == before.Value {
+				t.Fatalf("impersonate: %d, want 200 and a new session cookie", w.Code)
+			}
+			if got, want := whoami(t, app, acting), target+" as seen by "+support; got != want {
+				t.Errorf("while impersonating: %q, want %q", got, want)
+			}
+
+			// Credential changes belong to the user, not to support.
+			for _, path := range []string{"/2fa", "/settings/tokens"} {
+				if w := do(app, http.MethodPost, path, nil, acting); w.Code != http.StatusForbidden {
+					t.Errorf("POST %s while impersonating: %d, want 403", path, w.Code)
+				}
+			}
+
+			time.Sleep(2 * time.Millisecond) // cookie sessions carry milliseconds
+			w = do(app, http.MethodPost, "/impersonate/stop", nil, acting)
+			after := sessionCookie(w)
+			if w.Code != http.StatusSeeOther || after == nil || after.Value == acting.Value {
+				t.Fatalf("stop: %d, want 303 and a new session cookie", w.Code)
+			}
+			if got, want := whoami(t, app, after), support+" as seen by "+support; got != want {
+				t.Errorf("after stop: %q, want %q", got, want)
+			}
+			if got := whoami(t, app, acting); got != "401 Unauthorized" {
+				t.Errorf("impersonating cookie after stop: %s, want 401", got)
+			}
+			if w := do(app, http.MethodPost, "/impersonate/stop", nil, after); w.Code != http.StatusBadRequest {
+				t.Errorf("stop when not impersonating: %d, want 400", w.Code)
+			}
+
+			// Newest first, every step names the admin as actor and the user
+			// as target, refused requests included.
+			var trail []string
+			for _, e := range audit.Recent(100) {
+				if e.Actor == support && e.Target == target {
+					trail = append(trail, e.Action+" "+e.Detail)
+				}
+			}
+			want := []string{
+				"impersonation.stop ",
+				"impersonation.request POST /impersonate/stop",
+				"impersonation.request POST /settings/tokens",
+				"impersonation.request POST /2fa",
+				"impersonation.request GET /whoami",
+				"impersonation.start ",
+			}
+			if !slices.Equal(trail, want) {
+				t.Errorf("audit trail = %q, want %q", trail, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/accounts"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/audit"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/remember"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/sessionstore"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/middleware"
+)
+
+//go:embed templates/*.html
+var templateFiles embed.FS
+
+// pages holds one template set per page, each combined with the layout.
+var pages = map[string]*template.Template{}
+
+func init() {
+	for _, name := range []string{"sessions.html", "twofactor.html", "login.html", "forgot.html", "reset.html", "notice.html", "tokens.html"} {
+		pages[name] = template.Must(template.ParseFS(templateFiles, "templates/layout.html", "templates/"+name))
+	}
+}
+
+// page is what the layout template receives; Data is the page's own.
+type page struct {
+	Title        string
+	User         string
+	Impersonator string // the admin behind User, while impersonating
+	Data         any
+}
+
+func render(w http.ResponseWriter, r *http.Request, name, title string, data any) {
+	username, _ := middleware.GetUserFromContext(r)
+	p := page{Title: title, User: username, Data: data}
+	if middleware.Impersonating(r) {
+		p.Impersonator, _ = middleware.GetRealUserFromContext(r)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := pages[name].ExecuteTemplate(w, "layout", p); err != nil {
+		log.Println("render", name+":", err)
+	}
+}
+
+// sessionView is one row of the active sessions page and API.
+type sessionView struct {
+	sessionstore.Session
+	Device  string `json:"device"`
+	Current bool   `json:"current"`
+}
+
+// mySessions lists the caller's sessions. Called with a token there is no
+// current session: current is the zero Session, whose Ref matches nothing.
+func mySessions(r *http.Request) []sessionView {
+	username, _ := middleware.GetRealUserFromContext(r)
+	current, hasSession := middleware.GetSessionFromContext(r)
+	if sessionstore.Stateless() {
+		// Cookie sessions aren't listed anywhere: this device is all we know
+		if !hasSession {
+			return nil
+		}
+		return []sessionView{{Session: current, Device: describeAgent(current.UserAgent), Current: true}}
+	}
+	var views []sessionView
+	for _, s := range sessionstore.ListSessions(username) {
+		views = append(views, sessionView{Session: s, Device: describeAgent(s.UserAgent), Current: s.Ref == current.Ref})
+	}
+	return views
+}
+
+// SessionsPage lists the user's logins with buttons to end them.
+func SessionsPage(w http.ResponseWriter, r *http.Request) {
+	render(w, r, "sessions.html", "Active sessions", mySessions(r))
+}
+
+// RevokeSession ends one session (form POST from the sessions page). Ending
+// the current one is the same as logging out.
+func RevokeSession(w http.ResponseWriter, r *http.Request) {
+	current, _ := middleware.GetSessionFromContext(r)
+	ref := r.PathValue("ref")
+	if !sessionstore.DeleteByRef(current.Username, ref) && ref != current.Ref {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	remember.RevokeRef(current.Username, ref)
+	if ref == current.Ref {
+		sessionstore.ClearCookie(w)
+		remember.ClearCookie(w)
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/sessions", http.StatusSeeOther)
+}
+
+// RevokeOtherSessions signs the user out on every other device.
+func RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	current, _ := middleware.GetSessionFromContext(r)
+	sessionstore.DeleteUserSessions(current.Username, current.ID)
+	remember.RevokeUser(current.Username, current.Ref)
+	http.Redirect(w, r, "/sessions", http.StatusSeeOther)
+}
+
+// ListSessionsAPI is GET /api/sessions.
+func ListSessionsAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, mySessions(r))
+}
+
+// DeleteSessionAPI is DELETE /api/sessions/{ref}: 204, or 404 for a
+// session that is not the caller's.
+func DeleteSessionAPI(w http.ResponseWriter, r *http.Request) {
+	username, _ := middleware.GetRealUserFromContext(r)
+	current, _ := middleware.GetSessionFromContext(r)
+	ref := r.PathValue("ref")
+	if !sessionstore.DeleteByRef(username, ref) && ref != current.Ref {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	remember.RevokeRef(username, ref)
+	if ref == current.Ref {
+		sessionstore.ClearCookie(w)
+		remember.ClearCookie(w)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteOtherSessionsAPI is DELETE /api/sessions: end all sessions but
+// this one (all of them, called with a token).
+func DeleteOtherSessionsAPI(w http.ResponseWriter, r *http.Request) {
+	username, _ := middleware.GetRealUserFromContext(r)
+	current, _ := middleware.GetSessionFromContext(r)
+	n := sessionstore.DeleteUserSessions(username, current.ID)
+	remember.RevokeUser(username, current.Ref)
+	writeJSON(w, http.StatusOK, map[string]any{"revoked": revokedCount(n)})
+}
+
+// ForceLogout is the admin action POST /admin/users/{username}/logout: it
+// ends every session of that user, on every device.
+func ForceLogout(w http.ResponseWriter, r *http.Request) {
+	username := r.PathValue("username")
+	if _, ok := accounts.Lookup(username); !ok {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	n := revokedCount(sessionstore.DeleteUserSessions(username, ""))
+	remember.RevokeUser(username, "")
+	admin, _ := middleware.GetUserFromContext(r)
+	audit.Record(audit.Event{Actor: admin, Action: "sessions.revoked", Target: username, IP: sessionstore.ClientOf(r).IP, Detail: fmt.Sprintf("%v sessions", n)})
+	writeJSON(w, http.StatusOK, map[string]any{"username": username, "revoked": n})
+}
+
+// revokedCount is how the API reports DeleteUserSessions' result: the
+// number ended, or "all" in cookie mode, which can't count them.
+func revokedCount(n int) any {
+	if n == sessionstore.AllSessions {
+		return "all"
+	}
+	return n
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// describeAgent turns a User-Agent header into "Firefox on Linux".
+func describeAgent(ua string) string {
+	browser := "Unknown browser"
+	for _, b := range []struct{ token, name string }{
+		{"Edg/", "Edge"}, {"Firefox/", "Firefox"}, {"Chrome/", "Chrome"},
+		{"Safari/", "Safari"}, {"curl/", "curl"},
+	} {
+		if strings.Contains(ua, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	for _, o := range []struct{ token, name string }{
+		{"Android", "Android"}, {"iPhone", "iOS"}, {"iPad", "iOS"},
+		{"Windows", "Windows"}, {"Mac OS", "macOS"}, {"Linux", "Linux"},
+	} {
+		if strings.Contains(ua, o.token) {
+			return browser + " on " + o.name
+		}
+	}
+	return browser
+}
+
+/*
+🧠 ACTIVE SESSIONS PAGE, API & ADMIN FORCE-LOGOUT
+
+✅ What Happens Here:
+- `GET /sessions` renders every login of the current user (device, IP, started, last seen) with "Sign out" buttons.
+- The same data is JSON at `GET /api/sessions`; `DELETE /api/sessions/{id}` ends one, `DELETE /api/sessions` all others.
+- `POST /admin/users/{username}/logout` (admins only) ends all of a user's sessions.
+
+✅ Why This Matters:
+- A user who forgot to sign out on a shared computer, or sees a device they don't own, can fix it themselves.
+- Revocation is instant because the session lives on the server: deleting it is enough. The device's remember-me token goes with it, so it can't silently sign back in.
+- In cookie mode (`SESSION_MODE=cookie`) the server has no list: the page shows only this device. Signing out elsewhere still works, because the server refuses cookies from before the sign-out; the API then reports `"revoked": "all"` instead of a count.
+- Sessions are addressed by their public `Ref`, and only within the caller's own sessions, so one user cannot end another's.
+
+✅ Key Concepts:
+| Concept                    | Explanation |
+|----------------------------|-------------|
+| `r.PathValue("ref")`       | Path parameters from Go 1.22 `ServeMux` patterns |
+| `303 See Other`            | Redirect after a form POST so refresh doesn't resubmit |
+| `embed.FS` + `html/template` | Templates compiled into the binary, auto-escaped |
+| Layout + page templates    | Each page defines "content"; the layout wraps it |
+*/
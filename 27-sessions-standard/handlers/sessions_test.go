@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/cookiecodec"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/sessionstore"
+)
+
+// useCookieSessions switches sessionstore to cookie mode until t ends.
+func useCookieSessions(t *testing.T) {
+	t.Helper()
+	keys, err := cookiecodec.ParseKeys("test:" + cookiecodec.GenerateKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	codec, err := cookiecodec.New(time.Hour, keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionstore.UseCookies(codec)
+	t.Cleanup(func() { sessionstore.UseCookies(nil) })
+}
+
+func TestForceLogout(t *testing.T) {
+	tests := []struct {
+		name        string
+		cookies     bool
+		wantRevoked any // as decoded from JSON
+	}{
+		{"server sessions", false, float64(2)},
+		{"cookie sessions", true, "all"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.cookies {
+				useCookieSessions(t)
+			}
+			app, _ := emailApp(t)
+			mux := http.NewServeMux()
+			mux.Handle("/", app)
+			mux.HandleFunc("POST /admin/users/{username}/logout", ForceLogout)
+
+			username := []string{"kicked", "kicked-cookie"}[i]
+			newUser(t, username, "the-password")
+			laptop := login(mux, username, "the-password").Result().Cookies()
+			phone := login(mux, username, "the-password").Result().Cookies()
+			time.Sleep(2 * time.Millisecond) // cookie sessions carry milliseconds
+
+			w := do(mux, http.MethodPost, "/admin/users/"+username+"/logout", nil)
+			var got struct{ Revoked any }
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil || w.Code != http.StatusOK {
+				t.Fatalf("ForceLogout: %d, %v", w.Code, err)
+			}
+			if got.Revoked != tt.wantRevoked {
+				t.Errorf("revoked = %#v, want %#v", got.Revoked, tt.wantRevoked)
+			}
+			for _, device := range [][]*http.Cookie{laptop, phone} {
+				if w := do(mux, http.MethodGet, "/dashboard", nil, device...); w.Code != http.StatusUnauthorized {
+					t.Errorf("dashboard after force-logout: %d, want 401", w.Code)
+				}
+			}
+		})
+	}
+}
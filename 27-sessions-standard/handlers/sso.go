@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/accounts"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/audit"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/oidc"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/sessionstore"
+)
+
+// SSO is the company identity provider; nil (the default) turns SSO off.
+// main sets it from the OIDC_* environment variables.
+var SSO *oidc.Client
+
+// ssoCookie binds a login started here to the browser that started it.
+// It is SameSite=Lax, not Strict: the callback is a redirect from the
+// provider's site, and a Strict cookie wouldn't come along.
+const ssoCookie = "sso_state"
+
+// SSOLogin is GET /login/sso: off to the identity provider. remember=1
+// keeps the user signed in afterwards, as with a password login.
+func SSOLogin(w http.ResponseWriter, r *http.Request) {
+	if SSO == nil {
+		http.NotFound(w, r)
+		return
+	}
+	authURL, state := SSO.AuthCodeURL()
+	keep := "0"
+	if r.FormValue("remember") == "1" {
+		keep = "1"
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     ssoCookie,
+		Value:    keep + ":" + state,
+		Path:     "/login/sso",
+		MaxAge:   600,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// SSOCallback is GET /login/sso/callback, where the provider sends the
+// browser back with a code. It verifies the login, finds or creates the
+// account, and signs the user in like a password login would.
+func SSOCallback(w http.ResponseWriter, r *http.Request) {
+	if SSO == nil {
+		http.NotFound(w, r)
+		return
+	}
+	fail := func(status int, msg string) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+		render(w, r, "notice.html", "Single sign-on", msg)
+	}
+	http.SetCookie(w, &http.Cookie{Name: ssoCookie, Path: "/login/sso", MaxAge: -1, HttpOnly: true})
+
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		log.Printf("⚠️ SSO login refused by provider: %s %s", e, q.Get("error_description"))
+		fail(http.StatusUnauthorized, "The identity provider did not sign you in. Please try again.")
+		return
+	}
+	c, err := r.Cookie(ssoCookie)
+	keep, state, _ := strings.Cut(cookieValue(c, err), ":")
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(q.Get("state"))) != 1 {
+		// A callback this browser didn't start: login CSRF, or a stale tab.
+		fail(http.StatusBadRequest, "This sign-in link has expired. Please start again.")
+		return
+	}
+
+	claims, err := SSO.Finish(r.Context(), state, q.Get("code"))
+	if err != nil {
+		log.Printf("❌ SSO login: %v", err)
+		status := http.StatusUnauthorized
+		if errors.Is(err, oidc.ErrState) {
+			status = http.StatusBadRequest
+		}
+		fail(status, "We couldn't verify your sign-in. Please try again.")
+		return
+	}
+
+	u, how, err := accounts.LinkExternal(accounts.External{
+		Issuer:            claims.Issuer,
+		Subject:           claims.Subject,
+		Email:             claims.Email,
+		EmailVerified:     claims.EmailVerified,
+		PreferredUsername: claims.PreferredUsername,
+	})
+	if errors.Is(err, accounts.ErrEmailInUse) {
+		fail(http.StatusConflict, "An account with the email "+claims.Email+" already exists. Log in with your password, or ask your identity provider to verify the address.")
+		return
+	}
+	if err != nil {
+		log.Printf("❌ SSO login: %v", err)
+		fail(http.StatusInternalServerError, "Could not sign you in right now.")
+		return
+	}
+	if how != accounts.LinkedBefore {
+		audit.Record(audit.Event{Actor: u.Username, Action: "sso." + how, Target: u.Username,
+			IP: sessionstore.ClientOf(r).IP, Detail: claims.Issuer + " " + claims.Subject})
+	}
+	if RequireVerifiedEmail && !u.Verified {
+		sendVerification(u)
+		fail(http.StatusForbidden, "Please confirm your email address first. We've sent a new link to "+u.Email+".")
+		return
+	}
+
+	signIn(w, r, u.Username, keep == "1")
+}
+
+func cookieValue(c *http.Cookie, err error) string {
+	if err != nil {
+		return ""
+	}
+	return c.Value
+}
+
+/*
+🧠 SIGN IN WITH SSO (handlers/sso.go)
+
+✅ What Happens Here:
+- `GET /login/sso` asks `oidc` for the provider URL, remembers the `state` in a short-lived cookie, and redirects.
+- `GET /login/sso/callback` checks the state against that cookie, lets `oidc.Finish` redeem the code and verify the ID token, then maps the identity to an account with `accounts.LinkExternal`.
+- From there it is an ordinary login: `signIn` creates the same server-side session (pending, if the account has 2FA), so `RequireSession` and everything behind it just work.
+
+✅ Why This Matters:
+- The state cookie stops **login CSRF**: an attacker can't finish *their* login in *your* browser.
+- First logins are audited (`sso.linked`, `sso.provisioned`), so a new account or a new link to an existing one leaves a trace.
+- Errors from the provider or the verifier are logged in detail but shown to the user in plain words.
+
+✅ Key Concepts:
+| Step             | Where                    |
+|------------------|--------------------------|
+| state / nonce / PKCE | `oidc.AuthCodeURL`   |
+| Code → ID token  | `oidc.Finish`            |
+| Who is this?     | `accounts.LinkExternal`  |
+| Session          | `signIn` (shared with `/login`) |
+*/
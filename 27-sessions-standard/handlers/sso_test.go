@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/accounts"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/oidc"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/oidc/oidctest"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/sessionstore"
+)
+
+// ssoApp points SSO at a fresh fake provider and routes /login/sso.
+func ssoApp(t *testing.T) (http.Handler, *oidctest.Provider) {
+	t.Helper()
+	p := oidctest.NewProvider("lesson-27", "secret")
+	t.Cleanup(p.Close)
+	p.Users["mallory"] = oidctest.User{Subject: "mallory-1", Email: "demo@example.com", PreferredUsername: "mallory"}
+	p.Users["newbie"] = oidctest.User{Subject: "newbie-1", Email: "newbie@sso.example", PreferredUsername: "newbie"}
+
+	client, err := oidc.New(context.Background(), oidc.Config{
+		Issuer: p.Issuer(), ClientID: p.ClientID, ClientSecret: p.ClientSecret,
+		RedirectURL: "http://app.test/login/sso/callback",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sso, verified := SSO, RequireVerifiedEmail
+	t.Cleanup(func() { SSO, RequireVerifiedEmail = sso, verified })
+	SSO = client
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /login/sso", SSOLogin)
+	mux.HandleFunc("GET /login/sso/callback", SSOCallback)
+	return mux, p
+}
+
+// ssoLogin runs the browser's round trip: start at /login/sso, sign in at
+// the provider as loginHint ("" for its default user), and return the
+// callback URL and the state cookie.
+func ssoLogin(t *testing.T, app http.Handler, loginHint string) (callback string, state *http.Cookie) {
+	t.Helper()
+	w := do(app, http.MethodGet, "/login/sso", nil)
+	if w.Code != http.StatusFound || len(w.Result().Cookies()) != 1 {
+		t.Fatalf("/login/sso: %d, cookies %v", w.Code, w.Result().Cookies())
+	}
+	authURL := w.Header().Get("Location")
+	if loginHint != "" {
+		authURL += "&login_hint=" + url.QueryEscape(loginHint)
+	}
+	noRedirects := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := noRedirects.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	callback = strings.TrimPrefix(resp.Header.Get("Location"), "http://app.test")
+	if !strings.HasPrefix(callback, "/login/sso/callback?") {
+		t.Fatalf("provider redirected to %q", resp.Header.Get("Location"))
+	}
+	return callback, w.Result().Cookies()[0]
+}
+
+func sessionCookie(w *httptest.ResponseRecorder) *http.Cookie {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == sessionstore.CookieName && c.Value != "" {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestSSOLogin(t *testing.T) {
+	tests := []struct {
+		name      string
+		loginHint string
+		wantUser  string
+	}{
+		{"new identity is provisioned", "", "jane"},
+		{"verified email links the existing account", "demo", "demo_user"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := ssoApp(t)
+			callback, state := ssoLogin(t, app, tt.loginHint)
+
+			w := do(app, http.MethodGet, callback, nil, state)
+			if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Logged in as "+tt.wantUser) {
+				t.Fatalf("callback: %d %s", w.Code, w.Body)
+			}
+			c := sessionCookie(w)
+			if c == nil {
+				t.Fatal("no session cookie")
+			}
+			if s, ok := sessionstore.GetSession(c.Value); !ok || s.Username != tt.wantUser {
+				t.Errorf("session = %+v, %v; want one for %s", s, ok, tt.wantUser)
+			}
+
+			// The state cookie is spent with the login
+			if w := do(app, http.MethodGet, callback, nil, state); w.Code != http.StatusBadRequest {
+				t.Errorf("replayed callback: %d, want 400", w.Code)
+			}
+		})
+	}
+}
+
+func TestSSOCallbackRejects(t *testing.T) {
+	tests := []struct {
+		name       string
+		loginHint  string
+		tamper     func(callback string, state *http.Cookie) (string, []*http.Cookie)
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "no state cookie",
+			tamper:     func(cb string, _ *http.Cookie) (string, []*http.Cookie) { return cb, nil },
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "expired",
+		},
+		{
+			name: "state from another browser",
+			tamper: func(cb string, state *http.Cookie) (string, []*http.Cookie) {
+				keep, _, _ := strings.Cut(state.Value, ":")
+				return cb, []*http.Cookie{{Name: state.Name, Value: keep + ":attacker-state"}}
+			},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "expired",
+		},
+		{
+			name: "provider refused",
+			tamper: func(_ string, state *http.Cookie) (string, []*http.Cookie) {
+				return "/login/sso/callback?error=access_denied", []*http.Cookie{state}
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   "did not sign you in",
+		},
+		{
+			name: "forged code",
+			tamper: func(cb string, state *http.Cookie) (string, []*http.Cookie) {
+				u, _ := url.Parse(cb)
+				q := u.Query()
+				q.Set("code", "forged")
+				return u.Path + "?" + q.Encode(), []*http.Cookie{state}
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   "couldn&#39;t verify",
+		},
+		{
+			name:       "unverified email of an existing account",
+			loginHint:  "mallory",
+			tamper:     func(cb string, state *http.Cookie) (string, []*http.Cookie) { return cb, []*http.Cookie{state} },
+			wantStatus: http.StatusConflict,
+			wantBody:   "already exists",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := ssoApp(t)
+			callback, state := ssoLogin(t, app, tt.loginHint)
+			target, cookies := tt.tamper(callback, state)
+
+			w := do(app, http.MethodGet, target, nil, cookies...)
+			if w.Code != tt.wantStatus || !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("callback: %d %q, want %d and %q", w.Code, w.Body, tt.wantStatus, tt.wantBody)
+			}
+			if c := sessionCookie(w); c != nil {
+				t.Error("a refused login set a session cookie")
+			}
+		})
+	}
+	if _, ok := accounts.Lookup("mallory"); ok {
+		t.Error("an account was created for the unverified identity")
+	}
+}
+
+func TestSSORequireVerifiedEmail(t *testing.T) {
+	app, _ := ssoApp(t)
+	RequireVerifiedEmail = true
+	callback, state := ssoLogin(t, app, "newbie")
+
+	w := do(app, http.MethodGet, callback, nil, state)
+	if w.Code != http.StatusForbidden || sessionCookie(w) != nil {
+		t.Errorf("unverified SSO login: %d, session %v; want 403 and none", w.Code, sessionCookie(w))
+	}
+	if u, ok := accounts.Lookup("newbie"); !ok || u.Verified {
+		t.Errorf("newbie = %+v, %v; want a provisioned, unverified account", u, ok)
+	}
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/accounts"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/audit"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/mfa"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/remember"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/sessionstore"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/middleware"
+)
+
+// maxCodeAttempts is how many wrong codes a pending login may try before
+// it is thrown away and the password step must be repeated.
+const maxCodeAttempts = 5
+
+// twoFactorView is what twofactor.html shows.
+type twoFactorView struct {
+	Enabled       bool
+	CodesLeft     int
+	Secret, URI   string
+	RecoveryCodes []string // only right after enrollment
+	Error         string
+}
+
+// SecondFactorLogin is POST /login/2fa: the second step of a 2FA login.
+// It takes a TOTP code or a recovery code in the form field "code".
+func SecondFactorLogin(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(sessionstore.CookieName)
+	if err != nil {
+		http.Error(w, "Log in with your password first", http.StatusUnauthorized)
+		return
+	}
+	session, ok := sessionstore.GetSession(cookie.Value)
+	if !ok || !session.SecondFactorPending {
+		http.Error(w, "No login waiting for a code", http.StatusBadRequest)
+		return
+	}
+
+	if err := mfa.Verify(session.Username, r.FormValue("code")); err != nil {
+		if sessionstore.FailSecondFactor(session.ID) >= maxCodeAttempts {
+			sessionstore.DeleteSession(session.ID)
+			sessionstore.ClearCookie(w)
+			log.Printf("too many wrong 2FA codes for %s, login abandoned", session.Username)
+			http.Error(w, "Too many wrong codes. Log in again.", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "Invalid code", http.StatusUnauthorized)
+		return
+	}
+
+	sessionID, ok := sessionstore.CompleteSecondFactor(session.ID)
+	if !ok {
+		http.Error(w, "Session expired", http.StatusUnauthorized)
+		return
+	}
+	sessionstore.SetCookie(w, sessionID)
+	if r.FormValue("remember") == "1" {
+		ref := session.Ref // a new one in cookie mode
+		if s, ok := sessionstore.GetSession(sessionID); ok {
+			ref = s.Ref
+		}
+		value, expires := remember.Issue(session.Username, ref)
+		remember.SetCookie(w, value, expires)
+	}
+	w.Write([]byte("✅ Logged in as " + session.Username + ". Visit /dashboard or /sessions"))
+}
+
+// TwoFactorPage is GET /2fa: the status page, or the enrollment screen
+// (secret + otpauth URI) while 2FA is off.
+func TwoFactorPage(w http.ResponseWriter, r *http.Request) {
+	username, _ := middleware.GetUserFromContext(r)
+	render(w, r, "twofactor.html", "Two-factor authentication", twoFactorViewFor(username, ""))
+}
+
+func twoFactorViewFor(username, errMsg string) twoFactorView {
+	if mfa.Enabled(username) {
+		return twoFactorView{Enabled: true, CodesLeft: mfa.RecoveryCodesLeft(username)}
+	}
+	secret, uri := mfa.Begin(username)
+	return twoFactorView{Secret: secret, URI: uri, Error: errMsg}
+}
+
+// ConfirmTwoFactor is POST /2fa: check the first code from the app, turn
+// 2FA on and show the recovery codes once.
+func ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	current, _ := middleware.GetSessionFromContext(r)
+	codes, err := mfa.Confirm(current.Username, r.FormValue("code"))
+	if err != nil {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		render(w, r, "twofactor.html", "Two-factor authentication",
+			twoFactorViewFor(current.Username, "That code didn't match. Check your phone's clock and try again."))
+		return
+	}
+	// Devices remembered before 2FA never passed it: make them log in again.
+	remember.RevokeUser(current.Username, current.Ref)
+	render(w, r, "twofactor.html", "Two-factor authentication", twoFactorView{Enabled: true, RecoveryCodes: codes})
+}
+
+// ResetTwoFactor is the admin action POST /admin/users/{username}/2fa/reset
+// for a user who lost their phone and their recovery codes.
+func ResetTwoFactor(w http.ResponseWriter, r *http.Request) {
+	username := r.PathValue("username")
+	if _, ok := accounts.Lookup(username); !ok {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	reset := mfa.Reset(username)
+	admin, _ := middleware.GetUserFromContext(r)
+	audit.Record(audit.Event{Actor: admin, Action: "2fa.reset", Target: username, IP: sessionstore.ClientOf(r).IP})
+	writeJSON(w, http.StatusOK, map[string]any{"username": username, "reset": reset})
+}
+
+/*
+🧠 TWO-STEP LOGIN, ENROLLMENT & ADMIN RESET (handlers/twofactor.go)
+
+✅ What Happens Here:
+- `/login` for a 2FA user creates a session marked `SecondFactorPending`; `POST /login/2fa` with a TOTP or recovery code completes it and issues a new session ID.
+- `GET /2fa` shows the secret and `otpauth://` link; `POST /2fa` confirms the first code and shows the recovery codes exactly once.
+- `POST /admin/users/{username}/2fa/reset` lets an admin (who must have 2FA themselves) switch a user's 2FA off.
+
+✅ Why This Matters:
+- Until the code is accepted the session opens nothing: `RequireSession` refuses pending sessions.
+- Five wrong codes throw the pending login away, so the 1-in-a-million guess can't be brute-forced in place.
+- Rotating the ID after the second factor keeps the half-authenticated ID from ever becoming a full one.
+
+✅ Key Concepts:
+| Concept                 | Explanation |
+|-------------------------|-------------|
+| Pending session         | Password done, second factor owed |
+| `CompleteSecondFactor`  | Clears the flag and rotates the ID |
+| Recovery codes          | Shown once, stored hashed, single use |
+| `RequireTwoFactor`      | Admin routes demand an enrolled second factor |
+*/
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/accounts"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/mfa"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/middleware"
+)
+
+// userView is an account as the users API shows it.
+type userView struct {
+	Username  string   `json:"username"`
+	Email     string   `json:"email"`
+	Verified  bool     `json:"email_verified"`
+	Roles     []string `json:"roles"`
+	TwoFactor bool     `json:"two_factor"`
+}
+
+func viewUser(u accounts.User) userView {
+	return userView{Username: u.Username, Email: u.Email, Verified: u.Verified, Roles: u.Roles, TwoFactor: mfa.Enabled(u.Username)}
+}
+
+// MeAPI is GET /api/users/me: the caller's own account.
+func MeAPI(w http.ResponseWriter, r *http.Request) {
+	username, _ := middleware.GetUserFromContext(r)
+	u, ok := accounts.Lookup(username)
+	if !ok {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, viewUser(u))
+}
+
+// ListUsersAPI is GET /api/users: every account (permission users:read).
+func ListUsersAPI(w http.ResponseWriter, r *http.Request) {
+	var list []userView
+	for _, u := range accounts.List() {
+		list = append(list, viewUser(u))
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+/*
+🧠 THE USERS API (handlers/users.go)
+
+✅ What Happens Here:
+- `GET /api/users/me` returns the caller's account; `GET /api/users` lists all accounts for callers with `users:read` (admins).
+- Both take a browser session or a personal access token with the matching scope (`profile:read`, `users:read`).
+
+✅ Why This Matters:
+- The handlers only ask "who is calling?" (`GetUserFromContext`) — the middleware already decided how they proved it.
+- Password hashes never leave `accounts`: the API returns a view struct with explicit JSON names.
+*/
@@ -0,0 +1,312 @@
+// Package accounts is the lesson's user directory: a fixed set of demo
+// users, their roles, emails and password hashes.
+package accounts
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Role names.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Permissions a role can grant.
+const (
+	PermImpersonate   = "impersonate"    // act as another user (support)
+	PermProfileRead   = "profile:read"   // read your own account
+	PermSessionsRead  = "sessions:read"  // list your sessions
+	PermSessionsWrite = "sessions:write" // sign your sessions out
+	PermUsersRead     = "users:read"     // list every account
+)
+
+// rolePermissions lists what each role may do beyond being logged in.
+var rolePermissions = map[string][]string{
+	RoleUser:  {PermProfileRead, PermSessionsRead, PermSessionsWrite},
+	RoleAdmin: {PermImpersonate, PermUsersRead},
+}
+
+// TokenScopes are the permissions a personal access token may carry: the
+// ones an API route checks. Impersonating needs a browser session.
+var TokenScopes = []string{PermProfileRead, PermSessionsRead, PermSessionsWrite, PermUsersRead}
+
+// MinPasswordLength is the shortest password SetPassword accepts.
+const MinPasswordLength = 8
+
+// ErrWeakPassword is returned by SetPassword for a too-short password.
+var ErrWeakPassword = errors.New("accounts: password must be at least 8 characters")
+
+// User is a known account.
+type User struct {
+	Username string
+	Email    string
+	Verified bool // the user proved they own Email
+	Roles    []string
+
+	password passwordHash
+}
+
+// HasRole reports whether u has role.
+func (u User) HasRole(role string) bool {
+	return slices.Contains(u.Roles, role)
+}
+
+// Can reports whether one of u's roles grants perm.
+func (u User) Can(perm string) bool {
+	for _, role := range u.Roles {
+		if slices.Contains(rolePermissions[role], perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// Scopes returns the TokenScopes u holds, the most u may give a token: a
+// token never does more than its owner.
+func (u User) Scopes() []string {
+	var scopes []string
+	for _, scope := range TokenScopes {
+		if u.Can(scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+var users = map[string]*User{
+	"demo_user": {Username: "demo_user", Email: "demo@example.com", Roles: []string{RoleUser}},
+	"admin":     {Username: "admin", Email: "admin@example.com", Verified: true, Roles: []string{RoleUser, RoleAdmin}},
+}
+var mu sync.RWMutex
+
+// Demo passwords, documented in the README. Real apps never ship these.
+func init() {
+	users["demo_user"].password = hashPassword("demo-password")
+	users["admin"].password = hashPassword("admin-password")
+}
+
+// Lookup returns the account with this username.
+func Lookup(username string) (User, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	u, ok := users[username]
+	if !ok {
+		return User{}, false
+	}
+	return *u, true
+}
+
+// List returns every account, sorted by username.
+func List() []User {
+	mu.RLock()
+	defer mu.RUnlock()
+	list := make([]User, 0, len(users))
+	for _, u := range users {
+		list = append(list, *u)
+	}
+	slices.SortFunc(list, func(a, b User) int { return strings.Compare(a.Username, b.Username) })
+	return list
+}
+
+// LookupEmail returns the account with this email address (any case).
+func LookupEmail(email string) (User, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, u := range users {
+		if strings.EqualFold(u.Email, strings.TrimSpace(email)) {
+			return *u, true
+		}
+	}
+	return User{}, false
+}
+
+// Authenticate checks username and password. Unknown users cost the same
+// hashing work as wrong passwords, so timing doesn't reveal which it was.
+func Authenticate(username, password string) (User, bool) {
+	mu.RLock()
+	u, ok := users[username]
+	var stored passwordHash
+	if ok {
+		stored = u.password
+	} else {
+		stored = dummyHash
+	}
+	mu.RUnlock()
+	if !stored.matches(password) || !ok {
+		return User{}, false
+	}
+	return Lookup(username)
+}
+
+// SetPassword replaces username's password.
+func SetPassword(username, password string) error {
+	if len(password) < MinPasswordLength {
+		return ErrWeakPassword
+	}
+	h := hashPassword(password)
+	mu.Lock()
+	defer mu.Unlock()
+	u, ok := users[username]
+	if !ok {
+		return errors.New("accounts: no such user")
+	}
+	u.password = h
+	return nil
+}
+
+// MarkVerified records that username's email address is confirmed.
+func MarkVerified(username string) {
+	mu.Lock()
+	if u, ok := users[username]; ok {
+		u.Verified = true
+	}
+	mu.Unlock()
+}
+
+// SetRoles replaces username's roles. Sessions and tokens see the change
+// on their next request.
+func SetRoles(username string, roles ...string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	u, ok := users[username]
+	if !ok {
+		return errors.New("accounts: no such user")
+	}
+	u.Roles = slices.Clone(roles)
+	return nil
+}
+
+// External is a user as an identity provider (SSO) describes them.
+type External struct {
+	Issuer, Subject   string // together, the stable identity
+	Email             string
+	EmailVerified     bool // the provider vouches for Email
+	PreferredUsername string
+}
+
+// How LinkExternal found the account.
+const (
+	LinkedBefore  = "existing"    // this identity has logged in before
+	LinkedByEmail = "linked"      // attached to the account with its verified email
+	Provisioned   = "provisioned" // a new account was created
+)
+
+// ErrEmailInUse is returned by LinkExternal when an account already has
+// the identity's email address, but the provider hasn't verified it, so
+// we can't tell the identity owns that account.
+var ErrEmailInUse = errors.New("accounts: email belongs to another account")
+
+// identities maps issuer + subject to a username. Emails and usernames at
+// the provider can change; the subject never does.
+var identities = map[[2]string]string{}
+
+// LinkExternal returns the account for an SSO identity. On first login it
+// is linked to the account with the same (provider-verified) email, or a
+// new account is created with no password.
+func LinkExternal(e External) (User, string, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	id := [2]string{e.Issuer, e.Subject}
+	if username, ok := identities[id]; ok {
+		if u, ok := users[username]; ok {
+			return *u, LinkedBefore, nil
+		}
+	}
+
+	for _, u := range users {
+		if e.Email == "" || !strings.EqualFold(u.Email, e.Email) {
+			continue
+		}
+		if !e.EmailVerified {
+			return User{}, "", ErrEmailInUse
+		}
+		identities[id] = u.Username
+		u.Verified = true
+		return *u, LinkedByEmail, nil
+	}
+
+	u := &User{
+		Username: freeUsername(e),
+		Email:    e.Email,
+		Verified: e.EmailVerified,
+		Roles:    []string{RoleUser},
+	}
+	users[u.Username] = u
+	identities[id] = u.Username
+	return *u, Provisioned, nil
+}
+
+// freeUsername picks an unused username from the identity's preferred
+// username or email; callers hold mu.
+func freeUsername(e External) string {
+	base := e.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(e.Email, "@")
+	}
+	base = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_', r == '-', r == '.':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return -1
+	}, base)
+	if base == "" {
+		base = "sso"
+	}
+	name := base
+	for n := 2; users[name] != nil; n++ {
+		name = fmt.Sprintf("%s-%d", base, n)
+	}
+	return name
+}
+
+// passwordHash is a PBKDF2-HMAC-SHA256 hash with its salt.
+type passwordHash struct {
+	salt, key []byte
+}
+
+// pbkdf2Iterations follows the OWASP recommendation for PBKDF2-SHA256.
+const pbkdf2Iterations = 600_000
+
+var dummyHash = hashPassword("not a real password")
+
+func hashPassword(password string) passwordHash {
+	salt := make([]byte, 16)
+	rand.Read(salt)
+	key, _ := pbkdf2.Key(sha256.New, password, salt, pbkdf2Iterations, 32)
+	return passwordHash{salt: salt, key: key}
+}
+
+func (h passwordHash) matches(password string) bool {
+	key, err := pbkdf2.Key(sha256.New, password, h.salt, pbkdf2Iterations, 32)
+	return err == nil && subtle.ConstantTimeCompare(key, h.key) == 1
+}
+
+/*
+🧠 A TINY USER DIRECTORY (internal/accounts/accounts.go)
+
+✅ What Happens Here:
+- Two demo accounts exist: `demo_user` (role `user`, password `demo-password`) and `admin` (roles `user` and `admin`, password `admin-password`).
+- `Lookup` is how login and the role middleware find out who someone is and what they may do.
+- Roles grant permissions (`Can(PermImpersonate)`), so a feature asks for what it needs rather than for a role name. The API permissions (`sessions:read`, `users:read`, ...) double as personal access token scopes.
+- Passwords are stored as salted PBKDF2-SHA256 (`crypto/pbkdf2`, 600k iterations); `Authenticate` compares in constant time.
+- Each account has an email and a `Verified` flag, set by the email verification flow.
+- `LinkExternal` maps an SSO identity (issuer + subject) to an account: the one it used before, the one with its verified email, or a new password-less one.
+
+✅ Why This Matters:
+- Roles are looked up on each request instead of being copied into the session, so changing them (`SetRoles`) takes effect immediately.
+- Keeping accounts behind a package makes it easy to swap the map for a database later.
+- Linking by email only when the provider says the email is verified stops someone from claiming an account by typing its address into their IdP profile.
+- A slow, salted hash makes a leaked password table expensive to crack; hashing a dummy for unknown users keeps login timing from revealing which usernames exist.
+*/
@@ -0,0 +1,146 @@
+// Package apitoken keeps personal access tokens: long-lived, named,
+// revocable secrets a user creates to call the API from scripts. Only a
+// SHA-256 hash of each token is stored; the token itself is shown once.
+package apitoken
+
+import (
+	"cmp"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Prefix starts every token, so leaked ones are easy to recognise (and
+// for secret scanners to find).
+const Prefix = "pat_"
+
+// ErrInvalid covers unknown, revoked, expired and malformed tokens alike.
+var ErrInvalid = errors.New("apitoken: invalid, revoked or expired token")
+
+// Token is what the server knows about a personal access token: everything
+// but the secret.
+type Token struct {
+	ID         string    `json:"id"` // public; names the token in URLs
+	Username   string    `json:"-"`
+	Name       string    `json:"name"`
+	Scopes     []string  `json:"scopes"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at,omitzero"` // zero: never
+	LastUsed   time.Time `json:"last_used,omitzero"`
+	LastUsedIP string    `json:"last_used_ip,omitempty"`
+}
+
+// HasScope reports whether the token was granted scope.
+func (t Token) HasScope(scope string) bool {
+	return slices.Contains(t.Scopes, scope)
+}
+
+// Expired reports whether the token has passed its expiry.
+func (t Token) Expired() bool {
+	return !t.ExpiresAt.IsZero() && time.Now().After(t.ExpiresAt)
+}
+
+var tokens = make(map[[sha256.Size]byte]*Token) // keyed by hash of the secret
+var mu sync.Mutex
+
+// Create issues a token for username and returns the secret (to show the
+// user once) and the stored Token. A zero expires never expires. Checking
+// name and scopes is the caller's job.
+func Create(username, name string, scopes []string, expires time.Time) (string, Token) {
+	raw := Prefix + randomHex(32)
+	t := &Token{
+		ID:        randomHex(8),
+		Username:  username,
+		Name:      name,
+		Scopes:    slices.Clone(scopes),
+		CreatedAt: time.Now(),
+		ExpiresAt: expires,
+	}
+	mu.Lock()
+	tokens[sha256.Sum256([]byte(raw))] = t
+	mu.Unlock()
+	return raw, *t
+}
+
+// Authenticate returns the token behind raw, recording that it was just
+// used from ip. Expired tokens are deleted on sight.
+func Authenticate(raw, ip string) (Token, error) {
+	if !strings.HasPrefix(raw, Prefix) {
+		return Token{}, ErrInvalid
+	}
+	h := sha256.Sum256([]byte(raw))
+	mu.Lock()
+	defer mu.Unlock()
+	t, ok := tokens[h]
+	if !ok {
+		return Token{}, ErrInvalid
+	}
+	if t.Expired() {
+		delete(tokens, h)
+		return Token{}, ErrInvalid
+	}
+	t.LastUsed, t.LastUsedIP = time.Now(), ip
+	return *t, nil
+}
+
+// List returns username's tokens, newest first. Expired ones are listed
+// too, until they are revoked or someone tries to use them.
+func List(username string) []Token {
+	mu.Lock()
+	defer mu.Unlock()
+	var list []Token
+	for _, t := range tokens {
+		if t.Username == username {
+			list = append(list, *t)
+		}
+	}
+	slices.SortFunc(list, func(a, b Token) int { return cmp.Compare(b.CreatedAt.UnixNano(), a.CreatedAt.UnixNano()) })
+	return list
+}
+
+// Revoke deletes username's token with this ID. It reports false if
+// username has no such token.
+func Revoke(username, id string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	for h, t := range tokens {
+		if t.Username == username && t.ID == id {
+			delete(tokens, h)
+			return true
+		}
+	}
+	return false
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+/*
+🧠 PERSONAL ACCESS TOKENS (internal/apitoken/apitoken.go)
+
+✅ What Happens Here:
+- `Create` makes a random `pat_…` secret, stores its SHA-256 hash with a name, scopes and an optional expiry, and hands the secret back exactly once.
+- `Authenticate` hashes whatever the client sent and looks the hash up; a hit records "last used" (time and IP).
+- Each token also has a public `ID`, so the settings page can list and revoke tokens without ever knowing their secrets.
+
+✅ Why This Matters:
+- A stolen token table is useless: hashes can't be sent as tokens. The secrets are 256 random bits, so a fast hash is enough — unlike passwords, there is nothing to guess.
+- Tokens are separate from the password: a script gets only the scopes it needs, and revoking it doesn't log the user out anywhere.
+- "Last used" tells the user which tokens are dead weight (or being used from somewhere unexpected).
+
+✅ Key Concepts:
+| Concept        | Explanation |
+|----------------|-------------|
+| `pat_` prefix  | Recognisable in logs and by secret scanners |
+| Hashed at rest | Only `sha256(token)` is kept |
+| Scopes         | Permissions the token may use (`sessions:read`, …) |
+| `ExpiresAt`    | Optional; zero means the token lives until revoked |
+*/
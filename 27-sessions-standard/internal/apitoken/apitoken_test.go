@@ -0,0 +1,85 @@
+package apitoken
+
+import (
+	"crypto/sha256"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOnlyTheHashIsKept(t *testing.T) {
+	raw, tok := Create("alice", "ci", []string{"sessions:read"}, time.Time{})
+	t.Cleanup(func() { Revoke("alice", tok.ID) })
+	if !strings.HasPrefix(raw, Prefix) || len(raw) != len(Prefix)+64 {
+		t.Errorf("token %q: want %s and 256 random bits in hex", raw, Prefix)
+	}
+
+	mu.Lock()
+	stored, ok := tokens[sha256.Sum256([]byte(raw))]
+	mu.Unlock()
+	if !ok {
+		t.Fatal("token not stored under sha256(token)")
+	}
+	for _, field := range []string{stored.ID, stored.Name, stored.Username, strings.Join(stored.Scopes, " ")} {
+		if strings.Contains(field, strings.TrimPrefix(raw, Prefix)) {
+			t.Errorf("the secret is kept in a field: %+v", stored)
+		}
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	good, tok := Create("alice", "ci", []string{"sessions:read"}, time.Now().Add(time.Hour))
+	expired, _ := Create("alice", "old", nil, time.Now().Add(-time.Second))
+	revoked, gone := Create("alice", "leaked", nil, time.Time{})
+	Revoke("alice", gone.ID)
+	t.Cleanup(func() { Revoke("alice", tok.ID) })
+
+	got, err := Authenticate(good, "192.0.2.1")
+	if err != nil || got.ID != tok.ID || got.Username != "alice" || !got.HasScope("sessions:read") {
+		t.Fatalf("Authenticate = %+v, %v", got, err)
+	}
+	if got.LastUsedIP != "192.0.2.1" || time.Since(got.LastUsed) > time.Minute {
+		t.Errorf("last used = %v from %q, want now from 192.0.2.1", got.LastUsed, got.LastUsedIP)
+	}
+
+	for name, raw := range map[string]string{
+		"expired":        expired,
+		"revoked":        revoked,
+		"unknown":        Prefix + strings.Repeat("0", 64),
+		"no prefix":      strings.TrimPrefix(good, Prefix),
+		"one char short": good[:len(good)-1],
+		"empty":          "",
+	} {
+		if _, err := Authenticate(raw, "192.0.2.1"); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: %v, want ErrInvalid", name, err)
+		}
+	}
+	for _, l := range List("alice") {
+		if l.Name == "old" {
+			t.Error("expired token still listed after it was refused")
+		}
+	}
+}
+
+func TestListAndRevoke(t *testing.T) {
+	_, first := Create("bob", "first", nil, time.Time{})
+	time.Sleep(time.Millisecond)
+	_, second := Create("bob", "second", nil, time.Time{})
+	_, other := Create("carol", "carol's", nil, time.Time{})
+	t.Cleanup(func() { Revoke("carol", other.ID) })
+
+	list := List("bob")
+	if len(list) != 2 || list[0].ID != second.ID || list[1].ID != first.ID {
+		t.Fatalf("List = %+v, want bob's two tokens newest first", list)
+	}
+	if Revoke("bob", other.ID) {
+		t.Error("bob revoked carol's token")
+	}
+	if !Revoke("bob", first.ID) || Revoke("bob", first.ID) {
+		t.Error("Revoke should succeed once")
+	}
+	if !Revoke("bob", second.ID) || len(List("bob")) != 0 {
+		t.Errorf("after revoking both: %+v", List("bob"))
+	}
+}
@@ -0,0 +1,65 @@
+// Package audit records security events — lockouts, unlocks, admin
+// actions — so there is a trail of who did what to which account.
+package audit
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Event is one audit log entry.
+type Event struct {
+	Time   time.Time `json:"time"`
+	Actor  string    `json:"actor"`  // username, or "system" for automatic actions
+	Action string    `json:"action"` // e.g. "account.locked"
+	Target string    `json:"target"` // account or IP acted on
+	IP     string    `json:"ip,omitempty"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// maxEvents bounds the in-memory log; the oldest entries drop off.
+const maxEvents = 1000
+
+var events []Event
+var mu sync.Mutex
+
+// Record appends e (stamping Time if unset) and echoes it to the server log.
+func Record(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	mu.Lock()
+	events = append(events, e)
+	if len(events) > maxEvents {
+		events = events[len(events)-maxEvents:]
+	}
+	mu.Unlock()
+	log.Printf("📝 audit: %s %s %s %s", e.Actor, e.Action, e.Target, e.Detail)
+}
+
+// Recent returns up to n events, newest first.
+func Recent(n int) []Event {
+	mu.Lock()
+	defer mu.Unlock()
+	if n <= 0 || n > len(events) {
+		n = len(events)
+	}
+	out := make([]Event, 0, n)
+	for i := len(events) - 1; i >= len(events)-n; i-- {
+		out = append(out, events[i])
+	}
+	return out
+}
+
+/*
+🧠 AN AUDIT TRAIL (internal/audit/audit.go)
+
+✅ What Happens Here:
+- `Record` keeps the last 1000 security events in memory and writes each one to the server log.
+- Admins read them newest-first at `GET /admin/audit`.
+
+✅ Why This Matters:
+- When an account gets locked or a user is signed out by an admin, support needs to see when, why and by whom.
+- The log line means nothing is lost on restart if stdout is collected.
+*/
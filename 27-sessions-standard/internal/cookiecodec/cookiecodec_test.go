@@ -0,0 +1,186 @@
+package cookiecodec
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testKey(id string, fill byte) Key {
+	return Key{ID: id, Secret: bytes.Repeat([]byte{fill}, 32)}
+}
+
+func mustCodec(t *testing.T, keys ...Key) *Codec {
+	t.Helper()
+	c, err := New(time.Hour, keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// at runs the codec's clock at t until the test ends.
+func at(t *testing.T, when time.Time) {
+	t.Helper()
+	now = func() time.Time { return when }
+	t.Cleanup(func() { now = time.Now })
+}
+
+func TestRoundTrip(t *testing.T) {
+	c := mustCodec(t, testKey("k1", 1))
+	v, err := c.Encode("session", []byte(`{"u":"alice"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(v, "k1.") || strings.Contains(v, "alice") {
+		t.Errorf("value %q: want kid prefix and no plaintext", v)
+	}
+	got, err := c.Decode("session", v)
+	if err != nil || string(got) != `{"u":"alice"}` {
+		t.Errorf("Decode = %q, %v", got, err)
+	}
+	if w, _ := c.Encode("session", []byte(`{"u":"alice"}`)); w == v {
+		t.Error("two seals of the same data are identical: nonce reused")
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	k1, k2 := testKey("k1", 1), testKey("k2", 2)
+	c := mustCodec(t, k1, k2)
+	good, err := c.Encode("session", []byte("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	flip := func(i int) string { // flips one bit of the sealed bytes
+		kid, payload, _ := strings.Cut(good, ".")
+		b, _ := base64.RawURLEncoding.DecodeString(payload)
+		b[i] ^= 0x01
+		return kid + "." + base64.RawURLEncoding.EncodeToString(b)
+	}
+	_, payload, _ := strings.Cut(good, ".")
+
+	tests := []struct {
+		name, cookie, value string
+		when                time.Time // zero: now
+		want                error
+	}{
+		{name: "tampered nonce", value: flip(0), want: ErrInvalid},
+		{name: "tampered timestamp", value: flip(12), want: ErrInvalid},
+		{name: "tampered payload", value: flip(20), want: ErrInvalid},
+		{name: "tampered tag", value: flip(len(payload)*3/4 - 1), want: ErrInvalid},
+		{name: "truncated", value: good[:len(good)-4], want: ErrInvalid},
+		{name: "no kid", value: payload, want: ErrInvalid},
+		{name: "not base64", value: "k1.!!!", want: ErrInvalid},
+		{name: "unknown kid", value: "k9." + payload, want: ErrUnknownKey},
+		{name: "relabelled with another known kid", value: "k2." + payload, want: ErrInvalid},
+		{name: "replayed under another cookie name", cookie: "remember_me", value: good, want: ErrInvalid},
+		{name: "oversized", value: good + strings.Repeat("A", MaxCookieSize), want: ErrInvalid},
+		{name: "expired", value: good, when: time.Now().Add(time.Hour + time.Second), want: ErrExpired},
+		{name: "issued in the future", value: good, when: time.Now().Add(-2 * time.Minute), want: ErrExpired},
+		{name: "just inside max age", value: good, when: time.Now().Add(time.Hour - time.Second)},
+		{name: "clock skew under a minute", value: good, when: time.Now().Add(-30 * time.Second)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.when.IsZero() {
+				at(t, tt.when)
+			}
+			cookie := tt.cookie
+			if cookie == "" {
+				cookie = "session"
+			}
+			got, err := c.Decode(cookie, tt.value)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Decode error = %v, want %v", err, tt.want)
+			}
+			if tt.want == nil && string(got) != "payload" {
+				t.Errorf("Decode = %q, want payload", got)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	old, fresh := testKey("2025", 1), testKey("2026", 2)
+	sealedBefore, err := mustCodec(t, old).Encode("session", []byte("hi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		keys []Key
+		want error
+	}{
+		{"old key kept after the new one", []Key{fresh, old}, nil},
+		{"old key dropped", []Key{fresh}, ErrUnknownKey},
+		{"same kid, different secret", []Key{testKey("2025", 3)}, ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := mustCodec(t, tt.keys...)
+			if _, err := c.Decode("session", sealedBefore); !errors.Is(err, tt.want) {
+				t.Errorf("Decode = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	rotated := mustCodec(t, fresh, old)
+	v, _ := rotated.Encode("session", []byte("hi"))
+	if !strings.HasPrefix(v, "2026.") {
+		t.Errorf("rotated codec sealed with %q, want the first key", v[:strings.Index(v, ".")])
+	}
+}
+
+func TestTooLarge(t *testing.T) {
+	c := mustCodec(t, testKey("k", 1))
+	const name = "session"
+	// "k." + base64(12-byte nonce + 8-byte time + data + 16-byte tag)
+	overhead := len(name) + 1 + len("k.")
+	fits := (MaxCookieSize-overhead)/4*3 - 12 - 8 - 16
+
+	if v, err := c.Encode(name, make([]byte, fits)); err != nil || len(name)+1+len(v) > MaxCookieSize {
+		t.Errorf("largest payload that fits: len %d, err %v", len(name)+1+len(v), err)
+	}
+	if _, err := c.Encode(name, make([]byte, fits+3)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("payload over the limit: %v, want ErrTooLarge", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name   string
+		maxAge time.Duration
+		keys   []Key
+	}{
+		{"no keys", time.Hour, nil},
+		{"zero max age", 0, []Key{testKey("k", 1)}},
+		{"empty kid", time.Hour, []Key{testKey("", 1)}},
+		{"kid with a dot", time.Hour, []Key{testKey("k.1", 1)}},
+		{"duplicate kid", time.Hour, []Key{testKey("k", 1), testKey("k", 2)}},
+		{"short key", time.Hour, []Key{{ID: "k", Secret: []byte("too short")}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.maxAge, tt.keys...); err == nil {
+				t.Error("New accepted it")
+			}
+		})
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	a, b := GenerateKey(), GenerateKey()
+	keys, err := ParseKeys("new:" + a + ", old:" + b)
+	if err != nil || len(keys) != 2 || keys[0].ID != "new" || keys[1].ID != "old" || len(keys[0].Secret) != 32 {
+		t.Fatalf("ParseKeys = %+v, %v", keys, err)
+	}
+	for _, bad := range []string{"", "nocolon", "k:not-hex"} {
+		if _, err := ParseKeys(bad); err == nil {
+			t.Errorf("ParseKeys(%q) accepted it", bad)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates/*
+var templateFiles embed.FS
+
+// kind is one kind of message: templates/NAME.txt (which also defines
+// "subject") and templates/NAME.html.
+type kind struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+var kinds = map[string]kind{}
+
+func init() {
+	files, _ := fs.Glob(templateFiles, "templates/*.txt")
+	for _, f := range files {
+		name := strings.TrimSuffix(path.Base(f), ".txt")
+		kinds[name] = kind{
+			text: texttemplate.Must(texttemplate.ParseFS(templateFiles, f)),
+			html: htmltemplate.Must(htmltemplate.ParseFS(templateFiles, "templates/"+name+".html")),
+		}
+	}
+}
+
+// Compose renders message name (e.g. "password_reset") for to with data.
+func Compose(to, name string, data any) (Message, error) {
+	k, ok := kinds[name]
+	if !ok {
+		return Message{}, fmt.Errorf("mail: no message template %q", name)
+	}
+	m := Message{To: to}
+	var buf bytes.Buffer
+	if err := k.text.ExecuteTemplate(&buf, "subject", data); err != nil {
+		return Message{}, err
+	}
+	m.Subject = strings.TrimSpace(buf.String())
+
+	buf.Reset()
+	if err := k.text.Execute(&buf, data); err != nil {
+		return Message{}, err
+	}
+	m.Text = strings.TrimSpace(buf.String()) + "\n"
+
+	buf.Reset()
+	if err := k.html.Execute(&buf, data); err != nil {
+		return Message{}, err
+	}
+	m.HTML = buf.String()
+	return m, nil
+}
@@ -0,0 +1,152 @@
+package mfa
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clock runs the package clock from a fixed time; advance moves it.
+func clock(t *testing.T) (advance func(time.Duration)) {
+	t.Helper()
+	current := time.Unix(1800000000, 0)
+	now = func() time.Time { return current }
+	t.Cleanup(func() { now = time.Now })
+	return func(d time.Duration) { current = current.Add(d) }
+}
+
+// enroll runs Begin and Confirm for a fresh user and returns the secret
+// and the recovery codes.
+func enroll(t *testing.T, username string) (string, []string) {
+	t.Helper()
+	t.Cleanup(func() { Reset(username) })
+	secret, _ := Begin(username)
+	codes, err := Confirm(username, current(t, secret))
+	if err != nil {
+		t.Fatalf("Confirm: %v", err)
+	}
+	return secret, codes
+}
+
+func current(t *testing.T, secret string) string {
+	t.Helper()
+	c, err := Code(secret, Step(now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestEnrollment(t *testing.T) {
+	clock(t)
+	t.Cleanup(func() { Reset("alice") })
+
+	secret, uri := Begin("alice")
+	if again, _ := Begin("alice"); again != secret {
+		t.Error("Begin again changed the secret the app already scanned")
+	}
+	if !strings.Contains(uri, "secret="+secret) {
+		t.Errorf("URI %q doesn't carry the secret", uri)
+	}
+	if Enabled("alice") {
+		t.Fatal("enabled before Confirm")
+	}
+	if err := Verify("alice", current(t, secret)); !errors.Is(err, ErrNotEnrolled) {
+		t.Errorf("Verify before Confirm = %v, want ErrNotEnrolled", err)
+	}
+	if _, err := Confirm("alice", "000000"); !errors.Is(err, ErrInvalidCode) || Enabled("alice") {
+		t.Errorf("Confirm with a wrong code = %v, enabled %v", err, Enabled("alice"))
+	}
+
+	codes, err := Confirm("alice", current(t, secret))
+	if err != nil || !Enabled("alice") {
+		t.Fatalf("Confirm = %v, enabled %v", err, Enabled("alice"))
+	}
+	if len(codes) != RecoveryCodeCount || RecoveryCodesLeft("alice") != RecoveryCodeCount {
+		t.Errorf("%d recovery codes, %d left; want %d", len(codes), RecoveryCodesLeft("alice"), RecoveryCodeCount)
+	}
+	if _, err := Confirm("alice", current(t, secret)); !errors.Is(err, ErrNoPending) {
+		t.Errorf("second Confirm = %v, want ErrNoPending", err)
+	}
+}
+
+func TestVerifyRefusesReplay(t *testing.T) {
+	advance := clock(t)
+	secret, _ := enroll(t, "bob")
+
+	// The code used for Confirm can't be used again to log in.
+	if err := Verify("bob", current(t, secret)); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("code used by Confirm: %v, want ErrInvalidCode", err)
+	}
+
+	advance(Period * time.Second)
+	code := current(t, secret)
+	if err := Verify("bob", code); err != nil {
+		t.Fatalf("fresh code: %v", err)
+	}
+	if err := Verify("bob", code); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("same code again: %v, want ErrInvalidCode", err)
+	}
+
+	// A phone running ahead used the next step; the current one, though
+	// still inside the window, is now older than the last one accepted.
+	next, _ := Code(secret, Step(now())+1)
+	if err := Verify("bob", next); err != nil {
+		t.Fatalf("next step's code: %v", err)
+	}
+	advance(Period * time.Second)
+	previous, _ := Code(secret, Step(now())-1)
+	if err := Verify("bob", previous); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("code older than the last one accepted: %v, want ErrInvalidCode", err)
+	}
+}
+
+func TestRecoveryCodesWorkOnce(t *testing.T) {
+	clock(t)
+	_, codes := enroll(t, "carol")
+
+	seen := map[string]bool{}
+	for _, c := range codes {
+		if seen[c] {
+			t.Errorf("recovery code %s handed out twice", c)
+		}
+		seen[c] = true
+	}
+
+	// Typed with spaces and capitals, as people do.
+	typed := " " + strings.ToUpper(codes[0]) + " "
+	if err := Verify("carol", typed); err != nil {
+		t.Fatalf("recovery code: %v", err)
+	}
+	if err := Verify("carol", codes[0]); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("recovery code reused: %v, want ErrInvalidCode", err)
+	}
+	if n := RecoveryCodesLeft("carol"); n != RecoveryCodeCount-1 {
+		t.Errorf("%d codes left, want %d", n, RecoveryCodeCount-1)
+	}
+	if err := Verify("carol", strings.ReplaceAll(codes[1], "-", "")); err != nil {
+		t.Errorf("recovery code without its dash: %v", err)
+	}
+}
+
+func TestReset(t *testing.T) {
+	clock(t)
+	secret, codes := enroll(t, "dave")
+
+	if !Reset("dave") {
+		t.Fatal("Reset reported nothing to reset")
+	}
+	if Enabled("dave") || RecoveryCodesLeft("dave") != 0 {
+		t.Error("still enabled after Reset")
+	}
+	if err := Verify("dave", codes[0]); !errors.Is(err, ErrNotEnrolled) {
+		t.Errorf("recovery code after Reset: %v, want ErrNotEnrolled", err)
+	}
+	if Reset("dave") {
+		t.Error("second Reset reported something to reset")
+	}
+	if fresh, _ := Begin("dave"); fresh == secret {
+		t.Error("re-enrolling after Reset reused the old secret")
+	}
+}
@@ -0,0 +1,95 @@
+package mfa
+
+import (
+	"testing"
+	"time"
+)
+
+// The RFC test secret, the ASCII bytes "12345678901234567890".
+var rfcSecret = b32.EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeRFC4226(t *testing.T) {
+	// RFC 4226 Appendix D: HOTP for counters 0…9.
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	for counter, w := range want {
+		if got, err := Code(rfcSecret, int64(counter)); err != nil || got != w {
+			t.Errorf("Code(counter %d) = %q, %v; want %s", counter, got, err, w)
+		}
+	}
+}
+
+func TestCodeRFC6238(t *testing.T) {
+	// RFC 6238 Appendix B, SHA-1 rows; the vectors are 8 digits, so a
+	// 6-digit code is their last six.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil || got != tt.want[8-Digits:] {
+			t.Errorf("Code at %d = %q, %v; want %s", tt.unix, got, err, tt.want[8-Digits:])
+		}
+	}
+}
+
+func TestMatchWindow(t *testing.T) {
+	// 1111111110 is the first second of step 37037037.
+	start := time.Unix(1111111110, 0)
+	cur := Step(start)
+	code := func(step int64) string {
+		c, err := Code(rfcSecret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name  string
+		at    time.Time
+		step  int64
+		after int64
+		ok    bool
+	}{
+		{"current step", start, cur, 0, true},
+		{"previous step, first second", start, cur - 1, 0, true},
+		{"previous step, last second", start.Add(Period*time.Second - time.Second), cur - 1, 0, true},
+		{"next step (phone ahead)", start, cur + 1, 0, true},
+		{"two steps old", start, cur - 2, 0, false},
+		{"two steps old, one second before the window moves", start.Add(-time.Second), cur - 2, 0, true},
+		{"two steps ahead", start, cur + 2, 0, false},
+		{"step already used", start, cur, cur, false},
+		{"older than the step used", start, cur - 1, cur, false},
+		{"newer than the step used", start, cur + 1, cur, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := match(rfcSecret, code(tt.step), tt.at, tt.after)
+			if ok != tt.ok || (ok && step != tt.step) {
+				t.Errorf("match = %d, %v; want %d, %v", step, ok, tt.step, tt.ok)
+			}
+		})
+	}
+
+	for _, bad := range []string{"", "12345", "1234567", "abcdef"} {
+		if _, ok := match(rfcSecret, bad, start, 0); ok {
+			t.Errorf("match(%q) accepted it", bad)
+		}
+	}
+}
+
+func TestURI(t *testing.T) {
+	got := URI("Learn Go", "alice@example.com", "JBSWY3DPEHPK3PXP")
+	want := "otpauth://totp/Learn%20Go:alice@example.com?algorithm=SHA1&digits=6&issuer=Learn+Go&period=30&secret=JBSWY3DPEHPK3PXP"
+	if got != want {
+		t.Errorf("URI =\n  %s\nwant\n  %s", got, want)
+	}
+}
@@ -0,0 +1,182 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Claims are the ID-token claims the app uses.
+type Claims struct {
+	Issuer            string   `json:"iss"`
+	Subject           string   `json:"sub"`
+	Audience          audience `json:"aud"`
+	AuthorizedParty   string   `json:"azp"`
+	Expiry            int64    `json:"exp"`
+	IssuedAt          int64    `json:"iat"`
+	Nonce             string   `json:"nonce"`
+	Email             string   `json:"email"`
+	EmailVerified     bool     `json:"email_verified"`
+	Name              string   `json:"name"`
+	PreferredUsername string   `json:"preferred_username"`
+}
+
+// audience is "aud", which may be a string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var one string
+	if err := json.Unmarshal(b, &one); err == nil {
+		*a = audience{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+// clockSkew is how far apart our clock and the provider's may be.
+const clockSkew = time.Minute
+
+// verifyIDToken checks an ID token's signature against the provider's
+// JWKS and validates iss, aud, azp, exp, iat and nonce.
+func (c *Client) verifyIDToken(ctx context.Context, raw, nonce string) (*Claims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("oidc: ID token is not a JWS")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("oidc: ID token header: %w", err)
+	}
+	// Only RS256: never "none", and never an HMAC alg keyed with our
+	// copy of a public key.
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("oidc: ID token alg %q not allowed", header.Alg)
+	}
+	key, err := c.publicKey(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("oidc: ID token signature: %w", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return nil, errors.New("oidc: ID token signature is invalid")
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("oidc: ID token claims: %w", err)
+	}
+	now := time.Now()
+	switch {
+	case claims.Issuer != c.meta.Issuer:
+		return nil, fmt.Errorf("oidc: ID token issuer %q, want %q", claims.Issuer, c.meta.Issuer)
+	case !slices.Contains(claims.Audience, c.cfg.ClientID):
+		return nil, errors.New("oidc: ID token is not for this client")
+	case len(claims.Audience) > 1 && claims.AuthorizedParty != c.cfg.ClientID:
+		return nil, errors.New("oidc: ID token azp is not this client")
+	case now.After(time.Unix(claims.Expiry, 0).Add(clockSkew)):
+		return nil, errors.New("oidc: ID token expired")
+	case time.Unix(claims.IssuedAt, 0).After(now.Add(clockSkew)):
+		return nil, errors.New("oidc: ID token issued in the future")
+	case claims.Nonce != nonce:
+		return nil, errors.New("oidc: ID token nonce mismatch")
+	case claims.Subject == "":
+		return nil, errors.New("oidc: ID token has no subject")
+	}
+	return &claims, nil
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// publicKey returns the signing key with id kid, refetching the JWKS when
+// the provider has rotated to a key we haven't seen (at most once a minute).
+func (c *Client) publicKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if k, ok := c.keys[kid]; ok {
+		return k, nil
+	}
+	if time.Since(c.keysFetched) < time.Minute && c.keys != nil {
+		return nil, fmt.Errorf("oidc: unknown signing key %q", kid)
+	}
+	keys, err := c.fetchJWKS(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.keys, c.keysFetched = keys, time.Now()
+	if k, ok := keys[kid]; ok {
+		return k, nil
+	}
+	return nil, fmt.Errorf("oidc: unknown signing key %q", kid)
+}
+
+func (c *Client) fetchJWKS(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := c.getJSON(ctx, c.meta.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("oidc: fetching JWKS: %w", err)
+	}
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err1 := base64.RawURLEncoding.DecodeString(k.N)
+		e, err2 := base64.RawURLEncoding.DecodeString(k.E)
+		if err1 != nil || err2 != nil || len(e) > 4 {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	return keys, nil
+}
+
+func (c *Client) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,244 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/oidc"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/oidc/oidctest"
+)
+
+const redirectURL = "http://app.test/login/sso/callback"
+
+// setup starts a fake provider and a Client for it. mint, if not nil,
+// replaces how the provider turns claims into an ID token.
+func setup(t *testing.T, mint func(p *oidctest.Provider, claims map[string]any) string) (*oidctest.Provider, *oidc.Client) {
+	t.Helper()
+	p := oidctest.NewProvider("app", "app-secret")
+	t.Cleanup(p.Close)
+	if mint != nil {
+		p.IDToken = func(claims map[string]any) string { return mint(p, claims) }
+	}
+	c, err := oidc.New(context.Background(), oidc.Config{
+		Issuer: p.Issuer(), ClientID: p.ClientID, ClientSecret: p.ClientSecret, RedirectURL: redirectURL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p, c
+}
+
+// authorize sends the browser's trip to the provider and returns what
+// comes back on the callback.
+func authorize(t *testing.T, authURL string) url.Values {
+	t.Helper()
+	noRedirects := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := noRedirects.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if resp.StatusCode != http.StatusFound || err != nil || !strings.HasPrefix(loc.String(), redirectURL+"?") {
+		t.Fatalf("authorize: %s, Location %q", resp.Status, resp.Header.Get("Location"))
+	}
+	return loc.Query()
+}
+
+func TestDiscovery(t *testing.T) {
+	p, c := setup(t, nil)
+	if c.Issuer() != p.Issuer() {
+		t.Errorf("Issuer() = %q, want %q", c.Issuer(), p.Issuer())
+	}
+
+	// The document must name the issuer we asked for
+	_, err := oidc.New(context.Background(), oidc.Config{Issuer: p.Issuer() + "/", ClientID: "app"})
+	if err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("New with a different issuer spelling: %v", err)
+	}
+	_, err = oidc.New(context.Background(), oidc.Config{Issuer: p.Issuer() + "/nowhere", ClientID: "app"})
+	if err == nil {
+		t.Error("New with no discovery document: want an error")
+	}
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	p, c := setup(t, nil)
+	authURL, state := c.AuthCodeURL()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	for param, want := range map[string]string{
+		"response_type":         "code",
+		"client_id":             p.ClientID,
+		"redirect_uri":          redirectURL,
+		"state":                 state,
+		"code_challenge_method": "S256",
+		"scope":                 "openid email profile",
+	} {
+		if got := q.Get(param); got != want {
+			t.Errorf("%s = %q, want %q", param, got, want)
+		}
+	}
+	if q.Get("nonce") == "" || len(q.Get("code_challenge")) != 43 {
+		t.Errorf("nonce %q, code_challenge %q: want a nonce and a base64url SHA-256", q.Get("nonce"), q.Get("code_challenge"))
+	}
+	if _, other := c.AuthCodeURL(); other == state {
+		t.Error("two logins got the same state")
+	}
+}
+
+func TestCodeFlow(t *testing.T) {
+	_, c := setup(t, nil)
+	authURL, state := c.AuthCodeURL()
+	back := authorize(t, authURL)
+	if back.Get("state") != state {
+		t.Fatalf("callback state %q, want %q", back.Get("state"), state)
+	}
+
+	claims, err := c.Finish(context.Background(), state, back.Get("code"))
+	if err != nil {
+		t.Fatalf("Finish: %v", err)
+	}
+	want := oidctest.DefaultUser
+	if claims.Subject != want.Subject || claims.Email != want.Email || !claims.EmailVerified || claims.PreferredUsername != want.PreferredUsername {
+		t.Errorf("claims = %+v, want %+v", claims, want)
+	}
+
+	// The state is spent: the same callback can't be replayed
+	if _, err := c.Finish(context.Background(), state, back.Get("code")); !errors.Is(err, oidc.ErrState) {
+		t.Errorf("replayed callback: %v, want ErrState", err)
+	}
+}
+
+func TestPKCE(t *testing.T) {
+	_, c := setup(t, nil)
+	url1, state1 := c.AuthCodeURL()
+	url2, _ := c.AuthCodeURL()
+	authorize(t, url1)
+	code2 := authorize(t, url2).Get("code")
+
+	// A code redeemed with another login's verifier (a stolen code) fails
+	_, err := c.Finish(context.Background(), state1, code2)
+	if err == nil || !strings.Contains(err.Error(), "PKCE") {
+		t.Errorf("code from another login: %v, want a PKCE failure", err)
+	}
+}
+
+func TestFinishRejects(t *testing.T) {
+	resign := func(edit func(claims map[string]any)) func(*oidctest.Provider, map[string]any) string {
+		return func(p *oidctest.Provider, claims map[string]any) string {
+			edit(claims)
+			return p.Sign(claims)
+		}
+	}
+	tests := []struct {
+		name    string
+		mint    func(p *oidctest.Provider, claims map[string]any) string
+		state   string // "" means the real one
+		wantErr string
+	}{
+		{
+			name:    "unknown state",
+			state:   "forged-state",
+			wantErr: oidc.ErrState.Error(),
+		},
+		{
+			name:    "wrong nonce",
+			mint:    resign(func(c map[string]any) { c["nonce"] = "replayed" }),
+			wantErr: "nonce mismatch",
+		},
+		{
+			name:    "wrong audience",
+			mint:    resign(func(c map[string]any) { c["aud"] = "another-app" }),
+			wantErr: "not for this client",
+		},
+		{
+			name:    "several audiences without azp",
+			mint:    resign(func(c map[string]any) { c["aud"] = []string{"app", "another-app"} }),
+			wantErr: "azp",
+		},
+		{
+			name:    "wrong issuer",
+			mint:    resign(func(c map[string]any) { c["iss"] = "https://evil.example" }),
+			wantErr: "issuer",
+		},
+		{
+			name:    "expired",
+			mint:    resign(func(c map[string]any) { c["exp"] = time.Now().Add(-2 * time.Minute).Unix() }),
+			wantErr: "expired",
+		},
+		{
+			name:    "issued in the future",
+			mint:    resign(func(c map[string]any) { c["iat"] = time.Now().Add(time.Hour).Unix() }),
+			wantErr: "future",
+		},
+		{
+			name: "unknown kid",
+			mint: func(p *oidctest.Provider, c map[string]any) string {
+				return p.SignWithHeader(map[string]string{"alg": "RS256", "kid": "rotated-away"}, c)
+			},
+			wantErr: `unknown signing key "rotated-away"`,
+		},
+		{
+			name: "alg none",
+			mint: func(_ *oidctest.Provider, c map[string]any) string {
+				return segment(map[string]string{"alg": "none"}) + "." + segment(c) + "."
+			},
+			wantErr: `alg "none" not allowed`,
+		},
+		{
+			name: "alg HS256",
+			mint: func(_ *oidctest.Provider, c map[string]any) string {
+				signed := segment(map[string]string{"alg": "HS256"}) + "." + segment(c)
+				mac := hmac.New(sha256.New, []byte("app-secret"))
+				mac.Write([]byte(signed))
+				return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+			},
+			wantErr: `alg "HS256" not allowed`,
+		},
+		{
+			name: "tampered claims",
+			mint: func(p *oidctest.Provider, c map[string]any) string {
+				parts := strings.Split(p.Sign(c), ".")
+				c["sub"] = "someone-else"
+				return parts[0] + "." + segment(c) + "." + parts[2]
+			},
+			wantErr: "signature is invalid",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, c := setup(t, tt.mint)
+			authURL, state := c.AuthCodeURL()
+			code := authorize(t, authURL).Get("code")
+			if tt.state != "" {
+				state = tt.state
+			}
+
+			claims, err := c.Finish(context.Background(), state, code)
+			if err == nil {
+				t.Fatalf("Finish accepted the login: %+v", claims)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Finish error = %q, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func segment(v any) string {
+	b, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,100 @@
+// Package onetime issues single-use, time-limited tokens for links sent by
+// email (password reset, email verification). Only a SHA-256 hash of each
+// token is kept.
+package onetime
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Purpose keeps a token for one flow from being used in another.
+type Purpose string
+
+const (
+	PasswordReset Purpose = "password_reset"
+	VerifyEmail   Purpose = "verify_email"
+)
+
+// ErrInvalid covers unknown, used, expired and wrong-purpose tokens alike;
+// callers show one message for all of them.
+var ErrInvalid = errors.New("onetime: invalid or expired link")
+
+type token struct {
+	purpose  Purpose
+	username string
+	expires  time.Time
+}
+
+var tokens = make(map[[sha256.Size]byte]token)
+var mu sync.Mutex
+
+// Issue returns a new token for username, valid for ttl. Earlier tokens of
+// the same purpose for username stop working, so only the newest link does.
+func Issue(p Purpose, username string, ttl time.Duration) string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	raw := hex.EncodeToString(b)
+
+	mu.Lock()
+	defer mu.Unlock()
+	for h, t := range tokens {
+		if (t.purpose == p && t.username == username) || time.Now().After(t.expires) {
+			delete(tokens, h)
+		}
+	}
+	tokens[sha256.Sum256([]byte(raw))] = token{purpose: p, username: username, expires: time.Now().Add(ttl)}
+	return raw
+}
+
+// Check returns the user a token belongs to without using it up (to show
+// a form before the real action).
+func Check(p Purpose, raw string) (string, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	t, ok := tokens[sha256.Sum256([]byte(raw))]
+	if !ok || t.purpose != p || time.Now().After(t.expires) {
+		return "", ErrInvalid
+	}
+	return t.username, nil
+}
+
+// Consume checks a token and deletes it, so it can't be used again.
+func Consume(p Purpose, raw string) (string, error) {
+	h := sha256.Sum256([]byte(raw))
+	mu.Lock()
+	defer mu.Unlock()
+	t, ok := tokens[h]
+	if !ok || t.purpose != p {
+		return "", ErrInvalid
+	}
+	delete(tokens, h)
+	if time.Now().After(t.expires) {
+		return "", ErrInvalid
+	}
+	return t.username, nil
+}
+
+/*
+🧠 SINGLE-USE EMAIL LINKS (internal/onetime/onetime.go)
+
+✅ What Happens Here:
+- `Issue` makes a 256-bit random token, stores its SHA-256 with the user, purpose and expiry, and returns the raw token for the link.
+- `Check` validates without spending (to render the "new password" form); `Consume` validates and deletes.
+- A new token for the same user and purpose invalidates the previous one.
+
+✅ Why This Matters:
+- A reset link is as good as the password: it must expire, work once, and not be readable from storage.
+- One error for every failure means the page never says *why* a link is bad.
+
+✅ Key Concepts:
+| Concept        | Explanation |
+|----------------|-------------|
+| Hashed at rest | The database/map only holds `sha256(token)` |
+| `Purpose`      | A verification link can't be replayed as a reset link |
+| TTL            | Reset: 30 minutes; verification: 24 hours |
+*/
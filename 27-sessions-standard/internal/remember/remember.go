@@ -0,0 +1,220 @@
+// Package remember implements "keep me signed in" with split
+// selector/validator tokens. The cookie holds "selector:validator"; the
+// server keeps the selector in the clear (to find the row) and only a
+// SHA-256 hash of the validator (to check it).
+package remember
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CookieName is the name of the remember-me cookie.
+const CookieName = "remember_me"
+
+// Lifetime is how long a remember-me login lasts, counted from the moment
+// the user ticked the box. Rotation does not extend it.
+var Lifetime = 30 * 24 * time.Hour
+
+// Grace is how long the validator replaced by the last rotation is still
+// accepted. Two tabs (or a retried request) can present the same cookie
+// at once; only the first rotates it, and without a grace period the
+// other would look like theft and log the user out everywhere.
+var Grace = 30 * time.Second
+
+var (
+	ErrInvalid = errors.New("remember: unknown or malformed token")
+	ErrExpired = errors.New("remember: token expired")
+	// ErrTheft means the selector was right but the validator was not:
+	// someone else already used this token. The series has been revoked.
+	ErrTheft = errors.New("remember: token reused, series revoked")
+)
+
+// series is one remembered device. The selector stays the same for its
+// whole life; the validator changes every time it is used.
+type series struct {
+	username  string
+	validator [sha256.Size]byte // hash of the current validator
+	previous  [sha256.Size]byte // hash of the validator it replaced
+	rotated   time.Time         // when previous was replaced; zero before the first rotation
+	ref       string            // session it last signed in (sessionstore Ref)
+	expires   time.Time
+}
+
+var tokens = make(map[string]*series) // keyed by selector
+var mu sync.Mutex
+
+// Issue starts a new series for username, bound to the session with ref,
+// and returns the cookie value and when it expires.
+func Issue(username, ref string) (string, time.Time) {
+	selector, validator := randomHex(12), randomHex(32)
+	s := &series{username: username, validator: hash(validator), ref: ref, expires: time.Now().Add(Lifetime)}
+	mu.Lock()
+	tokens[selector] = s
+	mu.Unlock()
+	return selector + ":" + validator, s.expires
+}
+
+// Redeem checks a cookie value and, if it is good, rotates the validator.
+// It returns the user, the new cookie value and its expiry. When it
+// returns ErrTheft, username says whose series was revoked.
+//
+// A validator rotated away less than Grace ago still signs the user in,
+// but next is "": a concurrent request already rotated the series and its
+// response carries the new cookie, so the caller must leave it alone.
+func Redeem(value string) (username, next string, expires time.Time, err error) {
+	selector, validator, ok := strings.Cut(value, ":")
+	if !ok {
+		return "", "", time.Time{}, ErrInvalid
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	s, ok := tokens[selector]
+	if !ok {
+		return "", "", time.Time{}, ErrInvalid
+	}
+	h := hash(validator)
+	now := time.Now()
+	current := subtle.ConstantTimeCompare(h[:], s.validator[:]) == 1
+	raced := !s.rotated.IsZero() && now.Sub(s.rotated) < Grace &&
+		subtle.ConstantTimeCompare(h[:], s.previous[:]) == 1
+	if !current && !raced {
+		delete(tokens, selector)
+		return s.username, "", time.Time{}, ErrTheft
+	}
+	if now.After(s.expires) {
+		delete(tokens, selector)
+		return "", "", time.Time{}, ErrExpired
+	}
+	if raced {
+		return s.username, "", s.expires, nil
+	}
+	validator = randomHex(32)
+	s.previous, s.validator, s.rotated = s.validator, hash(validator), now
+	return s.username, selector + ":" + validator, s.expires, nil
+}
+
+// Bind records that the series behind value signed in the session with
+// ref, so ending that session also forgets the device.
+func Bind(value, ref string) {
+	selector, _, _ := strings.Cut(value, ":")
+	mu.Lock()
+	if s, ok := tokens[selector]; ok {
+		s.ref = ref
+	}
+	mu.Unlock()
+}
+
+// Rebind moves username's series bound to the session with ref from to
+// the session with ref to (cookie-mode sessions change Ref when they gain
+// or drop privileges).
+func Rebind(username, from, to string) {
+	if from == to {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, s := range tokens {
+		if s.username == username && s.ref == from {
+			s.ref = to
+		}
+	}
+}
+
+// Revoke forgets the series behind a cookie value (on logout).
+func Revoke(value string) {
+	selector, _, _ := strings.Cut(value, ":")
+	mu.Lock()
+	delete(tokens, selector)
+	mu.Unlock()
+}
+
+// RevokeRef forgets username's series bound to the session with ref.
+func RevokeRef(username, ref string) {
+	mu.Lock()
+	defer mu.Unlock()
+	for selector, s := range tokens {
+		if s.username == username && s.ref == ref {
+			delete(tokens, selector)
+		}
+	}
+}
+
+// RevokeUser forgets every series of username except the one bound to
+// keepRef ("" keeps none) and returns how many were forgotten.
+func RevokeUser(username, keepRef string) int {
+	mu.Lock()
+	defer mu.Unlock()
+	n := 0
+	for selector, s := range tokens {
+		if s.username == username && (keepRef == "" || s.ref != keepRef) {
+			delete(tokens, selector)
+			n++
+		}
+	}
+	return n
+}
+
+// SetCookie sends the remember-me cookie, persistent until expires.
+func SetCookie(w http.ResponseWriter, value string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// ClearCookie tells the browser to drop the remember-me cookie.
+func ClearCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    "",
+		Path:     "/",
+		HttpOnly: true,
+		MaxAge:   -1,
+	})
+}
+
+func hash(validator string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(validator))
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+/*
+🧠 "KEEP ME SIGNED IN" — SELECTOR/VALIDATOR TOKENS (internal/remember/remember.go)
+
+✅ What Happens Here:
+- Ticking "remember me" issues a long-lived cookie `selector:validator` next to the normal session cookie.
+- When the session is gone (browser restart, expiry), the cookie is redeemed: the selector finds the row, the validator is checked against its hash, and a **new** validator is issued.
+- Presenting a valid selector with a stale validator means two browsers hold the same token — one of them stole it — so the whole series is deleted.
+- The one exception is the validator replaced within the last `Grace` (30s): that is two tabs racing the same rotation, not a thief, so the request is signed in without touching the cookie.
+
+✅ Why This Matters:
+- The database only holds hashes, so a leaked token table can't be replayed as cookies.
+- Looking rows up by selector (not by the secret) keeps the comparison constant-time and avoids timing leaks.
+- Rotation on every use turns cookie theft into something we can *detect* instead of a silent, permanent backdoor.
+
+✅ Key Concepts:
+| Concept                  | Explanation |
+|--------------------------|-------------|
+| Selector                 | Public lookup key; stable for the series |
+| Validator                | Secret; stored as SHA-256, replaced on every use |
+| `subtle.ConstantTimeCompare` | Compares hashes without leaking where they differ |
+| `Lifetime`               | Absolute limit from the original login (`REMEMBER_ME_LIFETIME`) |
+| `Grace`                  | How long the previous validator survives a rotation |
+*/
@@ -0,0 +1,126 @@
+package remember
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// reset empties the token map and restores Lifetime and Grace when t ends.
+func reset(t *testing.T) {
+	t.Helper()
+	lifetime, grace := Lifetime, Grace
+	mu.Lock()
+	tokens = make(map[string]*series)
+	mu.Unlock()
+	t.Cleanup(func() { Lifetime, Grace = lifetime, grace })
+}
+
+func TestRedeemRotates(t *testing.T) {
+	reset(t)
+	first, _ := Issue("alice", "ref1")
+
+	user, second, _, err := Redeem(first)
+	if err != nil || user != "alice" {
+		t.Fatalf("Redeem = %q, %v; want alice, nil", user, err)
+	}
+	if second == "" || second == first {
+		t.Fatalf("Redeem did not rotate: next = %q", second)
+	}
+	if _, third, _, err := Redeem(second); err != nil || third == "" {
+		t.Fatalf("rotated value: next = %q, err = %v", third, err)
+	}
+}
+
+func TestRedeemGrace(t *testing.T) {
+	tests := []struct {
+		name    string
+		grace   time.Duration
+		replay  func(first, second string) string // returns the value to redeem
+		wantErr error
+	}{
+		{
+			name:   "concurrent request with the previous validator",
+			grace:  time.Minute,
+			replay: func(first, _ string) string { return first },
+		},
+		{
+			name:    "previous validator after the grace period",
+			grace:   0,
+			replay:  func(first, _ string) string { return first },
+			wantErr: ErrTheft,
+		},
+		{
+			name:  "validator two rotations old",
+			grace: time.Minute,
+			replay: func(first, second string) string {
+				if _, _, _, err := Redeem(second); err != nil {
+					panic(err)
+				}
+				return first
+			},
+			wantErr: ErrTheft,
+		},
+		{
+			name:    "made-up validator",
+			grace:   time.Minute,
+			replay:  func(first, _ string) string { return first[:25] + "00" },
+			wantErr: ErrTheft,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reset(t)
+			Grace = tt.grace
+			first, _ := Issue("alice", "ref1")
+			_, second, _, err := Redeem(first)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			user, next, _, err := Redeem(tt.replay(first, second))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Redeem error = %v, want %v", err, tt.wantErr)
+			}
+			if user != "alice" {
+				t.Errorf("username = %q, want alice", user)
+			}
+			if tt.wantErr != nil {
+				if _, _, _, err := Redeem(second); !errors.Is(err, ErrInvalid) {
+					t.Errorf("series survived theft: Redeem(current) = %v", err)
+				}
+				return
+			}
+			if next != "" {
+				t.Errorf("grace redemption rotated again: next = %q, want \"\"", next)
+			}
+			if _, _, _, err := Redeem(second); err != nil {
+				t.Errorf("current validator after a grace redemption: %v", err)
+			}
+		})
+	}
+}
+
+func TestRedeemExpired(t *testing.T) {
+	reset(t)
+	Lifetime = -time.Second
+	value, _ := Issue("alice", "ref1")
+	if _, _, _, err := Redeem(value); !errors.Is(err, ErrExpired) {
+		t.Fatalf("Redeem = %v, want ErrExpired", err)
+	}
+}
+
+func TestRebind(t *testing.T) {
+	reset(t)
+	value, _ := Issue("alice", "old-ref")
+	Rebind("alice", "old-ref", "new-ref")
+
+	RevokeRef("alice", "old-ref")
+	if _, _, _, err := Redeem(value); err != nil {
+		t.Fatalf("series was still bound to the old ref: %v", err)
+	}
+	RevokeRef("alice", "new-ref")
+	if _, _, _, err := Redeem(value); !errors.Is(err, ErrInvalid) {
+		t.Errorf("series survived revoking its new ref: %v", err)
+	}
+}
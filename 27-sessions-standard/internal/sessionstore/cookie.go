@@ -0,0 +1,240 @@
+package sessionstore
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/cookiecodec"
+)
+
+// codec is set in cookie mode: the session itself, sealed, is the cookie
+// value, and the server keeps nothing per session.
+var codec *cookiecodec.Codec
+
+// UseCookies switches the store to cookie mode; call it before serving.
+func UseCookies(c *cookiecodec.Codec) { codec = c }
+
+// Stateless reports whether sessions live in cookies (see UseCookies).
+func Stateless() bool { return codec != nil }
+
+// sealed is a Session as it travels in the cookie. Short JSON names
+// leave more of the 4 KB for data.
+type sealed struct {
+	Ref       string                     `json:"r"`
+	Username  string                     `json:"u"`
+	CreatedAt int64                      `json:"tm"` // Unix milliseconds
+	IP        string                     `json:"ip,omitempty"`
+	UserAgent string                     `json:"ua,omitempty"`
+	Pending   bool                       `json:"p,omitempty"`
+	ActingAs  string                     `json:"a,omitempty"`
+	Values    map[string]json.RawMessage `json:"v,omitempty"`
+}
+
+// maxUserAgent caps the User-Agent kept in a cookie session; the header
+// is client-controlled and could otherwise push the cookie over 4 KB.
+const maxUserAgent = 200
+
+func seal(s *Session) (string, error) {
+	ua := s.UserAgent
+	if len(ua) > maxUserAgent {
+		ua = ua[:maxUserAgent]
+	}
+	b, err := json.Marshal(sealed{
+		Ref: s.Ref, Username: s.Username, CreatedAt: s.CreatedAt.UnixMilli(),
+		IP: s.IP, UserAgent: ua, Pending: s.SecondFactorPending, ActingAs: s.ActingAs, Values: s.values,
+	})
+	if err != nil {
+		return "", err
+	}
+	return codec.Encode(CookieName, b)
+}
+
+func unseal(token string) (*Session, bool) {
+	b, err := codec.Decode(CookieName, token)
+	if err != nil {
+		return nil, false
+	}
+	var v sealed
+	if err := json.Unmarshal(b, &v); err != nil || v.CreatedAt == 0 {
+		return nil, false
+	}
+	created := time.UnixMilli(v.CreatedAt)
+	return &Session{
+		ID: token, Ref: v.Ref, Username: v.Username, CreatedAt: created, LastSeen: created,
+		IP: v.IP, UserAgent: v.UserAgent, SecondFactorPending: v.Pending, ActingAs: v.ActingAs, values: v.Values,
+	}, true
+}
+
+// reseal is cookie mode's answer to a privilege change: the session gets a
+// new Ref, is sealed again, and the old Ref is refused, so the cookie from
+// before the change (say, one still acting as an impersonated user) no
+// longer opens.
+func reseal(s *Session) (string, bool) {
+	old := s.Ref
+	s.Ref = generateToken()[:16]
+	token := sealOrLog(s)
+	if token == "" {
+		return "", false
+	}
+	replaceRef(s.Username, old, s.Ref)
+	return token, true
+}
+
+// sealOrLog is seal for callers that can't report an error; an empty ID
+// simply isn't a session.
+func sealOrLog(s *Session) string {
+	token, err := seal(s)
+	if err != nil {
+		log.Printf("❌ sealing session cookie for %s: %v", s.Username, err)
+	}
+	return token
+}
+
+// open is unseal plus the little server state cookie mode keeps: a
+// pending 2FA login that was abandoned stays abandoned, and a revoked
+// session stays revoked.
+func open(token string) (*Session, bool) {
+	s, ok := unseal(token)
+	if !ok || s.SecondFactorPending && pendingLogin(s.Ref).abandoned || revoked(s) {
+		return nil, false
+	}
+	return s, true
+}
+
+// A cookie can't be deleted from the browsers that hold it, but it can be
+// refused. Per user, the server remembers that sessions started before a
+// moment are over (all but the one that asked, for "sign out other
+// devices"), and which single sessions were ended by Ref. Each entry goes
+// once every cookie it could match has expired.
+var (
+	cutoffs     = make(map[string]cutoff)       // by username
+	revokedRefs = make(map[[2]string]time.Time) // username + Ref: when the entry can go
+	revokedMu   sync.Mutex
+)
+
+type cutoff struct {
+	before  time.Time // sessions created earlier are over...
+	keepRef string    // ...except this one ("" for none)
+}
+
+func revoked(s *Session) bool {
+	revokedMu.Lock()
+	defer revokedMu.Unlock()
+	if c, ok := cutoffs[s.Username]; ok && s.CreatedAt.Before(c.before) && s.Ref != c.keepRef {
+		return true
+	}
+	_, ok := revokedRefs[[2]string{s.Username, s.Ref}]
+	return ok
+}
+
+// revokeBefore ends every cookie session of username created until now,
+// except the one with keepRef.
+func revokeBefore(username, keepRef string) {
+	// Cookies carry milliseconds: a login in the same millisecond as the
+	// revocation has to survive it.
+	now := time.Now().Truncate(time.Millisecond)
+	revokedMu.Lock()
+	defer revokedMu.Unlock()
+	pruneRevocations(now)
+	cutoffs[username] = cutoff{before: now, keepRef: keepRef}
+}
+
+// revokeRef ends username's cookie session with ref.
+func revokeRef(username, ref string) {
+	now := time.Now()
+	revokedMu.Lock()
+	defer revokedMu.Unlock()
+	pruneRevocations(now)
+	revokedRefs[[2]string{username, ref}] = now.Add(codec.MaxAge)
+}
+
+// replaceRef refuses username's old Ref from now on and moves a "sign out
+// other devices" exemption from old to new, so the session that asked
+// survives its own privilege changes.
+func replaceRef(username, old, new string) {
+	now := time.Now()
+	revokedMu.Lock()
+	defer revokedMu.Unlock()
+	pruneRevocations(now)
+	revokedRefs[[2]string{username, old}] = now.Add(codec.MaxAge)
+	if c, ok := cutoffs[username]; ok && c.keepRef == old {
+		c.keepRef = new
+		cutoffs[username] = c
+	}
+}
+
+// pruneRevocations drops entries no unexpired cookie can match; callers
+// hold revokedMu.
+func pruneRevocations(now time.Time) {
+	for username, c := range cutoffs {
+		if now.Sub(c.before) > codec.MaxAge {
+			delete(cutoffs, username)
+		}
+	}
+	for key, until := range revokedRefs {
+		if now.After(until) {
+			delete(revokedRefs, key)
+		}
+	}
+}
+
+// A pending cookie can be replayed, so wrong 2FA codes (and giving up after
+// too many) are tracked here, by Ref, until the cookie expires anyway.
+var (
+	pendingLogins   = make(map[string]pending)
+	pendingLoginsMu sync.Mutex
+)
+
+type pending struct {
+	failures  int
+	abandoned bool
+	expires   time.Time
+}
+
+func pendingLogin(ref string) pending {
+	pendingLoginsMu.Lock()
+	defer pendingLoginsMu.Unlock()
+	return pendingLogins[ref]
+}
+
+// updatePending changes the record for s, dropping expired ones.
+func updatePending(s *Session, fn func(*pending)) pending {
+	pendingLoginsMu.Lock()
+	defer pendingLoginsMu.Unlock()
+	now := time.Now()
+	for ref, p := range pendingLogins {
+		if now.After(p.expires) {
+			delete(pendingLogins, ref)
+		}
+	}
+	p := pendingLogins[s.Ref]
+	fn(&p)
+	p.expires = s.CreatedAt.Add(codec.MaxAge)
+	pendingLogins[s.Ref] = p
+	return p
+}
+
+/*
+🧠 SESSIONS IN THE COOKIE (internal/sessionstore/cookie.go)
+
+✅ What Happens Here:
+- After `UseCookies(codec)`, a session *is* its cookie: `CreateSession` seals the `Session` (and its typed data) with `cookiecodec` and returns that as the "ID".
+- `GetSession` just opens the cookie, so every function that takes a token keeps working and handlers and middleware stay the same.
+- The server can't find cookies it never kept, so revocation works by refusing them: `DeleteUserSessions` records "nothing of this user's from before now", and `DeleteSession` / `DeleteByRef` record one `Ref`. `open` checks both.
+
+✅ Why This Matters:
+- No memory per session and nothing to share between instances — any server with the key can read the cookie.
+- Revocation is the price: logout, "sign out other devices", force-logout and a password reset need a small per-user record, kept until `SESSION_MAX_AGE` has passed. It is far less state than one entry per session, but it's in this process's memory, so several instances would have to share it.
+- Wrong 2FA codes are still counted on the server, and an abandoned 2FA login stays abandoned: a counter inside the cookie would reset on every replay.
+
+✅ Key Concepts:
+| Server mode               | Cookie mode                         |
+|---------------------------|-------------------------------------|
+| Cookie = random ID        | Cookie = sealed session             |
+| `Rotate` kills the old ID | `Rotate` re-seals under a new Ref and refuses the old one |
+| `Touch` updates last seen | Last seen stays at login time       |
+| Delete the entry          | Remember "refuse this Ref" / "refuse older than now" |
+| Unlimited session data    | ~4 KB in total                      |
+*/
@@ -0,0 +1,167 @@
+package sessionstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/cookiecodec"
+)
+
+// useTestCookies switches to cookie mode with a fresh key and empty
+// revocation records until t ends.
+func useTestCookies(t *testing.T) {
+	t.Helper()
+	keys, err := cookiecodec.ParseKeys("test:" + cookiecodec.GenerateKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := cookiecodec.New(time.Hour, keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	UseCookies(c)
+	revokedMu.Lock()
+	cutoffs, revokedRefs = make(map[string]cutoff), make(map[[2]string]time.Time)
+	revokedMu.Unlock()
+	t.Cleanup(func() { UseCookies(nil) })
+}
+
+func login(t *testing.T, username string) (token string, s Session) {
+	t.Helper()
+	token = CreateSession(username, Client{IP: "192.0.2.1"})
+	s, ok := GetSession(token)
+	if !ok {
+		t.Fatalf("fresh session for %s does not open", username)
+	}
+	return token, s
+}
+
+func alive(token string) bool {
+	_, ok := GetSession(token)
+	return ok
+}
+
+func TestCookieDeleteUserSessions(t *testing.T) {
+	useTestCookies(t)
+	laptop, _ := login(t, "alice")
+	phone, _ := login(t, "alice")
+	bob, _ := login(t, "bob")
+	time.Sleep(2 * time.Millisecond) // cookies carry milliseconds
+
+	if n := DeleteUserSessions("alice", ""); n != AllSessions {
+		t.Errorf("DeleteUserSessions = %d, want AllSessions", n)
+	}
+	if alive(laptop) || alive(phone) {
+		t.Error("alice's cookies still open after DeleteUserSessions")
+	}
+	if !alive(bob) {
+		t.Error("bob was signed out with alice")
+	}
+	if again, _ := login(t, "alice"); !alive(again) {
+		t.Error("a login after the revocation does not open")
+	}
+}
+
+func TestCookieDeleteOtherSessions(t *testing.T) {
+	useTestCookies(t)
+	current, s := login(t, "alice")
+	other, _ := login(t, "alice")
+	time.Sleep(2 * time.Millisecond)
+
+	DeleteUserSessions("alice", current)
+	if !alive(current) {
+		t.Fatal("the session that asked was signed out")
+	}
+	if alive(other) {
+		t.Error("the other device is still signed in")
+	}
+	// The kept session survives its own privilege changes (new Ref)
+	rotated, ok := Rotate(current)
+	if got, _ := GetSession(rotated); !ok || got.Ref == s.Ref {
+		t.Errorf("rotated kept session: %+v, %v; want it open under a new Ref", got, ok)
+	}
+
+	// A later "everywhere" includes the one kept before
+	time.Sleep(2 * time.Millisecond)
+	DeleteUserSessions("alice", "")
+	if alive(rotated) {
+		t.Error("kept session survived a later sign-out everywhere")
+	}
+}
+
+func TestCookieDeleteByRef(t *testing.T) {
+	useTestCookies(t)
+	token, s := login(t, "alice")
+
+	DeleteByRef("mallory", s.Ref) // only alice can end alice's sessions
+	if !alive(token) {
+		t.Fatal("another user ended alice's session by its Ref")
+	}
+	if !DeleteByRef("alice", s.Ref) {
+		t.Error("DeleteByRef = false in cookie mode")
+	}
+	if alive(token) {
+		t.Error("session still opens after DeleteByRef")
+	}
+}
+
+func TestCookieLogoutEndsCopies(t *testing.T) {
+	useTestCookies(t)
+	token, _ := login(t, "alice")
+	stolen := token // the same cookie value, copied elsewhere
+
+	DeleteSession(token)
+	if alive(stolen) {
+		t.Error("a copy of the cookie still works after logout")
+	}
+}
+
+func TestCookiePrivilegeChangesRetireTheOldCookie(t *testing.T) {
+	useTestCookies(t)
+	before, s := login(t, "admin")
+
+	acting, ok := StartImpersonation(before, "bob")
+	if !ok {
+		t.Fatal("StartImpersonation failed")
+	}
+	if alive(before) {
+		t.Error("the cookie from before impersonation still opens")
+	}
+	got, _ := GetSession(acting)
+	if got.Effective() != "bob" || got.Username != "admin" || got.Ref == s.Ref {
+		t.Errorf("impersonating session = %+v", got)
+	}
+
+	after, ok := StopImpersonation(acting)
+	if !ok {
+		t.Fatal("StopImpersonation failed")
+	}
+	if alive(acting) {
+		t.Error("the impersonating cookie still opens after stop")
+	}
+	if got, ok := GetSession(after); !ok || got.ActingAs != "" {
+		t.Errorf("session after stop = %+v, %v", got, ok)
+	}
+
+	pending := CreatePendingSession("carol", Client{})
+	done, ok := CompleteSecondFactor(pending)
+	if !ok || !alive(done) || alive(pending) {
+		t.Errorf("2FA: new cookie open %v, pending cookie open %v; want true, false", alive(done), alive(pending))
+	}
+}
+
+func TestCookieRevocationsExpire(t *testing.T) {
+	useTestCookies(t)
+	codec.MaxAge = time.Millisecond
+	DeleteUserSessions("alice", "")
+	DeleteByRef("bob", "ref")
+
+	time.Sleep(5 * time.Millisecond) // every cookie they could match is gone
+	DeleteByRef("carol", "ref")      // prunes as a side effect
+
+	revokedMu.Lock()
+	defer revokedMu.Unlock()
+	if _, ok := cutoffs["alice"]; ok || len(revokedRefs) != 1 {
+		t.Errorf("stale records kept: cutoffs %v, refs %v", cutoffs, revokedRefs)
+	}
+}
@@ -0,0 +1,168 @@
+package sessionstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"maps"
+)
+
+// ErrNoSession is returned by Set when the request has no session.
+var ErrNoSession = errors.New("sessionstore: no session")
+
+// Data is one request's copy of a session's key/value data. Values are
+// kept as JSON, so any JSON-encodable type can be stored and Get decodes
+// into whatever type the caller asks for.
+type Data struct {
+	s       *Session // the live session (Rotate keeps the pointer); a copy in cookie mode
+	values  map[string]json.RawMessage
+	changed map[string]bool // keys set or deleted since Load / Save
+}
+
+// Load returns the data of the session with this ID.
+func Load(token string) (*Data, bool) {
+	if codec != nil {
+		s, ok := open(token)
+		if !ok {
+			return nil, false
+		}
+		values := maps.Clone(s.values)
+		if values == nil {
+			values = make(map[string]json.RawMessage)
+		}
+		return &Data{s: s, values: values, changed: make(map[string]bool)}, true
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	s, ok := sessions[token]
+	if !ok {
+		return nil, false
+	}
+	values := maps.Clone(s.values)
+	if values == nil {
+		values = make(map[string]json.RawMessage)
+	}
+	return &Data{s: s, values: values, changed: make(map[string]bool)}, true
+}
+
+// Get decodes the value under key into a T. It reports false if there is
+// no session, no such key, or the value isn't a T.
+func Get[T any](d *Data, key string) (T, bool) {
+	var v T
+	if d == nil {
+		return v, false
+	}
+	raw, ok := d.values[key]
+	if !ok || json.Unmarshal(raw, &v) != nil {
+		return v, false
+	}
+	return v, true
+}
+
+// Set stores v under key. Storing the value the key already has changes
+// nothing, so Save has nothing to do. In cookie mode it returns
+// cookiecodec.ErrTooLarge, and stores nothing, if the session would no
+// longer fit in a cookie.
+func Set(d *Data, key string, v any) error {
+	if d == nil {
+		return ErrNoSession
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if old, ok := d.values[key]; ok && bytes.Equal(old, raw) {
+		return nil
+	}
+	if codec != nil {
+		trial := *d.s
+		trial.values = maps.Clone(d.values)
+		trial.values[key] = raw
+		if _, err := seal(&trial); err != nil {
+			return err
+		}
+	}
+	d.values[key] = raw
+	d.changed[key] = true
+	return nil
+}
+
+// Delete removes key.
+func (d *Data) Delete(key string) {
+	if d == nil {
+		return
+	}
+	if _, ok := d.values[key]; ok {
+		delete(d.values, key)
+		d.changed[key] = true
+	}
+}
+
+// Dirty reports whether anything changed since Load or the last Save.
+func (d *Data) Dirty() bool { return d != nil && len(d.changed) > 0 }
+
+// Save writes the changed keys back to the session. Only those keys are
+// written, so two requests changing different keys don't undo each
+// other. A session that ended meanwhile (logout) is left ended.
+//
+// In cookie mode the session is re-sealed instead, and Save returns the
+// new cookie value, which the caller must send before the response
+// starts. Otherwise it returns "".
+func (d *Data) Save() (string, error) {
+	if !d.Dirty() {
+		return "", nil
+	}
+	if codec != nil {
+		d.s.values = maps.Clone(d.values)
+		token, err := seal(d.s)
+		if err != nil {
+			return "", err
+		}
+		d.s.ID = token
+		clear(d.changed)
+		return token, nil
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if sessions[d.s.ID] == d.s {
+		// Copy, then swap: copies of the Session handed out earlier share
+		// the old map and may be reading it.
+		values := maps.Clone(d.s.values)
+		if values == nil {
+			values = make(map[string]json.RawMessage)
+		}
+		for key := range d.changed {
+			if v, ok := d.values[key]; ok {
+				values[key] = v
+			} else {
+				delete(values, key)
+			}
+		}
+		d.s.values = values
+	}
+	clear(d.changed)
+	return "", nil
+}
+
+/*
+🧠 TYPED SESSION DATA (internal/sessionstore/data.go)
+
+✅ What Happens Here:
+- Besides who is logged in, a session can now hold any values: `Set(d, "cart", cart)`, then `Get[Cart](d, "cart")`.
+- `Load` gives each request its own copy; `Set`/`Delete` record which keys changed; `Save` writes back just those keys.
+- `middleware.Autosave` does the `Load` and the `Save`, so handlers never have to.
+- In cookie mode the data rides inside the sealed cookie: `Set` refuses values that would break the 4 KB limit, and `Save` hands back the re-sealed cookie.
+
+✅ Why This Matters:
+- A type mismatch is an `ok == false`, not a panic or a silent zero value from a type assertion.
+- Reading a page doesn't write anything; parallel requests that touch different keys don't overwrite each other.
+- Values are JSON, so they're easy to inspect — and ready to move to Redis or a database later.
+
+✅ Key Concepts:
+| API               | Purpose |
+|-------------------|---------|
+| `Load(id)`        | This request's copy of the data |
+| `Get[T](d, key)`  | Typed, checked read |
+| `Set(d, key, v)`  | Write; marks the key changed only if the value differs |
+| `Dirty` / `Save`  | Skip the write when nothing changed |
+*/
@@ -0,0 +1,164 @@
+package sessionstore
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/cookiecodec"
+)
+
+type cart struct {
+	Items []string `json:"items"`
+}
+
+// forEachMode runs test with sessions kept in memory and in cookies.
+func forEachMode(t *testing.T, test func(t *testing.T)) {
+	t.Run("memory", test)
+	t.Run("cookie", func(t *testing.T) {
+		useTestCookies(t)
+		test(t)
+	})
+}
+
+// load opens token's data, failing t if there is none.
+func load(t *testing.T, token string) *Data {
+	t.Helper()
+	d, ok := Load(token)
+	if !ok {
+		t.Fatal("Load: no session")
+	}
+	return d
+}
+
+// save saves d and returns the token to load it back with: the new
+// cookie in cookie mode, otherwise the same one.
+func save(t *testing.T, d *Data, token string) string {
+	t.Helper()
+	sealed, err := d.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Dirty() {
+		t.Error("dirty after Save")
+	}
+	if sealed != "" {
+		return sealed
+	}
+	return token
+}
+
+func TestDataSet(t *testing.T) {
+	forEachMode(t, func(t *testing.T) {
+		token, _ := login(t, "alice")
+		d := load(t, token)
+		if d.Dirty() {
+			t.Error("dirty straight after Load")
+		}
+		if err := Set(d, "cart", cart{Items: []string{"apple"}}); err != nil || !d.Dirty() {
+			t.Fatalf("Set: err = %v, dirty = %v", err, d.Dirty())
+		}
+		token = save(t, d, token)
+
+		d = load(t, token)
+		if got, ok := Get[cart](d, "cart"); !ok || len(got.Items) != 1 || got.Items[0] != "apple" {
+			t.Errorf("after Save: Get = %+v, %v", got, ok)
+		}
+		if err := Set(d, "cart", cart{Items: []string{"apple"}}); err != nil || d.Dirty() {
+			t.Errorf("setting an equal value: err = %v, dirty = %v", err, d.Dirty())
+		}
+		if sealed, err := d.Save(); sealed != "" || err != nil {
+			t.Errorf("Save with nothing changed = %q, %v; want no new cookie", sealed, err)
+		}
+		if err := Set(d, "f", func() {}); err == nil || d.Dirty() {
+			t.Errorf("Set of a value JSON can't encode: err = %v, dirty = %v", err, d.Dirty())
+		}
+	})
+}
+
+func TestDataDelete(t *testing.T) {
+	forEachMode(t, func(t *testing.T) {
+		token, _ := login(t, "alice")
+		d := load(t, token)
+		Set(d, "cart", cart{})
+		token = save(t, d, token)
+
+		d = load(t, token)
+		d.Delete("missing")
+		if d.Dirty() {
+			t.Error("deleting a missing key marked the data dirty")
+		}
+		d.Delete("cart")
+		if !d.Dirty() {
+			t.Error("Delete did not mark the data dirty")
+		}
+		token = save(t, d, token)
+		if _, ok := Get[cart](load(t, token), "cart"); ok {
+			t.Error("the deleted key came back after Save")
+		}
+	})
+}
+
+func TestDataGetTypeMismatch(t *testing.T) {
+	token, _ := login(t, "alice")
+	d := load(t, token)
+	Set(d, "username", "alice")
+
+	if v, ok := Get[int](d, "username"); ok || v != 0 {
+		t.Errorf("Get[int] of a string = %v, %v; want 0, false", v, ok)
+	}
+	if v, ok := Get[cart](d, "username"); ok || v.Items != nil {
+		t.Errorf("Get[cart] of a string = %+v, %v; want zero, false", v, ok)
+	}
+	if _, ok := Get[string](d, "missing"); ok {
+		t.Error("Get found a missing key")
+	}
+}
+
+func TestDataSaveWritesOnlyChangedKeys(t *testing.T) {
+	token, _ := login(t, "alice")
+	a, b := load(t, token), load(t, token) // two requests at once
+	Set(a, "theme", "dark")
+	Set(b, "cart", cart{Items: []string{"apple"}})
+	save(t, a, token)
+	save(t, b, token)
+
+	d := load(t, token)
+	if theme, _ := Get[string](d, "theme"); theme != "dark" {
+		t.Errorf("theme = %q: the second Save undid the first", theme)
+	}
+	if _, ok := Get[cart](d, "cart"); !ok {
+		t.Error("cart missing after Save")
+	}
+}
+
+func TestDataSetTooLargeForCookie(t *testing.T) {
+	useTestCookies(t)
+	token, _ := login(t, "alice")
+	d := load(t, token)
+
+	err := Set(d, "notes", strings.Repeat("x", 5000))
+	if !errors.Is(err, cookiecodec.ErrTooLarge) {
+		t.Errorf("Set = %v, want ErrTooLarge", err)
+	}
+	if _, ok := Get[string](d, "notes"); ok || d.Dirty() {
+		t.Error("Set stored a value that doesn't fit in the cookie")
+	}
+}
+
+func TestNilData(t *testing.T) {
+	var d *Data // a request without a session
+	if _, ok := Get[string](d, "username"); ok {
+		t.Error("Get found a value in no session")
+	}
+	if err := Set(d, "username", "alice"); !errors.Is(err, ErrNoSession) {
+		t.Errorf("Set = %v, want ErrNoSession", err)
+	}
+	d.Delete("username")
+	if d.Dirty() {
+		t.Error("no session is dirty")
+	}
+	if sealed, err := d.Save(); sealed != "" || err != nil {
+		t.Errorf("Save = %q, %v", sealed, err)
+	}
+}
@@ -3,35 +3,352 @@ package sessionstore
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"slices"
 	"sync"
+	"time"
 )
 
-var sessions = make(map[string]string)
+// Session is one login on one device. ID is the secret in the cookie and
+// changes on login and privilege changes; Ref identifies the session to
+// its owner (on the "active sessions" page) and stays the same.
+type Session struct {
+	ID        string    `json:"-"`
+	Ref       string    `json:"id"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+	LastSeen  time.Time `json:"last_seen"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+
+	// SecondFactorPending is set between the password step and the TOTP
+	// step of a 2FA login; such a session is not authenticated yet.
+	SecondFactorPending bool `json:"second_factor_pending"`
+	failedCodes         int  // wrong codes entered while pending
+
+	// ActingAs is the user an admin is impersonating; Username stays the
+	// admin, who owns the session.
+	ActingAs string `json:"acting_as,omitempty"`
+
+	values map[string]json.RawMessage // typed data; see Data
+}
+
+// Effective is who the session acts as: ActingAs while impersonating,
+// otherwise Username.
+func (s Session) Effective() string {
+	if s.ActingAs != "" {
+		return s.ActingAs
+	}
+	return s.Username
+}
+
+// Client is what we record about the device a request came from.
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+// ClientOf reads the client's address and User-Agent from r.
+func ClientOf(r *http.Request) Client {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return Client{IP: ip, UserAgent: r.UserAgent()}
+}
+
+// CookieName is the name of the cookie that carries the session ID.
+const CookieName = "session_token"
+
+// SetCookie sends the session ID to the browser.
+func SetCookie(w http.ResponseWriter, sessionID string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    sessionID,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// ClearCookie tells the browser to drop the session cookie.
+func ClearCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    "",
+		Path:     "/",
+		HttpOnly: true,
+		MaxAge:   -1,
+	})
+}
+
+var sessions = make(map[string]*Session)
 var mu sync.RWMutex
 
-func CreateSession(username string) string {
-	token := generateToken()
+// CreateSession starts a new session for username and returns its ID.
+func CreateSession(username string, c Client) string {
+	return newSession(username, c, false)
+}
+
+// CreatePendingSession starts a session that still needs the second
+// factor; see CompleteSecondFactor.
+func CreatePendingSession(username string, c Client) string {
+	return newSession(username, c, true)
+}
+
+func newSession(username string, c Client, pending bool) string {
+	now := time.Now()
+	s := &Session{
+		ID:        generateToken(),
+		Ref:       generateToken()[:16],
+		Username:  username,
+		CreatedAt: now,
+		LastSeen:  now,
+		IP:        c.IP,
+		UserAgent: c.UserAgent,
+
+		SecondFactorPending: pending,
+	}
+	if codec != nil {
+		return sealOrLog(s)
+	}
 	mu.Lock()
-	sessions[token] = username
+	sessions[s.ID] = s
 	mu.Unlock()
-	return token
+	return s.ID
 }
 
-func GetSession(token string) (string, bool) {
+// GetSession returns a copy of the session with this ID.
+func GetSession(token string) (Session, bool) {
+	if codec != nil {
+		s, ok := open(token)
+		if !ok {
+			return Session{}, false
+		}
+		return *s, true
+	}
 	mu.RLock()
 	defer mu.RUnlock()
-	username, ok := sessions[token]
-	return username, ok
+	s, ok := sessions[token]
+	if !ok {
+		return Session{}, false
+	}
+	return *s, true
 }
 
+// Touch records that the session was just used from c.
+func Touch(token string, c Client) {
+	if codec != nil {
+		return // would mean a new cookie on every request
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if s, ok := sessions[token]; ok {
+		s.LastSeen, s.IP, s.UserAgent = time.Now(), c.IP, c.UserAgent
+	}
+}
+
+// Rotate moves the session to a fresh ID and returns it; the old ID stops
+// working at once. Call it whenever the session gains privileges, so an ID
+// planted or leaked before that point (session fixation) is worthless.
+//
+// In cookie mode the session is re-sealed under a new Ref and the old Ref
+// is refused (see reseal).
+func Rotate(token string) (string, bool) {
+	if codec != nil {
+		s, ok := open(token)
+		if !ok {
+			return "", false
+		}
+		return reseal(s)
+	}
+	return rotate(token, func(*Session) {})
+}
+
+// rotate applies change and moves the session to a new ID under one
+// lock, so no request can see the changed session under the old ID.
+func rotate(token string, change func(*Session)) (string, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	s, ok := sessions[token]
+	if !ok {
+		return "", false
+	}
+	change(s)
+	delete(sessions, token)
+	s.ID = generateToken()
+	sessions[s.ID] = s
+	return s.ID, true
+}
+
+// CompleteSecondFactor marks a pending session fully authenticated and,
+// since that is a privilege change, moves it to a new ID, which it
+// returns.
+func CompleteSecondFactor(token string) (string, bool) {
+	if codec != nil {
+		s, ok := open(token)
+		if !ok {
+			return "", false
+		}
+		s.SecondFactorPending = false
+		return reseal(s)
+	}
+	return rotate(token, func(s *Session) { s.SecondFactorPending = false })
+}
+
+// StartImpersonation makes the session act as username and, as with any
+// privilege change, moves it to a new ID, which it returns. In cookie mode
+// the Ref changes too; callers holding the old one must update it.
+func StartImpersonation(token, username string) (string, bool) {
+	return actAs(token, username)
+}
+
+// StopImpersonation returns the session to its owner, under a new ID.
+func StopImpersonation(token string) (string, bool) {
+	return actAs(token, "")
+}
+
+func actAs(token, username string) (string, bool) {
+	if codec != nil {
+		s, ok := open(token)
+		if !ok {
+			return "", false
+		}
+		s.ActingAs = username
+		return reseal(s)
+	}
+	return rotate(token, func(s *Session) { s.ActingAs = username })
+}
+
+// FailSecondFactor counts a wrong code against a pending session and
+// returns the total so far.
+func FailSecondFactor(token string) int {
+	if codec != nil {
+		s, ok := open(token)
+		if !ok {
+			return 0
+		}
+		return updatePending(s, func(p *pending) { p.failures++ }).failures
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	s, ok := sessions[token]
+	if !ok {
+		return 0
+	}
+	s.failedCodes++
+	return s.failedCodes
+}
+
+// DeleteSession ends the session with this ID. In cookie mode its Ref is
+// refused from now on, so a copy of the cookie stops working too.
 func DeleteSession(token string) {
+	if codec != nil {
+		if s, ok := open(token); ok {
+			if s.SecondFactorPending {
+				updatePending(s, func(p *pending) { p.abandoned = true })
+			}
+			revokeRef(s.Username, s.Ref)
+		}
+		return
+	}
 	mu.Lock()
 	delete(sessions, token)
 	mu.Unlock()
 }
 
+// ListSessions returns username's sessions, most recently used first
+// (none in cookie mode, where the server doesn't know them).
+func ListSessions(username string) []Session {
+	mu.RLock()
+	var list []Session
+	for _, s := range sessions {
+		if s.Username == username {
+			list = append(list, *s)
+		}
+	}
+	mu.RUnlock()
+	slices.SortFunc(list, func(a, b Session) int { return b.LastSeen.Compare(a.LastSeen) })
+	return list
+}
+
+// DeleteByRef ends one of username's sessions by its Ref. It reports false
+// if username has no such session, so nobody can end someone else's. In
+// cookie mode the server can't tell: the Ref is refused for username only,
+// and DeleteByRef reports true.
+func DeleteByRef(username, ref string) bool {
+	if codec != nil {
+		revokeRef(username, ref)
+		return true
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for id, s := range sessions {
+		if s.Ref == ref && s.Username == username {
+			delete(sessions, id)
+			return true
+		}
+	}
+	return false
+}
+
+// AllSessions is what DeleteUserSessions returns in cookie mode, where
+// every session it matches is ended but the server can't count them.
+const AllSessions = -1
+
+// DeleteUserSessions ends every session of username except the one with
+// ID keep ("" keeps none) and returns how many were ended, or AllSessions
+// in cookie mode.
+func DeleteUserSessions(username, keep string) int {
+	if codec != nil {
+		var keepRef string
+		if s, ok := open(keep); keep != "" && ok {
+			keepRef = s.Ref
+		}
+		revokeBefore(username, keepRef)
+		return AllSessions
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	n := 0
+	for id, s := range sessions {
+		if s.Username == username && id != keep {
+			delete(sessions, id)
+			n++
+		}
+	}
+	return n
+}
+
 func generateToken() string {
 	b := make([]byte, 32)
 	rand.Read(b)
 	return hex.EncodeToString(b)
 }
+
+/*
+🧠 SESSIONS WITH METADATA, ROTATION & REVOCATION (internal/sessionstore/session.go)
+
+✅ What Happens Here:
+- Each session records who owns it, when it started, when it was last used, and from which IP / browser.
+- `Rotate` swaps the secret ID for a new one while keeping the login (its `Ref` and metadata).
+- `ListSessions`, `DeleteByRef` and `DeleteUserSessions` power the "active sessions" page and the admin force-logout.
+
+✅ Why This Matters:
+- **Session fixation**: if an attacker can make your browser use an ID they know *before* you log in, an ID that survives login hands them your account. A new ID at every privilege change closes that hole.
+- Users can spot a login they don't recognise and end it; admins can cut off a compromised account everywhere.
+- The `Ref` shown to users is not the cookie value, so listing sessions never leaks a usable token.
+
+✅ Key Concepts:
+| Concept               | Explanation |
+|-----------------------|-------------|
+| Session ID            | 32 random bytes from `crypto/rand`; a bearer secret |
+| `Ref`                 | Public, stable handle for one session |
+| Rotation              | New ID on login / privilege change (including passing 2FA); the old one is deleted |
+| `SecondFactorPending` | Password accepted, TOTP code still owed |
+| `ActingAs`            | Impersonation: the owner (`Username`) acts as another user; `Effective()` says who |
+| Revocation            | Deleting the server-side entry logs that device out immediately |
+| Cookie mode           | `UseCookies`: the same API with sessions sealed into the cookie (`cookie.go`); deletes become "refuse from now on" |
+*/
@@ -0,0 +1,47 @@
+package sessionstore
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func TestCompleteSecondFactor(t *testing.T) {
+	pending := CreatePendingSession("alice", Client{IP: "192.0.2.1"})
+
+	// Readers of the old ID must never see it fully authenticated.
+	var leaked atomic.Bool
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if s, ok := GetSession(pending); ok && !s.SecondFactorPending {
+				leaked.Store(true)
+			}
+		}
+	}()
+
+	full, ok := CompleteSecondFactor(pending)
+	close(stop)
+	<-done
+	if !ok || full == pending {
+		t.Fatalf("CompleteSecondFactor = %q, %v; want a new ID", full, ok)
+	}
+	if leaked.Load() {
+		t.Error("the old ID was seen with the second factor done")
+	}
+	if alive(pending) {
+		t.Error("the pending ID still opens")
+	}
+	if s, ok := GetSession(full); !ok || s.SecondFactorPending || s.Username != "alice" {
+		t.Errorf("new session = %+v, %v", s, ok)
+	}
+	if _, ok := CompleteSecondFactor(pending); ok {
+		t.Error("CompleteSecondFactor worked twice on the same pending ID")
+	}
+}
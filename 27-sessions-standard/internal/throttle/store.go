@@ -0,0 +1,152 @@
+package throttle
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps counters in a map: fine for a single process.
+// Counters past their Expires are swept out every minute or so, so a
+// spray of made-up usernames doesn't grow the map for ever.
+type MemoryStore struct {
+	mu        sync.Mutex
+	counters  map[string]Counter
+	lastSweep time.Time
+}
+
+// sweepEvery is how often Update looks for expired counters.
+const sweepEvery = time.Minute
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{counters: make(map[string]Counter)}
+}
+
+func (m *MemoryStore) Get(ctx context.Context, key string) (Counter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counters[key], nil
+}
+
+func (m *MemoryStore) Update(ctx context.Context, key string, fn func(*Counter)) (Counter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if t := now(); t.Sub(m.lastSweep) >= sweepEvery {
+		for k, c := range m.counters {
+			if !c.Expires.IsZero() && t.After(c.Expires) {
+				delete(m.counters, k)
+			}
+		}
+		m.lastSweep = t
+	}
+	c := m.counters[key]
+	fn(&c)
+	m.counters[key] = c
+	return c, nil
+}
+
+func (m *MemoryStore) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	delete(m.counters, key)
+	m.mu.Unlock()
+	return nil
+}
+
+// SQLStore keeps counters in a login_failures table, so several app
+// instances share them and they survive restarts. Times are stored as
+// Unix seconds; the SQL uses ? placeholders (SQLite, MySQL). Expires is
+// not stored: a row is simply overwritten by the key's next failure.
+//
+// With SQLite, open the database with _txlock=immediate: a deferred
+// transaction only takes the write lock at the INSERT, so two concurrent
+// Updates can both read the old count and one increment is lost.
+type SQLStore struct {
+	DB *sql.DB
+}
+
+// Schema creates the table SQLStore needs.
+const Schema = `CREATE TABLE IF NOT EXISTS login_failures (
+	key          TEXT PRIMARY KEY,
+	failures     INTEGER NOT NULL,
+	last_failure INTEGER NOT NULL,
+	locked_until INTEGER NOT NULL
+)`
+
+// NewSQLStore creates the table if needed and returns the store.
+func NewSQLStore(ctx context.Context, db *sql.DB) (*SQLStore, error) {
+	if _, err := db.ExecContext(ctx, Schema); err != nil {
+		return nil, err
+	}
+	return &SQLStore{DB: db}, nil
+}
+
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func get(ctx context.Context, q querier, key string) (Counter, error) {
+	var failures int
+	var last, locked int64
+	err := q.QueryRowContext(ctx,
+		`SELECT failures, last_failure, locked_until FROM login_failures WHERE key = ?`, key,
+	).Scan(&failures, &last, &locked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Counter{}, nil
+	}
+	if err != nil {
+		return Counter{}, err
+	}
+	return Counter{Failures: failures, LastFailure: fromUnix(last), LockedUntil: fromUnix(locked)}, nil
+}
+
+func (s *SQLStore) Get(ctx context.Context, key string) (Counter, error) {
+	return get(ctx, s.DB, key)
+}
+
+// Update runs the read-modify-write in one transaction.
+func (s *SQLStore) Update(ctx context.Context, key string, fn func(*Counter)) (Counter, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Counter{}, err
+	}
+	defer tx.Rollback()
+
+	c, err := get(ctx, tx, key)
+	if err != nil {
+		return Counter{}, err
+	}
+	fn(&c)
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO login_failures (key, failures, last_failure, locked_until) VALUES (?, ?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = excluded.failures,
+			last_failure = excluded.last_failure,
+			locked_until = excluded.locked_until`,
+		key, c.Failures, toUnix(c.LastFailure), toUnix(c.LockedUntil))
+	if err != nil {
+		return Counter{}, err
+	}
+	return c, tx.Commit()
+}
+
+func (s *SQLStore) Delete(ctx context.Context, key string) error {
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM login_failures WHERE key = ?`, key)
+	return err
+}
+
+func toUnix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func fromUnix(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(n, 0)
+}
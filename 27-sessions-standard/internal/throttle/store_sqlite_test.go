@@ -0,0 +1,28 @@
+//go:build sqlite
+
+package throttle
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+// With -tags sqlite every Store test also runs against SQLStore.
+func init() {
+	stores["sqlite"] = func(t *testing.T) Store {
+		db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "throttle.db")+"?_pragma=busy_timeout(5000)&_txlock=immediate")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		s, err := NewSQLStore(context.Background(), db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+}
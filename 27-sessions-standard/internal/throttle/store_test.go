@@ -0,0 +1,94 @@
+package throttle
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		if c, err := s.Get(ctx, "user:nobody"); err != nil || c != (Counter{}) {
+			t.Fatalf("Get of a missing key = %+v, %v; want zero", c, err)
+		}
+
+		locked := time.Date(2026, 1, 2, 3, 19, 5, 0, time.UTC)
+		c, err := s.Update(ctx, "user:alice", func(c *Counter) {
+			c.Failures = 10
+			c.LastFailure = locked.Add(-15 * time.Minute)
+			c.LockedUntil = locked
+		})
+		if err != nil || c.Failures != 10 {
+			t.Fatalf("Update = %+v, %v", c, err)
+		}
+		got, err := s.Get(ctx, "user:alice")
+		if err != nil || got.Failures != 10 || !got.LockedUntil.Equal(locked) || !got.LastFailure.Equal(locked.Add(-15*time.Minute)) {
+			t.Errorf("Get after Update = %+v, %v", got, err)
+		}
+
+		if err := s.Delete(ctx, "user:alice"); err != nil {
+			t.Fatal(err)
+		}
+		if c, _ := s.Get(ctx, "user:alice"); c.Failures != 0 {
+			t.Errorf("Get after Delete = %+v, want zero", c)
+		}
+		if err := s.Delete(ctx, "user:alice"); err != nil {
+			t.Errorf("Delete of a missing key: %v", err)
+		}
+	})
+}
+
+func TestStoreUpdateIsAtomic(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		const n = 20
+		var wg sync.WaitGroup
+		for range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := s.Update(ctx, "ip:192.0.2.1", func(c *Counter) { c.Failures++ }); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+		if c, _ := s.Get(ctx, "ip:192.0.2.1"); c.Failures != n {
+			t.Errorf("%d concurrent increments left %d", n, c.Failures)
+		}
+	})
+}
+
+func TestMemoryStoreSweepsExpiredCounters(t *testing.T) {
+	advance := clock(t)
+	ctx := context.Background()
+	s := NewMemoryStore()
+	l := New(s)
+	for _, user := range []string{"a", "b", "c"} {
+		if err := l.Failure(ctx, user, "192.0.2.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Account.LockAfter, l.Account.LockFor = 1, 2*l.Account.Window
+	if err := l.Failure(ctx, "locked", "192.0.2.2"); err != nil {
+		t.Fatal(err)
+	}
+
+	advance(l.Account.Window + time.Second)
+	if err := l.Failure(ctx, "d", "192.0.2.3"); err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	want := []string{"user:d", "ip:192.0.2.3", "user:locked"} // the lock outlasts the window
+	if len(s.counters) != len(want) {
+		t.Errorf("counters after the window: %v, want only %v", s.counters, want)
+	}
+	for _, k := range want {
+		if _, ok := s.counters[k]; !ok {
+			t.Errorf("%s was swept", k)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+// Package throttle slows down and then locks out repeated failed logins,
+// counted per account and per client IP, to stop password guessing and
+// spraying.
+package throttle
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/audit"
+)
+
+// Counter is the failure history of one key.
+type Counter struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+	Expires     time.Time // after this the counter is as good as zero
+}
+
+// now is the clock; tests move it.
+var now = time.Now
+
+// Store keeps counters by key ("user:alice", "ip:203.0.113.9").
+// Implementations must make Update atomic per key.
+type Store interface {
+	Get(ctx context.Context, key string) (Counter, error)
+	// Update loads key's counter (zero if none), lets fn change it and
+	// saves the result.
+	Update(ctx context.Context, key string, fn func(*Counter)) (Counter, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Policy is the rule for one kind of key.
+type Policy struct {
+	FreeAttempts int           // failures before any delay
+	BaseDelay    time.Duration // delay after the first counted failure, doubling each time
+	MaxDelay     time.Duration
+	LockAfter    int // failures that lock the key
+	LockFor      time.Duration
+	Window       time.Duration // failures older than this are forgotten
+}
+
+// Defaults: an account tolerates a few typos, an IP (which may be a whole
+// office behind NAT) a lot more.
+var (
+	DefaultAccountPolicy = Policy{FreeAttempts: 3, BaseDelay: 500 * time.Millisecond, MaxDelay: 8 * time.Second, LockAfter: 10, LockFor: 15 * time.Minute, Window: time.Hour}
+	DefaultIPPolicy      = Policy{FreeAttempts: 10, BaseDelay: 250 * time.Millisecond, MaxDelay: 8 * time.Second, LockAfter: 100, LockFor: 15 * time.Minute, Window: time.Hour}
+)
+
+// Limiter applies the account and IP policies on top of a Store.
+type Limiter struct {
+	Store   Store
+	Account Policy
+	IP      Policy
+}
+
+// New returns a Limiter with the default policies.
+func New(store Store) *Limiter {
+	return &Limiter{Store: store, Account: DefaultAccountPolicy, IP: DefaultIPPolicy}
+}
+
+// Decision says how to treat the next login attempt.
+type Decision struct {
+	Delay  time.Duration // wait this long before answering
+	Locked bool          // refuse without checking the password
+}
+
+func userKey(username string) string { return "user:" + strings.ToLower(username) }
+func ipKey(ip string) string         { return "ip:" + ip }
+
+// Check is called before verifying credentials. Unknown usernames are
+// counted like real ones, so the answer never depends on existence.
+func (l *Limiter) Check(ctx context.Context, username, ip string) (Decision, error) {
+	now := now()
+	var d Decision
+	for _, k := range []struct {
+		key    string
+		policy Policy
+	}{{userKey(username), l.Account}, {ipKey(ip), l.IP}} {
+		c, err := l.Store.Get(ctx, k.key)
+		if err != nil {
+			return Decision{}, err
+		}
+		if now.Before(c.LockedUntil) {
+			d.Locked = true
+		}
+		if now.Sub(c.LastFailure) < k.policy.Window {
+			d.Delay = max(d.Delay, k.policy.delay(c.Failures))
+		}
+	}
+	return d, nil
+}
+
+// delay is BaseDelay·2^(n-FreeAttempts-1) once n passes FreeAttempts.
+func (p Policy) delay(failures int) time.Duration {
+	n := failures - p.FreeAttempts
+	if n <= 0 {
+		return 0
+	}
+	d := p.BaseDelay
+	for i := 1; i < n && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	return min(d, p.MaxDelay)
+}
+
+// Failure counts a failed login for the account and the IP, locking
+// either one that reaches its threshold.
+func (l *Limiter) Failure(ctx context.Context, username, ip string) error {
+	if err := l.fail(ctx, userKey(username), l.Account, "account.locked", username, ip); err != nil {
+		return err
+	}
+	return l.fail(ctx, ipKey(ip), l.IP, "ip.locked", ip, ip)
+}
+
+func (l *Limiter) fail(ctx context.Context, key string, p Policy, action, target, ip string) error {
+	now := now()
+	locked := false
+	c, err := l.Store.Update(ctx, key, func(c *Counter) {
+		expired := !c.LockedUntil.IsZero() && !now.Before(c.LockedUntil)
+		if expired || now.Sub(c.LastFailure) >= p.Window {
+			*c = Counter{} // start over after a lockout or a quiet spell
+		}
+		c.Failures++
+		c.LastFailure = now
+		if c.Failures >= p.LockAfter && c.LockedUntil.IsZero() {
+			c.LockedUntil = now.Add(p.LockFor)
+			locked = true
+		}
+		c.Expires = now.Add(p.Window)
+		if c.LockedUntil.After(c.Expires) {
+			c.Expires = c.LockedUntil
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if locked {
+		audit.Record(audit.Event{
+			Actor: "system", Action: action, Target: target, IP: ip,
+			Detail: "after " + strconv.Itoa(c.Failures) + " failed logins, until " + c.LockedUntil.Format(time.RFC3339),
+		})
+	}
+	return nil
+}
+
+// Success clears the account's failures after a good login. The IP
+// counter is left alone: a sprayer who guesses one password is still
+// spraying.
+func (l *Limiter) Success(ctx context.Context, username string) error {
+	return l.Store.Delete(ctx, userKey(username))
+}
+
+// Unlock clears an account's failures and lockout (admin action).
+func (l *Limiter) Unlock(ctx context.Context, username, admin string) error {
+	if err := l.Store.Delete(ctx, userKey(username)); err != nil {
+		return err
+	}
+	audit.Record(audit.Event{Actor: admin, Action: "account.unlocked", Target: username})
+	return nil
+}
+
+// UnlockIP clears an IP address's failures and lockout (admin action).
+func (l *Limiter) UnlockIP(ctx context.Context, ip, admin string) error {
+	if err := l.Store.Delete(ctx, ipKey(ip)); err != nil {
+		return err
+	}
+	audit.Record(audit.Event{Actor: admin, Action: "ip.unlocked", Target: ip})
+	return nil
+}
+
+/*
+🧠 LOGIN THROTTLING & LOCKOUT (internal/throttle/throttle.go)
+
+✅ What Happens Here:
+- Every failed login bumps two counters: one for the username, one for the client IP.
+- After a few free attempts each further failure doubles the wait before the server answers (capped).
+- At the threshold the key is locked for a while; the lock and any admin unlock are written to the audit log.
+- A good login clears the account counter; the IP counter only fades with time.
+
+✅ Why This Matters:
+- **Brute force** (many passwords, one account) hits the account limit; **password spraying** (one password, many accounts) hits the IP limit.
+- Non-existent usernames get counters too, and a locked account gets the same "invalid username or password" as a wrong password — an attacker learns nothing about which accounts exist or are locked.
+
+✅ Key Concepts:
+| Concept              | Explanation |
+|----------------------|-------------|
+| `Store` interface    | `MemoryStore` for one process, `SQLStore` to share state between instances |
+| Exponential delay    | `BaseDelay · 2ⁿ`, capped at `MaxDelay` |
+| Lockout              | `LockAfter` failures within `Window` → refused for `LockFor` |
+| Audit                | `account.locked`, `ip.locked`, `account.unlocked`, `ip.unlocked` |
+*/
@@ -0,0 +1,196 @@
+package throttle
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/audit"
+)
+
+// stores are the Store implementations every test here runs against;
+// store_sqlite_test.go adds SQLStore when built with -tags sqlite.
+var stores = map[string]func(t *testing.T) Store{
+	"memory": func(*testing.T) Store { return NewMemoryStore() },
+}
+
+func forEachStore(t *testing.T, test func(t *testing.T, s Store)) {
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) { test(t, open(t)) })
+	}
+}
+
+// clock runs the package clock from a fixed start; advance moves it.
+func clock(t *testing.T) (advance func(time.Duration)) {
+	t.Helper()
+	current := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	now = func() time.Time { return current }
+	t.Cleanup(func() { now = time.Now })
+	return func(d time.Duration) { current = current.Add(d) }
+}
+
+func lastAudit() audit.Event {
+	if e := audit.Recent(1); len(e) == 1 {
+		return e[0]
+	}
+	return audit.Event{}
+}
+
+func TestDelay(t *testing.T) {
+	p := Policy{FreeAttempts: 3, BaseDelay: 500 * time.Millisecond, MaxDelay: 8 * time.Second}
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{3, 0},
+		{4, 500 * time.Millisecond},
+		{5, time.Second},
+		{6, 2 * time.Second},
+		{7, 4 * time.Second},
+		{8, 8 * time.Second},
+		{9, 8 * time.Second},
+		{1000, 8 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := p.delay(tt.failures); got != tt.want {
+			t.Errorf("delay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLockout(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		clock(t)
+		ctx := context.Background()
+		l := New(s)
+
+		for i := 1; i < l.Account.LockAfter; i++ {
+			if err := l.Failure(ctx, "Alice", "192.0.2.1"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		d, err := l.Check(ctx, "alice", "192.0.2.1")
+		if err != nil || d.Locked || d.Delay != l.Account.MaxDelay {
+			t.Fatalf("after %d failures: %+v, %v; want max delay, not locked", l.Account.LockAfter-1, d, err)
+		}
+
+		if err := l.Failure(ctx, "alice", "192.0.2.1"); err != nil {
+			t.Fatal(err)
+		}
+		if d, _ := l.Check(ctx, "ALICE", "198.51.100.7"); !d.Locked {
+			t.Error("account not locked at LockAfter (checked from another IP)")
+		}
+		if e := lastAudit(); e.Action != "account.locked" || e.Target != "alice" || e.IP != "192.0.2.1" {
+			t.Errorf("audit = %+v, want account.locked for alice", e)
+		}
+		if d, _ := l.Check(ctx, "bob", "198.51.100.7"); d.Locked || d.Delay != 0 {
+			t.Errorf("another account on another IP: %+v, want untouched", d)
+		}
+	})
+}
+
+func TestSprayLocksTheIP(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		clock(t)
+		ctx := context.Background()
+		l := New(s)
+		l.IP.LockAfter = 5
+
+		for _, user := range []string{"a", "b", "c", "d", "e"} {
+			if err := l.Failure(ctx, user, "203.0.113.9"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if d, _ := l.Check(ctx, "f", "203.0.113.9"); !d.Locked {
+			t.Error("IP not locked after failures across many accounts")
+		}
+		if e := lastAudit(); e.Action != "ip.locked" || e.Target != "203.0.113.9" {
+			t.Errorf("audit = %+v, want ip.locked", e)
+		}
+		if err := l.Success(ctx, "a"); err != nil {
+			t.Fatal(err)
+		}
+		if d, _ := l.Check(ctx, "a", "203.0.113.9"); !d.Locked {
+			t.Error("a good login cleared the IP counter")
+		}
+	})
+}
+
+func TestReset(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		wait     func(p Policy) time.Duration
+	}{
+		{"quiet for a window", 5, func(p Policy) time.Duration { return p.Window }},
+		{"lock expired", 10, func(p Policy) time.Duration { return p.LockFor }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachStore(t, func(t *testing.T, s Store) {
+				advance := clock(t)
+				ctx := context.Background()
+				l := New(s)
+				for range tt.failures {
+					if err := l.Failure(ctx, "alice", "192.0.2.1"); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				advance(tt.wait(l.Account) - time.Second)
+				if d, _ := l.Check(ctx, "alice", "192.0.2.2"); !d.Locked && d.Delay == 0 {
+					t.Fatalf("a second early: %+v, want still throttled", d)
+				}
+
+				advance(time.Second)
+				if d, _ := l.Check(ctx, "alice", "192.0.2.2"); d.Locked {
+					t.Fatalf("on time: %+v, want unlocked", d)
+				}
+				if err := l.Failure(ctx, "alice", "192.0.2.2"); err != nil {
+					t.Fatal(err)
+				}
+				if c, _ := s.Get(ctx, userKey("alice")); c.Failures != 1 {
+					t.Errorf("next failure counted as %d, want a fresh start at 1", c.Failures)
+				}
+			})
+		})
+	}
+}
+
+func TestUnlock(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		clock(t)
+		ctx := context.Background()
+		l := New(s)
+		l.IP.LockAfter = l.Account.LockAfter
+		for range l.Account.LockAfter {
+			if err := l.Failure(ctx, "alice", "192.0.2.1"); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := l.Unlock(ctx, "Alice", "root"); err != nil {
+			t.Fatal(err)
+		}
+		if e := lastAudit(); e.Action != "account.unlocked" || e.Actor != "root" || e.Target != "Alice" {
+			t.Errorf("audit = %+v, want account.unlocked by root", e)
+		}
+		if d, _ := l.Check(ctx, "alice", "198.51.100.7"); d.Locked || d.Delay != 0 {
+			t.Errorf("after Unlock: %+v, want a clean account", d)
+		}
+		if d, _ := l.Check(ctx, "bob", "192.0.2.1"); !d.Locked {
+			t.Error("Unlock of the account also unlocked the IP")
+		}
+
+		if err := l.UnlockIP(ctx, "192.0.2.1", "root"); err != nil {
+			t.Fatal(err)
+		}
+		if e := lastAudit(); e.Action != "ip.unlocked" || e.Target != "192.0.2.1" {
+			t.Errorf("audit = %+v, want ip.unlocked", e)
+		}
+		if d, _ := l.Check(ctx, "bob", "192.0.2.1"); d.Locked {
+			t.Error("IP still locked after UnlockIP")
+		}
+	})
+}
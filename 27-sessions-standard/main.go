@@ -1,15 +1,124 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
 
 	// Importing our handlers and middleware packages
 	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/handlers"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/accounts"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/cookiecodec"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/mail"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/oidc"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/oidc/oidctest"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/remember"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/sessionstore"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/throttle"
 	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/middleware"
 )
 
 func main() {
+	// `go run . keygen` prints a fresh cookie-mode key and exits
+	if len(os.Args) > 1 && os.Args[1] == "keygen" {
+		fmt.Printf("SESSION_KEYS=%s:%s\n", time.Now().Format("20060102"), cookiecodec.GenerateKey())
+		fmt.Fprintln(os.Stderr, "# rotating? keep the old id:key after the new one, comma-separated")
+		return
+	}
+
+	// Where sessions live: in server memory (default), or sealed into the
+	// cookie with SESSION_MODE=cookie and SESSION_KEYS from `keygen`
+	switch mode := os.Getenv("SESSION_MODE"); mode {
+	case "", "server":
+	case "cookie":
+		keys, err := cookiecodec.ParseKeys(os.Getenv("SESSION_KEYS"))
+		if err != nil {
+			log.Fatalf("SESSION_KEYS (generate one with `go run . keygen`): %v", err)
+		}
+		maxAge := 24 * time.Hour
+		if v := os.Getenv("SESSION_MAX_AGE"); v != "" {
+			if maxAge, err = time.ParseDuration(v); err != nil {
+				log.Fatalf("SESSION_MAX_AGE=%q is not a duration", v)
+			}
+		}
+		codec, err := cookiecodec.New(maxAge, keys...)
+		if err != nil {
+			log.Fatalf("SESSION_KEYS: %v", err)
+		}
+		sessionstore.UseCookies(codec)
+		log.Printf("🍪 Sessions are sealed into cookies (key %s, max age %s)", keys[0].ID, maxAge)
+	default:
+		log.Fatalf("SESSION_MODE=%q: want server or cookie", mode)
+	}
+
+	// How long "remember me" lasts, e.g. REMEMBER_ME_LIFETIME=168h (default 30 days)
+	if v := os.Getenv("REMEMBER_ME_LIFETIME"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatalf("REMEMBER_ME_LIFETIME=%q is not a positive duration", v)
+		}
+		remember.Lifetime = d
+	}
+
+	// Outgoing mail: SMTP_ADDR for a real server, otherwise .eml files in ./outbox
+	handlers.Mailer = mail.FromEnv()
+	if v := os.Getenv("APP_BASE_URL"); v != "" {
+		handlers.BaseURL = strings.TrimSuffix(v, "/")
+	}
+	handlers.RequireVerifiedEmail = os.Getenv("REQUIRE_VERIFIED_EMAIL") == "true"
+
+	// Failed-login counters: in memory by default, or in SQLite so several
+	// instances (and restarts) share them, e.g. THROTTLE_DB=throttle.db;
+	// the SQLite driver is only compiled in with -tags sqlite (sqlite.go)
+	if path := os.Getenv("THROTTLE_DB"); path != "" {
+		if !slices.Contains(sql.Drivers(), "sqlite") {
+			log.Fatal("THROTTLE_DB needs the SQLite driver: go run -tags sqlite .")
+		}
+		db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_txlock=immediate")
+		if err != nil {
+			log.Fatal(err)
+		}
+		store, err := throttle.NewSQLStore(context.Background(), db)
+		if err != nil {
+			log.Fatalf("THROTTLE_DB: %v", err)
+		}
+		handlers.Throttle = throttle.New(store)
+	}
+
+	// Single sign-on through the company identity provider (OIDC_ISSUER,
+	// OIDC_CLIENT_ID, OIDC_CLIENT_SECRET), or OIDC_FAKE=true for a fake
+	// provider running inside this process
+	ssoConfig := oidc.Config{
+		Issuer:       os.Getenv("OIDC_ISSUER"),
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+	}
+	if os.Getenv("OIDC_FAKE") == "true" {
+		fake := oidctest.NewProvider("lesson-27", "fake-secret")
+		defer fake.Close()
+		ssoConfig.Issuer, ssoConfig.ClientID, ssoConfig.ClientSecret = fake.Issuer(), fake.ClientID, fake.ClientSecret
+		log.Println("🧪 Fake OIDC provider at", fake.Issuer())
+	}
+	if ssoConfig.Issuer != "" {
+		if ssoConfig.RedirectURL == "" {
+			ssoConfig.RedirectURL = handlers.BaseURL + "/login/sso/callback"
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		client, err := oidc.New(ctx, ssoConfig)
+		cancel()
+		if err != nil {
+			log.Fatalf("OIDC_ISSUER: %v", err)
+		}
+		handlers.SSO = client
+	}
+
 	// Create a new HTTP request multiplexer (router)
 	mux := http.NewServeMux()
 
@@ -17,15 +126,80 @@ func main() {
 	mux.HandleFunc("/", handlers.Home)       // Accessible by anyone
 	mux.HandleFunc("/login", handlers.Login) // Simulates login and sets session cookie
 	mux.HandleFunc("/logout", handlers.Logout) // Clears the session
+	mux.HandleFunc("POST /login/2fa", handlers.SecondFactorLogin) // Second step for users with 2FA
+	mux.HandleFunc("GET /login/sso", handlers.SSOLogin)             // Off to the identity provider...
+	mux.HandleFunc("GET /login/sso/callback", handlers.SSOCallback) // ...and back, signed in
+
+	// ✉️ Password reset and email verification links
+	mux.HandleFunc("GET /password/forgot", handlers.ForgotPasswordPage)
+	mux.HandleFunc("POST /password/forgot", handlers.ForgotPassword)
+	mux.HandleFunc("GET /password/reset", handlers.ResetPasswordPage)
+	mux.HandleFunc("POST /password/reset", handlers.ResetPassword)
+	mux.HandleFunc("GET /email/verify", handlers.VerifyEmail)
+	mux.Handle("POST /email/verify", middleware.RequireSession(http.HandlerFunc(handlers.SendVerification)))
 
 	// 🔐 Protected endpoint wrapped with session-checking middleware
 	mux.Handle("/dashboard", middleware.RequireSession(http.HandlerFunc(handlers.Dashboard)))
 	// If the session is valid, the request proceeds to Dashboard handler
 	// If not, it returns 401 Unauthorized
 
+	// 📱 "Your active sessions": list devices and sign them out
+	mux.Handle("GET /sessions", middleware.RequireSession(http.HandlerFunc(handlers.SessionsPage)))
+	mux.Handle("POST /sessions/{ref}/revoke", middleware.RequireSession(http.HandlerFunc(handlers.RevokeSession)))
+	mux.Handle("POST /sessions/revoke-others", middleware.RequireSession(http.HandlerFunc(handlers.RevokeOtherSessions)))
+
+	// 🤖 The API: a session cookie or "Authorization: Bearer <token>" with the scope
+	sessionsRead := middleware.RequireSessionOrToken(accounts.PermSessionsRead)
+	sessionsWrite := middleware.RequireSessionOrToken(accounts.PermSessionsWrite)
+	mux.Handle("GET /api/sessions", sessionsRead(http.HandlerFunc(handlers.ListSessionsAPI)))
+	mux.Handle("DELETE /api/sessions/{ref}", sessionsWrite(http.HandlerFunc(handlers.DeleteSessionAPI)))
+	mux.Handle("DELETE /api/sessions", sessionsWrite(http.HandlerFunc(handlers.DeleteOtherSessionsAPI)))
+	mux.Handle("GET /api/users/me", middleware.RequireSessionOrToken(accounts.PermProfileRead)(http.HandlerFunc(handlers.MeAPI)))
+	mux.Handle("GET /api/users", middleware.RequireSessionOrToken(accounts.PermUsersRead)(
+		middleware.RequireTwoFactor(http.HandlerFunc(handlers.ListUsersAPI))))
+
+	// 🔑 Personal access tokens: made by their owner, in a browser session,
+	// never while impersonating
+	requireOwner := func(h http.HandlerFunc) http.Handler {
+		return middleware.RequireSession(middleware.NotImpersonating(h))
+	}
+	mux.Handle("GET /settings/tokens", requireOwner(handlers.TokensPage))
+	mux.Handle("POST /settings/tokens", requireOwner(handlers.CreateToken))
+	mux.Handle("POST /settings/tokens/{id}/revoke", requireOwner(handlers.RevokeToken))
+	mux.Handle("GET /api/tokens", requireOwner(handlers.ListTokensAPI))
+	mux.Handle("POST /api/tokens", requireOwner(handlers.CreateTokenAPI))
+	mux.Handle("DELETE /api/tokens/{id}", requireOwner(handlers.RevokeTokenAPI))
+
+	// 🛒 A cart kept in typed session data
+	mux.Handle("GET /cart", middleware.RequireSession(http.HandlerFunc(handlers.ShowCart)))
+	mux.Handle("POST /cart", middleware.RequireSession(http.HandlerFunc(handlers.AddToCart)))
+
+	// 🔑 Two-factor authentication: enroll and check status
+	mux.Handle("GET /2fa", middleware.RequireSession(middleware.NotImpersonating(http.HandlerFunc(handlers.TwoFactorPage))))
+	mux.Handle("POST /2fa", middleware.RequireSession(middleware.NotImpersonating(http.HandlerFunc(handlers.ConfirmTwoFactor))))
+
+	// 🛡️ Admin only (and only with 2FA on): sign a user out everywhere, reset
+	// a user's 2FA, lift login lockouts, read the audit log
+	requireAdmin := func(h http.HandlerFunc) http.Handler {
+		return middleware.RequireSession(middleware.RequireRole(accounts.RoleAdmin)(middleware.RequireTwoFactor(h)))
+	}
+	mux.Handle("POST /admin/users/{username}/logout", requireAdmin(handlers.ForceLogout))
+	mux.Handle("POST /admin/users/{username}/2fa/reset", requireAdmin(handlers.ResetTwoFactor))
+	mux.Handle("POST /admin/users/{username}/unlock", requireAdmin(handlers.UnlockAccount))
+	mux.Handle("POST /admin/ips/{ip}/unlock", requireAdmin(handlers.UnlockIP))
+	mux.Handle("GET /admin/audit", requireAdmin(handlers.AuditLog))
+
+	// 🎭 Support: act as a user (permission "impersonate", 2FA on), and back
+	mux.Handle("POST /admin/users/{username}/impersonate", middleware.RequireSession(
+		middleware.RequirePermission(accounts.PermImpersonate)(middleware.RequireTwoFactor(http.HandlerFunc(handlers.StartImpersonation)))))
+	mux.Handle("POST /impersonate/stop", middleware.RequireSession(http.HandlerFunc(handlers.StopImpersonation)))
+
 	// Start the web server on localhost:8080
 	log.Println("🔐 Server running at http://localhost:8080")
-	http.ListenAndServe(":8080", mux)
+	// RememberMe runs first, so an expired session is silently renewed
+	// from the remember-me cookie before any RequireSession check; then
+	// Autosave loads the session's data and saves what handlers change
+	http.ListenAndServe(":8080", middleware.RememberMe(middleware.Autosave(mux)))
 }
 
 /*
@@ -50,6 +224,14 @@ func main() {
 | Middleware                   | Intercepts HTTP requests to enforce security             |
 | Cookie-based session         | Tracks user identity without full login systems          |
 | Context propagation          | Safely injects user identity into handlers               |
+| `"GET /sessions"` patterns   | Method + path routing (Go 1.22+), `{ref}` path values    |
+| `middleware.RememberMe(mux)` | Re-creates a session from the remember-me cookie         |
+| `middleware.Autosave`        | Typed session data, saved only when changed              |
+| `SESSION_MODE=cookie`        | Sessions sealed into the cookie (`cookiecodec`, AES-GCM) |
+| `RequirePermission`          | Route needs a permission (e.g. `impersonate`), not a role |
+| `RequireSessionOrToken`      | API route open to personal access tokens with the scope  |
+| `RequireTwoFactor`           | Admin routes need an enrolled second factor              |
+| `OIDC_*` / `handlers.SSO`    | Optional "Sign in with SSO" via OpenID Connect           |
 
 🔐 Security Tip:
 - Cookies are marked `HttpOnly` and `SameSite` to mitigate XSS and CSRF risks.
@@ -57,6 +239,5 @@ func main() {
 
 📚 Up Next:
 - Improve sessions using secure stores like `gorilla/sessions`
-- Add login forms and password hashing
 - Store session tokens in Redis or a database for scalability
 */
\ No newline at end of file
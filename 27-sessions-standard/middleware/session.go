@@ -2,49 +2,304 @@ package middleware
 
 import (
 	"context"
+	"errors"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/accounts"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/audit"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/mfa"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/remember"
 	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/sessionstore"
 )
 
 // Define a custom type for context keys to avoid collisions with other packages
 type contextKey string
 
-// Constant key for storing the username in request context
-const userContextKey contextKey = "user"
+// Constant keys for storing the username and the session in request context
+const (
+	userContextKey     contextKey = "user"
+	realUserContextKey contextKey = "real_user"
+	sessionContextKey  contextKey = "session"
+	dataContextKey     contextKey = "data"
+	tokenContextKey    contextKey = "token"
+)
 
 // RequireSession is middleware that validates the session_token cookie.
-// If valid, it attaches the username to the request context.
+// If valid, it records the request as the session's latest activity and
+// attaches the username and the session to the request context.
 func RequireSession(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Look for session_token cookie
-		cookie, err := r.Cookie("session_token")
+		cookie, err := r.Cookie(sessionstore.CookieName)
 		if err != nil || cookie.Value == "" {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		// Check session store for matching user
-		username, ok := sessionstore.GetSession(cookie.Value)
+		session, ok := sessionstore.GetSession(cookie.Value)
 		if !ok {
 			http.Error(w, "Session expired", http.StatusUnauthorized)
 			return
 		}
 
-		// Store username in the request context using a safe custom key
-		ctx := context.WithValue(r.Context(), userContextKey, username)
+		// Password accepted but the TOTP code isn't: not logged in yet
+		if session.SecondFactorPending {
+			http.Error(w, "Second factor required: POST your code to /login/2fa", http.StatusUnauthorized)
+			return
+		}
+
+		// Last seen / IP / browser for the "active sessions" page
+		sessionstore.Touch(session.ID, sessionstore.ClientOf(r))
+
+		// While an admin impersonates someone, handlers see that user; the
+		// admin stays available as the real user, and every request is
+		// audited under both names. If the admin lost the permission
+		// meanwhile, the impersonation is simply over.
+		effective := session.Username
+		if session.ActingAs != "" {
+			if u, ok := accounts.Lookup(session.Username); ok && u.Can(accounts.PermImpersonate) {
+				effective = session.ActingAs
+				audit.Record(audit.Event{Actor: session.Username, Action: "impersonation.request", Target: effective,
+					IP: sessionstore.ClientOf(r).IP, Detail: r.Method + " " + r.URL.Path})
+			} else {
+				session.ActingAs = ""
+			}
+		}
+
+		// Store username and session in the request context using safe custom keys
+		ctx := context.WithValue(r.Context(), userContextKey, effective)
+		ctx = context.WithValue(ctx, realUserContextKey, session.Username)
+		ctx = context.WithValue(ctx, sessionContextKey, session)
 
 		// Pass the updated request context to the next handler
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-// GetUserFromContext extracts the username string from request context.
+// RememberMe silently signs the user back in: when a request has no live
+// session but carries a remember-me cookie, it redeems the token, starts a
+// new session and sets both cookies. Wrap the whole router with it so it
+// runs before RequireSession.
+func RememberMe(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie(sessionstore.CookieName); err == nil {
+			if _, ok := sessionstore.GetSession(cookie.Value); ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		cookie, err := r.Cookie(remember.CookieName)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		username, value, expires, err := remember.Redeem(cookie.Value)
+		if err == nil {
+			if _, ok := accounts.Lookup(username); !ok {
+				remember.Revoke(cookie.Value) // same selector; value may be ""
+				err = remember.ErrInvalid
+			}
+		}
+		if err != nil {
+			if errors.Is(err, remember.ErrTheft) {
+				// Someone used this token before us: assume the account's
+				// sessions are compromised and end them all.
+				ended := "all"
+				if n := sessionstore.DeleteUserSessions(username, ""); n != sessionstore.AllSessions {
+					ended = strconv.Itoa(n)
+				}
+				log.Printf("⚠️ remember-me token reuse for %s: series revoked, %s sessions ended", username, ended)
+			}
+			remember.ClearCookie(w)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		sessionID := sessionstore.CreateSession(username, sessionstore.ClientOf(r))
+		session, _ := sessionstore.GetSession(sessionID)
+		sessionstore.SetCookie(w, sessionID)
+		if value != "" { // "" means a racing request already sent the rotated cookie
+			remember.Bind(value, session.Ref)
+			remember.SetCookie(w, value, expires)
+		}
+
+		next.ServeHTTP(w, withCookie(r, sessionstore.CookieName, sessionID))
+	})
+}
+
+// Autosave loads the typed data of the request's session (see
+// GetDataFromContext) and saves whatever the handler changed. In cookie
+// mode saving means a new Set-Cookie, so it happens just before the
+// handler's first write, or after it returns if it wrote nothing. Wrap
+// the router with it, inside RememberMe.
+func Autosave(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(sessionstore.CookieName)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		data, ok := sessionstore.Load(cookie.Value)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		sw := &saveWriter{ResponseWriter: w}
+		sw.save = func() {
+			token, err := data.Save()
+			if err != nil {
+				log.Printf("❌ Failed to save session: %v", err)
+			} else if token != "" && !setsSessionCookie(w) {
+				sessionstore.SetCookie(w, token)
+			}
+		}
+		next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), dataContextKey, data)))
+		sw.once.Do(sw.save)
+	})
+}
+
+// setsSessionCookie reports whether the handler already replaced the
+// session cookie (login, logout, 2FA); its cookie wins.
+func setsSessionCookie(w http.ResponseWriter) bool {
+	for _, c := range w.Header().Values("Set-Cookie") {
+		if strings.HasPrefix(c, sessionstore.CookieName+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// saveWriter runs save once, before the first byte of the response.
+type saveWriter struct {
+	http.ResponseWriter
+	save func()
+	once sync.Once
+}
+
+func (sw *saveWriter) WriteHeader(code int) {
+	sw.once.Do(sw.save)
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *saveWriter) Write(b []byte) (int, error) {
+	sw.once.Do(sw.save)
+	return sw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the real writer.
+func (sw *saveWriter) Unwrap() http.ResponseWriter { return sw.ResponseWriter }
+
+// withCookie returns a copy of r whose cookie name has value, so handlers
+// further down see the session we just created.
+func withCookie(r *http.Request, name, value string) *http.Request {
+	r2 := r.Clone(r.Context())
+	r2.Header.Del("Cookie")
+	for _, c := range r.Cookies() {
+		if c.Name != name {
+			r2.AddCookie(c)
+		}
+	}
+	r2.AddCookie(&http.Cookie{Name: name, Value: value})
+	return r2
+}
+
+// GetUserFromContext extracts the username string from request context:
+// the effective user, who is the impersonated one while an admin
+// impersonates.
 func GetUserFromContext(r *http.Request) (string, bool) {
 	username, ok := r.Context().Value(userContextKey).(string)
 	return username, ok
 }
 
+// GetRealUserFromContext returns who actually logged in: the admin while
+// they impersonate someone, otherwise the same as GetUserFromContext.
+func GetRealUserFromContext(r *http.Request) (string, bool) {
+	username, ok := r.Context().Value(realUserContextKey).(string)
+	return username, ok
+}
+
+// Impersonating reports whether the request comes from an admin acting
+// as another user.
+func Impersonating(r *http.Request) bool {
+	user, _ := GetUserFromContext(r)
+	real, _ := GetRealUserFromContext(r)
+	return user != real
+}
+
+// GetSessionFromContext returns the session RequireSession validated.
+func GetSessionFromContext(r *http.Request) (sessionstore.Session, bool) {
+	s, ok := r.Context().Value(sessionContextKey).(sessionstore.Session)
+	return s, ok
+}
+
+// GetDataFromContext returns the session data Autosave loaded, or nil if
+// the request has no session; sessionstore.Get treats nil as empty.
+func GetDataFromContext(r *http.Request) *sessionstore.Data {
+	d, _ := r.Context().Value(dataContextKey).(*sessionstore.Data)
+	return d
+}
+
+// RequireRole lets the request through only if the logged-in user has
+// role; use it inside RequireSession. Others get 403 Forbidden.
+func RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			username, _ := GetUserFromContext(r)
+			if u, ok := accounts.Lookup(username); !ok || !u.HasRole(role) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequirePermission lets the request through only if the logged-in user
+// (the effective one) has perm; use it inside RequireSession.
+func RequirePermission(perm string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			username, _ := GetUserFromContext(r)
+			if u, ok := accounts.Lookup(username); !ok || !u.Can(perm) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// NotImpersonating keeps impersonating admins out of routes that change a
+// user's credentials, like 2FA enrollment; use it inside RequireSession.
+func NotImpersonating(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if Impersonating(r) {
+			http.Error(w, "Forbidden while impersonating: return to your own account first", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireTwoFactor lets the request through only if the logged-in user
+// has 2FA turned on; use it inside RequireSession for sensitive routes.
+func RequireTwoFactor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, _ := GetUserFromContext(r)
+		if !mfa.Enabled(username) {
+			http.Error(w, "Forbidden: turn on two-factor authentication at /2fa first", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 /*
 🧠 SAFELY ENFORCING SESSION AUTH — CONTEXT KEY BEST PRACTICES
 
@@ -52,6 +307,12 @@ func GetUserFromContext(r *http.Request) (string, bool) {
 - We wrap protected routes with `RequireSession` middleware to ensure a valid session exists.
 - If a valid session is found, we store the username in the context using a **custom type**.
 - The new `GetUserFromContext()` helper makes it easy to retrieve the user later in handlers.
+- Each authenticated request updates the session's "last seen" time, IP and browser; `GetSessionFromContext()` exposes the whole session.
+- `RequireRole("admin")` stacks on top of `RequireSession` for admin-only routes, and `RequireTwoFactor` makes those routes demand 2FA.
+- While an admin impersonates a user, `GetUserFromContext()` is that user and `GetRealUserFromContext()` the admin; each such request is audited, and `NotImpersonating` guards credential changes.
+- A session still waiting for its TOTP code (`SecondFactorPending`) is rejected like no session at all.
+- `Autosave` hands handlers the session's typed data (`GetDataFromContext`) and saves it only if something changed — before the response starts, since in cookie mode that means a new cookie.
+- `RememberMe` wraps the whole router: a request without a live session but with a remember-me cookie gets a fresh session before `RequireSession` looks.
 
 ✅ Why This Matters:
 - Using a custom context key type prevents accidental overwrites or conflicts across packages.
@@ -71,7 +332,5 @@ func GetUserFromContext(r *http.Request) (string, bool) {
 - This approach scales to RBAC, request tracing, or tenant-aware routing with minimal change.
 
 📚 Up Next:
-- Add role-based access logic
-- Integrate a session timeout/refresh mechanism
 - Store sessions in Redis or PostgreSQL for persistence
 */
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/cookiecodec"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/sessionstore"
+)
+
+// useCookies keeps sessions in cookies, sealed with a fresh key, until t
+// ends.
+func useCookies(t *testing.T) {
+	t.Helper()
+	keys, err := cookiecodec.ParseKeys("test:" + cookiecodec.GenerateKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := cookiecodec.New(time.Hour, keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionstore.UseCookies(c)
+	t.Cleanup(func() { sessionstore.UseCookies(nil) })
+}
+
+func TestAutosave(t *testing.T) {
+	useCookies(t)
+	setCart := func(r *http.Request) { sessionstore.Set(GetDataFromContext(r), "cart", []string{"apple"}) }
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    bool // a new session cookie in the response
+	}{
+		{
+			name: "change, then write",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				setCart(r)
+				w.Write([]byte("hello"))
+			},
+			want: true,
+		},
+		{
+			name: "change, then status",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				setCart(r)
+				w.WriteHeader(http.StatusCreated)
+			},
+			want: true,
+		},
+		{
+			name:    "change, no body",
+			handler: func(w http.ResponseWriter, r *http.Request) { setCart(r) },
+			want:    true,
+		},
+		{
+			name: "read only",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				sessionstore.Get[[]string](GetDataFromContext(r), "cart")
+				w.Write([]byte("hello"))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := sessionstore.CreateSession("alice", sessionstore.Client{IP: "192.0.2.1"})
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.AddCookie(&http.Cookie{Name: sessionstore.CookieName, Value: token})
+			// The recorder keeps the headers as they were at the first
+			// write, so a cookie set after that doesn't show up.
+			w := httptest.NewRecorder()
+			Autosave(tt.handler).ServeHTTP(w, r)
+
+			var got *http.Cookie
+			for _, c := range w.Result().Cookies() {
+				if c.Name == sessionstore.CookieName {
+					got = c
+				}
+			}
+			if (got != nil) != tt.want {
+				t.Fatalf("session cookie = %v, want one: %v", got, tt.want)
+			}
+			if got == nil {
+				return
+			}
+			d, ok := sessionstore.Load(got.Value)
+			if cart, _ := sessionstore.Get[[]string](d, "cart"); !ok || len(cart) != 1 {
+				t.Errorf("the new cookie holds cart = %v (session %v)", cart, ok)
+			}
+		})
+	}
+}
+
+func TestAutosaveLeavesTheHandlersCookie(t *testing.T) {
+	useCookies(t)
+	token := sessionstore.CreateSession("alice", sessionstore.Client{IP: "192.0.2.1"})
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: sessionstore.CookieName, Value: token})
+	w := httptest.NewRecorder()
+	Autosave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sessionstore.Set(GetDataFromContext(r), "cart", []string{"apple"})
+		sessionstore.SetCookie(w, "from-the-handler") // e.g. logout
+	})).ServeHTTP(w, r)
+
+	if cookies := w.Header().Values("Set-Cookie"); len(cookies) != 1 {
+		t.Errorf("Set-Cookie = %q, want only the handler's", cookies)
+	}
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/accounts"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/apitoken"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/27-sessions-standard/internal/sessionstore"
+)
+
+// RequireSessionOrToken protects API routes that scripts may call too. A
+// request with "Authorization: Bearer <token>" is authenticated by its
+// personal access token, anything else by RequireSession. Either way the
+// user ends up in the context exactly as RequireSession puts it there, and
+// must have scope; a token must also have been granted it.
+func RequireSessionOrToken(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		withSession := RequireSession(RequirePermission(scope)(next))
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			raw, ok := bearerToken(r)
+			if !ok {
+				withSession.ServeHTTP(w, r)
+				return
+			}
+
+			t, err := apitoken.Authenticate(raw, sessionstore.ClientOf(r).IP)
+			u, found := accounts.Lookup(t.Username)
+			if err != nil || !found {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+				return
+			}
+			// The owner's roles may have changed since the token was made:
+			// it keeps only the scopes they still have.
+			if !t.HasScope(scope) || !u.Can(scope) {
+				w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+scope+`"`)
+				http.Error(w, "Forbidden: this token lacks the "+scope+" scope", http.StatusForbidden)
+				return
+			}
+
+			// A token has no session (so nothing is "this device") and can't
+			// impersonate: the real and the effective user are its owner.
+			ctx := context.WithValue(r.Context(), userContextKey, t.Username)
+			ctx = context.WithValue(ctx, realUserContextKey, t.Username)
+			ctx = context.WithValue(ctx, tokenContextKey, t)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// GetTokenFromContext returns the personal access token the request was
+// authenticated with; false for browser sessions.
+func GetTokenFromContext(r *http.Request) (apitoken.Token, bool) {
+	t, ok := r.Context().Value(tokenContextKey).(apitoken.Token)
+	return t, ok
+}
+
+/*
+🧠 ONE API, TWO WAYS IN (middleware/token.go)
+
+✅ What Happens Here:
+- `RequireSessionOrToken("sessions:read")` accepts either the browser's session cookie or `Authorization: Bearer pat_…`.
+- For a token, the user is resolved from the token and stored under the same context keys `RequireSession` uses, so `GetUserFromContext` and `GetRealUserFromContext` work unchanged in the handler.
+- The route's scope must be both granted to the token and still held by its owner; otherwise `403` with `WWW-Authenticate: Bearer error="insufficient_scope"`.
+
+✅ Why This Matters:
+- Handlers don't care how the caller logged in, so one handler serves the browser and scripts alike.
+- A token is never more powerful than its owner, even after the owner loses a role.
+- Routes that manage tokens or credentials keep plain `RequireSession`: a leaked token can't mint more tokens.
+
+✅ Key Concepts:
+| Concept                 | Explanation |
+|-------------------------|-------------|
+| Bearer token            | Whoever holds it is authenticated; send it over HTTPS only |
+| Scope                   | A permission name (`accounts.TokenScopes`) checked per route |
+| `GetTokenFromContext`   | Which token made the request, if any |
+*/
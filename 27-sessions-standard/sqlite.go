@@ -0,0 +1,8 @@
+//go:build sqlite
+
+package main
+
+// Built with -tags sqlite, the binary carries the pure-Go SQLite driver so
+// THROTTLE_DB can keep login counters in a file. Without the tag the app
+// uses only the standard library.
+import _ "modernc.org/sqlite"
@@ -9,14 +9,23 @@ import (
     // Internal packages
     "github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/28-deployment/internal/db"
     "github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/28-deployment/internal/router"
+    "github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/28-deployment/internal/tracing"
 )
 
 func main() {
-    // Load environment variables from .env (e.g., DB credentials)
+    // Load environment variables from .env (e.g., DB credentials).
+    // It is optional so `DB_DRIVER=sqlite go run ./cmd/api` works without one.
     if err := godotenv.Load(); err != nil {
-        log.Fatal("❌ Failed to load .env file") // Stop app if .env is missing
+        log.Println("⚠️  No .env file loaded, using the process environment")
     }
 
+    // Configure trace exporting (TRACE_FILE for JSON lines, TRACE_BUFFER for the in-memory buffer)
+    closeTraces, err := tracing.InitFromEnv()
+    if err != nil {
+        log.Fatalf("❌ Tracing setup failed: %v", err)
+    }
+    defer closeTraces()
+
     // Initialize the global database connection using environment config
     if err := db.InitDB(); err != nil {
         log.Fatalf("❌ Database connection failed: %v", err)
@@ -39,7 +48,9 @@ This is the entry point of your Go application. When the program starts, it foll
 
 Loads environment variables using the godotenv package. These values (like DBUSER, DBPASS, etc.) configure your database securely without hardcoding.
 
-Initializes the global database connection through a reusable helper InitDB() in the db package. This allows the rest of your app to use the same open DB connection.
+Configures tracing so every request, SQL statement and template render can be timed as a span.
+
+Initializes the global database connection through a reusable helper InitDB() in the db package. DB_DRIVER selects SQL Server (the default, as in Docker) or SQLite for local development. This allows the rest of your app to use the same open DB connection.
 
 Sets up routing using chi, connecting URL endpoints to handler functions for things like serving static files and user management.
 
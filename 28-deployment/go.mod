@@ -1,13 +1,30 @@
 module github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/28-deployment
 
-go 1.24
+go 1.24.0
 
 require (
 	github.com/denisenkom/go-mssqldb v0.12.3
 	github.com/joho/godotenv v1.5.1
 )
 
-require github.com/go-chi/chi/v5 v5.2.1
+require (
+	github.com/go-chi/chi/v5 v5.2.1
+	learn-go-with-cyber-mountain-man/26-databases/shared v0.0.0
+	modernc.org/sqlite v1.38.2
+)
+
+require (
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/ncruces/go-strftime v0.1.9 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
+	golang.org/x/exp v0.0.0-20250620022241-b7579e27df2b // indirect
+	golang.org/x/sys v0.34.0 // indirect
+	modernc.org/libc v1.66.3 // indirect
+	modernc.org/mathutil v1.7.1 // indirect
+	modernc.org/memory v1.11.0 // indirect
+)
 
 require (
 	// indirect dependencies
@@ -15,3 +32,6 @@ require (
 	github.com/golang-sql/sqlexp v0.1.0 // indirect
 	golang.org/x/crypto v0.37.0 // indirect
 )
+
+// The CORS middleware and sqlscan are shared with lesson 26
+replace learn-go-with-cyber-mountain-man/26-databases/shared => ../26-databases/shared
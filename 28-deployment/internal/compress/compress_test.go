@@ -0,0 +1,126 @@
+package compress
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := map[string]string{
+		"":                          "",
+		"gzip":                      "gzip",
+		"deflate":                   "deflate",
+		"gzip, deflate, br":         "gzip",
+		"deflate, gzip":             "gzip", // tie goes to gzip
+		"gzip;q=0.5, deflate":       "deflate",
+		"gzip;q=0, deflate;q=0":     "",
+		"GZIP":                      "gzip",
+		"*":                         "gzip",
+		"br, identity":              "",
+		"deflate;q=0.8, gzip;q=0.8": "gzip",
+	}
+	for header, want := range tests {
+		if got := negotiate(header); got != want {
+			t.Errorf("negotiate(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	big := strings.Repeat(`{"name":"alice"},`, 200) // well over MinSize
+	small := `{"name":"alice"}`
+
+	tests := []struct {
+		name           string
+		contentType    string
+		body           string
+		status         int
+		etag           string
+		method         string
+		acceptEncoding string
+		rangeHeader    string
+		wantEncoding   string
+		wantVary       bool
+		wantETag       string
+	}{
+		{name: "json, gzip", contentType: "application/json", body: big, acceptEncoding: "gzip", wantEncoding: "gzip", wantVary: true},
+		{name: "json, deflate", contentType: "application/json", body: big, acceptEncoding: "deflate", wantEncoding: "deflate", wantVary: true},
+		{name: "strong ETag is weakened", contentType: "text/html", body: big, etag: `"abc"`, acceptEncoding: "gzip", wantEncoding: "gzip", wantVary: true, wantETag: `W/"abc"`},
+		{name: "below MinSize", contentType: "application/json", body: small, acceptEncoding: "gzip", wantVary: true},
+		{name: "client can't decode", contentType: "application/json", body: big, wantVary: true},
+		{name: "already compressed type", contentType: "image/png", body: big, acceptEncoding: "gzip"},
+		{name: "type not on the allowlist", contentType: "application/octet-stream", body: big, acceptEncoding: "gzip"},
+		{name: "sniffed text", body: "<html>" + big, acceptEncoding: "gzip", wantEncoding: "gzip", wantVary: true},
+		{name: "HEAD", method: http.MethodHead, contentType: "text/html", body: big, acceptEncoding: "gzip", wantVary: true},
+		{name: "Range request", contentType: "text/html", body: big, acceptEncoding: "gzip", rangeHeader: "bytes=0-99"},
+		{name: "304", contentType: "text/html", status: http.StatusNotModified, acceptEncoding: "gzip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := Handler(Options{MinSize: 1024})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				if tt.etag != "" {
+					w.Header().Set("ETag", tt.etag)
+				}
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+				}
+				for rest := tt.body; rest != ""; { // many small writes
+					n := min(len(rest), 100)
+					io.WriteString(w, rest[:n])
+					rest = rest[n:]
+				}
+			}))
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			r := httptest.NewRequest(method, "/users", nil)
+			r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			if tt.rangeHeader != "" {
+				r.Header.Set("Range", tt.rangeHeader)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if got := w.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Fatalf("Content-Encoding = %q, want %q", got, tt.wantEncoding)
+			}
+			if got := w.Header().Get("Vary") == "Accept-Encoding"; got != tt.wantVary {
+				t.Errorf("Vary = %q, want Accept-Encoding: %v", w.Header().Get("Vary"), tt.wantVary)
+			}
+			if tt.wantETag != "" && w.Header().Get("ETag") != tt.wantETag {
+				t.Errorf("ETag = %q, want %q", w.Header().Get("ETag"), tt.wantETag)
+			}
+
+			var body io.Reader = w.Body
+			switch tt.wantEncoding {
+			case "gzip":
+				zr, err := gzip.NewReader(w.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body = zr
+			case "deflate":
+				body = flate.NewReader(w.Body)
+			}
+			got, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.body {
+				t.Errorf("decoded body has %d bytes, want %d", len(got), len(tt.body))
+			}
+			if tt.wantEncoding != "" && w.Body.Len() >= len(tt.body) {
+				t.Errorf("compressed %d bytes into %d", len(tt.body), w.Body.Len())
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"time"
+)
+
+// PoolConfig controls how InitDB connects and sizes the connection pool.
+type PoolConfig struct {
+	StartupTimeout time.Duration // keep retrying the first ping this long
+	InitialBackoff time.Duration // first wait between attempts
+	MaxBackoff     time.Duration // the wait doubles up to this
+	PingTimeout    time.Duration // how long a readiness probe waits for the ping
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// DefaultPoolConfig is used for every DB_* variable that is unset or invalid.
+var DefaultPoolConfig = PoolConfig{
+	StartupTimeout:  60 * time.Second,
+	InitialBackoff:  250 * time.Millisecond,
+	MaxBackoff:      5 * time.Second,
+	PingTimeout:     2 * time.Second,
+	MaxOpenConns:    10,
+	MaxIdleConns:    5,
+	ConnMaxLifetime: 30 * time.Minute,
+	ConnMaxIdleTime: 5 * time.Minute,
+}
+
+// PoolConfigFromEnv reads DB_STARTUP_TIMEOUT, DB_RETRY_BACKOFF,
+// DB_RETRY_MAX_BACKOFF, DB_PING_TIMEOUT, DB_MAX_OPEN_CONNS,
+// DB_MAX_IDLE_CONNS, DB_CONN_MAX_LIFETIME and DB_CONN_MAX_IDLE_TIME. Like
+// DB_QUERY_TIMEOUT, an invalid value is logged and replaced by the default.
+func PoolConfigFromEnv() PoolConfig {
+	c := DefaultPoolConfig
+	envDuration("DB_STARTUP_TIMEOUT", &c.StartupTimeout)
+	envDuration("DB_RETRY_BACKOFF", &c.InitialBackoff)
+	envDuration("DB_RETRY_MAX_BACKOFF", &c.MaxBackoff)
+	envDuration("DB_PING_TIMEOUT", &c.PingTimeout)
+	envDuration("DB_CONN_MAX_LIFETIME", &c.ConnMaxLifetime)
+	envDuration("DB_CONN_MAX_IDLE_TIME", &c.ConnMaxIdleTime)
+	envInt("DB_MAX_OPEN_CONNS", &c.MaxOpenConns)
+	envInt("DB_MAX_IDLE_CONNS", &c.MaxIdleConns)
+	return c
+}
+
+func envDuration(key string, dst *time.Duration) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+		*dst = d
+		return
+	}
+	log.Printf("Warning: invalid %s %q, using %s", key, v, *dst)
+}
+
+func envInt(key string, dst *int) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+		*dst = n
+		return
+	}
+	log.Printf("Warning: invalid %s %q, using %d", key, v, *dst)
+}
+
+// apply sets the pool limits on db.
+func (c PoolConfig) apply(db *sql.DB) {
+	db.SetMaxOpenConns(c.MaxOpenConns)
+	db.SetMaxIdleConns(c.MaxIdleConns)
+	db.SetConnMaxLifetime(c.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(c.ConnMaxIdleTime)
+}
+
+// retry calls op until it succeeds or ctx ends, sleeping a random part of
+// an exponentially growing backoff in between. It returns op's last error.
+func retry(ctx context.Context, c PoolConfig, op func(context.Context) error) error {
+	backoff := max(c.InitialBackoff, time.Millisecond)
+	for {
+		err := op(ctx)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(rand.N(backoff) + time.Millisecond):
+		}
+		if backoff *= 2; c.MaxBackoff > 0 && backoff > c.MaxBackoff {
+			backoff = c.MaxBackoff
+		}
+	}
+}
+
+// pingUntilReady pings db until it answers or c.StartupTimeout passes,
+// logging each failed attempt.
+func pingUntilReady(db *sql.DB, c PoolConfig) (attempts int, err error) {
+	ctx := context.Background()
+	if c.StartupTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.StartupTimeout)
+		defer cancel()
+	}
+	err = retry(ctx, c, func(ctx context.Context) error {
+		attempts++
+		err := db.PingContext(ctx)
+		if err != nil {
+			log.Printf("⏳ Database not ready (attempt %d): %v", attempts, err)
+		}
+		return err
+	})
+	return attempts, err
+}
+
+// pool is the configuration InitDB connected with.
+var pool PoolConfig
+
+// Ready reports whether the database answers now: one ping, given at most
+// PingTimeout (or less, if ctx ends sooner). The ping makes the pool
+// replace broken connections; the probe's next call is the retry.
+func Ready(ctx context.Context) error {
+	if DB == nil {
+		return sql.ErrConnDone
+	}
+	if pool.PingTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, pool.PingTimeout)
+		defer cancel()
+	}
+	return DB.DB.PingContext(ctx)
+}
+
+/*
+🧠 Blurb: Understanding connect.go
+In docker-compose the app can start before SQL Server accepts logins (the
+mssql-init service already needs a sleep for the same reason). InitDB used
+to give up after one failed ping; now it pings again after 250ms, 500ms,
+1s, ... up to 5s, each wait randomised ("jitter") so several replicas
+restarted together don't retry in lockstep, and stops after
+DB_STARTUP_TIMEOUT with an error that main can log.
+
+PoolConfigFromEnv also sizes the pool: SetMaxOpenConns keeps the app under
+the server's connection limit, and SetConnMaxLifetime / SetConnMaxIdleTime
+recycle connections so a failover or DNS change is picked up.
+
+Ready backs GET /ready. Unlike /health (is the process up?), it answers 503
+while the database is unreachable, so a load balancer or Kubernetes
+readiness probe stops routing traffic here until it comes back. It pings
+once, within DB_PING_TIMEOUT: the probe already runs every few seconds, so
+retrying inside it would only hold the request open.
+*/
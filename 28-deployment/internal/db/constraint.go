@@ -0,0 +1,170 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// ErrDuplicate is matched (errors.Is) by a unique constraint violation,
+// e.g. a second user with the same email.
+var ErrDuplicate = errors.New("duplicate value")
+
+// ErrConstraint is matched by not-null, foreign key and check violations.
+var ErrConstraint = errors.New("constraint violation")
+
+// ErrNotFound means no user has the requested ID. It wraps sql.ErrNoRows.
+var ErrNotFound = fmt.Errorf("user not found: %w", sql.ErrNoRows)
+
+// ConstraintKind says which kind of constraint a write violated.
+type ConstraintKind int
+
+const (
+	UniqueViolation ConstraintKind = iota + 1
+	NotNullViolation
+	ForeignKeyViolation
+	CheckViolation
+)
+
+// ConstraintError is a driver error caused by a constraint on the users
+// table, with the field it concerns ("email", "name" or "" if unknown).
+type ConstraintError struct {
+	Kind  ConstraintKind
+	Field string
+	Err   error
+}
+
+func (e *ConstraintError) Error() string { return e.Message() + ": " + e.Err.Error() }
+func (e *ConstraintError) Unwrap() error { return e.Err }
+
+// Is makes errors.Is(err, ErrDuplicate) and errors.Is(err, ErrConstraint) work.
+func (e *ConstraintError) Is(target error) bool {
+	if e.Kind == UniqueViolation {
+		return target == ErrDuplicate
+	}
+	return target == ErrConstraint
+}
+
+// Message explains the violation without driver details, for API clients.
+func (e *ConstraintError) Message() string {
+	field := e.Field
+	if field == "" {
+		field = "value"
+	}
+	switch e.Kind {
+	case UniqueViolation:
+		return "a user with this " + field + " already exists"
+	case NotNullViolation:
+		return field + " is required"
+	case ForeignKeyViolation:
+		return field + " refers to a record that does not exist"
+	}
+	return field + " is not valid"
+}
+
+// classify turns driver errors into ErrNotFound or a *ConstraintError and
+// returns everything else unchanged.
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	kind, name := violation(err)
+	if kind == 0 {
+		return err
+	}
+	return &ConstraintError{Kind: kind, Field: fieldOf(kind, name), Err: err}
+}
+
+// violation reads the constraint kind and the name the driver reports (a
+// column, "users.email", or a constraint name) from either driver's error.
+func violation(err error) (ConstraintKind, string) {
+	var se *sqlite.Error
+	if errors.As(err, &se) {
+		// "constraint failed: UNIQUE constraint failed: users.email (2067)"
+		msg := se.Error()
+		if i := strings.LastIndex(msg, "constraint failed: "); i >= 0 {
+			msg = msg[i+len("constraint failed: "):]
+		}
+		msg, _, _ = strings.Cut(msg, " (")
+		switch se.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			return UniqueViolation, msg
+		case sqlite3.SQLITE_CONSTRAINT_NOTNULL:
+			return NotNullViolation, msg
+		case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+			return ForeignKeyViolation, msg
+		case sqlite3.SQLITE_CONSTRAINT_CHECK:
+			return CheckViolation, msg
+		}
+		return 0, ""
+	}
+
+	var me mssql.Error
+	if errors.As(err, &me) {
+		// Only the quoted names are used: the rest of the message also
+		// contains the offending value, which could mention any column.
+		msg := me.Message
+		switch me.Number {
+		case 2627: // Violation of UNIQUE KEY constraint 'UQ__users__...'
+			return UniqueViolation, quoted(msg, "constraint '", "'")
+		case 2601: // Cannot insert duplicate key row ... with unique index 'IX_users_email'
+			return UniqueViolation, quoted(msg, "unique index '", "'")
+		case 515: // Cannot insert the value NULL into column 'name', ...
+			return NotNullViolation, quoted(msg, "column '", "'")
+		case 547: // ... conflicted with the CHECK constraint "users_email_check" ... column 'email'
+			name := quoted(msg, "column '", "'") + " " + quoted(msg, `constraint "`, `"`)
+			if strings.Contains(msg, "CHECK constraint") {
+				return CheckViolation, name
+			}
+			return ForeignKeyViolation, name
+		}
+	}
+	return 0, ""
+}
+
+// quoted returns the text between the first open and the next close.
+func quoted(msg, open, close string) string {
+	_, rest, ok := strings.Cut(msg, open)
+	if !ok {
+		return ""
+	}
+	inner, _, _ := strings.Cut(rest, close)
+	return inner
+}
+
+// fieldOf picks the users column a violation mentions. email is the only
+// unique column besides id, so an unnamed unique violation is about email
+// (SQL Server calls an inline UNIQUE constraint UQ__users__<hash>).
+func fieldOf(kind ConstraintKind, detail string) string {
+	detail = strings.ToLower(detail)
+	for _, c := range []string{"email", "name"} {
+		if strings.Contains(detail, c) {
+			return c
+		}
+	}
+	if kind == UniqueViolation {
+		return "email"
+	}
+	return ""
+}
+
+/*
+🧠 Blurb: Understanding constraint.go
+The users table already enforces its rules: email is UNIQUE, name and email
+are NOT NULL, and CHECK constraints reject an empty name or an email without
+an @. Without this file a broken rule surfaced as a 500 "Insert failed".
+
+classify recognises the violation from the driver's own error type (the
+SQLite result code, or the SQL Server error number 2627/2601, 515 or 547)
+and returns a *ConstraintError that says which field was at fault. Handlers
+use errors.Is(err, db.ErrDuplicate) / db.ErrConstraint / db.ErrNotFound to
+answer 409 Conflict, 422 Unprocessable Entity or 404 Not Found instead.
+*/
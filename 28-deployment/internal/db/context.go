@@ -0,0 +1,54 @@
+package db
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultQueryTimeout bounds the database work of one request when
+// DB_QUERY_TIMEOUT is not set.
+const DefaultQueryTimeout = 5 * time.Second
+
+var queryTimeout = sync.OnceValue(func() time.Duration {
+	if v := os.Getenv("DB_QUERY_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err == nil && d > 0 {
+			return d
+		}
+		log.Printf("Warning: invalid DB_QUERY_TIMEOUT %q, using %s", v, DefaultQueryTimeout)
+	}
+	return DefaultQueryTimeout
+})
+
+// QueryTimeout returns the configured per-request deadline for database
+// calls (DB_QUERY_TIMEOUT, e.g. "2s" or "750ms").
+func QueryTimeout() time.Duration {
+	return queryTimeout()
+}
+
+// WithTimeout derives a context that ends when parent is cancelled (for a
+// request: the client disconnected) or QueryTimeout elapses.
+func WithTimeout(parent context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(parent, QueryTimeout())
+}
+
+/*
+🧠 Blurb: Understanding db/context.go
+Every query function in db.go takes a context.Context as its first argument
+and runs through QueryContext / ExecContext. Handlers pass the request
+context wrapped by WithTimeout, which gives two ways for a query to stop
+early:
+
+The client goes away: net/http cancels r.Context(), the driver aborts the
+statement, and the connection goes back to the pool instead of serving a
+response nobody will read.
+
+The deadline passes: a slow query or a locked table fails after
+DB_QUERY_TIMEOUT with context.DeadlineExceeded instead of hanging.
+
+The handlers turn those two errors into 504 Gateway Timeout and 499 Client
+Closed Request, so logs and metrics show why a request failed.
+*/
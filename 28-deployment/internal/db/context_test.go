@@ -0,0 +1,40 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+// Cancelling a query that is really running is tested once, against every
+// engine, in 26-databases/shared/dbctx; here only the deadline wiring is.
+
+const testTimeout = 300 * time.Millisecond
+
+func TestMain(m *testing.M) {
+	os.Setenv("DB_QUERY_TIMEOUT", testTimeout.String()) // read once, on first use
+	os.Exit(m.Run())
+}
+
+func TestWithTimeout(t *testing.T) {
+	if got := QueryTimeout(); got != testTimeout {
+		t.Errorf("QueryTimeout() = %s, want %s from DB_QUERY_TIMEOUT", got, testTimeout)
+	}
+
+	start := time.Now()
+	ctx, cancel := WithTimeout(context.Background())
+	defer cancel()
+	if deadline, ok := ctx.Deadline(); !ok || deadline.Sub(start) < testTimeout || deadline.Sub(start) > testTimeout+time.Second {
+		t.Errorf("deadline %v after the call, want %s", deadline.Sub(start), testTimeout)
+	}
+
+	parent, disconnect := context.WithCancel(context.Background())
+	ctx, cancel = WithTimeout(parent)
+	defer cancel()
+	disconnect()
+	if err := ctx.Err(); !errors.Is(err, context.Canceled) {
+		t.Errorf("after the parent was cancelled: %v, want context.Canceled", err)
+	}
+}
@@ -1,92 +1,137 @@
 package db
 
 import (
+	"context"             // Cancellation and deadlines for every query
 	"database/sql"        // Go's standard SQL package for DB interaction
-	"fmt"                 // For string formatting (used in connection string)
-	"os"                  // For reading environment variables
+	"errors"              // ErrVersionConflict sentinel
+	"fmt"                 // For error wrapping
+	"log"                 // Startup message naming the chosen driver
 
-	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/28-deployment/internal/models" // Importing the User struct
-	_ "github.com/denisenkom/go-mssqldb" // MS SQL Server driver — the underscore means it’s imported for its side-effects (driver registration)
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/28-deployment/internal/models"  // Importing the User struct
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/28-deployment/internal/tracing" // Wraps *sql.DB so queries become trace spans
+
+	"learn-go-with-cyber-mountain-man/26-databases/shared/sqlscan" // Scans rows into structs by db tags (shared with lesson 26)
 )
 
-var DB *sql.DB // Global variable to hold the database connection pool
+var DB *tracing.DB // Global connection pool; *Context calls on it are traced
+
+// ErrVersionConflict means the row changed since the caller read it: the
+// version they passed is no longer the current one.
+var ErrVersionConflict = errors.New("user was modified by someone else")
 
-// InitDB initializes the database connection using env vars and sets the global DB variable.
+// InitDB opens the database selected by DB_DRIVER (mssql or sqlite; see
+// dialect.go), creates the schema if the dialect owns it, and sets the
+// global DB and Current variables. It retries the connection with backoff
+// until DB_STARTUP_TIMEOUT and sizes the pool from the DB_* variables (see
+// connect.go).
 func InitDB() error {
-	// Create connection string using env vars
-	connStr := fmt.Sprintf("sqlserver://%s:%s@%s:%s?database=%s&encrypt=disable",
-		os.Getenv("DBUSER"),     // Username for DB
-		os.Getenv("DBPASSWORD"), // Password
-		os.Getenv("DBHOST"),     // Hostname (e.g., "localhost" or "mssql" in Docker)
-		os.Getenv("DBPORT"),     // Port number (usually 1433 for MSSQL)
-		os.Getenv("DBNAME"),     // Database name
-	)
+	d, dsn, err := dialectFromEnv()
+	if err != nil {
+		return err
+	}
 
 	// Open a connection pool
-	db, err := sql.Open("sqlserver", connStr)
+	db, err := sql.Open(d.DriverName, dsn)
 	if err != nil {
 		return fmt.Errorf("failed to open db: %w", err)
 	}
+	cfg := PoolConfigFromEnv()
+	if dsn == sqliteDSN(":memory:") {
+		// Each new connection to :memory: would be a fresh, empty database
+		cfg.MaxOpenConns = 1
+		cfg.ConnMaxLifetime, cfg.ConnMaxIdleTime = 0, 0
+	}
+	cfg.apply(db)
 
-	// Ping to confirm connection is working
-	if err := db.Ping(); err != nil {
-		return fmt.Errorf("failed to connect: %w", err)
+	// Ping until the server accepts connections (it may still be starting)
+	if attempts, err := pingUntilReady(db, cfg); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to connect to %s after %d attempts: %w", d.Name, attempts, err)
 	}
 
-	// Assign to the global variable
-	DB = db
+	ctx, cancel := context.WithTimeout(context.Background(), QueryTimeout())
+	defer cancel()
+
+	// Create tables and seed data (a no-op for mssql, where init.sql does it)
+	if err := d.migrate(ctx, db); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to initialize %s schema: %w", d.Name, err)
+	}
+
+	// Assign to the global variables, wrapped so QueryContext/ExecContext record spans
+	DB = tracing.WrapDB(db)
+	Current = d
+	pool = cfg // for Ready
+	log.Printf("🗄️  Using %s database", d.Name)
 	return nil
 }
 
 // GetAllUsers retrieves all users from the 'users' table.
-func GetAllUsers() ([]models.User, error) {
-	rows, err := DB.Query(`SELECT id, name, email FROM users ORDER BY id`)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close() // Ensure the rows are closed after we're done
-
-	var users []models.User
-	for rows.Next() {
-		var u models.User
-		// Scan values into struct fields
-		if err := rows.Scan(&u.ID, &u.Name, &u.Email); err != nil {
-			return nil, err
-		}
-		users = append(users, u)
-	}
-	return users, nil
+func GetAllUsers(ctx context.Context) ([]models.User, error) {
+	// Columns are matched to the db:"..." tags on models.User; rows.Err is checked for us
+	return sqlscan.QueryAll[models.User](ctx, DB, `SELECT id, name, email, version FROM users ORDER BY id`)
 }
 
-// InsertUser adds a new user to the database using parameterized SQL.
-func InsertUser(name, email string) error {
-	_, err := DB.Exec(`INSERT INTO users (name, email) VALUES (@p1, @p2)`, name, email)
-	return err
+// InsertUser adds a new user to the database using parameterized SQL and
+// returns its generated ID. A taken email or a value the schema rejects
+// gives a *ConstraintError (see constraint.go).
+func InsertUser(ctx context.Context, name, email string) (int, error) {
+	id, err := Current.insertUser(ctx, name, email)
+	return id, classify(err)
 }
 
-// GetUserByID fetches a single user by ID.
-func GetUserByID(id int) (models.User, error) {
-	var u models.User
-	err := DB.QueryRow(`SELECT id, name, email FROM users WHERE id = @p1`, id).
-		Scan(&u.ID, &u.Name, &u.Email)
-	return u, err
+// GetUserByID fetches a single user by ID, or returns ErrNotFound.
+func GetUserByID(ctx context.Context, id int) (models.User, error) {
+	u, err := sqlscan.QueryOne[models.User](ctx, DB, Current.Rebind(`SELECT id, name, email, version FROM users WHERE id = ?`), id)
+	return u, classify(err)
 }
 
-// UpdateUser modifies an existing user's name and email based on ID.
-func UpdateUser(id int, name, email string) error {
-	_, err := DB.Exec(`UPDATE users SET name = @p1, email = @p2 WHERE id = @p3`, name, email, id)
-	return err
+// UpdateUser modifies an existing user's name and email based on ID, but
+// only if the row is still at version (0 skips the check). It returns the
+// new version. A stale version gives ErrVersionConflict, a missing user
+// ErrNotFound and a taken email a *ConstraintError.
+func UpdateUser(ctx context.Context, id, version int, name, email string) (int, error) {
+	q := `UPDATE users SET name = ?, email = ?, version = version + 1 WHERE id = ?`
+	args := []any{name, email, id}
+	if version != 0 {
+		q += ` AND version = ?`
+		args = append(args, version)
+	}
+	if err := execVersioned(ctx, id, q, args...); err != nil {
+		return 0, err
+	}
+	u, err := GetUserByID(ctx, id)
+	return u.Version, err
 }
 
-// DeleteUser removes a user by ID.
-func DeleteUser(id int) error {
-	_, err := DB.Exec(`DELETE FROM users WHERE id = @p1`, id)
-	return err
+// DeleteUser removes a user by ID if it is still at version (0 skips the
+// check), with the same errors as UpdateUser.
+func DeleteUser(ctx context.Context, id, version int) error {
+	q := `DELETE FROM users WHERE id = ?`
+	args := []any{id}
+	if version != 0 {
+		q += ` AND version = ?`
+		args = append(args, version)
+	}
+	return execVersioned(ctx, id, q, args...)
+}
+
+// execVersioned runs a write guarded by "AND version = ?". When no row
+// matched, it looks the user up to tell a stale version from a missing user.
+func execVersioned(ctx context.Context, id int, query string, args ...any) error {
+	n, err := sqlscan.Exec(ctx, DB, Current.Rebind(query), args...)
+	if err != nil || n > 0 {
+		return classify(err)
+	}
+	if _, err := GetUserByID(ctx, id); err != nil {
+		return err // ErrNotFound: the user is gone
+	}
+	return ErrVersionConflict
 }
 
 /*
 🧠 Blurb: Understanding db.go
-This Go file provides a full database access layer for managing users in a Microsoft SQL Server database. It defines a global DB connection pool initialized using environment variables, ensuring reusability across handlers.
+This Go file provides a full database access layer for managing users in Microsoft SQL Server or, for local development, SQLite (DB_DRIVER, see dialect.go). It defines a global DB connection pool initialized using environment variables, ensuring reusability across handlers.
 
 Each function corresponds to a common operation (CRUD):
 
@@ -100,5 +145,13 @@ UpdateUser: Edits a user.
 
 DeleteUser: Removes a user.
 
-It uses parameterized SQL (@p1, @p2) to prevent SQL injection and relies on the standard database/sql package for safe and efficient database interaction.
+Every row carries a version that UPDATE bumps. UpdateUser and DeleteUser take the version the caller last saw and add "AND version = ?" to the WHERE clause, so a change made by someone else in the meantime is reported as ErrVersionConflict instead of being silently overwritten (optimistic concurrency).
+
+Errors are classified on the way out (constraint.go): a missing row is ErrNotFound and a broken UNIQUE/NOT NULL/CHECK rule a *ConstraintError naming the field, so handlers can answer 404, 409 or 422 instead of 500.
+
+Rows are read with sqlscan (QueryAll / QueryOne / Exec) instead of hand-written rows.Scan loops, so a forgotten rows.Err() or a mismatched column list can't slip in.
+
+Every function takes a context.Context (see context.go), so queries stop when the request is cancelled or times out, and each call is recorded as a trace span.
+
+It uses parameterized SQL (written with ?, rebound to @p1, @p2 for SQL Server) to prevent SQL injection and relies on the standard database/sql package for safe and efficient database interaction.
 */
\ No newline at end of file
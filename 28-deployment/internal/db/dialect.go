@@ -0,0 +1,156 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	_ "github.com/denisenkom/go-mssqldb" // MS SQL Server driver ("sqlserver")
+	_ "modernc.org/sqlite"               // Pure-Go SQLite driver ("sqlite"); works with CGO_ENABLED=0
+)
+
+// Dialect captures what differs between the engines this app can run on.
+// Queries in db.go are written with `?` placeholders and rebound per dialect.
+type Dialect struct {
+	Name       string // value of DB_DRIVER
+	DriverName string // database/sql driver
+
+	// placeholder returns the bind parameter for the n-th argument (1-based).
+	placeholder func(n int) string
+
+	// insertUser inserts (name, email) and returns the generated id.
+	insertUser func(ctx context.Context, name, email string) (int, error)
+
+	// migrate creates the schema and seed data when they are missing.
+	migrate func(ctx context.Context, db *sql.DB) error
+}
+
+// Rebind rewrites `?` placeholders into the dialect's syntax.
+func (d *Dialect) Rebind(query string) string {
+	if d.placeholder == nil {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString(d.placeholder(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Current is the dialect chosen by InitDB.
+var Current *Dialect
+
+var mssqlDialect = &Dialect{
+	Name:        "mssql",
+	DriverName:  "sqlserver",
+	placeholder: func(n int) string { return "@p" + strconv.Itoa(n) },
+	insertUser: func(ctx context.Context, name, email string) (int, error) {
+		var id int
+		err := DB.QueryRowContext(ctx,
+			`INSERT INTO users (name, email) OUTPUT INSERTED.id VALUES (@p1, @p2)`, name, email).Scan(&id)
+		return id, err
+	},
+	// The schema and seed data come from mssql-init/init.sql (run by docker-compose).
+	migrate: func(context.Context, *sql.DB) error { return nil },
+}
+
+//go:embed sqlite_init.sql
+var sqliteInit string
+
+var sqliteDialect = &Dialect{
+	Name:       "sqlite",
+	DriverName: "sqlite",
+	insertUser: func(ctx context.Context, name, email string) (int, error) {
+		res, err := DB.ExecContext(ctx, `INSERT INTO users (name, email) VALUES (?, ?)`, name, email)
+		if err != nil {
+			return 0, err
+		}
+		id, err := res.LastInsertId()
+		return int(id), err
+	},
+	migrate: func(ctx context.Context, db *sql.DB) error {
+		if _, err := db.ExecContext(ctx, sqliteInit); err != nil {
+			return err
+		}
+		// Database files created before the version column existed
+		var n int
+		err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM pragma_table_info('users') WHERE name = 'version'`).Scan(&n)
+		if err != nil || n > 0 {
+			return err
+		}
+		_, err = db.ExecContext(ctx, `ALTER TABLE users ADD COLUMN version INTEGER NOT NULL DEFAULT 1`)
+		return err
+	},
+}
+
+// dialectFromEnv picks the dialect from DB_DRIVER (default "mssql") and
+// builds its data source from the matching environment variables.
+func dialectFromEnv() (*Dialect, string, error) {
+	switch driver := strings.ToLower(os.Getenv("DB_DRIVER")); driver {
+	case "", "mssql", "sqlserver":
+		u := url.URL{
+			Scheme:   "sqlserver",
+			User:     url.UserPassword(os.Getenv("DBUSER"), os.Getenv("DBPASSWORD")),
+			Host:     os.Getenv("DBHOST") + ":" + os.Getenv("DBPORT"),
+			RawQuery: url.Values{"database": {os.Getenv("DBNAME")}, "encrypt": {envOr("DBENCRYPT", "disable")}}.Encode(),
+		}
+		return mssqlDialect, u.String(), nil
+
+	case "sqlite", "sqlite3":
+		return sqliteDialect, sqliteDSN(envOr("SQLITE_PATH", "lesson28.db")), nil
+
+	default:
+		return nil, "", fmt.Errorf("unknown DB_DRIVER %q (want mssql or sqlite)", driver)
+	}
+}
+
+// sqliteDSN adds the pragmas the app relies on. ":memory:" stays in-memory;
+// InitDB limits the pool to one connection so every query sees the same
+// database.
+func sqliteDSN(path string) string {
+	pragmas := "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	if path == ":memory:" {
+		return "file::memory:?" + pragmas
+	}
+	if strings.Contains(path, "?") {
+		return path + "&" + pragmas
+	}
+	return "file:" + path + "?" + pragmas
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+/*
+🧠 Blurb: Understanding dialect.go
+The app was written for SQL Server, but new contributors shouldn't need
+Docker and a 2 GB database image just to click through the UI. DB_DRIVER
+picks one of two dialects:
+
+mssql (default): the original setup. The connection string is built from
+DBUSER/DBPASSWORD/DBHOST/DBPORT/DBNAME; placeholders are @p1, @p2; new IDs
+come back through OUTPUT INSERTED.id; init.sql creates the schema.
+
+sqlite: a file (SQLITE_PATH, default lesson28.db) or ":memory:". The
+driver is modernc.org/sqlite, written in pure Go, so the distroless
+CGO_ENABLED=0 build keeps working. sqlite_init.sql reproduces init.sql's
+table and Admin seed row on startup; older database files get the version
+column added.
+
+Queries elsewhere are written once with `?` and passed through Rebind.
+*/
@@ -0,0 +1,21 @@
+-- sqlite_init.sql
+-- SQLite version of mssql-init/init.sql, run by InitDB when DB_DRIVER=sqlite.
+-- The database file itself is created by the driver on first open, so only
+-- the table and the seed row are needed here. Every statement is idempotent.
+
+-- Create the 'users' table if it doesn't already exist
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,  -- Auto-incrementing ID (IDENTITY(1,1) in MSSQL)
+    name TEXT NOT NULL,                    -- User's name
+    email TEXT NOT NULL UNIQUE,            -- User's email (must be unique)
+    version INTEGER NOT NULL DEFAULT 1,    -- Bumped on every update (optimistic concurrency)
+    CONSTRAINT users_name_check CHECK (name <> ''),            -- Name can't be blank
+    CONSTRAINT users_email_check CHECK (email LIKE '%_@_%')    -- Email needs an @
+);
+-- SQLite can't add a CHECK to an existing table, so older database files
+-- keep working without these two rules.
+
+-- Seed with an initial admin user if the table is empty
+INSERT INTO users (name, email)
+SELECT 'Admin', 'admin@example.com'
+WHERE NOT EXISTS (SELECT 1 FROM users);
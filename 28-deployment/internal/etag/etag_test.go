@@ -0,0 +1,148 @@
+package etag
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	body := "<li>alice</li>"
+	tag := Compute([]byte(body))
+	page := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(body))
+	})
+	versioned := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", Version(3))
+		w.Write([]byte(`{"version":3}`))
+	})
+	missing := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no such user", http.StatusNotFound)
+	})
+
+	tests := []struct {
+		name        string
+		handler     http.Handler
+		method      string
+		ifNoneMatch string
+		wantStatus  int
+		wantETag    string
+		wantBody    string
+	}{
+		{"first request", page, http.MethodGet, "", http.StatusOK, tag, body},
+		{"matching", page, http.MethodGet, tag, http.StatusNotModified, tag, ""},
+		{"weakened by gzip", page, http.MethodGet, "W/" + tag, http.StatusNotModified, tag, ""},
+		{"one of a list", page, http.MethodGet, `"stale", ` + tag, http.StatusNotModified, tag, ""},
+		{"any", page, http.MethodGet, "*", http.StatusNotModified, tag, ""},
+		{"stale", page, http.MethodGet, `"stale"`, http.StatusOK, tag, body},
+		{"HEAD", page, http.MethodHead, "", http.StatusOK, tag, ""},
+		{"handler's own ETag", versioned, http.MethodGet, `W/"v3"`, http.StatusNotModified, `"v3"`, ""},
+		{"handler's own ETag, changed", versioned, http.MethodGet, `"v2"`, http.StatusOK, `"v3"`, `{"version":3}`},
+		{"not a 200", missing, http.MethodGet, "*", http.StatusNotFound, "", "no such user\n"},
+		{"not a read", page, http.MethodPost, tag, http.StatusOK, "", body},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/users", nil)
+			if tt.ifNoneMatch != "" {
+				r.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			w := httptest.NewRecorder()
+			Handler(tt.handler).ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus || w.Header().Get("ETag") != tt.wantETag || w.Body.String() != tt.wantBody {
+				t.Fatalf("got %d, ETag %q, body %q; want %d, %q, %q",
+					w.Code, w.Header().Get("ETag"), w.Body, tt.wantStatus, tt.wantETag, tt.wantBody)
+			}
+			if tt.wantStatus == http.StatusNotModified && (w.Header().Get("Content-Type") != "" || w.Header().Get("Content-Length") != "") {
+				t.Errorf("304 with body headers: %v", w.Header())
+			}
+			if tt.wantStatus == http.StatusOK && tt.wantETag != "" && w.Header().Get("Cache-Control") != "no-cache" {
+				t.Errorf("Cache-Control = %q, want no-cache", w.Header().Get("Cache-Control"))
+			}
+		})
+	}
+}
+
+func TestHandlerPassesLargeBodiesThrough(t *testing.T) {
+	chunk := strings.Repeat("x", 64<<10)
+	n := maxBuffer/len(chunk) + 1
+	h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		for range n {
+			w.Write([]byte(chunk))
+		}
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/export", nil))
+
+	if w.Code != http.StatusCreated || w.Body.Len() != n*len(chunk) {
+		t.Errorf("got %d with %d bytes, want %d with %d", w.Code, w.Body.Len(), http.StatusCreated, n*len(chunk))
+	}
+	if tag := w.Header().Get("ETag"); tag != "" {
+		t.Errorf("ETag %q on a body over maxBuffer", tag)
+	}
+}
+
+func TestHandlerFlushEndsBuffering(t *testing.T) {
+	w := httptest.NewRecorder()
+	h := Handler(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte("event: 1\n\n"))
+		if err := http.NewResponseController(rw).Flush(); err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+		if !w.Flushed || w.Body.String() != "event: 1\n\n" {
+			t.Errorf("after Flush the client has %q (flushed %v)", w.Body, w.Flushed)
+		}
+		rw.Write([]byte("event: 2\n\n"))
+		if w.Body.String() != "event: 1\n\nevent: 2\n\n" {
+			t.Errorf("write after Flush was buffered: client has %q", w.Body)
+		}
+	}))
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/events", nil))
+	if tag := w.Header().Get("ETag"); tag != "" {
+		t.Errorf("ETag %q on a flushed response", tag)
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		header      string
+		wantVersion int
+		wantStatus  int // 0: ok, nothing written
+	}{
+		{"", 0, http.StatusPreconditionRequired},
+		{"*", 0, 0},
+		{`"v3"`, 3, 0},
+		{`W/"v3"`, 3, 0},
+		{` "v3" , W/"v3"`, 3, 0},
+		{`"abc", "v5"`, 5, 0},
+		{`"v3", "v4"`, 0, http.StatusPreconditionFailed}, // two versions can't both be current
+		{`"v3", "v4", "v3"`, 0, http.StatusPreconditionFailed},
+		{`"abc"`, 0, http.StatusPreconditionFailed},
+		{`"v0"`, 0, http.StatusPreconditionFailed},
+		{`"v-1"`, 0, http.StatusPreconditionFailed},
+		{`v3`, 0, http.StatusPreconditionFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/users/1", nil)
+			if tt.header != "" {
+				r.Header.Set("If-Match", tt.header)
+			}
+			w := httptest.NewRecorder()
+			version, ok := IfMatch(w, r)
+			if ok != (tt.wantStatus == 0) || version != tt.wantVersion {
+				t.Errorf("IfMatch = %d, %v; want %d, %v", version, ok, tt.wantVersion, tt.wantStatus == 0)
+			}
+			if tt.wantStatus != 0 && w.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == 0 && w.Body.Len() != 0 {
+				t.Errorf("wrote %q on success", w.Body)
+			}
+		})
+	}
+}
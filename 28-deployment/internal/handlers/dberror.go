@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"html"
+	"log"
+	"net/http"
+
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/28-deployment/internal/db"
+)
+
+// StatusClientClosedRequest is nginx's non-standard 499: the client hung up
+// before the response was ready.
+const StatusClientClosedRequest = 499
+
+// dbError answers a failed database call. A passed deadline becomes 504 and
+// a disconnected client 499, a missing user 404 and a broken constraint 409
+// (duplicate) or 422 (anything else); any other error is a 500 with msg.
+func dbError(w http.ResponseWriter, r *http.Request, ctx context.Context, err error, msg string) {
+	var ce *db.ConstraintError
+	switch {
+	case errors.As(err, &ce):
+		status := http.StatusUnprocessableEntity
+		if errors.Is(ce, db.ErrDuplicate) {
+			status = http.StatusConflict
+		}
+		constraintError(w, r, status, ce)
+	case errors.Is(err, db.ErrNotFound):
+		http.Error(w, "User not found", http.StatusNotFound)
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded):
+		log.Println("Database call timed out:", err)
+		http.Error(w, "Database query timed out", http.StatusGatewayTimeout)
+	case errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled):
+		// Nobody is reading the body any more; the status is for logs and metrics.
+		w.WriteHeader(StatusClientClosedRequest)
+	default:
+		log.Println(msg+":", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+	}
+}
+
+// constraintError tells the client which field was rejected. HTMX requests
+// get a message swapped into #form-error (index.html); API clients get
+// {"error": ..., "field": ...}.
+func constraintError(w http.ResponseWriter, r *http.Request, status int, ce *db.ConstraintError) {
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Retarget", "#form-error")
+		w.Header().Set("HX-Reswap", "innerHTML")
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+		w.Write([]byte(`<p class="error" data-field="` + html.EscapeString(ce.Field) + `">` + html.EscapeString(ce.Message()) + `</p>`))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+		Field string `json:"field,omitempty"`
+	}{ce.Message(), ce.Field})
+}
+
+/*
+🧠 Blurb: Understanding dberror.go
+Database functions return context errors when the request context ends
+before the query does. Treating those like any other failure would hide
+what happened, so dbError separates three cases:
+
+context.DeadlineExceeded → 504 Gateway Timeout (the query took longer than
+DB_QUERY_TIMEOUT).
+
+context.Canceled → 499 Client Closed Request (the browser navigated away or
+HTMX aborted the request). http_requests_total shows these under
+status="499".
+
+db.ErrNotFound → 404 Not Found.
+
+A *db.ConstraintError → 409 Conflict for a duplicate email, 422
+Unprocessable Entity for a blank name or malformed email. The response
+names the field: JSON for API calls, a small HTML message (retargeted into
+#form-error) for HTMX forms.
+
+Anything else → 500 Internal Server Error with the handler's message.
+*/
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/28-deployment/internal/db"
+)
+
+// driverError stands in for a driver's own error type, which may or may
+// not wrap the context error that stopped the query.
+type driverError struct{ err error }
+
+func (e driverError) Error() string {
+	if e.err == nil {
+		return "driver: operation cancelled"
+	}
+	return "driver: " + e.err.Error()
+}
+func (e driverError) Unwrap() error { return e.err }
+
+func TestDBError(t *testing.T) {
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	hungUp, disconnect := context.WithCancel(context.Background())
+	disconnect()
+	live := context.Background()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want int
+	}{
+		{"deadline, wrapped", live, driverError{context.DeadlineExceeded}, http.StatusGatewayTimeout},
+		{"deadline, twice wrapped", live, fmt.Errorf("list users: %w", driverError{context.DeadlineExceeded}), http.StatusGatewayTimeout},
+		{"deadline, driver error only", expired, driverError{}, http.StatusGatewayTimeout},
+		{"client gone, wrapped", live, driverError{context.Canceled}, StatusClientClosedRequest},
+		{"client gone, driver error only", hungUp, driverError{}, StatusClientClosedRequest},
+		{"not found", live, fmt.Errorf("get user 7: %w", db.ErrNotFound), http.StatusNotFound},
+		{"duplicate", live, &db.ConstraintError{Kind: db.UniqueViolation, Field: "email", Err: fmt.Errorf("UNIQUE constraint failed: users.email")}, http.StatusConflict},
+		{"anything else", live, driverError{fmt.Errorf("connection reset")}, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			dbError(rec, httptest.NewRequest(http.MethodGet, "/users/7", nil), tt.ctx, tt.err, "Database query failed")
+			if rec.Code != tt.want {
+				t.Errorf("%v: status = %d, want %d", tt.err, rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -1,20 +1,23 @@
 package handlers
 
 import (
-	"database/sql"                         // SQL database interactions
+	"context"                              // Deadline passed to the 412 helper
 	"encoding/json"                        // JSON encoding/decoding
+	"errors"                               // errors.Is for db.ErrVersionConflict
 	"fmt"                                  // String formatting for HTML output
+	"html"                                 // Escape user data inside the HTML list
 	"net/http"                             // Core HTTP functionality
 	"strconv"                              // Convert path variables (ID) to integers
 
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/28-deployment/internal/db"     // WithTimeout for per-request query deadlines
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/28-deployment/internal/etag"   // Row-version ETag and If-Match
 	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/28-deployment/internal/models"
 	"github.com/go-chi/chi/v5"            // Router library for path parameters
 )
 
-// UserHandler groups database access so it can be injected and reused
-type UserHandler struct {
-	DB *sql.DB
-}
+// UserHandler groups the JSON/HTMX hybrid handlers. Queries go through the
+// db package, so they work on every DB_DRIVER.
+type UserHandler struct{}
 
 // GetAllUsers serves both:
 // - JSON (for API calls)
@@ -26,23 +29,15 @@ func (h *UserHandler) GetAllUsers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ctx, cancel := db.WithTimeout(r.Context())
+	defer cancel()
+
 	// JSON fallback: used in pure REST scenarios
-	rows, err := h.DB.Query("SELECT id, name, email FROM users")
+	users, err := db.GetAllUsers(ctx)
 	if err != nil {
-		http.Error(w, "Database query failed", http.StatusInternalServerError)
+		dbError(w, r, ctx, err, "Database query failed")
 		return
 	}
-	defer rows.Close()
-
-	var users []models.User
-	for rows.Next() {
-		var u models.User
-		if err := rows.Scan(&u.ID, &u.Name, &u.Email); err != nil {
-			http.Error(w, "Failed to scan row", http.StatusInternalServerError)
-			return
-		}
-		users = append(users, u)
-	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(users)
 }
@@ -56,13 +51,21 @@ func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var u models.User
-	err = h.DB.QueryRow("SELECT id, name, email FROM users WHERE id = @p1", id).
-		Scan(&u.ID, &u.Name, &u.Email)
-	if err != nil {
+	ctx, cancel := db.WithTimeout(r.Context())
+	defer cancel()
+
+	u, err := db.GetUserByID(ctx, id)
+	if errors.Is(err, db.ErrNotFound) {
 		http.Error(w, "User not found", http.StatusNotFound)
 		return
 	}
+	if err != nil {
+		dbError(w, r, ctx, err, "Failed to fetch user")
+		return
+	}
+	// The version is the ETag; send it back in If-Match to update or delete
+	w.Header().Set("ETag", etag.Version(u.Version))
+	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(u)
 }
 
@@ -85,13 +88,13 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ctx, cancel := db.WithTimeout(r.Context())
+	defer cancel()
+
 	// Insert user and return new ID
-	err = h.DB.QueryRow(
-		"INSERT INTO users (name, email) OUTPUT INSERTED.id VALUES (@p1, @p2)",
-		u.Name, u.Email,
-	).Scan(&u.ID)
+	u.ID, err = db.InsertUser(ctx, u.Name, u.Email)
 	if err != nil {
-		http.Error(w, "Insert failed", http.StatusInternalServerError)
+		dbError(w, r, ctx, err, "Insert failed")
 		return
 	}
 
@@ -101,7 +104,11 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 
 // UpdateUser allows users to be updated via JSON
 func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
 
 	var u models.User
 	if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
@@ -114,19 +121,47 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err := h.DB.Exec(
-		"UPDATE users SET name = @p1, email = @p2 WHERE id = @p3",
-		u.Name, u.Email, id,
-	)
+	version, ok := etag.IfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := db.WithTimeout(r.Context())
+	defer cancel()
+
+	newVersion, err := db.UpdateUser(ctx, id, version, u.Name, u.Email)
+	if errors.Is(err, db.ErrVersionConflict) {
+		h.preconditionFailed(w, ctx, id)
+		return
+	}
+	if errors.Is(err, db.ErrNotFound) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		http.Error(w, "Update failed", http.StatusInternalServerError)
+		dbError(w, r, ctx, err, "Update failed")
 		return
 	}
 
-	// No HTML returned; used in API or async HTMX context
+	// No body returned; the new ETag lets the client chain another update
+	w.Header().Set("ETag", etag.Version(newVersion))
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// preconditionFailed answers a stale If-Match with 412, the user as it is
+// stored now and its ETag, so an API client can merge and retry.
+func (h *UserHandler) preconditionFailed(w http.ResponseWriter, ctx context.Context, id int) {
+	current, err := db.GetUserByID(ctx, id)
+	if err != nil {
+		http.Error(w, "User changed since it was read", http.StatusPreconditionFailed)
+		return
+	}
+	w.Header().Set("ETag", etag.Version(current.Version))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusPreconditionFailed)
+	json.NewEncoder(w).Encode(current)
+}
+
 // DeleteUser removes the user and refreshes the list for HTMX
 func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	idParam := chi.URLParam(r, "id")
@@ -136,9 +171,21 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = h.DB.Exec("DELETE FROM users WHERE id = @p1", id)
-	if err != nil {
-		http.Error(w, "Delete failed", http.StatusInternalServerError)
+	version, ok := etag.IfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := db.WithTimeout(r.Context())
+	defer cancel()
+
+	err = db.DeleteUser(ctx, id, version)
+	if errors.Is(err, db.ErrVersionConflict) {
+		h.preconditionFailed(w, ctx, id)
+		return
+	}
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		dbError(w, r, ctx, err, "Delete failed")
 		return
 	}
 
@@ -148,24 +195,23 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 
 // RenderUserList creates an HTML unordered list of users (for HTMX swap)
 func (h *UserHandler) RenderUserList(w http.ResponseWriter, r *http.Request) {
-	rows, err := h.DB.Query("SELECT id, name, email FROM users")
+	ctx, cancel := db.WithTimeout(r.Context())
+	defer cancel()
+
+	users, err := db.GetAllUsers(ctx)
 	if err != nil {
-		http.Error(w, "Failed to load users", http.StatusInternalServerError)
+		dbError(w, r, ctx, err, "Failed to load users")
 		return
 	}
-	defer rows.Close()
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	fmt.Fprintln(w, `<ul id="user-list">`)
-	for rows.Next() {
-		var u models.User
-		if err := rows.Scan(&u.ID, &u.Name, &u.Email); err == nil {
-			fmt.Fprintf(
-				w,
-				`<li>%s (%s) <button class="delete-btn" hx-delete="/users/%d" hx-target="#user-list" hx-swap="outerHTML">Delete</button></li>`,
-				u.Name, u.Email, u.ID,
-			)
-		}
+	for _, u := range users {
+		fmt.Fprintf(
+			w,
+			`<li>%s (%s) <button class="delete-btn" hx-delete="/users/%d" hx-headers='{"If-Match": "\"v%d\""}' hx-target="#user-list" hx-swap="outerHTML">Delete</button></li>`,
+			html.EscapeString(u.Name), html.EscapeString(u.Email), u.ID, u.Version,
+		)
 	}
 	fmt.Fprintln(w, `</ul>`)
 }
@@ -186,6 +232,8 @@ Dynamically updated frontend via HTMX (hx-get, hx-post, hx-delete, etc.).
 
 Automatic user list refreshing after changes (Create/Delete).
 
+Optimistic concurrency: GetUser sends the row version as ETag, and UpdateUser/DeleteUser require it in If-Match (428 without it, 412 plus the current user when it is stale).
+
 Graceful fallback to JSON output when HTMX is not used.
 
 You can use this in parallel with a full HTMX interface and REST API consumers (e.g., Postman, frontend apps), making it flexible and powerful.
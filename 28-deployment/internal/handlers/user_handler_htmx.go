@@ -1,26 +1,49 @@
 package handlers
 
 import (
+	"context"                               // Request-scoped deadline passed to the conflict renderer
+	"errors"                                // errors.Is for db.ErrVersionConflict
 	"net/http"                              // Standard HTTP utilities
 	"strconv"                               // For string-to-int conversion (e.g., ID parsing)
 	"html/template"                         // HTML templating for rendering fragments
 	"log"                                   // Logging for debug and error output
+	"sync"                                  // sync.OnceValue parses the templates once
 	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/28-deployment/internal/db"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/28-deployment/internal/etag"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/28-deployment/internal/models"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/28-deployment/internal/tracing"
 	"github.com/go-chi/chi/v5"             // Router for extracting path parameters like /users/{id}
 )
 
-// Precompile all HTML templates in the static/templates directory
-var templates = template.Must(template.ParseGlob("static/templates/*.html"))
+// Compile all HTML templates in the static/templates directory on first use
+// (the path is relative to the module root, where the server runs; tests
+// run from the package directory and don't render pages).
+// The tracing wrapper records each execution as a span of the current request.
+var templates = sync.OnceValue(func() *tracing.Template {
+	return tracing.WrapTemplate(template.Must(template.ParseGlob("static/templates/*.html")))
+})
 
 // ListUsersHTMX renders all users using the user-list.html fragment.
 // This is triggered via HTMX GET and used to refresh the full user list.
 func ListUsersHTMX(w http.ResponseWriter, r *http.Request) {
-	users, err := db.GetAllUsers()
+	renderUserList(w, r, http.StatusOK)
+}
+
+// renderUserList sends the user-list.html fragment with the given status
+// (412 when a delete lost a race and the list is shown as it is now).
+func renderUserList(w http.ResponseWriter, r *http.Request, status int) {
+	ctx, cancel := db.WithTimeout(r.Context())
+	defer cancel()
+
+	users, err := db.GetAllUsers(ctx)
 	if err != nil {
-		http.Error(w, "Failed to fetch users", http.StatusInternalServerError)
+		dbError(w, r, ctx, err, "Failed to fetch users")
 		return
 	}
-	templates.ExecuteTemplate(w, "user-list.html", users)
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+	}
+	templates().ExecuteTemplate(r.Context(), w, "user-list.html", users)
 }
 
 // CreateUserHTMX handles the HTMX form submission for adding a user.
@@ -33,8 +56,11 @@ func CreateUserHTMX(w http.ResponseWriter, r *http.Request) {
 	name := r.FormValue("name")
 	email := r.FormValue("email")
 
-	if err := db.InsertUser(name, email); err != nil {
-		http.Error(w, "Failed to create user", http.StatusInternalServerError)
+	ctx, cancel := db.WithTimeout(r.Context())
+	defer cancel()
+
+	if _, err := db.InsertUser(ctx, name, email); err != nil {
+		dbError(w, r, ctx, err, "Failed to create user")
 		return
 	}
 
@@ -52,14 +78,24 @@ func EditUserFormHTMX(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := db.GetUserByID(id)
-	if err != nil {
+	ctx, cancel := db.WithTimeout(r.Context())
+	defer cancel()
+
+	user, err := db.GetUserByID(ctx, id)
+	if errors.Is(err, db.ErrNotFound) {
 		http.Error(w, "User not found", http.StatusNotFound)
 		return
 	}
+	if err != nil {
+		dbError(w, r, ctx, err, "Failed to fetch user")
+		return
+	}
+
+	// The form sends this version back in If-Match when it is saved
+	w.Header().Set("ETag", etag.Version(user.Version))
 
 	// Inject the edit form fragment into the page
-	err = templates.ExecuteTemplate(w, "user-edit.html", user)
+	err = templates().ExecuteTemplate(r.Context(), w, "user-edit.html", user)
 	if err != nil {
 		log.Println("Template execution error:", err)
 		http.Error(w, "Template execution failed: "+err.Error(), http.StatusInternalServerError)
@@ -83,8 +119,26 @@ func UpdateUserHTMX(w http.ResponseWriter, r *http.Request) {
 	name := r.FormValue("name")
 	email := r.FormValue("email")
 
-	if err := db.UpdateUser(id, name, email); err != nil {
-		http.Error(w, "Failed to update user", http.StatusInternalServerError)
+	// The version the form was rendered from (hx-headers in user-edit.html)
+	version, ok := etag.IfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := db.WithTimeout(r.Context())
+	defer cancel()
+
+	_, err = db.UpdateUser(ctx, id, version, name, email)
+	if errors.Is(err, db.ErrVersionConflict) {
+		renderConflict(w, r, ctx, models.User{ID: id, Name: name, Email: email})
+		return
+	}
+	if errors.Is(err, db.ErrNotFound) {
+		http.Error(w, "User not found (deleted by someone else?)", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		dbError(w, r, ctx, err, "Failed to update user")
 		return
 	}
 
@@ -92,6 +146,37 @@ func UpdateUserHTMX(w http.ResponseWriter, r *http.Request) {
 	ListUsersHTMX(w, r)
 }
 
+// userConflict is the data behind user-conflict.html: the row as it is
+// stored now and the values the editor tried to save.
+type userConflict struct {
+	Current models.User
+	Mine    models.User
+}
+
+// renderConflict answers a stale edit with 412 and a fragment showing both
+// versions side by side. It replaces the edit form (HX-Retarget), and the
+// new form inside it carries the current version, so saving it again is a
+// deliberate overwrite.
+func renderConflict(w http.ResponseWriter, r *http.Request, ctx context.Context, mine models.User) {
+	current, err := db.GetUserByID(ctx, mine.ID)
+	if errors.Is(err, db.ErrNotFound) {
+		http.Error(w, "User was deleted by someone else", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		dbError(w, r, ctx, err, "Failed to fetch user")
+		return
+	}
+
+	w.Header().Set("ETag", etag.Version(current.Version))
+	w.Header().Set("HX-Retarget", "#edit-form")
+	w.Header().Set("HX-Reswap", "innerHTML")
+	w.WriteHeader(http.StatusPreconditionFailed)
+	if err := templates().ExecuteTemplate(r.Context(), w, "user-conflict.html", userConflict{Current: current, Mine: mine}); err != nil {
+		log.Println("Template execution error:", err)
+	}
+}
+
 // DeleteUserHTMX removes a user from the database based on the URL param ID.
 // The result is a refreshed list returned to HTMX.
 func DeleteUserHTMX(w http.ResponseWriter, r *http.Request) {
@@ -102,8 +187,24 @@ func DeleteUserHTMX(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := db.DeleteUser(id); err != nil {
-		http.Error(w, "Failed to delete user", http.StatusInternalServerError)
+	// The delete button carries the version shown in the list
+	version, ok := etag.IfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := db.WithTimeout(r.Context())
+	defer cancel()
+
+	err = db.DeleteUser(ctx, id, version)
+	if errors.Is(err, db.ErrVersionConflict) {
+		// Someone edited the user after the list was loaded: show the
+		// current list instead of deleting something the user hasn't seen.
+		renderUserList(w, r, http.StatusPreconditionFailed)
+		return
+	}
+	if err != nil && !errors.Is(err, db.ErrNotFound) { // already gone is fine
+		dbError(w, r, ctx, err, "Failed to delete user")
 		return
 	}
 
@@ -125,5 +226,9 @@ user-list.html: Displays all users.
 
 user-edit.html: Editable form injected during the Edit cycle.
 
+user-conflict.html: Shown instead of saving when someone else changed the user first (412), with both versions so the edit can be merged.
+
+Concurrency: the edit form and delete button send the row version as If-Match; a stale version never overwrites newer data.
+
 This design provides a lightweight frontend-backend interaction without needing a SPA framework like React or Vue. It's a powerful, simple pattern for dynamic UIs.
 */
\ No newline at end of file
@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"database/sql"
+)
+
+// DBStatsCollector exports database/sql connection pool statistics.
+type DBStatsCollector struct {
+	db   *sql.DB
+	name string
+}
+
+// NewDBStatsCollector exposes db.Stats() under the label db="<name>".
+func NewDBStatsCollector(db *sql.DB, name string) *DBStatsCollector {
+	return &DBStatsCollector{db: db, name: name}
+}
+
+// Collect implements Collector.
+func (c *DBStatsCollector) Collect() []Family {
+	s := c.db.Stats()
+	labels := []Label{{Name: "db", Value: c.name}}
+
+	family := func(name, help, typ string, v float64) Family {
+		return Family{Name: name, Help: help, Type: typ, Samples: []Sample{{Labels: labels, Value: v}}}
+	}
+
+	return []Family{
+		family("db_max_open_connections", "Maximum number of open connections to the database.", TypeGauge, float64(s.MaxOpenConnections)),
+		family("db_open_connections", "Established connections, both in use and idle.", TypeGauge, float64(s.OpenConnections)),
+		family("db_in_use_connections", "Connections currently in use.", TypeGauge, float64(s.InUse)),
+		family("db_idle_connections", "Idle connections.", TypeGauge, float64(s.Idle)),
+		family("db_wait_count_total", "Connections waited for because the pool was exhausted.", TypeCounter, float64(s.WaitCount)),
+		family("db_wait_duration_seconds_total", "Time blocked waiting for a new connection.", TypeCounter, s.WaitDuration.Seconds()),
+		family("db_max_idle_closed_total", "Connections closed due to SetMaxIdleConns.", TypeCounter, float64(s.MaxIdleClosed)),
+		family("db_max_idle_time_closed_total", "Connections closed due to SetConnMaxIdleTime.", TypeCounter, float64(s.MaxIdleTimeClosed)),
+		family("db_max_lifetime_closed_total", "Connections closed due to SetConnMaxLifetime.", TypeCounter, float64(s.MaxLifetimeClosed)),
+	}
+}
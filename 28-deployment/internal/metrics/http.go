@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// HTTPMetrics records request counts, latencies and in-flight requests.
+type HTTPMetrics struct {
+	requests *CounterVec
+	duration *HistogramVec
+	inFlight *GaugeVec
+}
+
+// NewHTTPMetrics creates the HTTP metric families and registers them on reg.
+func NewHTTPMetrics(reg *Registry) *HTTPMetrics {
+	m := &HTTPMetrics{
+		requests: NewCounterVec("http_requests_total",
+			"Total HTTP requests by method, route pattern and status code.",
+			"method", "route", "status"),
+		duration: NewHistogramVec("http_request_duration_seconds",
+			"HTTP request latency by method and route pattern.",
+			nil, "method", "route"),
+		inFlight: NewGaugeVec("http_requests_in_flight",
+			"HTTP requests currently being served."),
+	}
+	reg.MustRegister(m.requests, m.duration, m.inFlight)
+	return m
+}
+
+// Middleware instruments every request. Routes are labelled with chi's
+// route pattern (e.g. "/users/{id}") rather than the raw path, so IDs do
+// not create a new time series each.
+func (m *HTTPMetrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		m.inFlight.With().Inc()
+		defer m.inFlight.With().Dec()
+
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			if p := rctx.RoutePattern(); p != "" {
+				route = p
+			}
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		m.requests.With(r.Method, route, strconv.Itoa(status)).Inc()
+		m.duration.With(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+// validBearer compares an Authorization header against the expected token
+// in constant time.
+func validBearer(header, token string) bool {
+	got, ok := strings.CutPrefix(header, "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
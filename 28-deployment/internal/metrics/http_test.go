@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestMiddlewareLabelsByRoutePattern(t *testing.T) {
+	reg := NewRegistry()
+	m := NewHTTPMetrics(reg)
+	r := chi.NewRouter()
+	r.Use(m.Middleware)
+	r.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if chi.URLParam(r, "id") == "404" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+
+	for _, path := range []string{"/users/1", "/users/2", "/users/3", "/users/404", "/nowhere"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	var got []string
+	for _, line := range strings.Split(text(t, reg), "\n") {
+		if strings.HasPrefix(line, "http_requests_total{") ||
+			strings.HasPrefix(line, "http_request_duration_seconds_count{") ||
+			strings.HasPrefix(line, "http_requests_in_flight ") {
+			got = append(got, line)
+		}
+	}
+	want := []string{
+		`http_request_duration_seconds_count{method="GET",route="/users/{id}"} 4`,
+		`http_request_duration_seconds_count{method="GET",route="unmatched"} 1`,
+		`http_requests_in_flight 0`,
+		`http_requests_total{method="GET",route="/users/{id}",status="200"} 3`,
+		`http_requests_total{method="GET",route="/users/{id}",status="404"} 1`,
+		`http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("series:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if !strings.Contains(text(t, reg), "# TYPE http_request_duration_seconds histogram\n") {
+		t.Error("missing TYPE line for the latency histogram")
+	}
+}
@@ -0,0 +1,169 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Metric types as they appear in the "# TYPE" line of the exposition format.
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+)
+
+// Label is a single name="value" pair attached to a sample.
+type Label struct {
+	Name, Value string
+}
+
+// Sample is one line of output. Suffix is appended to the family name
+// (e.g. "_bucket", "_sum") and is empty for counters and gauges.
+type Sample struct {
+	Suffix string
+	Labels []Label
+	Value  float64
+}
+
+// Family groups the samples that share a metric name, help text and type.
+type Family struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []Sample
+}
+
+// Collector produces metric families at scrape time. Counters, gauges and
+// histograms implement it, as do the database and runtime collectors.
+type Collector interface {
+	Collect() []Family
+}
+
+// Registry holds the collectors exposed on /metrics.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []Collector
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// MustRegister adds collectors to the registry.
+func (reg *Registry) MustRegister(cs ...Collector) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.collectors = append(reg.collectors, cs...)
+}
+
+// Gather collects every registered family, sorted by name.
+func (reg *Registry) Gather() []Family {
+	reg.mu.Lock()
+	collectors := append([]Collector(nil), reg.collectors...)
+	reg.mu.Unlock()
+
+	var families []Family
+	for _, c := range collectors {
+		families = append(families, c.Collect()...)
+	}
+	sort.SliceStable(families, func(i, j int) bool { return families[i].Name < families[j].Name })
+	return families
+}
+
+// WriteText writes all families in the Prometheus text exposition format
+// (version 0.0.4).
+func (reg *Registry) WriteText(w io.Writer) error {
+	var b strings.Builder
+	for _, f := range reg.Gather() {
+		if f.Help != "" {
+			fmt.Fprintf(&b, "# HELP %s %s\n", f.Name, escapeHelp(f.Help))
+		}
+		fmt.Fprintf(&b, "# TYPE %s %s\n", f.Name, f.Type)
+		for _, s := range f.Samples {
+			b.WriteString(f.Name)
+			b.WriteString(s.Suffix)
+			writeLabels(&b, s.Labels)
+			b.WriteByte(' ')
+			b.WriteString(formatValue(s.Value))
+			b.WriteByte('\n')
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Handler serves the registry's metrics. When token is non-empty, requests
+// must carry "Authorization: Bearer <token>".
+func Handler(reg *Registry, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token != "" && !validBearer(r.Header.Get("Authorization"), token) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		reg.WriteText(w)
+	})
+}
+
+func writeLabels(b *strings.Builder, labels []Label) {
+	if len(labels) == 0 {
+		return
+	}
+	b.WriteByte('{')
+	for i, l := range labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(l.Name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(l.Value))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+/*
+🧠 Blurb: Understanding metrics.go
+This is a tiny, dependency-free implementation of the Prometheus text format.
+Prometheus scrapes /metrics periodically and expects lines such as:
+
+# HELP http_requests_total Total HTTP requests.
+# TYPE http_requests_total counter
+http_requests_total{method="GET",route="/users/",status="200"} 42
+
+The Registry collects "families" (one metric name, many label combinations)
+from every registered Collector and prints them in that format. Counters,
+gauges and histograms live in vec.go; http.go, db.go and runtime.go plug in
+request, connection-pool and Go runtime numbers.
+
+Writing the format ourselves keeps the deployment image small and shows how
+little magic is involved in Prometheus instrumentation.
+*/
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func text(t *testing.T, reg *Registry) string {
+	t.Helper()
+	var b strings.Builder
+	if err := reg.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestWriteTextGolden(t *testing.T) {
+	reg := NewRegistry()
+	requests := NewCounterVec("app_requests_total", "Requests by path.\nSecond line with a \\ backslash.", "path")
+	temp := NewGaugeVec("app_temperature", "")
+	latency := NewHistogramVec("app_latency_seconds", "Latency.", []float64{1, 0.1, 0.5}, "op")
+	reg.MustRegister(requests, temp, latency)
+
+	requests.With(`C:\tmp`).Inc()
+	requests.With(`say "hi"`).Add(2)
+	requests.With("two\nlines").Inc()
+	requests.With("/ok").Add(-5) // counters never go down
+	temp.With().Set(-1.5)
+	for _, v := range []float64{0.05, 0.1, 0.3, 0.7, 3} {
+		latency.With("read").Observe(v)
+	}
+
+	// Families sorted by name; label values escaped; histogram buckets
+	// cumulative with le sorted, then +Inf, _sum and _count.
+	want := `# HELP app_latency_seconds Latency.
+# TYPE app_latency_seconds histogram
+app_latency_seconds_bucket{op="read",le="0.1"} 2
+app_latency_seconds_bucket{op="read",le="0.5"} 3
+app_latency_seconds_bucket{op="read",le="1"} 4
+app_latency_seconds_bucket{op="read",le="+Inf"} 5
+app_latency_seconds_sum{op="read"} 4.15
+app_latency_seconds_count{op="read"} 5
+# HELP app_requests_total Requests by path.\nSecond line with a \\ backslash.
+# TYPE app_requests_total counter
+app_requests_total{path="/ok"} 0
+app_requests_total{path="C:\\tmp"} 1
+app_requests_total{path="say \"hi\""} 2
+app_requests_total{path="two\nlines"} 1
+# TYPE app_temperature gauge
+app_temperature -1.5
+`
+	if got := text(t, reg); got != want {
+		t.Errorf("WriteText:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestHandlerToken(t *testing.T) {
+	reg := NewRegistry()
+	c := NewCounterVec("up_total", "Up.")
+	c.With().Inc()
+	reg.MustRegister(c)
+
+	tests := []struct {
+		name, token, authorization string
+		want                       int
+	}{
+		{"no token configured", "", "", http.StatusOK},
+		{"right token", "s3cret", "Bearer s3cret", http.StatusOK},
+		{"missing", "s3cret", "", http.StatusUnauthorized},
+		{"wrong", "s3cret", "Bearer guess", http.StatusUnauthorized},
+		{"prefix of the token", "s3cret", "Bearer s3c", http.StatusUnauthorized},
+		{"other scheme", "s3cret", "Basic s3cret", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			Handler(reg, tt.token).ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Fatalf("status %d, want %d", w.Code, tt.want)
+			}
+			if tt.want == http.StatusUnauthorized {
+				if w.Header().Get("WWW-Authenticate") != `Bearer realm="metrics"` || strings.Contains(w.Body.String(), "up_total") {
+					t.Errorf("401 with %v and body %q", w.Header(), w.Body)
+				}
+				return
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "text/plain; version=0.0.4; charset=utf-8" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+			if !strings.Contains(w.Body.String(), "\nup_total 1\n") {
+				t.Errorf("body = %q", w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"runtime"
+	"time"
+)
+
+// RuntimeCollector exports Go runtime statistics: goroutines, memory and GC.
+type RuntimeCollector struct {
+	start time.Time
+}
+
+// NewRuntimeCollector returns a collector for the current process.
+func NewRuntimeCollector() *RuntimeCollector {
+	return &RuntimeCollector{start: time.Now()}
+}
+
+// Collect implements Collector.
+func (c *RuntimeCollector) Collect() []Family {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	gauge := func(name, help string, v float64) Family {
+		return Family{Name: name, Help: help, Type: TypeGauge, Samples: []Sample{{Value: v}}}
+	}
+	counter := func(name, help string, v float64) Family {
+		return Family{Name: name, Help: help, Type: TypeCounter, Samples: []Sample{{Value: v}}}
+	}
+
+	return []Family{
+		{Name: "go_info", Help: "Information about the Go environment.", Type: TypeGauge,
+			Samples: []Sample{{Labels: []Label{{Name: "version", Value: runtime.Version()}}, Value: 1}}},
+		gauge("go_goroutines", "Number of goroutines that currently exist.", float64(runtime.NumGoroutine())),
+		gauge("go_gomaxprocs", "Value of GOMAXPROCS (threads executing Go code simultaneously).", float64(runtime.GOMAXPROCS(0))),
+		gauge("go_memstats_heap_alloc_bytes", "Heap bytes allocated and still in use.", float64(ms.HeapAlloc)),
+		gauge("go_memstats_heap_inuse_bytes", "Heap bytes in in-use spans.", float64(ms.HeapInuse)),
+		gauge("go_memstats_heap_objects", "Number of allocated heap objects.", float64(ms.HeapObjects)),
+		gauge("go_memstats_sys_bytes", "Bytes of memory obtained from the OS.", float64(ms.Sys)),
+		counter("go_memstats_alloc_bytes_total", "Cumulative bytes allocated for heap objects.", float64(ms.TotalAlloc)),
+		counter("go_gc_cycles_total", "Completed GC cycles.", float64(ms.NumGC)),
+		counter("go_gc_pause_seconds_total", "Cumulative GC stop-the-world pause time.", time.Duration(ms.PauseTotalNs).Seconds()),
+		gauge("process_uptime_seconds", "Seconds since the process started.", time.Since(c.start).Seconds()),
+	}
+}
@@ -0,0 +1,272 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are latency buckets in seconds, from 5ms to 10s.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// vec tracks one child per distinct combination of label values.
+type vec[T any] struct {
+	name, help string
+	labelNames []string
+	newChild   func() *T
+
+	mu       sync.RWMutex
+	children map[string]*T
+	values   map[string][]string
+}
+
+func newVec[T any](name, help string, labelNames []string, newChild func() *T) *vec[T] {
+	return &vec[T]{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		newChild:   newChild,
+		children:   make(map[string]*T),
+		values:     make(map[string][]string),
+	}
+}
+
+// with returns the child for the given label values, creating it on first use.
+func (v *vec[T]) with(values []string) *T {
+	if len(values) != len(v.labelNames) {
+		panic("metrics: " + v.name + ": wrong number of label values")
+	}
+	key := strings.Join(values, "\xff")
+
+	v.mu.RLock()
+	c, ok := v.children[key]
+	v.mu.RUnlock()
+	if ok {
+		return c
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if c, ok = v.children[key]; !ok {
+		c = v.newChild()
+		v.children[key] = c
+		v.values[key] = append([]string(nil), values...)
+	}
+	return c
+}
+
+// each visits children in a stable order so output does not jump around
+// between scrapes.
+func (v *vec[T]) each(fn func(labels []Label, child *T)) {
+	v.mu.RLock()
+	keys := make([]string, 0, len(v.children))
+	for k := range v.children {
+		keys = append(keys, k)
+	}
+	v.mu.RUnlock()
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v.mu.RLock()
+		child, values := v.children[k], v.values[k]
+		v.mu.RUnlock()
+
+		labels := make([]Label, len(values))
+		for i, val := range values {
+			labels[i] = Label{Name: v.labelNames[i], Value: val}
+		}
+		fn(labels, child)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Counter
+// ---------------------------------------------------------------------------
+
+// Counter is a value that only goes up.
+type Counter struct {
+	mu sync.Mutex
+	v  float64
+}
+
+// Inc adds one.
+func (c *Counter) Inc() { c.Add(1) }
+
+// Add adds d, which must not be negative.
+func (c *Counter) Add(d float64) {
+	if d < 0 {
+		return
+	}
+	c.mu.Lock()
+	c.v += d
+	c.mu.Unlock()
+}
+
+func (c *Counter) value() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.v
+}
+
+// CounterVec is a set of counters partitioned by labels.
+type CounterVec struct {
+	*vec[Counter]
+}
+
+// NewCounterVec creates a counter family. Register it with a Registry to expose it.
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{newVec(name, help, labelNames, func() *Counter { return &Counter{} })}
+}
+
+// With returns the counter for the given label values.
+func (cv *CounterVec) With(values ...string) *Counter { return cv.with(values) }
+
+// Collect implements Collector.
+func (cv *CounterVec) Collect() []Family {
+	f := Family{Name: cv.name, Help: cv.help, Type: TypeCounter}
+	cv.each(func(labels []Label, c *Counter) {
+		f.Samples = append(f.Samples, Sample{Labels: labels, Value: c.value()})
+	})
+	return []Family{f}
+}
+
+// ---------------------------------------------------------------------------
+// Gauge
+// ---------------------------------------------------------------------------
+
+// Gauge is a value that can go up and down.
+type Gauge struct {
+	mu sync.Mutex
+	v  float64
+}
+
+// Set replaces the current value.
+func (g *Gauge) Set(v float64) {
+	g.mu.Lock()
+	g.v = v
+	g.mu.Unlock()
+}
+
+// Add changes the value by d (which may be negative).
+func (g *Gauge) Add(d float64) {
+	g.mu.Lock()
+	g.v += d
+	g.mu.Unlock()
+}
+
+// Inc adds one; Dec subtracts one.
+func (g *Gauge) Inc() { g.Add(1) }
+func (g *Gauge) Dec() { g.Add(-1) }
+
+func (g *Gauge) value() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.v
+}
+
+// GaugeVec is a set of gauges partitioned by labels.
+type GaugeVec struct {
+	*vec[Gauge]
+}
+
+// NewGaugeVec creates a gauge family.
+func NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	return &GaugeVec{newVec(name, help, labelNames, func() *Gauge { return &Gauge{} })}
+}
+
+// With returns the gauge for the given label values.
+func (gv *GaugeVec) With(values ...string) *Gauge { return gv.with(values) }
+
+// Collect implements Collector.
+func (gv *GaugeVec) Collect() []Family {
+	f := Family{Name: gv.name, Help: gv.help, Type: TypeGauge}
+	gv.each(func(labels []Label, g *Gauge) {
+		f.Samples = append(f.Samples, Sample{Labels: labels, Value: g.value()})
+	})
+	return []Family{f}
+}
+
+// ---------------------------------------------------------------------------
+// Histogram
+// ---------------------------------------------------------------------------
+
+// Histogram counts observations into cumulative buckets.
+type Histogram struct {
+	upper []float64 // shared, sorted bucket upper bounds
+
+	mu     sync.Mutex
+	counts []uint64 // per-bucket (non-cumulative) counts
+	sum    float64
+	count  uint64
+}
+
+// Observe records one value.
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.upper, v)
+	h.mu.Lock()
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.sum += v
+	h.count++
+	h.mu.Unlock()
+}
+
+// HistogramVec is a set of histograms partitioned by labels.
+type HistogramVec struct {
+	*vec[Histogram]
+	buckets []float64
+}
+
+// NewHistogramVec creates a histogram family. nil buckets means DefaultBuckets.
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	upper := append([]float64(nil), buckets...)
+	sort.Float64s(upper)
+	return &HistogramVec{
+		vec: newVec(name, help, labelNames, func() *Histogram {
+			return &Histogram{upper: upper, counts: make([]uint64, len(upper))}
+		}),
+		buckets: upper,
+	}
+}
+
+// With returns the histogram for the given label values.
+func (hv *HistogramVec) With(values ...string) *Histogram { return hv.with(values) }
+
+// Collect implements Collector.
+func (hv *HistogramVec) Collect() []Family {
+	f := Family{Name: hv.name, Help: hv.help, Type: TypeHistogram}
+	hv.each(func(labels []Label, h *Histogram) {
+		h.mu.Lock()
+		counts := append([]uint64(nil), h.counts...)
+		sum, count := h.sum, h.count
+		h.mu.Unlock()
+
+		var cumulative uint64
+		for i, upper := range hv.buckets {
+			cumulative += counts[i]
+			f.Samples = append(f.Samples, Sample{
+				Suffix: "_bucket",
+				Labels: withLabel(labels, "le", formatValue(upper)),
+				Value:  float64(cumulative),
+			})
+		}
+		f.Samples = append(f.Samples,
+			Sample{Suffix: "_bucket", Labels: withLabel(labels, "le", formatValue(math.Inf(1))), Value: float64(count)},
+			Sample{Suffix: "_sum", Labels: labels, Value: sum},
+			Sample{Suffix: "_count", Labels: labels, Value: float64(count)},
+		)
+	})
+	return []Family{f}
+}
+
+// withLabel returns a copy of labels with one extra pair appended.
+func withLabel(labels []Label, name, value string) []Label {
+	out := make([]Label, len(labels), len(labels)+1)
+	copy(out, labels)
+	return append(out, Label{Name: name, Value: value})
+}
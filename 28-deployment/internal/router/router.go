@@ -1,22 +1,48 @@
 package router
 
 import (
+	"log"
 	"net/http"
+	"os"
 
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/28-deployment/internal/compress"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/28-deployment/internal/db"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/28-deployment/internal/etag"
 	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/28-deployment/internal/handlers"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/28-deployment/internal/metrics"
+	"github.com/cyber-mountain-man/learn-go-with-cyber-mountain-man/28-deployment/internal/tracing"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"learn-go-with-cyber-mountain-man/26-databases/shared/cors"
 )
 
 // SetupRouter defines all routes for the application and returns the configured router.
 func SetupRouter() http.Handler {
 	r := chi.NewRouter()
 
+	// Trace every request first, so the span also covers the middleware below
+	r.Use(tracing.Middleware)
+
 	// Log each request to the console for debugging
 	r.Use(middleware.Logger)
 
+	// Prometheus metrics: per-route request counts/latency, DB pool and Go runtime stats
+	reg := metrics.NewRegistry()
+	httpMetrics := metrics.NewHTTPMetrics(reg)
+	reg.MustRegister(metrics.NewRuntimeCollector())
+	if db.DB != nil {
+		reg.MustRegister(metrics.NewDBStatsCollector(db.DB.DB, "users"))
+	}
+	r.Use(httpMetrics.Middleware)
+
+	// gzip/deflate for text responses, tuned by COMPRESS_* env vars
+	gzip := compress.Handler(compress.FromEnv())
+
 	// Serve static files (index.html, styles, etc.) from the "static" folder at "/static"
-	fileServer(r, "/static", http.Dir("static"))
+	r.Group(func(r chi.Router) {
+		r.Use(gzip)
+		fileServer(r, "/static", http.Dir("static"))
+	})
 
 	// Redirect root "/" to the main index.html page
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
@@ -28,13 +54,39 @@ func SetupRouter() http.Handler {
 		w.Write([]byte("✅ OK"))
 	})
 
+	// Readiness: 503 while the database is unreachable, so traffic goes elsewhere
+	r.Get("/ready", func(w http.ResponseWriter, r *http.Request) {
+		if err := db.Ready(r.Context()); err != nil { // bounded by DB_PING_TIMEOUT
+			log.Println("Readiness check failed:", err)
+			http.Error(w, "database unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ready"))
+	})
+
+	// Metrics for Prometheus; set METRICS_TOKEN to require "Authorization: Bearer <token>"
+	r.Method(http.MethodGet, "/metrics", metrics.Handler(reg, os.Getenv("METRICS_TOKEN")))
+
+	// Recent traces (JSON, or ?format=text waterfall) when TRACE_DEBUG=true
+	if os.Getenv("TRACE_DEBUG") == "true" {
+		r.Mount("/debug/traces", tracing.DebugHandler())
+	}
+
 	// Define routes under the "/users" group
 	r.Route("/users", func(r chi.Router) {
-		r.Get("/", handlers.ListUsersHTMX)              // Load user list (HTML)
-		r.Post("/", handlers.CreateUserHTMX)            // Create new user (HTMX form POST)
-		r.Get("/{id}/edit", handlers.EditUserFormHTMX)  // Load user edit form (HTMX)
-		r.Put("/{id}", handlers.UpdateUserHTMX)         // Update user (HTMX form PUT)
-		r.Delete("/{id}", handlers.DeleteUserHTMX)      // Delete user
+		// Allow cross-origin API calls (and answer OPTIONS preflights) per CORS_USERS_* / CORS_* env vars
+		r.Use(cors.Handler(cors.FromEnv("USERS")))
+
+		// ETag/304 for GETs (outermost, so it hashes the encoded bytes), then compression
+		r.Use(etag.Handler)
+		r.Use(gzip)
+
+		r.Get("/", handlers.ListUsersHTMX)                // Load user list (HTML)
+		r.Post("/", handlers.CreateUserHTMX)              // Create new user (HTMX form POST)
+		r.Get("/{id}", (&handlers.UserHandler{}).GetUser) // One user as JSON; ETag is its version
+		r.Get("/{id}/edit", handlers.EditUserFormHTMX)    // Load user edit form (HTMX)
+		r.Put("/{id}", handlers.UpdateUserHTMX)           // Update user (HTMX form PUT)
+		r.Delete("/{id}", handlers.DeleteUserHTMX)        // Delete user
 	})
 
 	return r
@@ -60,13 +112,23 @@ This file is responsible for configuring all the HTTP routes of your Go applicat
 
 Registers middleware for logging requests.
 
-Serves static assets like HTML, CSS, and JS.
+Serves static assets like HTML, CSS, and JS, compressed when the browser accepts gzip or deflate.
 
 Redirects the root path to your frontend's index.html.
 
+Applies CORS rules to the /users group so browser apps on other origins can call the API.
+
+Tags /users responses with ETags so repeated HTMX loads get a cheap 304 Not Modified.
+
+Serves GET /users/{id} as JSON whose ETag is the row version; PUT and DELETE must send it back in If-Match.
+
 Defines RESTful endpoints for managing users via HTMX (GET, POST, PUT, DELETE).
 
-Supports health monitoring through a lightweight /health endpoint.
+Supports health monitoring through a lightweight /health endpoint, and readiness through /ready, which answers 503 while the database is unreachable.
+
+Exposes Prometheus metrics at /metrics (optionally protected by METRICS_TOKEN).
+
+Starts a trace span per request (continuing any incoming traceparent) and, with TRACE_DEBUG=true, shows recent traces at /debug/traces.
 
 This modular routing setup makes your app scalable and easy to debug or extend.
 */
\ No newline at end of file
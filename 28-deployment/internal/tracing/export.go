@@ -0,0 +1,198 @@
+package tracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// Exporter receives finished spans.
+type Exporter interface {
+	Export(SpanData)
+}
+
+var (
+	exportMu  sync.RWMutex
+	exporters []Exporter
+	recent    atomic.Pointer[MemoryExporter] // always on, backs the debug endpoint
+)
+
+func init() { recent.Store(NewMemoryExporter(1000)) }
+
+// SetExporters replaces the extra exporters that receive every finished span
+// in addition to the built-in in-memory buffer.
+func SetExporters(es ...Exporter) {
+	exportMu.Lock()
+	exporters = es
+	exportMu.Unlock()
+}
+
+// Recent returns the in-memory exporter that keeps the latest spans.
+func Recent() *MemoryExporter { return recent.Load() }
+
+func export(s SpanData) {
+	recent.Load().Export(s)
+	exportMu.RLock()
+	defer exportMu.RUnlock()
+	for _, e := range exporters {
+		e.Export(s)
+	}
+}
+
+// InitFromEnv configures exporting from the environment:
+//
+//	TRACE_FILE    path of a JSON-lines file to append spans to
+//	TRACE_BUFFER  number of recent spans kept in memory (default 1000)
+//
+// The returned function closes the trace file, if any.
+func InitFromEnv() (func() error, error) {
+	if n, err := strconv.Atoi(os.Getenv("TRACE_BUFFER")); err == nil && n > 0 {
+		// Swapped atomically: requests may already be exporting spans
+		recent.Store(NewMemoryExporter(n))
+	}
+
+	path := os.Getenv("TRACE_FILE")
+	if path == "" {
+		return func() error { return nil }, nil
+	}
+	f, err := NewJSONLExporter(path)
+	if err != nil {
+		return nil, err
+	}
+	SetExporters(f)
+	return f.Close, nil
+}
+
+// ---------------------------------------------------------------------------
+// In-memory exporter
+// ---------------------------------------------------------------------------
+
+// MemoryExporter keeps the most recent spans in a ring buffer. It backs the
+// /debug/traces endpoint and is handy for assertions in tests.
+type MemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+	next  int
+	full  bool
+}
+
+// NewMemoryExporter keeps up to capacity spans.
+func NewMemoryExporter(capacity int) *MemoryExporter {
+	return &MemoryExporter{spans: make([]SpanData, capacity)}
+}
+
+// Export implements Exporter.
+func (m *MemoryExporter) Export(s SpanData) {
+	m.mu.Lock()
+	m.spans[m.next] = s
+	m.next = (m.next + 1) % len(m.spans)
+	if m.next == 0 {
+		m.full = true
+	}
+	m.mu.Unlock()
+}
+
+// Spans returns the buffered spans, oldest first.
+func (m *MemoryExporter) Spans() []SpanData {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.full {
+		return append([]SpanData(nil), m.spans[:m.next]...)
+	}
+	return append(append([]SpanData(nil), m.spans[m.next:]...), m.spans[:m.next]...)
+}
+
+// Reset drops all buffered spans.
+func (m *MemoryExporter) Reset() {
+	m.mu.Lock()
+	clear(m.spans)
+	m.next, m.full = 0, false
+	m.mu.Unlock()
+}
+
+// Trace returns all buffered spans of one trace, ordered by start time.
+func (m *MemoryExporter) Trace(id string) []SpanData {
+	var out []SpanData
+	for _, s := range m.Spans() {
+		if s.TraceID.String() == id {
+			out = append(out, s)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Start.Before(out[j].Start) })
+	return out
+}
+
+// TraceSummary describes one trace in the debug listing.
+type TraceSummary struct {
+	TraceID    string  `json:"trace_id"`
+	Root       string  `json:"root"`
+	Spans      int     `json:"spans"`
+	DurationMS float64 `json:"duration_ms"`
+	Error      bool    `json:"error"`
+}
+
+// Traces summarises buffered traces, newest first.
+func (m *MemoryExporter) Traces() []TraceSummary {
+	byID := make(map[string]*TraceSummary)
+	var order []string
+	for _, s := range m.Spans() {
+		id := s.TraceID.String()
+		t, ok := byID[id]
+		if !ok {
+			t = &TraceSummary{TraceID: id}
+			byID[id] = t
+			order = append(order, id)
+		}
+		t.Spans++
+		t.Error = t.Error || s.Error != ""
+		// The longest span is the root for traces that started here, and the
+		// best available stand-in for traces whose parent is remote.
+		if s.DurationMS >= t.DurationMS {
+			t.Root, t.DurationMS = s.Name, s.DurationMS
+		}
+	}
+
+	out := make([]TraceSummary, 0, len(order))
+	for i := len(order) - 1; i >= 0; i-- {
+		out = append(out, *byID[order[i]])
+	}
+	return out
+}
+
+// ---------------------------------------------------------------------------
+// JSON-lines file exporter
+// ---------------------------------------------------------------------------
+
+// JSONLExporter appends one JSON object per span to a file.
+type JSONLExporter struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+// NewJSONLExporter opens (or creates) path for appending.
+func NewJSONLExporter(path string) (*JSONLExporter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open trace file: %w", err)
+	}
+	return &JSONLExporter{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Export implements Exporter.
+func (e *JSONLExporter) Export(s SpanData) {
+	e.mu.Lock()
+	e.enc.Encode(s)
+	e.mu.Unlock()
+}
+
+// Close closes the underlying file.
+func (e *JSONLExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.f.Close()
+}
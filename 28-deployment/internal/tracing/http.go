@@ -0,0 +1,121 @@
+package tracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Middleware starts a server span for every request, continuing the
+// caller's trace when a valid traceparent header is present. The span is
+// named after the chi route pattern once routing has finished, and the
+// response carries a traceparent header so clients can look the trace up.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if parent, ok := Extract(r.Header); ok {
+			ctx = ContextWithRemoteParent(ctx, parent)
+		}
+
+		ctx, span := Start(ctx, r.Method+" "+r.URL.Path)
+		defer span.End()
+		span.SetAttr("http.method", r.Method)
+		span.SetAttr("http.target", r.URL.RequestURI())
+
+		Inject(span.SpanContext(), w.Header())
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			if p := rctx.RoutePattern(); p != "" {
+				span.SetName(r.Method + " " + p)
+				span.SetAttr("http.route", p)
+			}
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttr("http.status_code", strconv.Itoa(status))
+		if status >= 500 {
+			span.RecordError(fmt.Errorf("HTTP %d", status))
+		}
+	})
+}
+
+// DebugHandler serves the spans collected by Recent():
+//
+//	GET /            JSON list of recent traces
+//	GET /{traceID}   spans of one trace; add ?format=text for an indented waterfall
+func DebugHandler() http.Handler {
+	r := chi.NewRouter()
+
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Recent().Traces())
+	})
+
+	r.Get("/{traceID}", func(w http.ResponseWriter, r *http.Request) {
+		spans := Recent().Trace(chi.URLParam(r, "traceID"))
+		if len(spans) == 0 {
+			http.Error(w, "Trace not found", http.StatusNotFound)
+			return
+		}
+		if r.URL.Query().Get("format") == "text" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			writeWaterfall(w, spans)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(spans)
+	})
+
+	return r
+}
+
+// writeWaterfall prints spans as an indented tree with start offsets and
+// durations, e.g.
+//
+//	+0.000ms  12.400ms  GET /users/
+//	  +0.310ms  9.850ms  sql.query  SELECT id, name, email FROM users ORDER BY id
+func writeWaterfall(w http.ResponseWriter, spans []SpanData) {
+	children := make(map[SpanID][]SpanData)
+	known := make(map[SpanID]bool)
+	for _, s := range spans {
+		known[s.SpanID] = true
+	}
+	var roots []SpanData
+	for _, s := range spans {
+		if s.ParentID != nil && known[*s.ParentID] {
+			children[*s.ParentID] = append(children[*s.ParentID], s)
+		} else {
+			roots = append(roots, s)
+		}
+	}
+
+	origin := spans[0].Start
+	var walk func(s SpanData, depth int)
+	walk = func(s SpanData, depth int) {
+		line := fmt.Sprintf("%s+%.3fms  %.3fms  %s", strings.Repeat("  ", depth),
+			float64(s.Start.Sub(origin).Microseconds())/1000, s.DurationMS, s.Name)
+		if stmt := s.Attributes["db.statement"]; stmt != "" {
+			line += "  " + strings.Join(strings.Fields(stmt), " ")
+		}
+		if s.Error != "" {
+			line += "  ERROR: " + s.Error
+		}
+		fmt.Fprintln(w, line)
+		for _, c := range children[s.SpanID] {
+			walk(c, depth+1)
+		}
+	}
+	for _, root := range roots {
+		walk(root, 0)
+	}
+}
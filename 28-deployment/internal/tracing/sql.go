@@ -0,0 +1,57 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+)
+
+// DB wraps *sql.DB so that QueryContext, QueryRowContext and ExecContext
+// each record a child span with the SQL statement. All other methods
+// (Ping, Close, Stats, BeginTx...) are promoted from the embedded *sql.DB.
+//
+// Only the context-aware methods are traced: a span needs the request
+// context to find its parent.
+type DB struct {
+	*sql.DB
+}
+
+// WrapDB returns a tracing wrapper around db.
+func WrapDB(db *sql.DB) *DB {
+	return &DB{DB: db}
+}
+
+// QueryContext runs a query inside a "sql.query" span.
+func (db *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := startSQL(ctx, "sql.query", query)
+	defer span.End()
+	rows, err := db.DB.QueryContext(ctx, query, args...)
+	span.RecordError(err)
+	return rows, err
+}
+
+// QueryRowContext runs a single-row query inside a "sql.query_row" span.
+// The span ends once the row is fetched; scan errors surface from Scan.
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, span := startSQL(ctx, "sql.query_row", query)
+	defer span.End()
+	row := db.DB.QueryRowContext(ctx, query, args...)
+	span.RecordError(row.Err())
+	return row
+}
+
+// ExecContext runs a statement inside a "sql.exec" span and records the
+// number of affected rows.
+func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := startSQL(ctx, "sql.exec", query)
+	defer span.End()
+	res, err := db.DB.ExecContext(ctx, query, args...)
+	span.RecordError(err)
+	return res, err
+}
+
+func startSQL(ctx context.Context, name, query string) (context.Context, *Span) {
+	ctx, span := Start(ctx, name)
+	span.SetAttr("db.system", "sql")
+	span.SetAttr("db.statement", query)
+	return ctx, span
+}
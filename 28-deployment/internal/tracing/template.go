@@ -0,0 +1,27 @@
+package tracing
+
+import (
+	"context"
+	"html/template"
+	"io"
+)
+
+// Template wraps *html/template.Template so that executions show up as
+// "template <name>" spans.
+type Template struct {
+	*template.Template
+}
+
+// WrapTemplate returns a tracing wrapper around t.
+func WrapTemplate(t *template.Template) *Template {
+	return &Template{Template: t}
+}
+
+// ExecuteTemplate renders the named template inside a span.
+func (t *Template) ExecuteTemplate(ctx context.Context, w io.Writer, name string, data any) error {
+	_, span := Start(ctx, "template "+name)
+	defer span.End()
+	err := t.Template.ExecuteTemplate(w, name, data)
+	span.RecordError(err)
+	return err
+}
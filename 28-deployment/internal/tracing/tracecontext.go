@@ -0,0 +1,154 @@
+package tracing
+
+import (
+	"encoding/hex"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+)
+
+// W3C Trace Context header names.
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+// flagSampled is the only trace-flag defined by the spec.
+const flagSampled = 0x01
+
+// TraceID and SpanID are the binary identifiers carried in traceparent.
+type (
+	TraceID [16]byte
+	SpanID  [8]byte
+)
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+
+// IsValid reports whether the ID is non-zero, as the spec requires.
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+func (s SpanID) IsValid() bool  { return s != SpanID{} }
+
+// MarshalText lets IDs appear as hex strings in JSON.
+func (t TraceID) MarshalText() ([]byte, error) { return []byte(t.String()), nil }
+func (s SpanID) MarshalText() ([]byte, error)  { return []byte(s.String()), nil }
+
+// SpanContext is the part of a span that crosses process boundaries.
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Flags      byte
+	TraceState string // opaque vendor data, forwarded unchanged
+	Remote     bool   // parsed from an incoming request
+}
+
+// IsValid reports whether both IDs are set.
+func (sc SpanContext) IsValid() bool { return sc.TraceID.IsValid() && sc.SpanID.IsValid() }
+
+// Sampled reports whether the sampled flag is set.
+func (sc SpanContext) Sampled() bool { return sc.Flags&flagSampled != 0 }
+
+// Traceparent formats the context as a version-00 traceparent value.
+func (sc SpanContext) Traceparent() string {
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + hex.EncodeToString([]byte{sc.Flags})
+}
+
+// ErrInvalidTraceparent is returned for headers that do not follow the spec.
+var ErrInvalidTraceparent = errors.New("tracing: invalid traceparent")
+
+// ParseTraceparent parses "00-<32 hex trace id>-<16 hex span id>-<2 hex flags>".
+// Future versions are accepted as long as they start with the version-00
+// fields, as the spec asks; version "ff" is always invalid.
+func ParseTraceparent(s string) (SpanContext, error) {
+	var sc SpanContext
+	s = strings.TrimSpace(s)
+	if len(s) < 55 || s[2] != '-' || s[35] != '-' || s[52] != '-' {
+		return sc, ErrInvalidTraceparent
+	}
+	version := s[:2]
+	if !isLowerHex(version) || version == "ff" {
+		return sc, ErrInvalidTraceparent
+	}
+	if version == "00" && len(s) != 55 {
+		return sc, ErrInvalidTraceparent
+	}
+	if len(s) > 55 && s[55] != '-' {
+		return sc, ErrInvalidTraceparent
+	}
+
+	if !decodeHex(sc.TraceID[:], s[3:35]) || !decodeHex(sc.SpanID[:], s[36:52]) {
+		return sc, ErrInvalidTraceparent
+	}
+	var flags [1]byte
+	if !decodeHex(flags[:], s[53:55]) {
+		return sc, ErrInvalidTraceparent
+	}
+	sc.Flags = flags[0]
+	if !sc.IsValid() {
+		return sc, ErrInvalidTraceparent
+	}
+	sc.Remote = true
+	return sc, nil
+}
+
+// Extract reads traceparent and tracestate from request headers. The
+// boolean is false when no valid parent was sent.
+func Extract(h http.Header) (SpanContext, bool) {
+	sc, err := ParseTraceparent(h.Get(TraceparentHeader))
+	if err != nil {
+		return SpanContext{}, false
+	}
+	sc.TraceState = strings.Join(h.Values(TracestateHeader), ",")
+	return sc, true
+}
+
+// Inject writes the span context into headers, e.g. on an outgoing request.
+func Inject(sc SpanContext, h http.Header) {
+	if !sc.IsValid() {
+		return
+	}
+	h.Set(TraceparentHeader, sc.Traceparent())
+	if sc.TraceState != "" {
+		h.Set(TracestateHeader, sc.TraceState)
+	}
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func decodeHex(dst []byte, s string) bool {
+	if !isLowerHex(s) {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
+
+func newTraceID() (t TraceID) {
+	for !t.IsValid() {
+		putUint64(t[:8], rand.Uint64())
+		putUint64(t[8:], rand.Uint64())
+	}
+	return t
+}
+
+func newSpanID() (s SpanID) {
+	for !s.IsValid() {
+		putUint64(s[:], rand.Uint64())
+	}
+	return s
+}
+
+func putUint64(b []byte, v uint64) {
+	for i := range 8 {
+		b[i] = byte(v >> (56 - 8*i))
+	}
+}
@@ -46,11 +46,32 @@ BEGIN
     CREATE TABLE users (
         id INT PRIMARY KEY IDENTITY(1,1),        -- Auto-incrementing ID
         name NVARCHAR(100) NOT NULL,             -- User's name
-        email NVARCHAR(100) NOT NULL UNIQUE      -- User's email (must be unique)
+        email NVARCHAR(100) NOT NULL UNIQUE,     -- User's email (must be unique)
+        version INT NOT NULL CONSTRAINT DF_users_version DEFAULT 1, -- Bumped on every update
+        CONSTRAINT users_name_check CHECK (name <> N''),          -- Name can't be blank
+        CONSTRAINT users_email_check CHECK (email LIKE N'%_@_%')  -- Email needs an @
     );
 END
 GO
 
+-- Add the version column to tables created before it existed
+IF COL_LENGTH('dbo.users', 'version') IS NULL
+BEGIN
+    ALTER TABLE users ADD version INT NOT NULL CONSTRAINT DF_users_version DEFAULT 1;
+END
+GO
+
+-- Add the name/email checks to older tables (WITH NOCHECK: existing rows are not re-validated)
+IF OBJECT_ID('dbo.users_name_check', 'C') IS NULL
+BEGIN
+    ALTER TABLE users WITH NOCHECK ADD CONSTRAINT users_name_check CHECK (name <> N'');
+END
+IF OBJECT_ID('dbo.users_email_check', 'C') IS NULL
+BEGIN
+    ALTER TABLE users WITH NOCHECK ADD CONSTRAINT users_email_check CHECK (email LIKE N'%_@_%');
+END
+GO
+
 -- Seed with an initial admin user if the table is empty
 IF NOT EXISTS (SELECT * FROM users)
 BEGIN
@@ -66,7 +87,9 @@ GO
 -- - Waits for the SQL Server engine to be ready (retry loop).
 -- - Creates the target database only if it doesn't exist.
 -- - Creates a login and maps it to a database user with full permissions.
--- - Creates the `users` table with `id`, `name`, and `email` fields.
+-- - Creates the `users` table with `id`, `name`, `email` and `version` fields
+--   (adding `version` to tables from older versions of this script), plus
+--   CHECK constraints against a blank name or an email without an @.
 -- - Seeds the table with a default admin user if the table is empty.
 --
 -- This script is executed automatically by the `mssql-init` service in your